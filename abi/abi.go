@@ -0,0 +1,194 @@
+package abi
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/sha3"
+)
+
+const selectorLen = 4
+
+var ErrInvalidABI = fmt.Errorf("invalid ABI")
+
+// ABI keeps methods and events of a contract by their selectors
+type ABI struct {
+	// methods maps hex encoded 4-byte selector to the method
+	methods map[string]*Method
+	// events maps hex encoded topic0 to the events with that signature, they
+	// may differ in indexed arguments, e.g. Transfer of ERC-20 and ERC-721
+	events map[string][]*Event
+}
+
+type Method struct {
+	Name      string
+	Signature string
+	Inputs    []Argument
+}
+
+type Event struct {
+	Name      string
+	Signature string
+	Inputs    []Argument
+}
+
+type Argument struct {
+	Name    string
+	Type    *Type
+	Indexed bool
+}
+
+type jsonEntry struct {
+	Type      string         `json:"type"`
+	Name      string         `json:"name"`
+	Inputs    []jsonArgument `json:"inputs"`
+	Anonymous bool           `json:"anonymous"`
+}
+
+type jsonArgument struct {
+	Name       string         `json:"name"`
+	Type       string         `json:"type"`
+	Indexed    bool           `json:"indexed"`
+	Components []jsonArgument `json:"components"`
+}
+
+func newABI() *ABI {
+	return &ABI{
+		methods: make(map[string]*Method),
+		events:  make(map[string][]*Event),
+	}
+}
+
+// Parse parses JSON ABI of a contract, entries other than functions and
+// events are skipped as well as anonymous events which could not be matched
+// by topic0
+func Parse(data []byte) (*ABI, error) {
+	var entries []jsonEntry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidABI, err)
+	}
+
+	abi := newABI()
+	for _, entry := range entries {
+		if entry.Type != "function" && entry.Type != "event" || entry.Anonymous {
+			continue
+		}
+		if len(entry.Name) == 0 {
+			return nil, fmt.Errorf("%w: %s without name", ErrInvalidABI, entry.Type)
+		}
+
+		inputs, err := parseArguments(entry.Inputs)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s %s: %w", ErrInvalidABI, entry.Type, entry.Name, err)
+		}
+
+		if entry.Type == "function" {
+			abi.addMethod(newMethod(entry.Name, inputs))
+		} else {
+			abi.addEvent(newEvent(entry.Name, inputs))
+		}
+	}
+
+	return abi, nil
+}
+
+// ParseSignature parses text signature of a method, e.g.
+// "transfer(address,uint256)", its arguments have no names
+func ParseSignature(signature string) (*Method, error) {
+	start := strings.IndexByte(signature, '(')
+	if start <= 0 || !strings.HasSuffix(signature, ")") {
+		return nil, fmt.Errorf("invalid signature '%s'", signature)
+	}
+
+	inputs, err := parseTypeList(signature[start+1 : len(signature)-1])
+	if err != nil {
+		return nil, fmt.Errorf("invalid signature '%s': %w", signature, err)
+	}
+
+	return newMethod(signature[:start], inputs), nil
+}
+
+func parseArguments(jsonArguments []jsonArgument) ([]Argument, error) {
+	arguments := make([]Argument, len(jsonArguments))
+	for i, argument := range jsonArguments {
+		t, err := parseType(argument.Type, argument.Components)
+		if err != nil {
+			return nil, err
+		}
+
+		arguments[i] = Argument{Name: argument.Name, Type: t, Indexed: argument.Indexed}
+	}
+
+	return arguments, nil
+}
+
+func newMethod(name string, inputs []Argument) *Method {
+	return &Method{Name: name, Signature: signature(name, inputs), Inputs: inputs}
+}
+
+func newEvent(name string, inputs []Argument) *Event {
+	return &Event{Name: name, Signature: signature(name, inputs), Inputs: inputs}
+}
+
+func signature(name string, inputs []Argument) string {
+	return name + newTupleType(inputs).canonical
+}
+
+// Selector returns hex encoded 4-byte selector of the method
+func (m *Method) Selector() string {
+	return "0x" + hex.EncodeToString(keccak256(m.Signature)[:selectorLen])
+}
+
+// Topic returns hex encoded topic0 of the event
+func (e *Event) Topic() string {
+	return "0x" + hex.EncodeToString(keccak256(e.Signature))
+}
+
+func (e *Event) indexedCount() int {
+	count := 0
+	for _, input := range e.Inputs {
+		if input.Indexed {
+			count++
+		}
+	}
+
+	return count
+}
+
+func (a *ABI) addMethod(method *Method) {
+	selector := method.Selector()
+	if _, ok := a.methods[selector]; !ok {
+		a.methods[selector] = method
+	}
+}
+
+func (a *ABI) addEvent(event *Event) {
+	topic := event.Topic()
+	for _, added := range a.events[topic] {
+		if added.indexedCount() == event.indexedCount() {
+			return
+		}
+	}
+
+	a.events[topic] = append(a.events[topic], event)
+}
+
+// merge adds methods and events of other ABI which are not defined yet
+func (a *ABI) merge(other *ABI) {
+	for _, method := range other.methods {
+		a.addMethod(method)
+	}
+	for _, events := range other.events {
+		for _, event := range events {
+			a.addEvent(event)
+		}
+	}
+}
+
+func keccak256(s string) []byte {
+	hash := sha3.NewLegacyKeccak256()
+	hash.Write([]byte(s))
+	return hash.Sum(nil)
+}
@@ -0,0 +1,271 @@
+package abi
+
+import (
+	"encoding/hex"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"eth-parser/eth"
+)
+
+const addressLen = 20
+
+var (
+	errShortData     = fmt.Errorf("data is too short")
+	errInvalidOffset = fmt.Errorf("invalid offset")
+
+	// twoTo256 is used to convert two's complement words to negative numbers
+	twoTo256 = new(big.Int).Lsh(big.NewInt(1), 256)
+)
+
+// decodeInput decodes transaction input with the method of its selector, nil
+// is returned if the method is unknown or input does not match it
+func (a *ABI) decodeInput(input []byte, source string) *eth.Decoded {
+	if len(input) < selectorLen {
+		return nil
+	}
+
+	method, ok := a.methods["0x"+hex.EncodeToString(input[:selectorLen])]
+	if !ok {
+		return nil
+	}
+
+	args, err := decodeArguments(method.Inputs, input[selectorLen:])
+	if err != nil {
+		return nil
+	}
+
+	return &eth.Decoded{
+		Name:      method.Name,
+		Signature: method.Signature,
+		Args:      args,
+		Source:    source,
+	}
+}
+
+// decodeLog decodes log with the event of its topic0 which has as many
+// indexed arguments as the log has topics
+func (a *ABI) decodeLog(topics [][]byte, data []byte, source string) *eth.Decoded {
+	if len(topics) == 0 {
+		return nil
+	}
+
+	for _, event := range a.events["0x"+hex.EncodeToString(topics[0])] {
+		if event.indexedCount() != len(topics)-1 {
+			continue
+		}
+
+		args, err := decodeEventArguments(event, topics[1:], data)
+		if err != nil {
+			continue
+		}
+
+		return &eth.Decoded{
+			Name:      event.Name,
+			Signature: event.Signature,
+			Args:      args,
+			Source:    source,
+		}
+	}
+
+	return nil
+}
+
+func decodeArguments(arguments []Argument, data []byte) ([]eth.DecodedArg, error) {
+	values, err := decodeTuple(arguments, data)
+	if err != nil {
+		return nil, err
+	}
+
+	args := make([]eth.DecodedArg, len(arguments))
+	for i, argument := range arguments {
+		args[i] = eth.DecodedArg{Name: argument.Name, Type: argument.Type.canonical, Value: values[i]}
+	}
+
+	return args, nil
+}
+
+// decodeEventArguments decodes indexed arguments from topics and the others
+// from data
+func decodeEventArguments(event *Event, topics [][]byte, data []byte) ([]eth.DecodedArg, error) {
+	var nonIndexed []Argument
+	for _, input := range event.Inputs {
+		if !input.Indexed {
+			nonIndexed = append(nonIndexed, input)
+		}
+	}
+
+	values, err := decodeTuple(nonIndexed, data)
+	if err != nil {
+		return nil, err
+	}
+
+	args := make([]eth.DecodedArg, 0, len(event.Inputs))
+	for _, input := range event.Inputs {
+		arg := eth.DecodedArg{Name: input.Name, Type: input.Type.canonical}
+
+		if !input.Indexed {
+			arg.Value, values = values[0], values[1:]
+			args = append(args, arg)
+			continue
+		}
+
+		topic := topics[0]
+		topics = topics[1:]
+		if input.Type.dynamic() || input.Type.kind == kindArray || input.Type.kind == kindTuple {
+			// values of such types are replaced with their hashes
+			arg.Value = "0x" + hex.EncodeToString(topic)
+		} else if arg.Value, err = decodeValue(input.Type, topic); err != nil {
+			return nil, err
+		}
+		args = append(args, arg)
+	}
+
+	return args, nil
+}
+
+// decodeTuple decodes values of arguments encoded one after another with
+// dynamic values referenced by offsets from the start of data
+func decodeTuple(arguments []Argument, data []byte) ([]interface{}, error) {
+	values := make([]interface{}, len(arguments))
+
+	head := 0
+	for i, argument := range arguments {
+		t := argument.Type
+		if head+t.headSize() > len(data) {
+			return nil, errShortData
+		}
+
+		valueData := data[head:]
+		if t.dynamic() {
+			offset, err := readLength(data, head)
+			if err != nil {
+				return nil, errInvalidOffset
+			}
+			valueData = data[offset:]
+		}
+
+		value, err := decodeValue(t, valueData)
+		if err != nil {
+			return nil, err
+		}
+		values[i] = value
+
+		head += t.headSize()
+	}
+
+	return values, nil
+}
+
+// decodeValue decodes value of the type which starts at the beginning of data
+func decodeValue(t *Type, data []byte) (interface{}, error) {
+	switch t.kind {
+	case kindSlice, kindBytes, kindString:
+		length, err := readLength(data, 0)
+		if err != nil {
+			return nil, err
+		}
+		data = data[wordSize:]
+
+		if t.kind == kindSlice {
+			// every element takes at least a word, so length is limited by data
+			if length > len(data)/wordSize {
+				return nil, errShortData
+			}
+			return decodeList(t.elem, length, data)
+		}
+
+		if length > len(data) {
+			return nil, errShortData
+		}
+		if t.kind == kindString {
+			return string(data[:length]), nil
+		}
+		return "0x" + hex.EncodeToString(data[:length]), nil
+	case kindArray:
+		return decodeList(t.elem, t.size, data)
+	case kindTuple:
+		return decodeTupleValue(t, data)
+	}
+
+	if len(data) < wordSize {
+		return nil, errShortData
+	}
+	word := data[:wordSize]
+
+	switch t.kind {
+	case kindUint:
+		value := new(big.Int).SetBytes(word)
+		if value.BitLen() > t.size {
+			return nil, fmt.Errorf("value overflows %s", t.canonical)
+		}
+		return value.String(), nil
+	case kindInt:
+		value := new(big.Int).SetBytes(word)
+		if word[0]&0x80 != 0 {
+			value.Sub(value, twoTo256)
+		}
+		return value.String(), nil
+	case kindAddress:
+		return "0x" + hex.EncodeToString(word[wordSize-addressLen:]), nil
+	case kindBool:
+		return word[wordSize-1] != 0, nil
+	case kindFixedBytes:
+		return "0x" + hex.EncodeToString(word[:t.size]), nil
+	}
+
+	return nil, fmt.Errorf("unsupported type %s", t.canonical)
+}
+
+func decodeList(elem *Type, length int, data []byte) ([]interface{}, error) {
+	arguments := make([]Argument, length)
+	for i := range arguments {
+		arguments[i] = Argument{Type: elem}
+	}
+
+	return decodeTuple(arguments, data)
+}
+
+// decodeTupleValue decodes tuple into object if all of its components are
+// named or into list otherwise
+func decodeTupleValue(t *Type, data []byte) (interface{}, error) {
+	values, err := decodeTuple(t.components, data)
+	if err != nil {
+		return nil, err
+	}
+
+	object := make(map[string]interface{}, len(values))
+	for i, component := range t.components {
+		if len(component.Name) == 0 {
+			return values, nil
+		}
+		object[component.Name] = values[i]
+	}
+
+	return object, nil
+}
+
+// readLength reads word at pos of data as offset or length which must not
+// exceed data
+func readLength(data []byte, pos int) (int, error) {
+	if pos+wordSize > len(data) {
+		return 0, errShortData
+	}
+
+	value := new(big.Int).SetBytes(data[pos : pos+wordSize])
+	if !value.IsInt64() || value.Int64() > int64(len(data)) {
+		return 0, errInvalidOffset
+	}
+
+	return int(value.Int64()), nil
+}
+
+// decodeHex decodes 0x prefixed hex string
+func decodeHex(s string) ([]byte, error) {
+	if !strings.HasPrefix(s, "0x") {
+		return nil, fmt.Errorf("'%s' is not 0x prefixed", s)
+	}
+
+	return hex.DecodeString(s[2:])
+}
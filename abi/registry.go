@@ -0,0 +1,273 @@
+package abi
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"eth-parser/eth"
+)
+
+const abiFileExt = ".json"
+
+type Config struct {
+	// Dir keeps uploaded ABIs as <dir>/<chain_id>/<address>.json, they are
+	// loaded on init. Uploaded ABIs are kept in memory only if empty.
+	Dir string `yaml:"dir"`
+}
+
+// Registry decodes transactions and logs of a single chain with ABI uploaded
+// for the contract, with ABIs of common standards and finally with signatures
+// known by selector, which include methods and events of all uploaded ABIs
+type Registry struct {
+	config  *Config
+	chainID uint64
+
+	// contracts maps address of contract to its ABI
+	contracts  map[string]*contractABI
+	signatures *ABI
+	mu         sync.RWMutex
+
+	standards *ABI
+
+	logger *slog.Logger
+}
+
+type contractABI struct {
+	abi *ABI
+	// raw is uploaded JSON of the ABI
+	raw []byte
+}
+
+func NewRegistry(config *Config, chainID uint64, logger *slog.Logger) *Registry {
+	standards := newABI()
+	for _, data := range standardABIs {
+		standards.merge(mustParse(data))
+	}
+
+	signatures := newABI()
+	for _, signature := range knownSignatures {
+		method, err := ParseSignature(signature)
+		if err != nil {
+			panic(err)
+		}
+		signatures.addMethod(method)
+	}
+
+	return &Registry{
+		config:     config,
+		chainID:    chainID,
+		contracts:  make(map[string]*contractABI),
+		signatures: signatures,
+		standards:  standards,
+		logger:     logger.With("component", "abi_registry"),
+	}
+}
+
+func mustParse(data string) *ABI {
+	abi, err := Parse([]byte(data))
+	if err != nil {
+		panic(err)
+	}
+
+	return abi
+}
+
+// Init loads ABIs stored in the directory of the chain
+func (r *Registry) Init() error {
+	if len(r.config.Dir) == 0 {
+		return nil
+	}
+
+	entries, err := os.ReadDir(r.chainDir())
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("could not read ABI dir: %w", err)
+	}
+
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != abiFileExt {
+			continue
+		}
+
+		data, err := os.ReadFile(filepath.Join(r.chainDir(), entry.Name()))
+		if err != nil {
+			return fmt.Errorf("could not read ABI file: %w", err)
+		}
+
+		address, err := eth.NormalizeAddress(strings.TrimSuffix(entry.Name(), abiFileExt))
+		if err != nil {
+			r.logger.Warn("skipping ABI file", "file", entry.Name(), "error", err)
+			continue
+		}
+		if err := r.store(address, data); err != nil {
+			return fmt.Errorf("could not load ABI of %s: %w", address, err)
+		}
+	}
+
+	r.logger.Info("loaded ABIs", "contracts", len(r.contracts))
+	return nil
+}
+
+func (r *Registry) Shutdown() error {
+	return nil
+}
+
+// Store stores JSON ABI of the contract replacing the previous one
+func (r *Registry) Store(address string, data []byte) error {
+	if r == nil {
+		return fmt.Errorf("ABI registry is uninitialized")
+	}
+
+	address, err := eth.NormalizeAddress(address)
+	if err != nil {
+		return err
+	}
+
+	if err := r.store(address, data); err != nil {
+		return err
+	}
+
+	if len(r.config.Dir) != 0 {
+		if err := r.persist(address); err != nil {
+			return err
+		}
+	}
+
+	r.logger.Info("stored ABI", "address", address)
+	return nil
+}
+
+func (r *Registry) store(address string, data []byte) error {
+	abi, err := Parse(data)
+	if err != nil {
+		return err
+	}
+
+	raw := &bytes.Buffer{}
+	if err := json.Compact(raw, data); err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidABI, err)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.contracts[address] = &contractABI{abi: abi, raw: raw.Bytes()}
+	r.signatures.merge(abi)
+	return nil
+}
+
+// persist writes ABI of the contract to its file atomically
+func (r *Registry) persist(address string) error {
+	r.mu.RLock()
+	raw := r.contracts[address].raw
+	r.mu.RUnlock()
+
+	if err := os.MkdirAll(r.chainDir(), 0o755); err != nil {
+		return fmt.Errorf("could not create ABI dir: %w", err)
+	}
+
+	path := filepath.Join(r.chainDir(), address+abiFileExt)
+	if err := os.WriteFile(path+".tmp", raw, 0o644); err != nil {
+		return fmt.Errorf("could not write ABI file: %w", err)
+	}
+	if err := os.Rename(path+".tmp", path); err != nil {
+		return fmt.Errorf("could not write ABI file: %w", err)
+	}
+
+	return nil
+}
+
+func (r *Registry) chainDir() string {
+	return filepath.Join(r.config.Dir, strconv.FormatUint(r.chainID, 10))
+}
+
+// Get returns JSON ABI stored for the contract
+func (r *Registry) Get(address string) ([]byte, bool) {
+	if r == nil {
+		return nil, false
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	contract, ok := r.contracts[strings.ToLower(address)]
+	if !ok {
+		return nil, false
+	}
+
+	return contract.raw, true
+}
+
+// DecodeInput decodes input of transaction to the contract, nil is returned
+// if the method is unknown
+func (r *Registry) DecodeInput(to, input string) *eth.Decoded {
+	if r == nil {
+		return nil
+	}
+
+	data, err := decodeHex(input)
+	if err != nil || len(data) < selectorLen {
+		return nil
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if contract, ok := r.contracts[strings.ToLower(to)]; ok {
+		decoded := contract.abi.decodeInput(data, eth.DecodedSourceContract)
+		if decoded != nil {
+			return decoded
+		}
+	}
+	if decoded := r.standards.decodeInput(data, eth.DecodedSourceStandard); decoded != nil {
+		return decoded
+	}
+
+	return r.signatures.decodeInput(data, eth.DecodedSourceSelector)
+}
+
+// DecodeLog decodes event of the log, nil is returned if the event is unknown
+func (r *Registry) DecodeLog(log *eth.Log) *eth.Decoded {
+	if r == nil {
+		return nil
+	}
+
+	topics := make([][]byte, len(log.Topics))
+	for i, topic := range log.Topics {
+		decoded, err := decodeHex(topic)
+		if err != nil || len(decoded) != wordSize {
+			return nil
+		}
+		topics[i] = decoded
+	}
+
+	data, err := decodeHex(log.Data)
+	if err != nil {
+		return nil
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if contract, ok := r.contracts[strings.ToLower(log.Address)]; ok {
+		decoded := contract.abi.decodeLog(topics, data, eth.DecodedSourceContract)
+		if decoded != nil {
+			return decoded
+		}
+	}
+	if decoded := r.standards.decodeLog(topics, data, eth.DecodedSourceStandard); decoded != nil {
+		return decoded
+	}
+
+	return r.signatures.decodeLog(topics, data, eth.DecodedSourceSelector)
+}
@@ -0,0 +1,115 @@
+package abi
+
+// standardABIs are ABIs of common standards used to decode calls of contracts
+// without uploaded ABI. Methods with the same selector in several standards,
+// e.g. transferFrom of ERC-20 and ERC-721, are decoded as the first one.
+var standardABIs = []string{erc20ABI, erc721ABI, erc1155ABI, wethABI}
+
+// knownSignatures are text signatures of widely used methods which are not
+// part of the standards, they are the fallback by 4-byte selector
+var knownSignatures = []string{
+	"multicall(bytes[])",
+	"multicall(uint256,bytes[])",
+	"execute(bytes,bytes[],uint256)",
+	"execute(bytes,bytes[])",
+	"swapExactETHForTokens(uint256,address[],address,uint256)",
+	"swapExactTokensForETH(uint256,uint256,address[],address,uint256)",
+	"swapExactTokensForTokens(uint256,uint256,address[],address,uint256)",
+	"swapETHForExactTokens(uint256,address[],address,uint256)",
+	"swapTokensForExactTokens(uint256,uint256,address[],address,uint256)",
+	"addLiquidity(address,address,uint256,uint256,uint256,uint256,address,uint256)",
+	"addLiquidityETH(address,uint256,uint256,uint256,address,uint256)",
+	"removeLiquidity(address,address,uint256,uint256,uint256,address,uint256)",
+	"removeLiquidityETH(address,uint256,uint256,uint256,address,uint256)",
+	"exactInputSingle((address,address,uint24,address,uint256,uint256,uint256,uint160))",
+	"exactInput((bytes,address,uint256,uint256,uint256))",
+	"permit(address,address,uint256,uint256,uint8,bytes32,bytes32)",
+	"increaseAllowance(address,uint256)",
+	"decreaseAllowance(address,uint256)",
+	"mint(address,uint256)",
+	"burn(uint256)",
+	"claim()",
+	"stake(uint256)",
+	"withdraw()",
+}
+
+const erc20ABI = `[
+  {"type": "function", "name": "transfer", "inputs": [
+    {"name": "to", "type": "address"}, {"name": "value", "type": "uint256"}]},
+  {"type": "function", "name": "transferFrom", "inputs": [
+    {"name": "from", "type": "address"}, {"name": "to", "type": "address"},
+    {"name": "value", "type": "uint256"}]},
+  {"type": "function", "name": "approve", "inputs": [
+    {"name": "spender", "type": "address"}, {"name": "value", "type": "uint256"}]},
+  {"type": "function", "name": "balanceOf", "inputs": [
+    {"name": "owner", "type": "address"}]},
+  {"type": "function", "name": "allowance", "inputs": [
+    {"name": "owner", "type": "address"}, {"name": "spender", "type": "address"}]},
+  {"type": "function", "name": "totalSupply", "inputs": []},
+  {"type": "event", "name": "Transfer", "inputs": [
+    {"name": "from", "type": "address", "indexed": true},
+    {"name": "to", "type": "address", "indexed": true},
+    {"name": "value", "type": "uint256"}]},
+  {"type": "event", "name": "Approval", "inputs": [
+    {"name": "owner", "type": "address", "indexed": true},
+    {"name": "spender", "type": "address", "indexed": true},
+    {"name": "value", "type": "uint256"}]}
+]`
+
+const erc721ABI = `[
+  {"type": "function", "name": "safeTransferFrom", "inputs": [
+    {"name": "from", "type": "address"}, {"name": "to", "type": "address"},
+    {"name": "tokenId", "type": "uint256"}]},
+  {"type": "function", "name": "safeTransferFrom", "inputs": [
+    {"name": "from", "type": "address"}, {"name": "to", "type": "address"},
+    {"name": "tokenId", "type": "uint256"}, {"name": "data", "type": "bytes"}]},
+  {"type": "function", "name": "setApprovalForAll", "inputs": [
+    {"name": "operator", "type": "address"}, {"name": "approved", "type": "bool"}]},
+  {"type": "function", "name": "ownerOf", "inputs": [
+    {"name": "tokenId", "type": "uint256"}]},
+  {"type": "event", "name": "Transfer", "inputs": [
+    {"name": "from", "type": "address", "indexed": true},
+    {"name": "to", "type": "address", "indexed": true},
+    {"name": "tokenId", "type": "uint256", "indexed": true}]},
+  {"type": "event", "name": "Approval", "inputs": [
+    {"name": "owner", "type": "address", "indexed": true},
+    {"name": "approved", "type": "address", "indexed": true},
+    {"name": "tokenId", "type": "uint256", "indexed": true}]},
+  {"type": "event", "name": "ApprovalForAll", "inputs": [
+    {"name": "owner", "type": "address", "indexed": true},
+    {"name": "operator", "type": "address", "indexed": true},
+    {"name": "approved", "type": "bool"}]}
+]`
+
+const erc1155ABI = `[
+  {"type": "function", "name": "safeTransferFrom", "inputs": [
+    {"name": "from", "type": "address"}, {"name": "to", "type": "address"},
+    {"name": "id", "type": "uint256"}, {"name": "value", "type": "uint256"},
+    {"name": "data", "type": "bytes"}]},
+  {"type": "function", "name": "safeBatchTransferFrom", "inputs": [
+    {"name": "from", "type": "address"}, {"name": "to", "type": "address"},
+    {"name": "ids", "type": "uint256[]"}, {"name": "values", "type": "uint256[]"},
+    {"name": "data", "type": "bytes"}]},
+  {"type": "event", "name": "TransferSingle", "inputs": [
+    {"name": "operator", "type": "address", "indexed": true},
+    {"name": "from", "type": "address", "indexed": true},
+    {"name": "to", "type": "address", "indexed": true},
+    {"name": "id", "type": "uint256"}, {"name": "value", "type": "uint256"}]},
+  {"type": "event", "name": "TransferBatch", "inputs": [
+    {"name": "operator", "type": "address", "indexed": true},
+    {"name": "from", "type": "address", "indexed": true},
+    {"name": "to", "type": "address", "indexed": true},
+    {"name": "ids", "type": "uint256[]"}, {"name": "values", "type": "uint256[]"}]}
+]`
+
+const wethABI = `[
+  {"type": "function", "name": "deposit", "inputs": []},
+  {"type": "function", "name": "withdraw", "inputs": [
+    {"name": "wad", "type": "uint256"}]},
+  {"type": "event", "name": "Deposit", "inputs": [
+    {"name": "dst", "type": "address", "indexed": true},
+    {"name": "wad", "type": "uint256"}]},
+  {"type": "event", "name": "Withdrawal", "inputs": [
+    {"name": "src", "type": "address", "indexed": true},
+    {"name": "wad", "type": "uint256"}]}
+]`
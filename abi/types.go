@@ -0,0 +1,231 @@
+package abi
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+const wordSize = 32
+
+type kind int
+
+const (
+	kindUint kind = iota
+	kindInt
+	kindAddress
+	kindBool
+	kindFixedBytes
+	kindBytes
+	kindString
+	kindSlice
+	kindArray
+	kindTuple
+)
+
+// Type is ABI type of argument
+type Type struct {
+	kind kind
+	// size is the number of bits of integers, the number of bytes of fixed
+	// bytes and the length of arrays
+	size int
+	elem *Type
+
+	components []Argument
+
+	// canonical is the type as it appears in signatures
+	canonical string
+}
+
+func (t *Type) String() string {
+	return t.canonical
+}
+
+// parseType parses type of JSON ABI argument, components are the fields of
+// tuples and arrays of tuples
+func parseType(s string, components []jsonArgument) (*Type, error) {
+	if strings.HasSuffix(s, "]") {
+		start := strings.LastIndexByte(s, '[')
+		if start < 0 {
+			return nil, fmt.Errorf("invalid type '%s'", s)
+		}
+
+		elem, err := parseType(s[:start], components)
+		if err != nil {
+			return nil, err
+		}
+
+		if start == len(s)-2 {
+			return &Type{kind: kindSlice, elem: elem, canonical: elem.canonical + "[]"}, nil
+		}
+
+		length, err := strconv.Atoi(s[start+1 : len(s)-1])
+		if err != nil || length <= 0 {
+			return nil, fmt.Errorf("invalid array length of type '%s'", s)
+		}
+		return &Type{
+			kind:      kindArray,
+			size:      length,
+			elem:      elem,
+			canonical: fmt.Sprintf("%s[%d]", elem.canonical, length),
+		}, nil
+	}
+
+	if s == "tuple" {
+		arguments, err := parseArguments(components)
+		if err != nil {
+			return nil, err
+		}
+		return newTupleType(arguments), nil
+	}
+	if strings.HasPrefix(s, "(") && strings.HasSuffix(s, ")") {
+		arguments, err := parseTypeList(s[1 : len(s)-1])
+		if err != nil {
+			return nil, err
+		}
+		return newTupleType(arguments), nil
+	}
+
+	return parseElementaryType(s)
+}
+
+func newTupleType(components []Argument) *Type {
+	types := make([]string, len(components))
+	for i, component := range components {
+		types[i] = component.Type.canonical
+	}
+
+	return &Type{
+		kind:       kindTuple,
+		components: components,
+		canonical:  "(" + strings.Join(types, ",") + ")",
+	}
+}
+
+func parseElementaryType(s string) (*Type, error) {
+	switch s {
+	case "address":
+		return &Type{kind: kindAddress, canonical: s}, nil
+	case "bool":
+		return &Type{kind: kindBool, canonical: s}, nil
+	case "string":
+		return &Type{kind: kindString, canonical: s}, nil
+	case "bytes":
+		return &Type{kind: kindBytes, canonical: s}, nil
+	case "function":
+		// address followed by selector
+		return &Type{kind: kindFixedBytes, size: 24, canonical: s}, nil
+	case "uint":
+		return &Type{kind: kindUint, size: 256, canonical: "uint256"}, nil
+	case "int":
+		return &Type{kind: kindInt, size: 256, canonical: "int256"}, nil
+	}
+
+	for _, sized := range []struct {
+		prefix string
+		kind   kind
+	}{
+		{prefix: "uint", kind: kindUint},
+		{prefix: "int", kind: kindInt},
+		{prefix: "bytes", kind: kindFixedBytes},
+	} {
+		prefix, k := sized.prefix, sized.kind
+		if !strings.HasPrefix(s, prefix) {
+			continue
+		}
+
+		size, err := strconv.Atoi(s[len(prefix):])
+		if err != nil {
+			return nil, fmt.Errorf("unsupported type '%s'", s)
+		}
+		if k == kindFixedBytes && (size < 1 || size > wordSize) {
+			return nil, fmt.Errorf("invalid size of type '%s'", s)
+		}
+		if k != kindFixedBytes && (size < 8 || size > 256 || size%8 != 0) {
+			return nil, fmt.Errorf("invalid size of type '%s'", s)
+		}
+
+		return &Type{kind: k, size: size, canonical: s}, nil
+	}
+
+	return nil, fmt.Errorf("unsupported type '%s'", s)
+}
+
+// parseTypeList parses comma separated types of text signature, e.g.
+// "address,(uint256,bytes)[]"
+func parseTypeList(s string) ([]Argument, error) {
+	if len(s) == 0 {
+		return nil, nil
+	}
+
+	var arguments []Argument
+	depth, start := 0, 0
+	for i := 0; i <= len(s); i++ {
+		if i < len(s) {
+			switch s[i] {
+			case '(':
+				depth++
+				continue
+			case ')':
+				depth--
+				continue
+			case ',':
+				if depth != 0 {
+					continue
+				}
+			default:
+				continue
+			}
+		}
+
+		t, err := parseType(s[start:i], nil)
+		if err != nil {
+			return nil, err
+		}
+		arguments = append(arguments, Argument{Type: t})
+		start = i + 1
+	}
+	if depth != 0 {
+		return nil, fmt.Errorf("unbalanced parentheses in '%s'", s)
+	}
+
+	return arguments, nil
+}
+
+// dynamic tells whether value of the type is encoded in the tail
+func (t *Type) dynamic() bool {
+	switch t.kind {
+	case kindBytes, kindString, kindSlice:
+		return true
+	case kindArray:
+		return t.elem.dynamic()
+	case kindTuple:
+		for _, component := range t.components {
+			if component.Type.dynamic() {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// headSize returns the size of the type in the head of encoding
+func (t *Type) headSize() int {
+	if t.dynamic() {
+		return wordSize
+	}
+
+	switch t.kind {
+	case kindArray:
+		return t.size * t.elem.headSize()
+	case kindTuple:
+		size := 0
+		for _, component := range t.components {
+			size += component.Type.headSize()
+		}
+		return size
+	default:
+		return wordSize
+	}
+}
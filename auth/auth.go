@@ -0,0 +1,121 @@
+package auth
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	APIKeyHeader = "X-API-Key"
+
+	// JWTTenantPrefix prefixes IDs of tenants authenticated with JWT, so that
+	// they do not collide with tenants of API keys, which must not have it
+	JWTTenantPrefix = "jwt-"
+
+	bearerPrefix = "Bearer "
+)
+
+var (
+	ErrNoCredentials      = fmt.Errorf("no credentials")
+	ErrInvalidCredentials = fmt.Errorf("invalid credentials")
+)
+
+// Tenant is the owner of subscriptions and transaction views
+type Tenant struct {
+	ID string
+
+	// MaxSubscriptions limits number of addresses subscribed by the tenant
+	// per chain, 0 means no limit
+	MaxSubscriptions int
+}
+
+type Authenticator struct {
+	// apiKeys maps SHA-256 of API key to its tenant, hashes are used to
+	// avoid leaking keys through lookup timings
+	apiKeys map[[sha256.Size]byte]Tenant
+
+	jwtConfig *JWTConfig
+	jwtParser *jwt.Parser
+}
+
+func NewAuthenticator(config *Config) *Authenticator {
+	apiKeys := make(map[[sha256.Size]byte]Tenant, len(config.APIKeys))
+	for _, key := range config.APIKeys {
+		apiKeys[sha256.Sum256([]byte(key.Key))] = Tenant{
+			ID:               key.Tenant,
+			MaxSubscriptions: key.MaxSubscriptions,
+		}
+	}
+
+	opts := []jwt.ParserOption{
+		jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}),
+		jwt.WithExpirationRequired(),
+	}
+	if len(config.JWT.Issuer) != 0 {
+		opts = append(opts, jwt.WithIssuer(config.JWT.Issuer))
+	}
+	if len(config.JWT.Audience) != 0 {
+		opts = append(opts, jwt.WithAudience(config.JWT.Audience))
+	}
+
+	return &Authenticator{
+		apiKeys:   apiKeys,
+		jwtConfig: &config.JWT,
+		jwtParser: jwt.NewParser(opts...),
+	}
+}
+
+// Enabled returns true if any authentication method is configured
+func (a *Authenticator) Enabled() bool {
+	return a != nil && (len(a.apiKeys) != 0 || len(a.jwtConfig.Secret) != 0)
+}
+
+// Authenticate returns tenant of the request authenticated with API key
+// header or JWT bearer token
+func (a *Authenticator) Authenticate(r *http.Request) (*Tenant, error) {
+	return a.AuthenticateCredentials(r.Header.Get(APIKeyHeader), r.Header.Get("Authorization"))
+}
+
+// AuthenticateCredentials returns tenant authenticated with API key or
+// authorization value with JWT bearer token
+func (a *Authenticator) AuthenticateCredentials(key, authorization string) (*Tenant, error) {
+	if len(key) != 0 {
+		tenant, ok := a.apiKeys[sha256.Sum256([]byte(key))]
+		if !ok {
+			return nil, fmt.Errorf("%w: unknown API key", ErrInvalidCredentials)
+		}
+
+		return &tenant, nil
+	}
+
+	if strings.HasPrefix(authorization, bearerPrefix) && len(a.jwtConfig.Secret) != 0 {
+		return a.authenticateJWT(strings.TrimPrefix(authorization, bearerPrefix))
+	}
+
+	return nil, ErrNoCredentials
+}
+
+func (a *Authenticator) authenticateJWT(rawToken string) (*Tenant, error) {
+	claims := jwt.MapClaims{}
+	_, err := a.jwtParser.ParseWithClaims(rawToken, claims, func(*jwt.Token) (interface{}, error) {
+		return []byte(a.jwtConfig.Secret), nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidCredentials, err)
+	}
+
+	tenantID, ok := claims[a.jwtConfig.TenantClaim].(string)
+	if !ok || len(tenantID) == 0 {
+		return nil, fmt.Errorf("%w: no '%s' claim in token",
+			ErrInvalidCredentials, a.jwtConfig.TenantClaim)
+	}
+
+	return &Tenant{
+		ID:               JWTTenantPrefix + tenantID,
+		MaxSubscriptions: a.jwtConfig.MaxSubscriptions,
+	}, nil
+}
@@ -0,0 +1,198 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const testSecret = "jwt-secret"
+
+func newTestAuthenticator() *Authenticator {
+	return NewAuthenticator(&Config{
+		APIKeys: []APIKeyConfig{
+			{Key: "key-a", Tenant: "team-a", MaxSubscriptions: 10},
+			{Key: "key-b", Tenant: "team-b"},
+		},
+		JWT: JWTConfig{
+			Secret:           testSecret,
+			Issuer:           "issuer",
+			Audience:         "parser",
+			TenantClaim:      "sub",
+			MaxSubscriptions: 100,
+		},
+	})
+}
+
+// signToken returns token with valid claims overridden with claims, claims
+// with nil values are removed
+func signToken(t *testing.T, method jwt.SigningMethod, key interface{}, claims jwt.MapClaims) string {
+	t.Helper()
+
+	tokenClaims := jwt.MapClaims{
+		"sub": "team-a",
+		"iss": "issuer",
+		"aud": "parser",
+		"exp": time.Now().Add(time.Hour).Unix(),
+	}
+	for name, value := range claims {
+		if value == nil {
+			delete(tokenClaims, name)
+			continue
+		}
+		tokenClaims[name] = value
+	}
+
+	token, err := jwt.NewWithClaims(method, tokenClaims).SignedString(key)
+	if err != nil {
+		t.Fatalf("could not sign token: %v", err)
+	}
+	return token
+}
+
+func TestAuthenticateCredentials(t *testing.T) {
+	a := newTestAuthenticator()
+
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("could not generate key: %v", err)
+	}
+	hs256 := jwt.SigningMethodHS256
+
+	testCases := []struct {
+		name          string
+		key           string
+		authorization string
+		expected      *Tenant
+		expectedErr   error
+	}{
+		{
+			name:     "api key",
+			key:      "key-a",
+			expected: &Tenant{ID: "team-a", MaxSubscriptions: 10},
+		},
+		{
+			name:     "api key without limit",
+			key:      "key-b",
+			expected: &Tenant{ID: "team-b"},
+		},
+		{
+			name:        "unknown api key",
+			key:         "key-c",
+			expectedErr: ErrInvalidCredentials,
+		},
+		{
+			// API key takes precedence over token
+			name:          "api key with token",
+			key:           "key-b",
+			authorization: "Bearer " + signToken(t, hs256, []byte(testSecret), nil),
+			expected:      &Tenant{ID: "team-b"},
+		},
+		{
+			// tenant of the same name authenticated with token is another one
+			// with quota of tokens
+			name:          "token",
+			authorization: "Bearer " + signToken(t, hs256, []byte(testSecret), nil),
+			expected:      &Tenant{ID: "jwt-team-a", MaxSubscriptions: 100},
+		},
+		{
+			name:          "bad signature",
+			authorization: "Bearer " + signToken(t, hs256, []byte("other-secret"), nil),
+			expectedErr:   ErrInvalidCredentials,
+		},
+		{
+			name: "expired token",
+			authorization: "Bearer " + signToken(t, hs256, []byte(testSecret),
+				jwt.MapClaims{"exp": time.Now().Add(-time.Minute).Unix()}),
+			expectedErr: ErrInvalidCredentials,
+		},
+		{
+			name: "token without expiration",
+			authorization: "Bearer " + signToken(t, hs256, []byte(testSecret),
+				jwt.MapClaims{"exp": nil}),
+			expectedErr: ErrInvalidCredentials,
+		},
+		{
+			name: "unsigned token",
+			authorization: "Bearer " + signToken(t, jwt.SigningMethodNone,
+				jwt.UnsafeAllowNoneSignatureType, nil),
+			expectedErr: ErrInvalidCredentials,
+		},
+		{
+			// secret is not accepted as key of other HMAC algorithms
+			name: "other hmac algorithm",
+			authorization: "Bearer " + signToken(t, jwt.SigningMethodHS384,
+				[]byte(testSecret), nil),
+			expectedErr: ErrInvalidCredentials,
+		},
+		{
+			name:          "rsa token",
+			authorization: "Bearer " + signToken(t, jwt.SigningMethodRS256, rsaKey, nil),
+			expectedErr:   ErrInvalidCredentials,
+		},
+		{
+			name: "other issuer",
+			authorization: "Bearer " + signToken(t, hs256, []byte(testSecret),
+				jwt.MapClaims{"iss": "other"}),
+			expectedErr: ErrInvalidCredentials,
+		},
+		{
+			name: "other audience",
+			authorization: "Bearer " + signToken(t, hs256, []byte(testSecret),
+				jwt.MapClaims{"aud": "other"}),
+			expectedErr: ErrInvalidCredentials,
+		},
+		{
+			name: "no tenant claim",
+			authorization: "Bearer " + signToken(t, hs256, []byte(testSecret),
+				jwt.MapClaims{"sub": nil}),
+			expectedErr: ErrInvalidCredentials,
+		},
+		{
+			name:          "not bearer",
+			authorization: "Basic " + signToken(t, hs256, []byte(testSecret), nil),
+			expectedErr:   ErrNoCredentials,
+		},
+		{
+			name:        "no credentials",
+			expectedErr: ErrNoCredentials,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			tenant, err := a.AuthenticateCredentials(tc.key, tc.authorization)
+			if tc.expectedErr != nil {
+				if !errors.Is(err, tc.expectedErr) {
+					t.Errorf("got error %v, want %v", err, tc.expectedErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("could not authenticate: %v", err)
+			}
+
+			if !reflect.DeepEqual(tenant, tc.expected) {
+				t.Errorf("tenants are not equal:\nhave: %+v\nwant: %+v", tenant, tc.expected)
+			}
+		})
+	}
+}
+
+func TestAuthenticateWithoutJWT(t *testing.T) {
+	a := NewAuthenticator(&Config{})
+	if a.Enabled() {
+		t.Errorf("authentication is enabled without methods")
+	}
+
+	// tokens are not verified without secret
+	token := signToken(t, jwt.SigningMethodHS256, []byte(""), nil)
+	if _, err := a.AuthenticateCredentials("", "Bearer "+token); !errors.Is(err, ErrNoCredentials) {
+		t.Errorf("got error %v, want %v", err, ErrNoCredentials)
+	}
+}
@@ -0,0 +1,44 @@
+package auth
+
+const redactedSecret = "<redacted>"
+
+type Config struct {
+	APIKeys []APIKeyConfig `yaml:"api_keys,omitempty"`
+	JWT     JWTConfig      `yaml:"jwt"`
+}
+
+type APIKeyConfig struct {
+	Key    Secret `yaml:"key"`
+	Tenant string `yaml:"tenant"`
+
+	// MaxSubscriptions limits number of addresses subscribed by the tenant
+	// per chain, 0 means no limit
+	MaxSubscriptions int `yaml:"max_subscriptions"`
+}
+
+type JWTConfig struct {
+	// Secret is the HMAC key to verify HS256 signed tokens, JWT
+	// authentication is disabled if empty
+	Secret Secret `yaml:"secret"`
+
+	Issuer   string `yaml:"issuer"`
+	Audience string `yaml:"audience"`
+
+	// TenantClaim is the name of the claim holding tenant ID
+	TenantClaim string `yaml:"tenant_claim"`
+
+	// MaxSubscriptions limits number of addresses subscribed by every
+	// tenant authenticated with JWT per chain, 0 means no limit
+	MaxSubscriptions int `yaml:"max_subscriptions"`
+}
+
+// Secret is a string which is not revealed when configuration is printed
+type Secret string
+
+func (s Secret) MarshalYAML() (interface{}, error) {
+	if len(s) == 0 {
+		return "", nil
+	}
+
+	return redactedSecret, nil
+}
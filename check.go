@@ -0,0 +1,49 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"eth-parser/poller"
+)
+
+// runCheck checks capabilities of the configured endpoint and prints them,
+// fails if any capability required by the parser is missing
+func runCheck(args []string) error {
+	fs, cfg, configPath := newConfigFlagSet("check-endpoint")
+	wsEndpoint := fs.String("ws-endpoint",
+		"", "websocket endpoint, the endpoint with websocket scheme if empty")
+	fs.Parse(args)
+
+	logger, err := loadConfig(fs, cfg, *configPath)
+	if err != nil {
+		return err
+	}
+
+	chainLogger := logger.With("chain_id", cfg.Poller.ChainID)
+	checks := poller.NewEthPoller(&cfg.Poller, chainLogger).Check(*wsEndpoint)
+
+	writer := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	failed := 0
+	for _, check := range checks {
+		status, detail := "ok", check.Detail
+		switch {
+		case check.Err != nil && check.Required:
+			status, detail = "FAILED", check.Err.Error()
+			failed++
+		case check.Err != nil:
+			status, detail = "unsupported", check.Err.Error()
+		}
+
+		fmt.Fprintf(writer, "%s\t%s\t%s\n", check.Name, status, detail)
+	}
+	if err := writer.Flush(); err != nil {
+		return fmt.Errorf("could not write checks: %w", err)
+	}
+
+	if failed != 0 {
+		return fmt.Errorf("%d required checks failed", failed)
+	}
+	return nil
+}
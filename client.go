@@ -0,0 +1,348 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"eth-parser/auth"
+	"eth-parser/export"
+)
+
+const (
+	defaultClientServer  = "http://localhost:8080"
+	defaultClientTimeout = 30 * time.Second
+)
+
+// apiClient calls API of the running instance
+type apiClient struct {
+	server     *string
+	apiKey     *string
+	chainID    *uint64
+	timeout    *time.Duration
+	httpClient *http.Client
+}
+
+// apiClientError is the error returned by the API
+type apiClientError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+func registerClientFlags(fs *flag.FlagSet) *apiClient {
+	return &apiClient{
+		server: fs.String("server",
+			defaultClientServer, "URL of the running instance"),
+		apiKey: fs.String("api-key",
+			"", "API key of the tenant, requests are not authenticated if empty"),
+		chainID: fs.Uint64("chain-id",
+			0, "ID of the chain, may be omitted if only one chain is served"),
+		timeout: fs.Duration("timeout",
+			defaultClientTimeout, "timeout of every request"),
+	}
+}
+
+// call calls the route with the query and the JSON body if it is not nil,
+// and unmarshals JSON response into result
+func (c *apiClient) call(
+	method, path string,
+	query url.Values,
+	body interface{},
+	result interface{},
+) error {
+	resp, err := c.do(method, path, query, body)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if err := json.NewDecoder(resp.Body).Decode(result); err != nil {
+		return fmt.Errorf("could not unmarshal response: %w", err)
+	}
+
+	return nil
+}
+
+// do calls the route with the query and the JSON body if it is not nil, and
+// returns successful response, which body must be closed by caller
+func (c *apiClient) do(
+	method, path string,
+	query url.Values,
+	body interface{},
+) (*http.Response, error) {
+	if c.httpClient == nil {
+		c.httpClient = &http.Client{Timeout: *c.timeout}
+	}
+
+	if query == nil {
+		query = url.Values{}
+	}
+	if *c.chainID != 0 {
+		query.Set("chain_id", strconv.FormatUint(*c.chainID, 10))
+	}
+	endpoint := strings.TrimSuffix(*c.server, "/") + path
+	if len(query) != 0 {
+		endpoint += "?" + query.Encode()
+	}
+
+	var bodyReader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return nil, fmt.Errorf("could not marshal request: %w", err)
+		}
+		bodyReader = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequest(method, endpoint, bodyReader)
+	if err != nil {
+		return nil, fmt.Errorf("could not create request: %w", err)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if len(*c.apiKey) != 0 {
+		req.Header.Set(auth.APIKeyHeader, *c.apiKey)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("could not execute request: %w", err)
+	}
+	if resp.StatusCode == http.StatusOK {
+		return resp, nil
+	}
+	defer resp.Body.Close()
+
+	var errResponse struct {
+		Error apiClientError `json:"error"`
+	}
+	err = json.NewDecoder(resp.Body).Decode(&errResponse)
+	if err != nil || len(errResponse.Error.Code) == 0 {
+		return nil, fmt.Errorf("got status %s", resp.Status)
+	}
+	return nil, fmt.Errorf("got error %s: %s", errResponse.Error.Code, errResponse.Error.Message)
+}
+
+// subscriptions returns addresses subscribed by the tenant
+func (c *apiClient) subscriptions() ([]string, error) {
+	var response struct {
+		Addresses []string `json:"addresses"`
+	}
+	err := c.call(http.MethodGet, "/v1/subscriptions", nil, nil, &response)
+	if err != nil {
+		return nil, fmt.Errorf("could not list subscriptions: %w", err)
+	}
+
+	return response.Addresses, nil
+}
+
+// commandAddresses returns normalized addresses passed as arguments and in
+// the addresses file
+func commandAddresses(fs *flag.FlagSet, addressesFile string) ([]string, error) {
+	return ingestAddresses(strings.Join(fs.Args(), ","), addressesFile)
+}
+
+// rawAddresses returns addresses passed as arguments and in the addresses
+// file as they are
+func rawAddresses(fs *flag.FlagSet, addressesFile string) ([]string, error) {
+	addresses := fs.Args()
+	if len(addressesFile) != 0 {
+		fileAddresses, err := readAddressesFile(addressesFile)
+		if err != nil {
+			return nil, err
+		}
+		addresses = append(addresses, fileAddresses...)
+	}
+
+	return addresses, nil
+}
+
+// runSubscribe subscribes the running instance to the addresses in bulk,
+// every address is reported separately
+func runSubscribe(args []string) error {
+	fs := flag.NewFlagSet("subscribe", flag.ExitOnError)
+	client := registerClientFlags(fs)
+	addressesFile := fs.String("addresses-file",
+		"", "CSV file with addresses to subscribe to in the first column")
+	fs.Usage = commandUsage(fs, "subscribe [flags] [address ...]")
+	fs.Parse(args)
+
+	// addresses are validated by the instance and reported separately
+	addresses, err := rawAddresses(fs, *addressesFile)
+	if err != nil {
+		return err
+	}
+	if len(addresses) == 0 {
+		return fmt.Errorf("no addresses to subscribe to")
+	}
+
+	var response struct {
+		Results []struct {
+			Address    string          `json:"address"`
+			Subscribed bool            `json:"subscribed"`
+			Error      *apiClientError `json:"error"`
+		} `json:"results"`
+	}
+	err = client.call(http.MethodPost, "/v1/subscribe/bulk", nil, addresses, &response)
+	if err != nil {
+		return fmt.Errorf("could not subscribe: %w", err)
+	}
+
+	failed := 0
+	for _, result := range response.Results {
+		if result.Subscribed {
+			fmt.Printf("%s subscribed\n", result.Address)
+			continue
+		}
+
+		failed++
+		if result.Error != nil {
+			fmt.Printf("%s failed: %s\n", result.Address, result.Error.Message)
+			continue
+		}
+		fmt.Printf("%s failed\n", result.Address)
+	}
+	if failed != 0 {
+		return fmt.Errorf("could not subscribe to %d of %d addresses", failed, len(addresses))
+	}
+
+	return nil
+}
+
+// runUnsubscribe unsubscribes the running instance from the addresses, their
+// stored transactions are kept
+func runUnsubscribe(args []string) error {
+	fs := flag.NewFlagSet("unsubscribe", flag.ExitOnError)
+	client := registerClientFlags(fs)
+	addressesFile := fs.String("addresses-file",
+		"", "CSV file with addresses to unsubscribe from in the first column")
+	fs.Usage = commandUsage(fs, "unsubscribe [flags] [address ...]")
+	fs.Parse(args)
+
+	addresses, err := commandAddresses(fs, *addressesFile)
+	if err != nil {
+		return err
+	}
+	if len(addresses) == 0 {
+		return fmt.Errorf("no addresses to unsubscribe from")
+	}
+
+	for _, address := range addresses {
+		var response struct{}
+		err := client.call(http.MethodPost, "/v1/unsubscribe",
+			url.Values{"address": {address}}, nil, &response)
+		if err != nil {
+			return fmt.Errorf("could not unsubscribe %s: %w", address, err)
+		}
+
+		fmt.Printf("%s unsubscribed\n", address)
+	}
+
+	return nil
+}
+
+// runList prints addresses subscribed on the running instance, one per line
+func runList(args []string) error {
+	fs := flag.NewFlagSet("list", flag.ExitOnError)
+	client := registerClientFlags(fs)
+	fs.Parse(args)
+
+	addresses, err := client.subscriptions()
+	if err != nil {
+		return err
+	}
+
+	writer := bufio.NewWriter(os.Stdout)
+	for _, address := range addresses {
+		fmt.Fprintln(writer, address)
+	}
+	return writer.Flush()
+}
+
+// runExport writes transactions stored by the running instance for the
+// addresses, or for all subscribed addresses if none are passed, as CSV or
+// JSONL
+func runExport(args []string) error {
+	fs := flag.NewFlagSet("export", flag.ExitOnError)
+	client := registerClientFlags(fs)
+	addressesFile := fs.String("addresses-file",
+		"", "CSV file with addresses to export in the first column")
+	format := fs.String("format",
+		export.FormatJSONL, fmt.Sprintf("format of the export, one of %v", export.Formats))
+	fromBlock := fs.Uint64("from-block",
+		0, "first block of the range, unbounded if zero")
+	toBlock := fs.Uint64("to-block",
+		0, "last block of the range, unbounded if zero")
+	fromTime := fs.String("from-time",
+		"", "inclusive start of the range as RFC 3339 time or date in UTC")
+	toTime := fs.String("to-time",
+		"", "exclusive end of the range as RFC 3339 time or date in UTC")
+	out := fs.String("out",
+		"", "file to write transactions to, stdout if empty")
+	fs.Usage = commandUsage(fs, "export [flags] [address ...]")
+	fs.Parse(args)
+
+	// all subscribed addresses are exported if none are passed
+	addresses, err := commandAddresses(fs, *addressesFile)
+	if err != nil {
+		return err
+	}
+
+	query := url.Values{"format": {*format}}
+	if len(addresses) != 0 {
+		query.Set("addresses", strings.Join(addresses, ","))
+	}
+	if *fromBlock != 0 {
+		query.Set("from_block", strconv.FormatUint(*fromBlock, 10))
+	}
+	if *toBlock != 0 {
+		query.Set("to_block", strconv.FormatUint(*toBlock, 10))
+	}
+	if len(*fromTime) != 0 {
+		query.Set("from_time", *fromTime)
+	}
+	if len(*toTime) != 0 {
+		query.Set("to_time", *toTime)
+	}
+
+	// the export is streamed by the server, so the response is written as
+	// it is read
+	resp, err := client.do(http.MethodGet, "/v1/export", query, nil)
+	if err != nil {
+		return fmt.Errorf("could not export transactions: %w", err)
+	}
+	defer resp.Body.Close()
+
+	output := io.Writer(os.Stdout)
+	if len(*out) != 0 {
+		file, err := os.Create(*out)
+		if err != nil {
+			return fmt.Errorf("could not create output file: %w", err)
+		}
+		defer file.Close()
+		output = file
+	}
+
+	if _, err := io.Copy(output, resp.Body); err != nil {
+		return fmt.Errorf("could not write transactions: %w", err)
+	}
+	return nil
+}
+
+// commandUsage returns usage of the command with positional arguments
+func commandUsage(fs *flag.FlagSet, usage string) func() {
+	return func() {
+		fmt.Fprintf(fs.Output(), "usage: %s %s\n", os.Args[0], usage)
+		fs.PrintDefaults()
+	}
+}
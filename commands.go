@@ -0,0 +1,108 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+
+	"eth-parser/config"
+	"eth-parser/logging"
+)
+
+type command struct {
+	name    string
+	summary string
+	run     func(args []string) error
+}
+
+// commands are listed in usage in this order, they are set on init since
+// help refers to them
+var commands []*command
+
+func init() {
+	commands = []*command{
+		{name: "serve", summary: "poll the chains and serve the API, the default", run: runServe},
+		{name: "backfill", summary: "match transactions of the range of blocks", run: runBackfill},
+		{name: "ingest", summary: "match transactions of block files", run: runIngest},
+		{name: "subscribe", summary: "subscribe running instance to addresses", run: runSubscribe},
+		{name: "unsubscribe", summary: "unsubscribe running instance", run: runUnsubscribe},
+		{name: "list", summary: "list addresses subscribed on running instance", run: runList},
+		{name: "export", summary: "export stored transactions of running instance", run: runExport},
+		{name: "check-endpoint", summary: "check capabilities of the endpoint", run: runCheck},
+		{name: "help", summary: "print this help", run: runHelp},
+	}
+}
+
+func findCommand(name string) *command {
+	for _, cmd := range commands {
+		if cmd.name == name {
+			return cmd
+		}
+	}
+
+	return nil
+}
+
+func printUsage() {
+	fmt.Fprintf(os.Stderr, "usage: %s [command] [flags]\n\ncommands:\n", os.Args[0])
+	for _, cmd := range commands {
+		fmt.Fprintf(os.Stderr, "  %-16s %s\n", cmd.name, cmd.summary)
+	}
+	fmt.Fprintf(os.Stderr, "\nrun '%s <command> -h' for flags of the command\n", os.Args[0])
+}
+
+func runHelp(args []string) error {
+	printUsage()
+	return nil
+}
+
+// newConfigFlagSet creates flag set of the command with flags of the config
+// and of the config file
+func newConfigFlagSet(name string) (*flag.FlagSet, *config.Config, *string) {
+	fs := flag.NewFlagSet(name, flag.ExitOnError)
+	cfg := config.Default()
+	cfg.RegisterFlags(fs)
+
+	configPath := fs.String("config",
+		"", "path to YAML or JSON configuration file")
+
+	return fs, &cfg, configPath
+}
+
+// loadConfig loads config of the flags parsed by fs, validates it and creates
+// the logger
+func loadConfig(fs *flag.FlagSet, cfg *config.Config, path string) (*slog.Logger, error) {
+	if err := cfg.Load(path, fs, os.LookupEnv); err != nil {
+		return nil, fmt.Errorf("could not load config: %w", err)
+	}
+
+	return newLogger(cfg)
+}
+
+// newLogger validates the config and creates the logger configured by it
+func newLogger(cfg *config.Config) (*slog.Logger, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid config: %w", err)
+	}
+
+	logger, err := logging.New(os.Stderr, cfg.Log.Level, cfg.Log.Format)
+	if err != nil {
+		return nil, fmt.Errorf("could not create logger: %w", err)
+	}
+
+	return logger, nil
+}
+
+// splitList splits comma separated list skipping empty items
+func splitList(list string) []string {
+	var items []string
+	for _, item := range strings.Split(list, ",") {
+		if item = strings.TrimSpace(item); len(item) != 0 {
+			items = append(items, item)
+		}
+	}
+
+	return items
+}
@@ -0,0 +1,759 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"eth-parser/abi"
+	"eth-parser/auth"
+	"eth-parser/grpcapi"
+	"eth-parser/logging"
+	"eth-parser/parser"
+	"eth-parser/poller"
+	"eth-parser/prices"
+	"eth-parser/server"
+	"eth-parser/sinks"
+	"eth-parser/storages"
+)
+
+const (
+	cloudflareEndpoint = "https://cloudflare-eth.com"
+
+	defaultLogLevel                      = "info"
+	defaultLogFormat                     = logging.FormatText
+	defaultServerAddr                    = "localhost:8080"
+	defaultStorageReset                  = false
+	defaultStorageBackend                = storages.BackendMemory
+	defaultStorageBloomFilterFPRate      = 0.01
+	defaultStoragePostgresMaxConns       = 10
+	defaultStoragePostgresTimeout        = 5 * time.Second
+	defaultStorageRedisPrefix            = "eth-parser"
+	defaultStorageRedisTimeout           = 5 * time.Second
+	defaultPollerChainID                 = 1
+	defaultPollerEndpoint                = cloudflareEndpoint
+	defaultPollerPollInterval            = 1 * time.Second
+	defaultPollerHeadRefreshInterval     = 10 * time.Second
+	defaultPollerTimeout                 = 5 * time.Second
+	defaultPollerMaxIdleConns            = 100
+	defaultPollerMaxConnsPerHost         = 100
+	defaultPollerMaxIdleConnsPerHost     = 100
+	defaultPollerNumRetries              = 3
+	defaultPollerRetryBackoff            = 200 * time.Millisecond
+	defaultPollerMaxRetryBackoff         = 5 * time.Second
+	defaultPollerQueueLen                = 10
+	defaultPollerPendingInterval         = 1 * time.Second
+	defaultPollerPendingBatchSize        = 100
+	defaultParserPendingDropTimeout      = 10 * time.Minute
+	defaultParserWatchTimeout            = 5 * time.Second
+	defaultParserBalancesReconcileBlocks = 100
+	defaultPricesResolution              = 24 * time.Hour
+	defaultPricesFailureTTL              = time.Minute
+	defaultPricesHTTPURL                 = "https://api.coingecko.com/api/v3"
+	defaultPricesHTTPCoin                = "ethereum"
+	defaultPricesHTTPAPIKeyHeader        = "x-cg-demo-api-key"
+	defaultPricesHTTPTimeout             = 10 * time.Second
+	defaultSinksKafkaClientID            = "eth-parser"
+	defaultSinksKafkaTopic               = "eth-transactions"
+	defaultSinksKafkaTimeout             = 10 * time.Second
+	defaultSinksNATSTopic                = "eth.transactions.{chain_id}"
+	defaultSinksNATSTimeout              = 5 * time.Second
+	defaultSinksRedisTopic               = "eth:transactions:{chain_id}"
+	defaultSinksRedisTimeout             = 5 * time.Second
+	defaultHealthMaxBlockAge             = 1 * time.Minute
+	defaultHealthMaxBlockLag             = 10
+	defaultAuthJWTTenantClaim            = "sub"
+)
+
+type Config struct {
+	Log     LogConfig              `yaml:"log"`
+	Server  ServerConfig           `yaml:"server"`
+	GRPC    grpcapi.Config         `yaml:"grpc"`
+	Storage StorageConfig          `yaml:"storage"`
+	Parser  parser.Config          `yaml:"parser"`
+	ABI     abi.Config             `yaml:"abi"`
+	Prices  prices.Config          `yaml:"prices"`
+	Sinks   sinks.Config           `yaml:"sinks"`
+	Poller  poller.EthPollerConfig `yaml:"poller"`
+	Health  server.HealthConfig    `yaml:"health"`
+	Auth    auth.Config            `yaml:"auth"`
+
+	// Chains lists pollers of the parsed chains, fields which are not set in
+	// the configuration file are taken from Poller. If empty, the only chain
+	// configured by Poller is parsed.
+	Chains []poller.EthPollerConfig `yaml:"chains,omitempty"`
+
+	// chainNodes keeps raw chains configuration from the file to be applied
+	// on top of the effective Poller configuration
+	chainNodes []yaml.Node
+}
+
+type LogConfig struct {
+	Level  string `yaml:"level"`
+	Format string `yaml:"format"`
+}
+
+type ServerConfig struct {
+	Addr string `yaml:"addr"`
+}
+
+type StorageConfig struct {
+	// Reset resets stored transactions after getting them
+	Reset bool `yaml:"reset"`
+
+	// Backend keeps transactions and subscribed addresses, memory, postgres
+	// or redis
+	Backend string `yaml:"backend"`
+
+	// BloomFilter fronts subscribed addresses storage with Bloom filter
+	BloomFilter storages.BloomFilterConfig `yaml:"bloom_filter"`
+
+	Postgres storages.PostgresConfig `yaml:"postgres"`
+	Redis    storages.RedisConfig    `yaml:"redis"`
+}
+
+// Default returns configuration with default values
+func Default() Config {
+	return Config{
+		Log: LogConfig{
+			Level:  defaultLogLevel,
+			Format: defaultLogFormat,
+		},
+		Server: ServerConfig{
+			Addr: defaultServerAddr,
+		},
+		Storage: StorageConfig{
+			Reset:   defaultStorageReset,
+			Backend: defaultStorageBackend,
+			BloomFilter: storages.BloomFilterConfig{
+				FalsePositiveRate: defaultStorageBloomFilterFPRate,
+			},
+			Postgres: storages.PostgresConfig{
+				MaxConns: defaultStoragePostgresMaxConns,
+				Timeout:  defaultStoragePostgresTimeout,
+			},
+			Redis: storages.RedisConfig{
+				Prefix:  defaultStorageRedisPrefix,
+				Timeout: defaultStorageRedisTimeout,
+			},
+		},
+		Parser: parser.Config{
+			PendingDropTimeout: defaultParserPendingDropTimeout,
+			WatchTimeout:       defaultParserWatchTimeout,
+			Balances: parser.BalancesConfig{
+				ReconcileBlocks: defaultParserBalancesReconcileBlocks,
+			},
+		},
+		Prices: prices.Config{
+			Resolution: defaultPricesResolution,
+			FailureTTL: defaultPricesFailureTTL,
+			HTTP: prices.HTTPConfig{
+				URL:          defaultPricesHTTPURL,
+				Coin:         defaultPricesHTTPCoin,
+				APIKeyHeader: defaultPricesHTTPAPIKeyHeader,
+				Timeout:      defaultPricesHTTPTimeout,
+			},
+		},
+		Sinks: sinks.Config{
+			Kafka: sinks.KafkaConfig{
+				ClientID: defaultSinksKafkaClientID,
+				Timeout:  defaultSinksKafkaTimeout,
+				Routing:  sinks.Routing{Topic: defaultSinksKafkaTopic},
+			},
+			NATS: sinks.NATSConfig{
+				Timeout: defaultSinksNATSTimeout,
+				Routing: sinks.Routing{Topic: defaultSinksNATSTopic},
+			},
+			Redis: sinks.RedisConfig{
+				Timeout: defaultSinksRedisTimeout,
+				Routing: sinks.Routing{Topic: defaultSinksRedisTopic},
+			},
+		},
+		Poller: poller.EthPollerConfig{
+			ChainID:             defaultPollerChainID,
+			Endpoint:            defaultPollerEndpoint,
+			PollInterval:        defaultPollerPollInterval,
+			HeadRefreshInterval: defaultPollerHeadRefreshInterval,
+			Timeout:             defaultPollerTimeout,
+			MaxIdleConns:        defaultPollerMaxIdleConns,
+			MaxConnsPerHost:     defaultPollerMaxConnsPerHost,
+			MaxIdleConnsPerHost: defaultPollerMaxIdleConnsPerHost,
+			NumRetries:          defaultPollerNumRetries,
+			RetryBackoff:        defaultPollerRetryBackoff,
+			MaxRetryBackoff:     defaultPollerMaxRetryBackoff,
+			QueueLen:            defaultPollerQueueLen,
+			Pending: poller.PendingConfig{
+				Interval:  defaultPollerPendingInterval,
+				BatchSize: defaultPollerPendingBatchSize,
+			},
+		},
+		Health: server.HealthConfig{
+			MaxBlockAge: defaultHealthMaxBlockAge,
+			MaxBlockLag: defaultHealthMaxBlockLag,
+		},
+		Auth: auth.Config{
+			JWT: auth.JWTConfig{
+				TenantClaim: defaultAuthJWTTenantClaim,
+			},
+		},
+	}
+}
+
+// RegisterFlags binds command line flags to the configuration fields. Flag
+// names match the paths of the fields in the configuration file.
+func (c *Config) RegisterFlags(fs *flag.FlagSet) {
+	fs.StringVar(&c.Log.Level, "log.level",
+		c.Log.Level, "log level: debug, info, warn or error")
+	fs.StringVar(&c.Log.Format, "log.format",
+		c.Log.Format, "log format: text or json")
+
+	fs.StringVar(&c.Server.Addr, "server.addr",
+		c.Server.Addr, "server addr to listen on")
+
+	fs.StringVar(&c.GRPC.Addr, "grpc.addr",
+		c.GRPC.Addr, "gRPC server addr to listen on, disabled if empty")
+
+	fs.BoolVar(&c.Storage.Reset, "storage.reset",
+		c.Storage.Reset, "reset stored transactions after getting them")
+	fs.StringVar(&c.Storage.Backend, "storage.backend",
+		c.Storage.Backend, "storage of transactions and subscriptions: memory, postgres or redis")
+	fs.UintVar(&c.Storage.BloomFilter.ExpectedAddresses, "storage.bloom_filter.expected_addresses",
+		c.Storage.BloomFilter.ExpectedAddresses,
+		"number of subscribed addresses Bloom filter is sized for, 0 disables the filter")
+	fs.Float64Var(&c.Storage.BloomFilter.FalsePositiveRate,
+		"storage.bloom_filter.false_positive_rate",
+		c.Storage.BloomFilter.FalsePositiveRate, "false positive rate of Bloom filter")
+	fs.IntVar(&c.Storage.Postgres.MaxConns, "storage.postgres.max_conns",
+		c.Storage.Postgres.MaxConns, "max connections to PostgreSQL")
+	fs.DurationVar(&c.Storage.Postgres.Timeout, "storage.postgres.timeout",
+		c.Storage.Postgres.Timeout, "PostgreSQL query timeout")
+	fs.StringVar(&c.Storage.Redis.Addr, "storage.redis.addr",
+		c.Storage.Redis.Addr, "Redis addr of redis backend")
+	fs.IntVar(&c.Storage.Redis.DB, "storage.redis.db",
+		c.Storage.Redis.DB, "Redis database number")
+	fs.StringVar(&c.Storage.Redis.Prefix, "storage.redis.prefix",
+		c.Storage.Redis.Prefix, "prefix of Redis keys and channels")
+	fs.DurationVar(&c.Storage.Redis.Timeout, "storage.redis.timeout",
+		c.Storage.Redis.Timeout, "Redis command timeout")
+
+	fs.BoolVar(&c.Parser.AutoSubscribeContracts, "parser.auto_subscribe_contracts",
+		c.Parser.AutoSubscribeContracts,
+		"subscribe to contracts deployed by subscribed addresses")
+	fs.DurationVar(&c.Parser.PendingDropTimeout, "parser.pending_drop_timeout",
+		c.Parser.PendingDropTimeout, "time after which not mined pending transaction is dropped")
+	fs.DurationVar(&c.Parser.WatchTimeout, "parser.watch_timeout",
+		c.Parser.WatchTimeout, "time to wait for watcher with full queue before closing it")
+	fs.BoolVar(&c.Parser.Balances.Enabled, "parser.balances.enabled",
+		c.Parser.Balances.Enabled, "track running balances of subscribed addresses")
+	fs.BoolVar(&c.Parser.Balances.Tokens, "parser.balances.tokens",
+		c.Parser.Balances.Tokens, "track ERC-20 balances of subscribed addresses as well")
+	fs.Int64Var(&c.Parser.Balances.ReconcileBlocks, "parser.balances.reconcile_blocks",
+		c.Parser.Balances.ReconcileBlocks,
+		"number of blocks between reconciliations of balances with the chain, 0 disables them")
+
+	fs.StringVar(&c.ABI.Dir, "abi.dir", c.ABI.Dir,
+		"directory of uploaded ABIs, they are kept in memory only if empty")
+
+	fs.Var((*stringList)(&c.Prices.Currencies), "prices.currencies",
+		"comma separated fiat currencies to valuate transactions in, disabled if empty")
+	fs.StringVar(&c.Prices.Source, "prices.source",
+		c.Prices.Source, "source of prices: file or http")
+	fs.StringVar(&c.Prices.File, "prices.file",
+		c.Prices.File, "CSV file of historical prices of file source")
+	fs.StringVar(&c.Prices.HTTP.URL, "prices.http.url",
+		c.Prices.HTTP.URL, "base URL of CoinGecko compatible API of http source")
+	fs.StringVar(&c.Prices.HTTP.Coin, "prices.http.coin",
+		c.Prices.HTTP.Coin, "ID of the native coin of the chain in the API")
+	fs.DurationVar(&c.Prices.HTTP.Timeout, "prices.http.timeout",
+		c.Prices.HTTP.Timeout, "price request timeout")
+	fs.DurationVar(&c.Prices.Resolution, "prices.resolution",
+		c.Prices.Resolution, "period prices are cached for, e.g. 1h or 24h")
+	fs.DurationVar(&c.Prices.FailureTTL, "prices.failure_ttl",
+		c.Prices.FailureTTL, "time failed price requests are not repeated for, 0 to disable")
+
+	fs.StringVar(&c.Sinks.CheckpointFile, "sinks.checkpoint_file", c.Sinks.CheckpointFile,
+		"file of the last blocks published to sinks to resume after, disabled if empty")
+	fs.Var((*stringList)(&c.Sinks.Kafka.Brokers), "sinks.kafka.brokers",
+		"comma separated Kafka bootstrap brokers, Kafka sink is disabled if empty")
+	fs.StringVar(&c.Sinks.Kafka.Topic, "sinks.kafka.topic",
+		c.Sinks.Kafka.Topic, "default Kafka topic")
+	fs.StringVar(&c.Sinks.NATS.URL, "sinks.nats.url",
+		c.Sinks.NATS.URL, "NATS servers URL, NATS sink is disabled if empty")
+	fs.BoolVar(&c.Sinks.NATS.JetStream, "sinks.nats.jetstream",
+		c.Sinks.NATS.JetStream, "wait for JetStream acknowledgements")
+	fs.StringVar(&c.Sinks.NATS.Topic, "sinks.nats.topic",
+		c.Sinks.NATS.Topic, "default NATS subject")
+	fs.StringVar(&c.Sinks.Redis.Addr, "sinks.redis.addr",
+		c.Sinks.Redis.Addr, "Redis addr, Redis Streams sink is disabled if empty")
+	fs.StringVar(&c.Sinks.Redis.Topic, "sinks.redis.topic",
+		c.Sinks.Redis.Topic, "default Redis stream")
+	fs.Int64Var(&c.Sinks.Redis.MaxLen, "sinks.redis.max_len",
+		c.Sinks.Redis.MaxLen, "approximate max length of Redis streams, 0 to disable trimming")
+
+	fs.Uint64Var(&c.Poller.ChainID, "poller.chain_id",
+		c.Poller.ChainID, "ID of the chain served by the endpoint")
+	fs.StringVar(&c.Poller.Endpoint, "poller.endpoint",
+		c.Poller.Endpoint, "endpoint for poller to get info about ETH blocks")
+	fs.DurationVar(&c.Poller.PollInterval, "poller.interval",
+		c.Poller.PollInterval, "poll interval")
+	fs.DurationVar(&c.Poller.HeadRefreshInterval, "poller.head_refresh_interval",
+		c.Poller.HeadRefreshInterval, "interval of refreshing the latest block number")
+	fs.DurationVar(&c.Poller.Timeout, "poller.timeout",
+		c.Poller.Timeout, "endpoint request timeout")
+	fs.IntVar(&c.Poller.MaxIdleConns, "poller.max_idle_conns",
+		c.Poller.MaxIdleConns, "max idle conns")
+	fs.IntVar(&c.Poller.MaxConnsPerHost, "poller.max_conns_per_host",
+		c.Poller.MaxConnsPerHost, "max conns per host")
+	fs.IntVar(&c.Poller.MaxIdleConnsPerHost, "poller.max_idle_conns_per_host",
+		c.Poller.MaxIdleConnsPerHost, "max idle conns per host")
+	fs.IntVar(&c.Poller.NumRetries, "poller.num_retries",
+		c.Poller.NumRetries, "num retries")
+	fs.DurationVar(&c.Poller.RetryBackoff, "poller.retry_backoff",
+		c.Poller.RetryBackoff, "delay before the first retry, doubled for every next one")
+	fs.DurationVar(&c.Poller.MaxRetryBackoff, "poller.max_retry_backoff",
+		c.Poller.MaxRetryBackoff, "max delay before retry, including the one of Retry-After")
+	fs.IntVar(&c.Poller.QueueLen, "poller.queue_len",
+		c.Poller.QueueLen, "queue length")
+	fs.BoolVar(&c.Poller.Pending.Enabled, "poller.pending.enabled",
+		c.Poller.Pending.Enabled, "watch pending transactions")
+	fs.DurationVar(&c.Poller.Pending.Interval, "poller.pending.interval",
+		c.Poller.Pending.Interval, "pending transactions poll interval")
+	fs.IntVar(&c.Poller.Pending.BatchSize, "poller.pending.batch_size",
+		c.Poller.Pending.BatchSize, "max number of pending transactions requested in one batch")
+	fs.StringVar(&c.Poller.RecordFile, "poller.record_file",
+		c.Poller.RecordFile, "JSONL file to record requests to the endpoint to, disabled if empty")
+	fs.StringVar(&c.Poller.Replay.File, "poller.replay.file",
+		c.Poller.Replay.File, "recording to replay instead of polling the endpoint")
+	fs.BoolVar(&c.Poller.Replay.RealTime, "poller.replay.real_time",
+		c.Poller.Replay.RealTime, "replay blocks with the recorded intervals between them")
+
+	fs.DurationVar(&c.Health.MaxBlockAge, "health.max_block_age",
+		c.Health.MaxBlockAge, "max time since last parsed block to be ready, 0 to disable")
+	fs.Int64Var(&c.Health.MaxBlockLag, "health.max_block_lag",
+		c.Health.MaxBlockLag, "max lag behind the latest block to be ready, 0 to disable")
+}
+
+// Load builds the effective configuration from defaults, configuration file
+// (if path is not empty), environment variables and explicitly set flags of
+// fs, in order of increasing priority. Flags of fs must be registered with
+// RegisterFlags and parsed.
+func (c *Config) Load(path string, fs *flag.FlagSet, lookupEnv func(string) (string, bool)) error {
+	setFlags := make(map[string]string)
+	fs.Visit(func(f *flag.Flag) {
+		setFlags[f.Name] = f.Value.String()
+	})
+
+	*c = Default()
+
+	if len(path) != 0 {
+		if err := c.loadFile(path); err != nil {
+			return fmt.Errorf("could not load config file '%s': %w", path, err)
+		}
+	}
+
+	if err := applyEnv(c, lookupEnv); err != nil {
+		return fmt.Errorf("could not apply environment variables: %w", err)
+	}
+
+	for name, value := range setFlags {
+		if err := fs.Set(name, value); err != nil {
+			return fmt.Errorf("could not apply flag '%s': %w", name, err)
+		}
+	}
+
+	if err := c.resolveChains(); err != nil {
+		return fmt.Errorf("could not resolve chains: %w", err)
+	}
+
+	return nil
+}
+
+// ChainPollers returns configurations of pollers of all parsed chains
+func (c *Config) ChainPollers() []poller.EthPollerConfig {
+	if len(c.Chains) == 0 {
+		return []poller.EthPollerConfig{c.Poller}
+	}
+
+	return c.Chains
+}
+
+// resolveChains decodes chains configuration from the file on top of the
+// effective Poller configuration
+func (c *Config) resolveChains() error {
+	if len(c.chainNodes) == 0 {
+		return nil
+	}
+
+	c.Chains = make([]poller.EthPollerConfig, len(c.chainNodes))
+	for i := range c.chainNodes {
+		c.Chains[i] = c.Poller
+		if err := c.chainNodes[i].Decode(&c.Chains[i]); err != nil {
+			return fmt.Errorf("chains[%d]: %w", i, err)
+		}
+	}
+
+	return nil
+}
+
+// loadFile reads YAML or JSON configuration file, JSON is parsed as YAML
+// as the latter is a superset of the former
+func (c *Config) loadFile(path string) error {
+	data, err := os.ReadFile(filepath.Clean(path))
+	if err != nil {
+		return err
+	}
+
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+
+	if err := decoder.Decode(c); err != nil {
+		return fmt.Errorf("could not decode: %w", err)
+	}
+
+	// chains are decoded once again later to inherit unset fields
+	var chains struct {
+		Chains []yaml.Node `yaml:"chains"`
+	}
+	if err := yaml.Unmarshal(data, &chains); err != nil {
+		return fmt.Errorf("could not decode chains: %w", err)
+	}
+	c.chainNodes = chains.Chains
+
+	return nil
+}
+
+// Validate checks configuration values and returns all found problems
+func (c *Config) Validate() error {
+	var errs []error
+	addErr := func(field, format string, args ...interface{}) {
+		errs = append(errs, fmt.Errorf("%s: %s", field, fmt.Sprintf(format, args...)))
+	}
+
+	var logLevel slog.Level
+	if err := logLevel.UnmarshalText([]byte(c.Log.Level)); err != nil {
+		addErr("log.level", "unknown level '%s'", c.Log.Level)
+	}
+	if c.Log.Format != logging.FormatText && c.Log.Format != logging.FormatJSON {
+		addErr("log.format", "must be '%s' or '%s', got '%s'",
+			logging.FormatText, logging.FormatJSON, c.Log.Format)
+	}
+
+	if len(c.Server.Addr) == 0 {
+		addErr("server.addr", "must not be empty")
+	}
+
+	errs = append(errs, validateStorage(&c.Storage)...)
+
+	if len(c.Chains) == 0 {
+		errs = append(errs, validatePoller("poller", &c.Poller)...)
+	}
+
+	chainIDs := make(map[uint64]struct{}, len(c.Chains))
+	recordFiles := make(map[string]struct{}, len(c.Chains))
+	for i := range c.Chains {
+		field := fmt.Sprintf("chains[%d]", i)
+		errs = append(errs, validatePoller(field, &c.Chains[i])...)
+
+		if _, ok := chainIDs[c.Chains[i].ChainID]; ok {
+			addErr(field+".chain_id", "duplicated chain ID %d", c.Chains[i].ChainID)
+		}
+		chainIDs[c.Chains[i].ChainID] = struct{}{}
+
+		// recordings of chains must not be mixed, record_file is inherited
+		// from poller section as other fields
+		if recordFile := c.Chains[i].RecordFile; len(recordFile) != 0 {
+			if _, ok := recordFiles[recordFile]; ok {
+				addErr(field+".record_file", "duplicated record file '%s'", recordFile)
+			}
+			recordFiles[recordFile] = struct{}{}
+		}
+	}
+
+	if c.Parser.PendingDropTimeout <= 0 {
+		addErr("parser.pending_drop_timeout", "must be positive, got %s",
+			c.Parser.PendingDropTimeout)
+	}
+	if c.Parser.WatchTimeout < 0 {
+		addErr("parser.watch_timeout", "must not be negative, got %s",
+			c.Parser.WatchTimeout)
+	}
+	if c.Parser.Balances.ReconcileBlocks < 0 {
+		addErr("parser.balances.reconcile_blocks", "must not be negative, got %d",
+			c.Parser.Balances.ReconcileBlocks)
+	}
+
+	errs = append(errs, validatePrices(&c.Prices)...)
+	errs = append(errs, validateSinks(&c.Sinks)...)
+
+	if c.Health.MaxBlockAge < 0 {
+		addErr("health.max_block_age", "must not be negative, got %s", c.Health.MaxBlockAge)
+	}
+	if c.Health.MaxBlockLag < 0 {
+		addErr("health.max_block_lag", "must not be negative, got %d", c.Health.MaxBlockLag)
+	}
+
+	errs = append(errs, validateAuth(&c.Auth)...)
+
+	return errors.Join(errs...)
+}
+
+func validateStorage(s *StorageConfig) []error {
+	var errs []error
+	addErr := func(field, format string, args ...interface{}) {
+		errs = append(errs, fmt.Errorf("storage.%s: %s", field, fmt.Sprintf(format, args...)))
+	}
+
+	if s.Backend != storages.BackendMemory && s.Reset {
+		addErr("reset", "is not supported by %s backend", s.Backend)
+	}
+
+	switch s.Backend {
+	case storages.BackendMemory:
+	case storages.BackendPostgres:
+		if len(s.Postgres.DSN) == 0 {
+			addErr("postgres.dsn", "must not be empty for %s backend", s.Backend)
+		}
+		if s.Postgres.MaxConns <= 0 {
+			addErr("postgres.max_conns", "must be positive, got %d", s.Postgres.MaxConns)
+		}
+		if s.Postgres.Timeout <= 0 {
+			addErr("postgres.timeout", "must be positive, got %s", s.Postgres.Timeout)
+		}
+	case storages.BackendRedis:
+		if _, _, err := net.SplitHostPort(s.Redis.Addr); err != nil {
+			addErr("redis.addr", "must be host:port, got '%s'", s.Redis.Addr)
+		}
+		if s.Redis.DB < 0 {
+			addErr("redis.db", "must not be negative, got %d", s.Redis.DB)
+		}
+		if len(s.Redis.Prefix) == 0 {
+			addErr("redis.prefix", "must not be empty")
+		}
+		if s.Redis.Timeout <= 0 {
+			addErr("redis.timeout", "must be positive, got %s", s.Redis.Timeout)
+		}
+	default:
+		addErr("backend", "must be '%s', '%s' or '%s', got '%s'", storages.BackendMemory,
+			storages.BackendPostgres, storages.BackendRedis, s.Backend)
+	}
+
+	if s.BloomFilter.ExpectedAddresses > 0 {
+		if rate := s.BloomFilter.FalsePositiveRate; rate <= 0 || rate >= 1 {
+			addErr("bloom_filter.false_positive_rate",
+				"must be between 0 and 1 exclusive, got %g", rate)
+		}
+	}
+
+	return errs
+}
+
+func validateAuth(a *auth.Config) []error {
+	var errs []error
+	addErr := func(field, format string, args ...interface{}) {
+		errs = append(errs, fmt.Errorf("auth.%s: %s", field, fmt.Sprintf(format, args...)))
+	}
+
+	keys := make(map[auth.Secret]struct{}, len(a.APIKeys))
+	for i, key := range a.APIKeys {
+		if len(key.Key) == 0 {
+			addErr(fmt.Sprintf("api_keys[%d].key", i), "must not be empty")
+		}
+		if _, ok := keys[key.Key]; ok {
+			addErr(fmt.Sprintf("api_keys[%d].key", i), "duplicated key")
+		}
+		keys[key.Key] = struct{}{}
+
+		if len(key.Tenant) == 0 {
+			addErr(fmt.Sprintf("api_keys[%d].tenant", i), "must not be empty")
+		}
+		if strings.HasPrefix(key.Tenant, auth.JWTTenantPrefix) {
+			addErr(fmt.Sprintf("api_keys[%d].tenant", i),
+				"must not start with '%s' reserved for JWT tenants, got '%s'",
+				auth.JWTTenantPrefix, key.Tenant)
+		}
+		if key.MaxSubscriptions < 0 {
+			addErr(fmt.Sprintf("api_keys[%d].max_subscriptions", i),
+				"must not be negative, got %d", key.MaxSubscriptions)
+		}
+	}
+
+	if len(a.JWT.Secret) != 0 && len(a.JWT.TenantClaim) == 0 {
+		addErr("jwt.tenant_claim", "must not be empty")
+	}
+	if a.JWT.MaxSubscriptions < 0 {
+		addErr("jwt.max_subscriptions", "must not be negative, got %d", a.JWT.MaxSubscriptions)
+	}
+
+	return errs
+}
+
+func validatePrices(p *prices.Config) []error {
+	if len(p.Currencies) == 0 {
+		return nil
+	}
+
+	var errs []error
+	addErr := func(field, format string, args ...interface{}) {
+		errs = append(errs, fmt.Errorf("prices.%s: %s", field, fmt.Sprintf(format, args...)))
+	}
+
+	for i, currency := range p.Currencies {
+		if len(currency) == 0 {
+			addErr(fmt.Sprintf("currencies[%d]", i), "must not be empty")
+		}
+	}
+
+	switch p.Source {
+	case prices.SourceFile:
+		if len(p.File) == 0 {
+			addErr("file", "must not be empty for %s source", p.Source)
+		}
+	case prices.SourceHTTP:
+		endpoint, err := url.Parse(p.HTTP.URL)
+		if err != nil || (endpoint.Scheme != "http" && endpoint.Scheme != "https") {
+			addErr("http.url", "must be a valid http(s) URL, got '%s'", p.HTTP.URL)
+		}
+		if len(p.HTTP.Coin) == 0 {
+			addErr("http.coin", "must not be empty")
+		}
+		if len(p.HTTP.APIKey) != 0 && len(p.HTTP.APIKeyHeader) == 0 {
+			addErr("http.api_key_header", "must not be empty")
+		}
+		if p.HTTP.Timeout <= 0 {
+			addErr("http.timeout", "must be positive, got %s", p.HTTP.Timeout)
+		}
+	default:
+		addErr("source", "must be '%s' or '%s', got '%s'",
+			prices.SourceFile, prices.SourceHTTP, p.Source)
+	}
+
+	if p.Resolution <= 0 {
+		addErr("resolution", "must be positive, got %s", p.Resolution)
+	}
+	if p.FailureTTL < 0 {
+		addErr("failure_ttl", "must not be negative, got %s", p.FailureTTL)
+	}
+
+	return errs
+}
+
+func validateSinks(s *sinks.Config) []error {
+	var errs []error
+	addErr := func(field, format string, args ...interface{}) {
+		errs = append(errs, fmt.Errorf("sinks.%s: %s", field, fmt.Sprintf(format, args...)))
+	}
+
+	validateSink := func(prefix string, timeout time.Duration, routing *sinks.Routing) {
+		if timeout <= 0 {
+			addErr(prefix+".timeout", "must be positive, got %s", timeout)
+		}
+		if len(routing.Topic) == 0 {
+			addErr(prefix+".topic", "must not be empty")
+		}
+		for i, route := range routing.Routes {
+			field := fmt.Sprintf("%s.routes[%d]", prefix, i)
+			if len(route.Tenant) == 0 && len(route.Address) == 0 {
+				addErr(field, "must match tenant or address")
+			}
+			if len(route.Topic) == 0 {
+				addErr(field+".topic", "must not be empty")
+			}
+		}
+	}
+
+	if len(s.Kafka.Brokers) != 0 {
+		for i, broker := range s.Kafka.Brokers {
+			if _, _, err := net.SplitHostPort(broker); err != nil {
+				addErr(fmt.Sprintf("kafka.brokers[%d]", i), "must be host:port, got '%s'", broker)
+			}
+		}
+		validateSink("kafka", s.Kafka.Timeout, &s.Kafka.Routing)
+	}
+	if len(s.NATS.URL) != 0 {
+		validateSink("nats", s.NATS.Timeout, &s.NATS.Routing)
+	}
+	if len(s.Redis.Addr) != 0 {
+		if s.Redis.MaxLen < 0 {
+			addErr("redis.max_len", "must not be negative, got %d", s.Redis.MaxLen)
+		}
+		validateSink("redis", s.Redis.Timeout, &s.Redis.Routing)
+	}
+
+	return errs
+}
+
+func validatePoller(prefix string, p *poller.EthPollerConfig) []error {
+	var errs []error
+	addErr := func(field, format string, args ...interface{}) {
+		errs = append(errs, fmt.Errorf("%s.%s: %s", prefix, field, fmt.Sprintf(format, args...)))
+	}
+
+	if p.ChainID == 0 {
+		addErr("chain_id", "must be set")
+	}
+	endpoint, err := url.Parse(p.Endpoint)
+	if err != nil || (endpoint.Scheme != "http" && endpoint.Scheme != "https") {
+		addErr("endpoint", "must be a valid http(s) URL, got '%s'", p.Endpoint)
+	}
+	if p.PollInterval <= 0 {
+		addErr("interval", "must be positive, got %s", p.PollInterval)
+	}
+	if p.HeadRefreshInterval < 0 {
+		addErr("head_refresh_interval", "must not be negative, got %s", p.HeadRefreshInterval)
+	}
+	if p.Timeout <= 0 {
+		addErr("timeout", "must be positive, got %s", p.Timeout)
+	}
+	if p.MaxIdleConns < 0 {
+		addErr("max_idle_conns", "must not be negative, got %d", p.MaxIdleConns)
+	}
+	if p.MaxConnsPerHost < 0 {
+		addErr("max_conns_per_host", "must not be negative, got %d", p.MaxConnsPerHost)
+	}
+	if p.MaxIdleConnsPerHost < 0 {
+		addErr("max_idle_conns_per_host", "must not be negative, got %d", p.MaxIdleConnsPerHost)
+	}
+	if p.NumRetries <= 0 {
+		addErr("num_retries", "must be at least 1, got %d "+
+			"(it is the total number of attempts, so no request would ever be sent)",
+			p.NumRetries)
+	}
+	if p.RetryBackoff < 0 {
+		addErr("retry_backoff", "must not be negative, got %s", p.RetryBackoff)
+	}
+	if p.MaxRetryBackoff < p.RetryBackoff {
+		addErr("max_retry_backoff", "must not be less than retry_backoff %s, got %s",
+			p.RetryBackoff, p.MaxRetryBackoff)
+	}
+	if p.QueueLen <= 0 {
+		addErr("queue_len", "must be positive, got %d", p.QueueLen)
+	}
+	if len(p.RecordFile) != 0 && len(p.Replay.File) != 0 {
+		addErr("record_file", "must not be set when replaying")
+	}
+	if p.Pending.Enabled {
+		if p.Pending.Interval <= 0 {
+			addErr("pending.interval", "must be positive, got %s", p.Pending.Interval)
+		}
+		if p.Pending.BatchSize <= 0 {
+			addErr("pending.batch_size", "must be positive, got %d", p.Pending.BatchSize)
+		}
+	}
+
+	return errs
+}
+
+// Marshal returns the configuration in YAML format
+func (c *Config) Marshal() ([]byte, error) {
+	return yaml.Marshal(c)
+}
@@ -0,0 +1,411 @@
+package config
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"eth-parser/auth"
+	"eth-parser/poller"
+	"eth-parser/prices"
+	"eth-parser/sinks"
+	"eth-parser/storages"
+)
+
+func TestLoad(t *testing.T) {
+	testCases := []struct {
+		name string
+		file string
+		env  map[string]string
+		args []string
+		// check checks the loaded configuration if loading succeeds
+		check func(t *testing.T, c *Config)
+		// expectedErr is the part of the error loading fails with if it is not
+		// empty
+		expectedErr string
+	}{
+		{
+			name: "defaults",
+			check: func(t *testing.T, c *Config) {
+				if c.Poller.NumRetries != defaultPollerNumRetries {
+					t.Errorf("got num_retries %d, want default", c.Poller.NumRetries)
+				}
+				if pollers := c.ChainPollers(); len(pollers) != 1 ||
+					pollers[0].Endpoint != defaultPollerEndpoint {
+					t.Errorf("got chain pollers %+v, want default poller", pollers)
+				}
+			},
+		},
+		{
+			name: "file",
+			file: "poller:\n  num_retries: 5\n  timeout: 2s\nstorage:\n  backend: redis\n",
+			check: func(t *testing.T, c *Config) {
+				if c.Poller.NumRetries != 5 || c.Poller.Timeout != 2*time.Second {
+					t.Errorf("got num_retries %d and timeout %s, want 5 and 2s",
+						c.Poller.NumRetries, c.Poller.Timeout)
+				}
+				if c.Storage.Backend != storages.BackendRedis {
+					t.Errorf("got backend %s, want redis", c.Storage.Backend)
+				}
+				// fields not set in the file keep defaults
+				if c.Poller.QueueLen != defaultPollerQueueLen {
+					t.Errorf("got queue_len %d, want default", c.Poller.QueueLen)
+				}
+			},
+		},
+		{
+			name: "json file",
+			file: `{"poller": {"num_retries": 5}}`,
+			check: func(t *testing.T, c *Config) {
+				if c.Poller.NumRetries != 5 {
+					t.Errorf("got num_retries %d, want 5", c.Poller.NumRetries)
+				}
+			},
+		},
+		{
+			name:        "unknown field",
+			file:        "poller:\n  num_retry: 5\n",
+			expectedErr: "field num_retry not found",
+		},
+		{
+			name:        "unknown section",
+			file:        "pollers:\n  num_retries: 5\n",
+			expectedErr: "field pollers not found",
+		},
+		{
+			name: "env overrides file",
+			file: "poller:\n  num_retries: 5\n  endpoint: http://file\n",
+			env: map[string]string{
+				"ETH_PARSER_POLLER_NUM_RETRIES":     "7",
+				"ETH_PARSER_POLLER_PENDING_ENABLED": "true",
+				"ETH_PARSER_STORAGE_POSTGRES_DSN":   "postgres://env",
+				"ETH_PARSER_PRICES_HTTP_TIMEOUT":    "3s",
+			},
+			check: func(t *testing.T, c *Config) {
+				if c.Poller.NumRetries != 7 || !c.Poller.Pending.Enabled {
+					t.Errorf("got num_retries %d and pending %t, want 7 and true",
+						c.Poller.NumRetries, c.Poller.Pending.Enabled)
+				}
+				// values not set in env are kept
+				if c.Poller.Endpoint != "http://file" {
+					t.Errorf("got endpoint %s, want one of the file", c.Poller.Endpoint)
+				}
+				if c.Storage.Postgres.DSN != "postgres://env" {
+					t.Errorf("got dsn %s, want one of env", c.Storage.Postgres.DSN)
+				}
+				if c.Prices.HTTP.Timeout != 3*time.Second {
+					t.Errorf("got prices timeout %s, want 3s", c.Prices.HTTP.Timeout)
+				}
+			},
+		},
+		{
+			name: "flags override env",
+			file: "poller:\n  num_retries: 5\n",
+			env:  map[string]string{"ETH_PARSER_POLLER_NUM_RETRIES": "7"},
+			args: []string{"-poller.num_retries", "9"},
+			check: func(t *testing.T, c *Config) {
+				if c.Poller.NumRetries != 9 {
+					t.Errorf("got num_retries %d, want 9", c.Poller.NumRetries)
+				}
+			},
+		},
+		{
+			name:        "invalid env",
+			env:         map[string]string{"ETH_PARSER_POLLER_NUM_RETRIES": "many"},
+			expectedErr: "ETH_PARSER_POLLER_NUM_RETRIES='many'",
+		},
+		{
+			name: "chains inherit poller",
+			file: "poller:\n  timeout: 2s\n" +
+				"chains:\n  - chain_id: 10\n    endpoint: http://op\n  - chain_id: 1\n",
+			env: map[string]string{"ETH_PARSER_POLLER_NUM_RETRIES": "7"},
+			check: func(t *testing.T, c *Config) {
+				pollers := c.ChainPollers()
+				if len(pollers) != 2 {
+					t.Fatalf("got %d chain pollers, want 2", len(pollers))
+				}
+				if pollers[0].ChainID != 10 || pollers[0].Endpoint != "http://op" {
+					t.Errorf("got first chain %d at %s, want 10 at http://op",
+						pollers[0].ChainID, pollers[0].Endpoint)
+				}
+				if pollers[1].Endpoint != defaultPollerEndpoint {
+					t.Errorf("got endpoint %s of second chain, want default",
+						pollers[1].Endpoint)
+				}
+				for _, p := range pollers {
+					if p.Timeout != 2*time.Second || p.NumRetries != 7 {
+						t.Errorf("chain %d got timeout %s and num_retries %d, want 2s and 7",
+							p.ChainID, p.Timeout, p.NumRetries)
+					}
+				}
+			},
+		},
+		{
+			name:        "unknown chain field",
+			file:        "chains:\n  - chain_id: 10\n    endpont: http://op\n",
+			expectedErr: "field endpont not found",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var path string
+			if len(tc.file) != 0 {
+				path = filepath.Join(t.TempDir(), "config.yaml")
+				if err := os.WriteFile(path, []byte(tc.file), 0o600); err != nil {
+					t.Fatalf("could not write config: %v", err)
+				}
+			}
+
+			var c Config
+			fs := flag.NewFlagSet("test", flag.ContinueOnError)
+			c.RegisterFlags(fs)
+			if err := fs.Parse(tc.args); err != nil {
+				t.Fatalf("could not parse flags: %v", err)
+			}
+
+			err := c.Load(path, fs, func(name string) (string, bool) {
+				value, ok := tc.env[name]
+				return value, ok
+			})
+			if len(tc.expectedErr) != 0 {
+				if err == nil || !strings.Contains(err.Error(), tc.expectedErr) {
+					t.Errorf("got error %v, want '%s'", err, tc.expectedErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("could not load config: %v", err)
+			}
+
+			tc.check(t, &c)
+		})
+	}
+}
+
+// useRedis sets valid redis backend
+func useRedis(c *Config) {
+	c.Storage.Backend = storages.BackendRedis
+	c.Storage.Redis.Addr = "localhost:6379"
+}
+
+func TestValidate(t *testing.T) {
+	defaults := Default()
+	if err := defaults.Validate(); err != nil {
+		t.Fatalf("default config is not valid: %v", err)
+	}
+
+	testCases := []struct {
+		// field is the field the only error is expected for
+		field  string
+		modify func(c *Config)
+	}{
+		{"log.level", func(c *Config) { c.Log.Level = "verbose" }},
+		{"log.format", func(c *Config) { c.Log.Format = "xml" }},
+		{"server.addr", func(c *Config) { c.Server.Addr = "" }},
+
+		{"storage.reset", func(c *Config) {
+			useRedis(c)
+			c.Storage.Reset = true
+		}},
+		{"storage.postgres.dsn", func(c *Config) {
+			c.Storage.Backend = storages.BackendPostgres
+		}},
+		{"storage.postgres.max_conns", func(c *Config) {
+			c.Storage.Backend = storages.BackendPostgres
+			c.Storage.Postgres.DSN = "postgres://localhost"
+			c.Storage.Postgres.MaxConns = 0
+		}},
+		{"storage.postgres.timeout", func(c *Config) {
+			c.Storage.Backend = storages.BackendPostgres
+			c.Storage.Postgres.DSN = "postgres://localhost"
+			c.Storage.Postgres.Timeout = 0
+		}},
+		{"storage.redis.addr", func(c *Config) {
+			useRedis(c)
+			c.Storage.Redis.Addr = "localhost"
+		}},
+		{"storage.redis.db", func(c *Config) {
+			useRedis(c)
+			c.Storage.Redis.DB = -1
+		}},
+		{"storage.redis.prefix", func(c *Config) {
+			useRedis(c)
+			c.Storage.Redis.Prefix = ""
+		}},
+		{"storage.redis.timeout", func(c *Config) {
+			useRedis(c)
+			c.Storage.Redis.Timeout = 0
+		}},
+		{"storage.backend", func(c *Config) { c.Storage.Backend = "mysql" }},
+		{"storage.bloom_filter.false_positive_rate", func(c *Config) {
+			c.Storage.BloomFilter.ExpectedAddresses = 1000
+			c.Storage.BloomFilter.FalsePositiveRate = 1
+		}},
+
+		{"poller.chain_id", func(c *Config) { c.Poller.ChainID = 0 }},
+		{"poller.endpoint", func(c *Config) { c.Poller.Endpoint = "ws://localhost" }},
+		{"poller.interval", func(c *Config) { c.Poller.PollInterval = 0 }},
+		{"poller.head_refresh_interval", func(c *Config) { c.Poller.HeadRefreshInterval = -1 }},
+		{"poller.timeout", func(c *Config) { c.Poller.Timeout = 0 }},
+		{"poller.max_idle_conns", func(c *Config) { c.Poller.MaxIdleConns = -1 }},
+		{"poller.max_conns_per_host", func(c *Config) { c.Poller.MaxConnsPerHost = -1 }},
+		{"poller.max_idle_conns_per_host", func(c *Config) { c.Poller.MaxIdleConnsPerHost = -1 }},
+		{"poller.num_retries", func(c *Config) { c.Poller.NumRetries = 0 }},
+		{"poller.retry_backoff", func(c *Config) { c.Poller.RetryBackoff = -1 }},
+		{"poller.max_retry_backoff", func(c *Config) { c.Poller.MaxRetryBackoff = time.Millisecond }},
+		{"poller.queue_len", func(c *Config) { c.Poller.QueueLen = 0 }},
+		{"poller.record_file", func(c *Config) {
+			c.Poller.RecordFile = "rpc.jsonl"
+			c.Poller.Replay.File = "rpc.jsonl"
+		}},
+		{"poller.pending.interval", func(c *Config) {
+			c.Poller.Pending.Enabled = true
+			c.Poller.Pending.Interval = 0
+		}},
+		{"poller.pending.batch_size", func(c *Config) {
+			c.Poller.Pending.Enabled = true
+			c.Poller.Pending.BatchSize = 0
+		}},
+
+		{"chains[1].chain_id", func(c *Config) {
+			c.Chains = []poller.EthPollerConfig{c.Poller, c.Poller}
+		}},
+		{"chains[1].timeout", func(c *Config) {
+			c.Chains = []poller.EthPollerConfig{c.Poller, c.Poller}
+			c.Chains[1].ChainID = 10
+			c.Chains[1].Timeout = 0
+		}},
+		{"chains[1].record_file", func(c *Config) {
+			c.Poller.RecordFile = "rpc.jsonl"
+			c.Chains = []poller.EthPollerConfig{c.Poller, c.Poller}
+			c.Chains[1].ChainID = 10
+		}},
+
+		{"parser.pending_drop_timeout", func(c *Config) { c.Parser.PendingDropTimeout = 0 }},
+		{"parser.watch_timeout", func(c *Config) { c.Parser.WatchTimeout = -time.Second }},
+		{"parser.balances.reconcile_blocks", func(c *Config) {
+			c.Parser.Balances.ReconcileBlocks = -1
+		}},
+
+		{"prices.currencies[1]", func(c *Config) {
+			c.Prices.Currencies = []string{"usd", ""}
+			c.Prices.Source = prices.SourceHTTP
+		}},
+		{"prices.file", func(c *Config) {
+			c.Prices.Currencies = []string{"usd"}
+			c.Prices.Source = prices.SourceFile
+		}},
+		{"prices.http.url", func(c *Config) {
+			c.Prices.Currencies = []string{"usd"}
+			c.Prices.Source = prices.SourceHTTP
+			c.Prices.HTTP.URL = "localhost"
+		}},
+		{"prices.http.coin", func(c *Config) {
+			c.Prices.Currencies = []string{"usd"}
+			c.Prices.Source = prices.SourceHTTP
+			c.Prices.HTTP.Coin = ""
+		}},
+		{"prices.http.api_key_header", func(c *Config) {
+			c.Prices.Currencies = []string{"usd"}
+			c.Prices.Source = prices.SourceHTTP
+			c.Prices.HTTP.APIKey = "key"
+			c.Prices.HTTP.APIKeyHeader = ""
+		}},
+		{"prices.http.timeout", func(c *Config) {
+			c.Prices.Currencies = []string{"usd"}
+			c.Prices.Source = prices.SourceHTTP
+			c.Prices.HTTP.Timeout = 0
+		}},
+		{"prices.source", func(c *Config) {
+			c.Prices.Currencies = []string{"usd"}
+			c.Prices.Source = "exchange"
+		}},
+		{"prices.resolution", func(c *Config) {
+			c.Prices.Currencies = []string{"usd"}
+			c.Prices.Source = prices.SourceHTTP
+			c.Prices.Resolution = 0
+		}},
+		{"prices.failure_ttl", func(c *Config) {
+			c.Prices.Currencies = []string{"usd"}
+			c.Prices.Source = prices.SourceHTTP
+			c.Prices.FailureTTL = -1
+		}},
+
+		{"sinks.kafka.brokers[0]", func(c *Config) { c.Sinks.Kafka.Brokers = []string{"kafka"} }},
+		{"sinks.kafka.timeout", func(c *Config) {
+			c.Sinks.Kafka.Brokers = []string{"kafka:9092"}
+			c.Sinks.Kafka.Timeout = 0
+		}},
+		{"sinks.kafka.topic", func(c *Config) {
+			c.Sinks.Kafka.Brokers = []string{"kafka:9092"}
+			c.Sinks.Kafka.Routing.Topic = ""
+		}},
+		{"sinks.nats.routes[0]", func(c *Config) {
+			c.Sinks.NATS.URL = "nats://localhost:4222"
+			c.Sinks.NATS.Routing.Routes = []sinks.Route{{Topic: "all"}}
+		}},
+		{"sinks.nats.routes[0].topic", func(c *Config) {
+			c.Sinks.NATS.URL = "nats://localhost:4222"
+			c.Sinks.NATS.Routing.Routes = []sinks.Route{{Tenant: "team-a"}}
+		}},
+		{"sinks.nats.timeout", func(c *Config) {
+			c.Sinks.NATS.URL = "nats://localhost:4222"
+			c.Sinks.NATS.Timeout = 0
+		}},
+		{"sinks.redis.max_len", func(c *Config) {
+			c.Sinks.Redis.Addr = "localhost:6379"
+			c.Sinks.Redis.MaxLen = -1
+		}},
+		{"sinks.redis.topic", func(c *Config) {
+			c.Sinks.Redis.Addr = "localhost:6379"
+			c.Sinks.Redis.Routing.Topic = ""
+		}},
+
+		{"health.max_block_age", func(c *Config) { c.Health.MaxBlockAge = -1 }},
+		{"health.max_block_lag", func(c *Config) { c.Health.MaxBlockLag = -1 }},
+
+		{"auth.api_keys[0].key", func(c *Config) {
+			c.Auth.APIKeys = []auth.APIKeyConfig{{Tenant: "team-a"}}
+		}},
+		{"auth.api_keys[1].key", func(c *Config) {
+			c.Auth.APIKeys = []auth.APIKeyConfig{
+				{Key: "key", Tenant: "team-a"},
+				{Key: "key", Tenant: "team-b"},
+			}
+		}},
+		{"auth.api_keys[0].tenant", func(c *Config) {
+			c.Auth.APIKeys = []auth.APIKeyConfig{{Key: "key"}}
+		}},
+		{"auth.api_keys[0].tenant", func(c *Config) {
+			c.Auth.APIKeys = []auth.APIKeyConfig{{Key: "key", Tenant: "jwt-team-a"}}
+		}},
+		{"auth.api_keys[0].max_subscriptions", func(c *Config) {
+			c.Auth.APIKeys = []auth.APIKeyConfig{{Key: "key", Tenant: "team-a", MaxSubscriptions: -1}}
+		}},
+		{"auth.jwt.tenant_claim", func(c *Config) {
+			c.Auth.JWT.Secret = "secret"
+			c.Auth.JWT.TenantClaim = ""
+		}},
+		{"auth.jwt.max_subscriptions", func(c *Config) { c.Auth.JWT.MaxSubscriptions = -1 }},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.field, func(t *testing.T) {
+			c := Default()
+			tc.modify(&c)
+
+			err := c.Validate()
+			if err == nil {
+				t.Fatalf("expected error of %s", tc.field)
+			}
+			errs := strings.Split(err.Error(), "\n")
+			if len(errs) != 1 || !strings.HasPrefix(errs[0], tc.field+": ") {
+				t.Errorf("got errors %q, want the only one of %s", errs, tc.field)
+			}
+		})
+	}
+}
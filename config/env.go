@@ -0,0 +1,71 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+const envPrefix = "ETH_PARSER"
+
+// applyEnv overrides configuration fields with environment variables named
+// after the paths of the fields in the configuration file, e.g.
+// ETH_PARSER_POLLER_NUM_RETRIES for poller.num_retries
+func applyEnv(c *Config, lookupEnv func(string) (string, bool)) error {
+	return applyEnvToStruct(reflect.ValueOf(c).Elem(), envPrefix, lookupEnv)
+}
+
+func applyEnvToStruct(
+	v reflect.Value,
+	prefix string,
+	lookupEnv func(string) (string, bool),
+) error {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		tag := strings.Split(t.Field(i).Tag.Get("yaml"), ",")[0]
+		field := v.Field(i)
+
+		// fields of inlined structs are named as the fields of the parent
+		if len(tag) == 0 && t.Field(i).Anonymous && field.Kind() == reflect.Struct {
+			if err := applyEnvToStruct(field, prefix, lookupEnv); err != nil {
+				return err
+			}
+			continue
+		}
+		if len(tag) == 0 || tag == "-" {
+			continue
+		}
+
+		name := prefix + "_" + strings.ToUpper(tag)
+
+		if field.Kind() == reflect.Struct {
+			if err := applyEnvToStruct(field, name, lookupEnv); err != nil {
+				return err
+			}
+			continue
+		}
+		if field.Kind() == reflect.Slice {
+			// lists are configured in the configuration file only
+			continue
+		}
+
+		raw, ok := lookupEnv(name)
+		if !ok {
+			continue
+		}
+
+		if field.Kind() == reflect.String {
+			field.SetString(raw)
+			continue
+		}
+
+		// values are decoded the same way as in the configuration file
+		if err := yaml.Unmarshal([]byte(raw), field.Addr().Interface()); err != nil {
+			return fmt.Errorf("could not parse %s='%s': %w", name, raw, err)
+		}
+	}
+
+	return nil
+}
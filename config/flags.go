@@ -0,0 +1,26 @@
+package config
+
+import "strings"
+
+// stringList is flag of comma separated list, which replaces the list of the
+// configuration file
+type stringList []string
+
+func (s *stringList) String() string {
+	if s == nil {
+		return ""
+	}
+
+	return strings.Join(*s, ",")
+}
+
+func (s *stringList) Set(value string) error {
+	*s = nil
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); len(item) != 0 {
+			*s = append(*s, item)
+		}
+	}
+
+	return nil
+}
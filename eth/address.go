@@ -0,0 +1,33 @@
+package eth
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+const addressLen = 20
+
+var ErrInvalidAddress = fmt.Errorf("invalid address")
+
+// NormalizeAddress validates hex encoded address and returns it in lower
+// case, the way addresses are returned by nodes
+func NormalizeAddress(address string) (string, error) {
+	address = strings.TrimSpace(address)
+
+	hexAddress, ok := strings.CutPrefix(address, "0x")
+	if !ok {
+		hexAddress, ok = strings.CutPrefix(address, "0X")
+	}
+	if !ok {
+		return "", fmt.Errorf("%w '%s': must start with 0x", ErrInvalidAddress, address)
+	}
+
+	decoded, err := hex.DecodeString(hexAddress)
+	if err != nil || len(decoded) != addressLen {
+		return "", fmt.Errorf("%w '%s': must be %d hex encoded bytes",
+			ErrInvalidAddress, address, addressLen)
+	}
+
+	return "0x" + strings.ToLower(hexAddress), nil
+}
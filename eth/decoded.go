@@ -0,0 +1,30 @@
+package eth
+
+// Sources of decoded calls and events
+const (
+	// DecodedSourceContract is ABI uploaded for the contract
+	DecodedSourceContract = "contract"
+	// DecodedSourceStandard is ABI of a common standard, e.g. ERC-20
+	DecodedSourceStandard = "standard"
+	// DecodedSourceSelector is text signature known by 4-byte selector, the
+	// arguments have no names
+	DecodedSourceSelector = "selector"
+)
+
+// Decoded is method call of transaction or event of log decoded with ABI
+type Decoded struct {
+	Name      string       `json:"name"`
+	Signature string       `json:"signature"`
+	Args      []DecodedArg `json:"args"`
+	Source    string       `json:"source"`
+}
+
+// DecodedArg is decoded argument. Integers are decimal strings, addresses and
+// bytes are hex encoded, arrays are lists and tuples are objects if all their
+// components are named and lists otherwise. Indexed arguments of dynamic
+// types are hashes.
+type DecodedArg struct {
+	Name  string      `json:"name,omitempty"`
+	Type  string      `json:"type"`
+	Value interface{} `json:"value"`
+}
@@ -0,0 +1,125 @@
+package eth
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+const (
+	maxTopics = 4
+	topicLen  = 32
+	// filterIDLen is the length of log filter ID in bytes
+	filterIDLen = 8
+)
+
+var ErrInvalidLogFilter = fmt.Errorf("invalid log filter")
+
+type Log struct {
+	Address          string   `json:"address"`
+	Topics           []string `json:"topics"`
+	Data             string   `json:"data"`
+	BlockNumber      string   `json:"blockNumber"`
+	BlockHash        string   `json:"blockHash"`
+	TransactionHash  string   `json:"transactionHash"`
+	TransactionIndex string   `json:"transactionIndex"`
+	LogIndex         string   `json:"logIndex"`
+	Removed          bool     `json:"removed"`
+}
+
+// MatchedLog is a log matched by log filter with its transaction
+type MatchedLog struct {
+	Log
+	Transaction *Transaction `json:"transaction,omitempty"`
+	// Decoded is the event decoded from the log if it is known
+	Decoded *Decoded `json:"decoded,omitempty"`
+}
+
+// LogFilter matches logs emitted by contract with given topics. Every
+// position of Topics lists alternatives of the topic at that position, empty
+// position matches any topic.
+type LogFilter struct {
+	// ID identifies the filter, it is derived from the address and topics
+	ID string `json:"id,omitempty"`
+
+	Address string     `json:"address"`
+	Topics  [][]string `json:"topics,omitempty"`
+}
+
+// Normalize validates the filter and returns it with addresses and topics in
+// lower case and ID set
+func (f LogFilter) Normalize() (LogFilter, error) {
+	address, err := NormalizeAddress(f.Address)
+	if err != nil {
+		return LogFilter{}, fmt.Errorf("%w: %w", ErrInvalidLogFilter, err)
+	}
+	if len(f.Topics) > maxTopics {
+		return LogFilter{}, fmt.Errorf("%w: at most %d topics are allowed, got %d",
+			ErrInvalidLogFilter, maxTopics, len(f.Topics))
+	}
+
+	normalized := LogFilter{
+		Address: address,
+		Topics:  make([][]string, len(f.Topics)),
+	}
+	for i, alternatives := range f.Topics {
+		for _, topic := range alternatives {
+			decoded, err := hex.DecodeString(strings.TrimPrefix(topic, "0x"))
+			if err != nil || len(decoded) != topicLen || !strings.HasPrefix(topic, "0x") {
+				return LogFilter{}, fmt.Errorf("%w: topic '%s' must be %d hex encoded bytes",
+					ErrInvalidLogFilter, topic, topicLen)
+			}
+
+			normalized.Topics[i] = append(normalized.Topics[i], "0x"+hex.EncodeToString(decoded))
+		}
+	}
+
+	// trailing positions matching any topic do not change the filter
+	for len(normalized.Topics) > 0 && len(normalized.Topics[len(normalized.Topics)-1]) == 0 {
+		normalized.Topics = normalized.Topics[:len(normalized.Topics)-1]
+	}
+
+	normalized.ID = normalized.id()
+	return normalized, nil
+}
+
+func (f *LogFilter) id() string {
+	hash := sha256.New()
+	hash.Write([]byte(f.Address))
+	for _, alternatives := range f.Topics {
+		hash.Write([]byte{'/'})
+		hash.Write([]byte(strings.Join(alternatives, ",")))
+	}
+
+	return hex.EncodeToString(hash.Sum(nil)[:filterIDLen])
+}
+
+// Matches tells whether log is matched by the normalized filter
+func (f *LogFilter) Matches(log *Log) bool {
+	if log.Address != f.Address {
+		return false
+	}
+
+	for i, alternatives := range f.Topics {
+		if len(alternatives) == 0 {
+			continue
+		}
+		if i >= len(log.Topics) {
+			return false
+		}
+
+		matched := false
+		for _, topic := range alternatives {
+			if topic == log.Topics[i] {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+
+	return true
+}
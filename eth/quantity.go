@@ -0,0 +1,33 @@
+package eth
+
+import (
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+// ParseBigQuantity parses hex encoded quantity, empty quantity is zero as well
+// as empty result of eth_call
+func ParseBigQuantity(quantity string) (*big.Int, error) {
+	if len(quantity) == 0 || quantity == "0x" {
+		return new(big.Int), nil
+	}
+
+	value, ok := new(big.Int).SetString(strings.TrimPrefix(quantity, "0x"), 16)
+	if !ok || !strings.HasPrefix(quantity, "0x") {
+		return nil, fmt.Errorf("invalid quantity '%s'", quantity)
+	}
+
+	return value, nil
+}
+
+// ErrNoBalance is returned for holding which has no balance on the chain, e.g.
+// if balanceOf call to the token reverts as it is not ERC-20 contract
+var ErrNoBalance = fmt.Errorf("no balance")
+
+// Holding identifies balance of address, Token is the address of ERC-20
+// token or empty for ETH
+type Holding struct {
+	Address string
+	Token   string
+}
@@ -1,12 +1,76 @@
 package eth
 
+// statuses of transactions
+const (
+	StatusPending  = "pending"
+	StatusMined    = "mined"
+	StatusDropped  = "dropped"
+	StatusReplaced = "replaced"
+)
+
 type Block struct {
-	Number       string        `json:"number"`
+	Number     string `json:"number"`
+	Hash       string `json:"hash"`
+	ParentHash string `json:"parentHash,omitempty"`
+	// Timestamp is hex encoded unix time of the block
+	Timestamp    string        `json:"timestamp,omitempty"`
 	Transactions []Transaction `json:"transactions"`
+	Withdrawals  []Withdrawal  `json:"withdrawals,omitempty"`
+}
+
+// Withdrawal is withdrawal of validator stake, Amount is in Gwei
+type Withdrawal struct {
+	Address string `json:"address"`
+	Amount  string `json:"amount"`
 }
 
 type Transaction struct {
 	Hash string `json:"hash"`
 	From string `json:"from"`
-	To   string `json:"to"`
+	// To is empty if transaction creates contract
+	To    string `json:"to"`
+	Nonce string `json:"nonce,omitempty"`
+	Value string `json:"value,omitempty"`
+	Input string `json:"input,omitempty"`
+
+	// Status is one of the statuses of transactions, it is set by the source
+	// of the transaction
+	Status string `json:"status,omitempty"`
+
+	// BlockNumber is hex encoded number of the block of mined transaction
+	BlockNumber string `json:"blockNumber,omitempty"`
+	// Timestamp is hex encoded unix time of the block of mined transaction,
+	// it is set by the parser from the block
+	Timestamp string `json:"timestamp,omitempty"`
+
+	// ContractAddress is the address of the contract created by transaction,
+	// it is set by the parser from the receipt for subscribed deployers
+	ContractAddress string `json:"contractAddress,omitempty"`
+
+	// Decoded is the method call decoded from input, it is set by the parser
+	// for stored transactions if the method is known
+	Decoded *Decoded `json:"decoded,omitempty"`
+
+	// Fiat maps lower case codes of fiat currencies to the value of mined
+	// transaction in them at the time of its block, it is set by the parser
+	// for stored transactions if prices are configured
+	Fiat map[string]string `json:"fiat,omitempty"`
+}
+
+// SubscribedTransaction is transaction stored for address subscribed by tenant
+type SubscribedTransaction struct {
+	Tenant      string
+	Address     string
+	Transaction Transaction
+}
+
+// ReceiptStatusSuccess is status of receipt of successful transaction
+const ReceiptStatusSuccess = "0x1"
+
+type Receipt struct {
+	TransactionHash   string `json:"transactionHash"`
+	ContractAddress   string `json:"contractAddress"`
+	Status            string `json:"status"`
+	GasUsed           string `json:"gasUsed"`
+	EffectiveGasPrice string `json:"effectiveGasPrice"`
 }
@@ -0,0 +1,37 @@
+package eth
+
+import (
+	"math/big"
+	"strings"
+)
+
+// EtherDecimals is the number of decimals of ETH in wei
+const EtherDecimals = 18
+
+// FormatEther formats amount in wei as decimal ETH without trailing zeros,
+// e.g. 1500000000000000000 as 1.5
+func FormatEther(wei *big.Int) string {
+	return FormatUnits(wei, EtherDecimals)
+}
+
+// FormatUnits formats amount in the smallest units as decimal with given
+// number of decimals without trailing zeros
+func FormatUnits(amount *big.Int, decimals int) string {
+	digits := new(big.Int).Abs(amount).String()
+	sign := ""
+	if amount.Sign() < 0 {
+		sign = "-"
+	}
+
+	if len(digits) <= decimals {
+		digits = strings.Repeat("0", decimals-len(digits)+1) + digits
+	}
+
+	integer, fraction := digits[:len(digits)-decimals], digits[len(digits)-decimals:]
+	fraction = strings.TrimRight(fraction, "0")
+	if len(fraction) == 0 {
+		return sign + integer
+	}
+
+	return sign + integer + "." + fraction
+}
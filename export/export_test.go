@@ -0,0 +1,374 @@
+package export
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math/big"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"eth-parser/eth"
+)
+
+func testRows(t *testing.T) []*Row {
+	t.Helper()
+
+	transactions := []struct {
+		address     string
+		transaction eth.Transaction
+	}{
+		{
+			address: "0xa1",
+			transaction: eth.Transaction{
+				Hash:        "0x01",
+				From:        "0xb1",
+				To:          "0xa1",
+				Value:       "0x14d1120d7b160000",
+				Status:      eth.StatusMined,
+				BlockNumber: "0x10",
+				Timestamp:   "0x6553f100",
+				Fiat:        map[string]string{"usd": "3000.00"},
+			},
+		},
+		{
+			address: "0xa1",
+			transaction: eth.Transaction{
+				Hash:        "0x02",
+				From:        "0xa1",
+				To:          "0xa1",
+				Value:       "0x1",
+				Status:      eth.StatusMined,
+				BlockNumber: "0x11",
+			},
+		},
+		{
+			address: "0xa2",
+			transaction: eth.Transaction{
+				Hash:            "0x03",
+				From:            "0xa2",
+				Status:          eth.StatusMined,
+				BlockNumber:     "0x12",
+				Timestamp:       "0x6553f118",
+				ContractAddress: "0xc1",
+			},
+		},
+	}
+
+	var rows []*Row
+	for _, tc := range transactions {
+		row, err := NewRow(tc.address, &tc.transaction)
+		if err != nil {
+			t.Fatalf("could not create row: %v", err)
+		}
+		rows = append(rows, row)
+	}
+
+	return rows
+}
+
+func TestNewRow(t *testing.T) {
+	rows := testRows(t)
+
+	expected := [][]string{
+		{"0xa1", "in", "16", "2023-11-14T22:13:20Z", "0x01", "0xb1", "0xa1",
+			"1500000000000000000", "1.5", "mined", ""},
+		{"0xa1", "self", "17", "", "0x02", "0xa1", "0xa1",
+			"1", "0.000000000000000001", "mined", ""},
+		{"0xa2", "out", "18", "2023-11-14T22:13:44Z", "0x03", "0xa2", "",
+			"0", "0", "mined", "0xc1"},
+	}
+	for i, row := range rows {
+		if values := row.strings(nil); !reflect.DeepEqual(values, expected[i]) {
+			t.Errorf("row %d is not equal:\nhave: %q\nwant: %q", i, values, expected[i])
+		}
+	}
+}
+
+func TestCSVAndJSONL(t *testing.T) {
+	testCases := []struct {
+		format   string
+		expected string
+	}{
+		{
+			format: FormatCSV,
+			expected: "address,direction,block_number,timestamp,hash,from,to," +
+				"value_wei,value_eth,status,contract_address,value_usd,value_eur\n" +
+				"0xa1,in,16,2023-11-14T22:13:20Z,0x01,0xb1,0xa1," +
+				"1500000000000000000,1.5,mined,,3000.00,\n",
+		},
+		{
+			format: FormatJSONL,
+			expected: `{"address":"0xa1","direction":"in","block_number":16,` +
+				`"timestamp":"2023-11-14T22:13:20Z","hash":"0x01","from":"0xb1","to":"0xa1",` +
+				`"value_wei":"1500000000000000000","value_eth":"1.5","status":"mined",` +
+				`"fiat":{"usd":"3000.00"}}` + "\n",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.format, func(t *testing.T) {
+			output := &bytes.Buffer{}
+			writer, err := NewWriter(output, tc.format, []string{"usd", "eur"})
+			if err != nil {
+				t.Fatalf("could not create writer: %v", err)
+			}
+			if err := writer.Write(testRows(t)[0]); err != nil {
+				t.Fatalf("could not write row: %v", err)
+			}
+			if err := writer.Close(); err != nil {
+				t.Fatalf("could not close writer: %v", err)
+			}
+
+			if output.String() != tc.expected {
+				t.Errorf("output is not equal:\nhave: %s\nwant: %s", output, tc.expected)
+			}
+		})
+	}
+}
+
+func TestParquet(t *testing.T) {
+	rows := testRows(t)
+	currencies := []string{"usd", "eur"}
+	allColumns := append(append([]string{}, columns...), fiatColumns(currencies)...)
+
+	output := &bytes.Buffer{}
+	// row groups of two rows check that rows are split into groups
+	writer, err := newParquetWriter(output, currencies, 2)
+	if err != nil {
+		t.Fatalf("could not create writer: %v", err)
+	}
+	for _, row := range rows {
+		if err := writer.Write(row); err != nil {
+			t.Fatalf("could not write row: %v", err)
+		}
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatalf("could not close writer: %v", err)
+	}
+
+	file := output.Bytes()
+	if !bytes.HasPrefix(file, []byte(parquetMagic)) || !bytes.HasSuffix(file, []byte(parquetMagic)) {
+		t.Fatalf("file is not framed by magic")
+	}
+	footerLen := int(binary.LittleEndian.Uint32(file[len(file)-8:]))
+	footer, err := readThriftStruct(bytes.NewReader(file[len(file)-8-footerLen : len(file)-8]))
+	if err != nil {
+		t.Fatalf("could not read footer: %v", err)
+	}
+
+	if numRows := footer[3]; numRows != int64(len(rows)) {
+		t.Errorf("got %v rows, want %d", numRows, len(rows))
+	}
+
+	var names []string
+	for _, element := range footer[2].([]interface{})[1:] {
+		names = append(names, element.(map[int16]interface{})[4].(string))
+	}
+	if !reflect.DeepEqual(names, allColumns) {
+		t.Errorf("schema is not equal:\nhave: %v\nwant: %v", names, allColumns)
+	}
+
+	// values of every column are read from data pages of all row groups,
+	// timestamp and fiat values are optional
+	read := make([][]interface{}, len(allColumns))
+	for _, rowGroup := range footer[4].([]interface{}) {
+		for i, chunk := range rowGroup.(map[int16]interface{})[1].([]interface{}) {
+			metaData := chunk.(map[int16]interface{})[3].(map[int16]interface{})
+			values, err := readParquetPage(file, metaData, i == 3 || i >= len(columns))
+			if err != nil {
+				t.Fatalf("could not read column %s: %v", allColumns[i], err)
+			}
+			read[i] = append(read[i], values...)
+		}
+	}
+
+	var readRows [][]string
+	for i := range rows {
+		timestamp := ""
+		if millis, ok := read[3][i].(int64); ok {
+			timestamp = time.UnixMilli(millis).UTC().Format(time.RFC3339)
+		}
+
+		readRow := []string{fmt.Sprint(read[0][i]), fmt.Sprint(read[1][i]),
+			fmt.Sprint(read[2][i]), timestamp}
+		for _, value := range read[4:] {
+			if value[i] == nil {
+				readRow = append(readRow, "")
+				continue
+			}
+			readRow = append(readRow, fmt.Sprint(value[i]))
+		}
+		readRows = append(readRows, readRow)
+	}
+
+	var expectedRows [][]string
+	for _, row := range rows {
+		expectedRows = append(expectedRows, row.strings(currencies))
+	}
+	if !reflect.DeepEqual(readRows, expectedRows) {
+		t.Errorf("rows are not equal:\nhave: %q\nwant: %q", readRows, expectedRows)
+	}
+}
+
+func TestFormatEther(t *testing.T) {
+	testCases := map[string]string{
+		"0":                        "0",
+		"1":                        "0.000000000000000001",
+		"1000000000000000000":      "1",
+		"-1500000000000000000":     "-1.5",
+		"123456789000000000000000": "123456.789",
+	}
+
+	for wei, expected := range testCases {
+		value, _ := new(big.Int).SetString(wei, 10)
+		if formatted := eth.FormatEther(value); formatted != expected {
+			t.Errorf("got %s for %s wei, want %s", formatted, wei, expected)
+		}
+	}
+}
+
+// readParquetPage reads values of the single data page of the column chunk
+func readParquetPage(
+	file []byte,
+	metaData map[int16]interface{},
+	optional bool,
+) ([]interface{}, error) {
+	offset := metaData[9].(int64)
+	reader := bytes.NewReader(file[offset:])
+	header, err := readThriftStruct(reader)
+	if err != nil {
+		return nil, fmt.Errorf("could not read page header: %w", err)
+	}
+
+	compressed := make([]byte, header[3].(int64))
+	if _, err := io.ReadFull(reader, compressed); err != nil {
+		return nil, err
+	}
+	gzipReader, err := gzip.NewReader(bytes.NewReader(compressed))
+	if err != nil {
+		return nil, err
+	}
+	page, err := io.ReadAll(gzipReader)
+	if err != nil {
+		return nil, err
+	}
+
+	numValues := int(header[5].(map[int16]interface{})[1].(int64))
+	levels := make([]byte, 0, numValues)
+	if optional {
+		levelsLen := binary.LittleEndian.Uint32(page)
+		runs := bytes.NewReader(page[4 : 4+levelsLen])
+		for runs.Len() != 0 {
+			runHeader, _ := binary.ReadUvarint(runs)
+			level, _ := runs.ReadByte()
+			levels = append(levels, bytes.Repeat([]byte{level}, int(runHeader>>1))...)
+		}
+		page = page[4+levelsLen:]
+	}
+
+	values := make([]interface{}, numValues)
+	for i := range values {
+		if optional && levels[i] == 0 {
+			continue
+		}
+
+		if metaData[1].(int64) == parquetTypeInt64 {
+			values[i] = int64(binary.LittleEndian.Uint64(page))
+			page = page[8:]
+			continue
+		}
+		length := binary.LittleEndian.Uint32(page)
+		values[i] = string(page[4 : 4+length])
+		page = page[4+length:]
+	}
+
+	return values, nil
+}
+
+// readThriftStruct reads struct encoded with Thrift compact protocol as map
+// of field IDs to values, integers are read as int64, lists as slices
+func readThriftStruct(reader *bytes.Reader) (map[int16]interface{}, error) {
+	fields := make(map[int16]interface{})
+	var lastID int16
+	for {
+		header, err := reader.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+		if header == 0 {
+			return fields, nil
+		}
+
+		fieldType := header & 0x0f
+		if delta := int16(header >> 4); delta != 0 {
+			lastID += delta
+		} else {
+			id, err := binary.ReadUvarint(reader)
+			if err != nil {
+				return nil, err
+			}
+			lastID = int16(unzigzag(id))
+		}
+
+		value, err := readThriftValue(reader, fieldType)
+		if err != nil {
+			return nil, err
+		}
+		fields[lastID] = value
+	}
+}
+
+func readThriftValue(reader *bytes.Reader, valueType byte) (interface{}, error) {
+	switch valueType {
+	case thriftI32, thriftI64:
+		value, err := binary.ReadUvarint(reader)
+		return unzigzag(value), err
+	case thriftBinary:
+		length, err := binary.ReadUvarint(reader)
+		if err != nil {
+			return nil, err
+		}
+		value := make([]byte, length)
+		_, err = io.ReadFull(reader, value)
+		return string(value), err
+	case thriftStruct:
+		return readThriftStruct(reader)
+	case thriftList:
+		header, err := reader.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+		size := uint64(header >> 4)
+		if size == 15 {
+			if size, err = binary.ReadUvarint(reader); err != nil {
+				return nil, err
+			}
+		}
+
+		list := make([]interface{}, size)
+		for i := range list {
+			if list[i], err = readThriftValue(reader, header&0x0f); err != nil {
+				return nil, err
+			}
+		}
+		return list, nil
+	}
+
+	return nil, fmt.Errorf("unsupported type %d", valueType)
+}
+
+func unzigzag(value uint64) int64 {
+	return int64(value>>1) ^ -int64(value&1)
+}
+
+func TestUnknownFormat(t *testing.T) {
+	_, err := NewWriter(io.Discard, "xlsx", nil)
+	if err == nil || !strings.Contains(err.Error(), "xlsx") {
+		t.Errorf("got error %v, want unknown format", err)
+	}
+}
@@ -0,0 +1,372 @@
+package export
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"fmt"
+	"io"
+
+	"eth-parser/eth"
+)
+
+// defaultRowGroupSize is the number of rows buffered in memory before they
+// are written as a row group
+const defaultRowGroupSize = 64 * 1024
+
+// values of Parquet enums, see parquet.thrift of parquet-format
+const (
+	parquetMagic = "PAR1"
+
+	parquetTypeInt64     = 2
+	parquetTypeByteArray = 6
+
+	parquetRepetitionRequired = 0
+	parquetRepetitionOptional = 1
+
+	parquetConvertedUTF8            = 0
+	parquetConvertedTimestampMillis = 9
+
+	parquetEncodingPlain = 0
+	parquetEncodingRLE   = 3
+
+	parquetCodecGzip = 2
+
+	parquetPageData = 0
+
+	// parquetCreatedBy is recorded as the writer of files
+	parquetCreatedBy = "eth-parser"
+)
+
+// parquetWriter writes rows as Parquet file. Rows are buffered by columns up
+// to the row group size, every column chunk of the group is written as a
+// single gzip compressed data page with plain encoding. Only footer with
+// metadata of the row groups is kept until Close.
+type parquetWriter struct {
+	writer     *countingWriter
+	currencies []string
+
+	columns      []*parquetColumn
+	rows         int
+	rowGroupSize int
+	rowGroups    []*parquetRowGroup
+	totalRows    int64
+}
+
+// parquetColumn is the column of flat schema with values of the current row
+// group
+type parquetColumn struct {
+	name          string
+	physicalType  int32
+	convertedType int32
+	optional      bool
+
+	// values are plain encoded non-null values
+	values bytes.Buffer
+	// definitionLevels are levels of optional column, 0 for null and 1 for
+	// non-null values
+	definitionLevels []byte
+}
+
+type parquetRowGroup struct {
+	chunks    []*parquetColumnChunk
+	numRows   int64
+	totalSize int64
+}
+
+type parquetColumnChunk struct {
+	column           *parquetColumn
+	offset           int64
+	uncompressedSize int64
+	compressedSize   int64
+	numValues        int64
+}
+
+// countingWriter counts written bytes, which are offsets of the written
+// parts of the file
+type countingWriter struct {
+	writer io.Writer
+	offset int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.writer.Write(p)
+	c.offset += int64(n)
+	return n, err
+}
+
+func newParquetWriter(
+	w io.Writer,
+	currencies []string,
+	rowGroupSize int,
+) (*parquetWriter, error) {
+	p := &parquetWriter{
+		writer:       &countingWriter{writer: w},
+		currencies:   currencies,
+		rowGroupSize: rowGroupSize,
+	}
+	for _, name := range columns {
+		column := &parquetColumn{
+			name:          name,
+			physicalType:  parquetTypeByteArray,
+			convertedType: parquetConvertedUTF8,
+		}
+		switch name {
+		case "block_number":
+			column.physicalType, column.convertedType = parquetTypeInt64, -1
+		case "timestamp":
+			column.physicalType = parquetTypeInt64
+			column.convertedType = parquetConvertedTimestampMillis
+			column.optional = true
+		}
+		p.columns = append(p.columns, column)
+	}
+	// fiat values are null if there is no price
+	for _, name := range fiatColumns(currencies) {
+		p.columns = append(p.columns, &parquetColumn{
+			name:          name,
+			physicalType:  parquetTypeByteArray,
+			convertedType: parquetConvertedUTF8,
+			optional:      true,
+		})
+	}
+
+	if _, err := io.WriteString(p.writer, parquetMagic); err != nil {
+		return nil, fmt.Errorf("could not write header: %w", err)
+	}
+
+	return p, nil
+}
+
+func (p *parquetWriter) Write(row *Row) error {
+	// values are in the order of the columns
+	values := []interface{}{
+		row.Address,
+		row.Direction,
+		row.BlockNumber,
+		nil,
+		row.Hash,
+		row.From,
+		row.To,
+		row.Value.String(),
+		eth.FormatEther(row.Value),
+		row.Status,
+		row.ContractAddress,
+	}
+	if !row.Timestamp.IsZero() {
+		values[3] = row.Timestamp.UnixMilli()
+	}
+	for _, currency := range p.currencies {
+		if value, ok := row.Fiat[currency]; ok {
+			values = append(values, value)
+		} else {
+			values = append(values, nil)
+		}
+	}
+
+	for i, column := range p.columns {
+		column.append(values[i])
+	}
+
+	p.rows++
+	if p.rows >= p.rowGroupSize {
+		return p.writeRowGroup()
+	}
+	return nil
+}
+
+// append appends plain encoded value, nil is null
+func (c *parquetColumn) append(value interface{}) {
+	if c.optional {
+		if value == nil {
+			c.definitionLevels = append(c.definitionLevels, 0)
+			return
+		}
+		c.definitionLevels = append(c.definitionLevels, 1)
+	}
+
+	switch value := value.(type) {
+	case int64:
+		c.values.Write(binary.LittleEndian.AppendUint64(nil, uint64(value)))
+	case string:
+		c.values.Write(binary.LittleEndian.AppendUint32(nil, uint32(len(value))))
+		c.values.WriteString(value)
+	}
+}
+
+// writeRowGroup writes buffered rows as row group and resets the columns
+func (p *parquetWriter) writeRowGroup() error {
+	rowGroup := &parquetRowGroup{numRows: int64(p.rows)}
+	for _, column := range p.columns {
+		chunk, err := p.writeColumnChunk(column)
+		if err != nil {
+			return fmt.Errorf("could not write column %s: %w", column.name, err)
+		}
+
+		rowGroup.chunks = append(rowGroup.chunks, chunk)
+		rowGroup.totalSize += chunk.uncompressedSize
+
+		column.values.Reset()
+		column.definitionLevels = column.definitionLevels[:0]
+	}
+
+	p.rowGroups = append(p.rowGroups, rowGroup)
+	p.totalRows += int64(p.rows)
+	p.rows = 0
+	return nil
+}
+
+// writeColumnChunk writes values of the column as a single data page
+func (p *parquetWriter) writeColumnChunk(column *parquetColumn) (*parquetColumnChunk, error) {
+	page := &bytes.Buffer{}
+	if column.optional {
+		levels := encodeLevels(column.definitionLevels)
+		page.Write(binary.LittleEndian.AppendUint32(nil, uint32(len(levels))))
+		page.Write(levels)
+	}
+	page.Write(column.values.Bytes())
+
+	compressed := &bytes.Buffer{}
+	gzipWriter := gzip.NewWriter(compressed)
+	if _, err := gzipWriter.Write(page.Bytes()); err != nil {
+		return nil, err
+	}
+	if err := gzipWriter.Close(); err != nil {
+		return nil, err
+	}
+
+	header := &thriftWriter{}
+	header.writeStruct(func() {
+		header.i32Field(1, parquetPageData)
+		header.i32Field(2, int32(page.Len()))
+		header.i32Field(3, int32(compressed.Len()))
+		header.structField(5, func() {
+			header.i32Field(1, int32(p.rows))
+			header.i32Field(2, parquetEncodingPlain)
+			header.i32Field(3, parquetEncodingRLE)
+			header.i32Field(4, parquetEncodingRLE)
+		})
+	})
+
+	chunk := &parquetColumnChunk{
+		column:           column,
+		offset:           p.writer.offset,
+		uncompressedSize: int64(header.buf.Len() + page.Len()),
+		compressedSize:   int64(header.buf.Len() + compressed.Len()),
+		numValues:        int64(p.rows),
+	}
+	if _, err := p.writer.Write(header.buf.Bytes()); err != nil {
+		return nil, err
+	}
+	if _, err := p.writer.Write(compressed.Bytes()); err != nil {
+		return nil, err
+	}
+
+	return chunk, nil
+}
+
+// encodeLevels encodes levels of bit width 1 as runs of RLE/bit-packing
+// hybrid encoding
+func encodeLevels(levels []byte) []byte {
+	var encoded []byte
+	for start := 0; start < len(levels); {
+		end := start + 1
+		for end < len(levels) && levels[end] == levels[start] {
+			end++
+		}
+
+		encoded = binary.AppendUvarint(encoded, uint64(end-start)<<1)
+		encoded = append(encoded, levels[start])
+		start = end
+	}
+
+	return encoded
+}
+
+// Close writes buffered rows and the footer
+func (p *parquetWriter) Close() error {
+	if p.rows != 0 {
+		if err := p.writeRowGroup(); err != nil {
+			return err
+		}
+	}
+
+	footer := &thriftWriter{}
+	footer.writeStruct(func() {
+		footer.i32Field(1, 1)
+
+		footer.listField(2, thriftStruct, len(p.columns)+1)
+		footer.writeStruct(func() {
+			footer.stringField(4, "schema")
+			footer.i32Field(5, int32(len(p.columns)))
+		})
+		for _, column := range p.columns {
+			footer.writeStruct(func() {
+				footer.i32Field(1, column.physicalType)
+				repetition := int32(parquetRepetitionRequired)
+				if column.optional {
+					repetition = parquetRepetitionOptional
+				}
+				footer.i32Field(3, repetition)
+				footer.stringField(4, column.name)
+				if column.convertedType >= 0 {
+					footer.i32Field(6, column.convertedType)
+				}
+			})
+		}
+
+		footer.i64Field(3, p.totalRows)
+
+		footer.listField(4, thriftStruct, len(p.rowGroups))
+		for _, rowGroup := range p.rowGroups {
+			footer.writeStruct(func() {
+				footer.listField(1, thriftStruct, len(rowGroup.chunks))
+				for _, chunk := range rowGroup.chunks {
+					footer.writeStruct(func() {
+						footer.i64Field(2, chunk.offset)
+						footer.structField(3, func() {
+							writeColumnMetaData(footer, chunk)
+						})
+					})
+				}
+				footer.i64Field(2, rowGroup.totalSize)
+				footer.i64Field(3, rowGroup.numRows)
+			})
+		}
+
+		footer.stringField(6, parquetCreatedBy)
+	})
+
+	if _, err := p.writer.Write(footer.buf.Bytes()); err != nil {
+		return fmt.Errorf("could not write footer: %w", err)
+	}
+	trailer := binary.LittleEndian.AppendUint32(nil, uint32(footer.buf.Len()))
+	trailer = append(trailer, parquetMagic...)
+	if _, err := p.writer.Write(trailer); err != nil {
+		return fmt.Errorf("could not write footer: %w", err)
+	}
+
+	return nil
+}
+
+func writeColumnMetaData(footer *thriftWriter, chunk *parquetColumnChunk) {
+	footer.i32Field(1, chunk.column.physicalType)
+
+	encodings := []int32{parquetEncodingPlain}
+	if chunk.column.optional {
+		encodings = append(encodings, parquetEncodingRLE)
+	}
+	footer.listField(2, thriftI32, len(encodings))
+	for _, encoding := range encodings {
+		footer.i32Element(encoding)
+	}
+
+	footer.listField(3, thriftBinary, 1)
+	footer.writeString(chunk.column.name)
+
+	footer.i32Field(4, parquetCodecGzip)
+	footer.i64Field(5, chunk.numValues)
+	footer.i64Field(6, chunk.uncompressedSize)
+	footer.i64Field(7, chunk.compressedSize)
+	footer.i64Field(9, chunk.offset)
+}
@@ -0,0 +1,131 @@
+//go:build parquet
+
+package export
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/xitongsys/parquet-go-source/buffer"
+	"github.com/xitongsys/parquet-go/parquet"
+	"github.com/xitongsys/parquet-go/reader"
+)
+
+// TestParquetIndependentReader reads written file with parquet-go, so that
+// the file is checked by the reader which shares no code with the writer.
+// Run with go test -tags parquet ./export.
+func TestParquetIndependentReader(t *testing.T) {
+	rows := testRows(t)
+	currencies := []string{"usd", "eur"}
+
+	output := &bytes.Buffer{}
+	writer, err := newParquetWriter(output, currencies, 2)
+	if err != nil {
+		t.Fatalf("could not create writer: %v", err)
+	}
+	for _, row := range rows {
+		if err := writer.Write(row); err != nil {
+			t.Fatalf("could not write row: %v", err)
+		}
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatalf("could not close writer: %v", err)
+	}
+
+	file, err := buffer.NewBufferFile(output.Bytes())
+	if err != nil {
+		t.Fatalf("could not open file: %v", err)
+	}
+	parquetReader, err := reader.NewParquetColumnReader(file, 1)
+	if err != nil {
+		t.Fatalf("could not read file: %v", err)
+	}
+	defer parquetReader.ReadStop()
+
+	if numRows := parquetReader.GetNumRows(); numRows != int64(len(rows)) {
+		t.Errorf("got %d rows, want %d", numRows, len(rows))
+	}
+	if rowGroups := len(parquetReader.Footer.RowGroups); rowGroups != 2 {
+		t.Errorf("got %d row groups, want 2", rowGroups)
+	}
+
+	// schema element describes name, type, converted type and repetition
+	element := func(
+		name string,
+		typ parquet.Type,
+		converted *parquet.ConvertedType,
+		repetition parquet.FieldRepetitionType,
+	) string {
+		convertedType := "-"
+		if converted != nil {
+			convertedType = converted.String()
+		}
+		return fmt.Sprintf("%s %s %s %s", name, typ, convertedType, repetition)
+	}
+	utf8 := parquet.ConvertedTypePtr(parquet.ConvertedType_UTF8)
+
+	expectedSchema := []string{
+		element("address", parquet.Type_BYTE_ARRAY, utf8, parquet.FieldRepetitionType_REQUIRED),
+		element("direction", parquet.Type_BYTE_ARRAY, utf8, parquet.FieldRepetitionType_REQUIRED),
+		element("block_number", parquet.Type_INT64, nil, parquet.FieldRepetitionType_REQUIRED),
+		element("timestamp", parquet.Type_INT64,
+			parquet.ConvertedTypePtr(parquet.ConvertedType_TIMESTAMP_MILLIS),
+			parquet.FieldRepetitionType_OPTIONAL),
+	}
+	for _, name := range columns[4:] {
+		expectedSchema = append(expectedSchema, element(name, parquet.Type_BYTE_ARRAY, utf8,
+			parquet.FieldRepetitionType_REQUIRED))
+	}
+	for _, name := range fiatColumns(currencies) {
+		expectedSchema = append(expectedSchema, element(name, parquet.Type_BYTE_ARRAY, utf8,
+			parquet.FieldRepetitionType_OPTIONAL))
+	}
+
+	var schema []string
+	// the first element is the root of the schema
+	for _, e := range parquetReader.Footer.Schema[1:] {
+		schema = append(schema, element(e.Name, e.GetType(), e.ConvertedType,
+			e.GetRepetitionType()))
+	}
+	if !reflect.DeepEqual(schema, expectedSchema) {
+		t.Errorf("schema is not equal:\nhave: %q\nwant: %q", schema, expectedSchema)
+	}
+
+	readRows := make([][]string, len(rows))
+	for i := range expectedSchema {
+		values, _, _, err := parquetReader.ReadColumnByIndex(int64(i), int64(len(rows)))
+		if err != nil {
+			t.Fatalf("could not read column %d: %v", i, err)
+		}
+		if len(values) != len(rows) {
+			t.Fatalf("got %d values of column %d, want %d", len(values), i, len(rows))
+		}
+
+		for j, value := range values {
+			switch value := value.(type) {
+			case nil:
+				readRows[j] = append(readRows[j], "")
+			case int64:
+				if i == 3 {
+					readRows[j] = append(readRows[j],
+						time.UnixMilli(value).UTC().Format(time.RFC3339))
+					continue
+				}
+				readRows[j] = append(readRows[j], fmt.Sprint(value))
+			default:
+				readRows[j] = append(readRows[j], fmt.Sprint(value))
+			}
+		}
+	}
+
+	var expectedRows [][]string
+	for _, row := range rows {
+		expectedRows = append(expectedRows, row.strings(currencies))
+	}
+	if !reflect.DeepEqual(readRows, expectedRows) {
+		t.Errorf("rows are not equal:\nhave: %q\nwant: %q", readRows, expectedRows)
+	}
+}
@@ -0,0 +1,146 @@
+package export
+
+import (
+	"fmt"
+	"math/big"
+	"time"
+
+	"eth-parser/eth"
+)
+
+// directions of transactions relative to the exported address
+const (
+	DirectionIn   = "in"
+	DirectionOut  = "out"
+	DirectionSelf = "self"
+)
+
+// fiatColumnPrefix prefixes codes of currencies in names of columns of fiat
+// values
+const fiatColumnPrefix = "value_"
+
+// columns are the names of exported fields in the order of CSV columns and
+// Parquet schema, fiat values follow them
+var columns = []string{
+	"address",
+	"direction",
+	"block_number",
+	"timestamp",
+	"hash",
+	"from",
+	"to",
+	"value_wei",
+	"value_eth",
+	"status",
+	"contract_address",
+}
+
+// Row is exported transaction of the address
+type Row struct {
+	Address   string
+	Direction string
+
+	BlockNumber int64
+	// Timestamp is time of the block, it is zero if unknown
+	Timestamp time.Time
+
+	Hash string
+	From string
+	// To is empty if transaction creates contract
+	To string
+
+	Value *big.Int
+
+	Status          string
+	ContractAddress string
+
+	// Fiat maps currencies to the value in them, currencies without price
+	// are missing
+	Fiat map[string]string
+}
+
+// NewRow converts mined transaction of the address to the row
+func NewRow(address string, transaction *eth.Transaction) (*Row, error) {
+	blockNumber, err := eth.ParseBigQuantity(transaction.BlockNumber)
+	if err != nil {
+		return nil, fmt.Errorf("invalid block number: %w", err)
+	}
+	value, err := eth.ParseBigQuantity(transaction.Value)
+	if err != nil {
+		return nil, fmt.Errorf("invalid value: %w", err)
+	}
+
+	row := &Row{
+		Address:         address,
+		Direction:       DirectionIn,
+		BlockNumber:     blockNumber.Int64(),
+		Hash:            transaction.Hash,
+		From:            transaction.From,
+		To:              transaction.To,
+		Value:           value,
+		Status:          transaction.Status,
+		ContractAddress: transaction.ContractAddress,
+		Fiat:            transaction.Fiat,
+	}
+
+	switch address {
+	case transaction.To, transaction.ContractAddress:
+		if address == transaction.From {
+			row.Direction = DirectionSelf
+		}
+	case transaction.From:
+		row.Direction = DirectionOut
+	}
+
+	if len(transaction.Timestamp) != 0 {
+		timestamp, err := eth.ParseBigQuantity(transaction.Timestamp)
+		if err != nil {
+			return nil, fmt.Errorf("invalid timestamp: %w", err)
+		}
+		row.Timestamp = time.Unix(timestamp.Int64(), 0).UTC()
+	}
+
+	return row, nil
+}
+
+// formatTimestamp formats timestamp as RFC 3339, unknown timestamp is empty
+func formatTimestamp(timestamp time.Time) string {
+	if timestamp.IsZero() {
+		return ""
+	}
+
+	return timestamp.Format(time.RFC3339)
+}
+
+// fiatColumns returns names of columns of fiat values of the currencies
+func fiatColumns(currencies []string) []string {
+	names := make([]string, 0, len(currencies))
+	for _, currency := range currencies {
+		names = append(names, fiatColumnPrefix+currency)
+	}
+
+	return names
+}
+
+// strings returns values of the row in the order of columns followed by fiat
+// values of the currencies
+func (r *Row) strings(currencies []string) []string {
+	values := []string{
+		r.Address,
+		r.Direction,
+		fmt.Sprint(r.BlockNumber),
+		formatTimestamp(r.Timestamp),
+		r.Hash,
+		r.From,
+		r.To,
+		r.Value.String(),
+		eth.FormatEther(r.Value),
+		r.Status,
+		r.ContractAddress,
+	}
+	for _, currency := range currencies {
+		values = append(values, r.Fiat[currency])
+	}
+
+	return values
+}
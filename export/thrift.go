@@ -0,0 +1,94 @@
+package export
+
+import (
+	"bytes"
+	"encoding/binary"
+)
+
+// types of Thrift compact protocol used by Parquet metadata
+const (
+	thriftI32    = 5
+	thriftI64    = 6
+	thriftBinary = 8
+	thriftList   = 9
+	thriftStruct = 12
+)
+
+// thriftWriter encodes structs with Thrift compact protocol, it supports only
+// the types needed by Parquet metadata
+type thriftWriter struct {
+	buf bytes.Buffer
+	// lastFieldIDs is the stack of IDs of the last written fields of nested
+	// structs, field IDs are encoded as deltas from them
+	lastFieldIDs []int16
+}
+
+// writeStruct writes struct with fields written by fields, it is used for
+// top level structs and elements of lists
+func (t *thriftWriter) writeStruct(fields func()) {
+	t.lastFieldIDs = append(t.lastFieldIDs, 0)
+	fields()
+	t.buf.WriteByte(0)
+	t.lastFieldIDs = t.lastFieldIDs[:len(t.lastFieldIDs)-1]
+}
+
+func (t *thriftWriter) structField(id int16, fields func()) {
+	t.fieldHeader(id, thriftStruct)
+	t.writeStruct(fields)
+}
+
+func (t *thriftWriter) i32Field(id int16, value int32) {
+	t.fieldHeader(id, thriftI32)
+	t.writeVarint(zigzag(int64(value)))
+}
+
+func (t *thriftWriter) i64Field(id int16, value int64) {
+	t.fieldHeader(id, thriftI64)
+	t.writeVarint(zigzag(value))
+}
+
+func (t *thriftWriter) stringField(id int16, value string) {
+	t.fieldHeader(id, thriftBinary)
+	t.writeString(value)
+}
+
+// listField writes header of the list of size elements, the elements are
+// written after it
+func (t *thriftWriter) listField(id int16, elementType byte, size int) {
+	t.fieldHeader(id, thriftList)
+	if size < 15 {
+		t.buf.WriteByte(byte(size)<<4 | elementType)
+		return
+	}
+
+	t.buf.WriteByte(0xf0 | elementType)
+	t.writeVarint(uint64(size))
+}
+
+func (t *thriftWriter) i32Element(value int32) {
+	t.writeVarint(zigzag(int64(value)))
+}
+
+func (t *thriftWriter) writeString(value string) {
+	t.writeVarint(uint64(len(value)))
+	t.buf.WriteString(value)
+}
+
+func (t *thriftWriter) fieldHeader(id int16, fieldType byte) {
+	last := &t.lastFieldIDs[len(t.lastFieldIDs)-1]
+	if delta := id - *last; delta > 0 && delta <= 15 {
+		t.buf.WriteByte(byte(delta)<<4 | fieldType)
+	} else {
+		t.buf.WriteByte(fieldType)
+		t.writeVarint(zigzag(int64(id)))
+	}
+	*last = id
+}
+
+func (t *thriftWriter) writeVarint(value uint64) {
+	t.buf.Write(binary.AppendUvarint(nil, value))
+}
+
+func zigzag(value int64) uint64 {
+	return uint64(value<<1) ^ uint64(value>>63)
+}
@@ -0,0 +1,144 @@
+package export
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"eth-parser/eth"
+)
+
+// formats of export
+const (
+	FormatCSV     = "csv"
+	FormatJSONL   = "jsonl"
+	FormatParquet = "parquet"
+)
+
+// Formats are all supported formats of export
+var Formats = []string{FormatCSV, FormatJSONL, FormatParquet}
+
+// Writer writes exported rows in a format, Close writes buffered rows and
+// the trailer of the format but does not close the underlying writer
+type Writer interface {
+	Write(row *Row) error
+	Close() error
+}
+
+// NewWriter creates writer of the format, fiat values of rows are written in
+// the currencies
+func NewWriter(w io.Writer, format string, currencies []string) (Writer, error) {
+	switch format {
+	case FormatCSV:
+		return newCSVWriter(w, currencies)
+	case FormatJSONL:
+		return newJSONLWriter(w, currencies), nil
+	case FormatParquet:
+		return newParquetWriter(w, currencies, defaultRowGroupSize)
+	}
+
+	return nil, fmt.Errorf("unknown format %s, must be one of %v", format, Formats)
+}
+
+// ContentType returns media type of the format
+func ContentType(format string) string {
+	switch format {
+	case FormatCSV:
+		return "text/csv"
+	case FormatJSONL:
+		return "application/jsonl"
+	}
+
+	return "application/vnd.apache.parquet"
+}
+
+type csvWriter struct {
+	writer     *csv.Writer
+	currencies []string
+}
+
+// newCSVWriter creates CSV writer and writes the header
+func newCSVWriter(w io.Writer, currencies []string) (*csvWriter, error) {
+	writer := csv.NewWriter(w)
+	header := append(append([]string{}, columns...), fiatColumns(currencies)...)
+	if err := writer.Write(header); err != nil {
+		return nil, fmt.Errorf("could not write header: %w", err)
+	}
+
+	return &csvWriter{writer: writer, currencies: currencies}, nil
+}
+
+func (c *csvWriter) Write(row *Row) error {
+	return c.writer.Write(row.strings(c.currencies))
+}
+
+func (c *csvWriter) Close() error {
+	c.writer.Flush()
+	return c.writer.Error()
+}
+
+type jsonlWriter struct {
+	writer     *bufio.Writer
+	encoder    *json.Encoder
+	currencies []string
+}
+
+// jsonlRow is a line of JSONL, amounts are strings as they may exceed
+// precision of JSON numbers
+type jsonlRow struct {
+	Address         string `json:"address"`
+	Direction       string `json:"direction"`
+	BlockNumber     int64  `json:"block_number"`
+	Timestamp       string `json:"timestamp,omitempty"`
+	Hash            string `json:"hash"`
+	From            string `json:"from"`
+	To              string `json:"to"`
+	ValueWei        string `json:"value_wei"`
+	ValueETH        string `json:"value_eth"`
+	Status          string `json:"status,omitempty"`
+	ContractAddress string `json:"contract_address,omitempty"`
+	// Fiat maps currencies to the value in them
+	Fiat map[string]string `json:"fiat,omitempty"`
+}
+
+func newJSONLWriter(w io.Writer, currencies []string) *jsonlWriter {
+	writer := bufio.NewWriter(w)
+	return &jsonlWriter{
+		writer:     writer,
+		encoder:    json.NewEncoder(writer),
+		currencies: currencies,
+	}
+}
+
+func (j *jsonlWriter) Write(row *Row) error {
+	var fiat map[string]string
+	for _, currency := range j.currencies {
+		if value, ok := row.Fiat[currency]; ok {
+			if fiat == nil {
+				fiat = make(map[string]string, len(j.currencies))
+			}
+			fiat[currency] = value
+		}
+	}
+
+	return j.encoder.Encode(&jsonlRow{
+		Address:         row.Address,
+		Direction:       row.Direction,
+		BlockNumber:     row.BlockNumber,
+		Timestamp:       formatTimestamp(row.Timestamp),
+		Hash:            row.Hash,
+		From:            row.From,
+		To:              row.To,
+		ValueWei:        row.Value.String(),
+		ValueETH:        eth.FormatEther(row.Value),
+		Status:          row.Status,
+		ContractAddress: row.ContractAddress,
+		Fiat:            fiat,
+	})
+}
+
+func (j *jsonlWriter) Close() error {
+	return j.writer.Flush()
+}
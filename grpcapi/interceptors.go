@@ -0,0 +1,146 @@
+package grpcapi
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"log/slog"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"eth-parser/auth"
+)
+
+const (
+	requestIDKey = "x-request-id"
+	requestIDLen = 8
+)
+
+type loggerCtxKey struct{}
+
+type tenantCtxKey struct{}
+
+// wrappedStream overrides context of the server stream
+type wrappedStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (w *wrappedStream) Context() context.Context {
+	return w.ctx
+}
+
+func (s *Server) unaryInterceptor(
+	ctx context.Context,
+	req interface{},
+	info *grpc.UnaryServerInfo,
+	handler grpc.UnaryHandler,
+) (interface{}, error) {
+	start := time.Now()
+
+	ctx, err := s.authenticate(ctx)
+	if err == nil {
+		var resp interface{}
+		resp, err = handler(ctx, req)
+		s.logCall(ctx, info.FullMethod, start, err)
+		return resp, err
+	}
+
+	s.logCall(ctx, info.FullMethod, start, err)
+	return nil, err
+}
+
+func (s *Server) streamInterceptor(
+	srv interface{},
+	stream grpc.ServerStream,
+	info *grpc.StreamServerInfo,
+	handler grpc.StreamHandler,
+) error {
+	start := time.Now()
+
+	ctx, err := s.authenticate(stream.Context())
+	if err == nil {
+		err = handler(srv, &wrappedStream{ServerStream: stream, ctx: ctx})
+	}
+
+	s.logCall(ctx, info.FullMethod, start, err)
+	return err
+}
+
+// authenticate authenticates the call with API key or JWT bearer token from
+// metadata and puts tenant and logger with request ID into the context
+func (s *Server) authenticate(ctx context.Context) (context.Context, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+
+	requestID := firstMetadataValue(md, requestIDKey)
+	if len(requestID) == 0 {
+		requestID = newRequestID()
+	}
+	logger := s.logger.With("request_id", requestID)
+	ctx = context.WithValue(ctx, loggerCtxKey{}, logger)
+
+	tenant := &auth.Tenant{}
+	if s.authenticator.Enabled() {
+		var err error
+		tenant, err = s.authenticator.AuthenticateCredentials(
+			firstMetadataValue(md, strings.ToLower(auth.APIKeyHeader)),
+			firstMetadataValue(md, "authorization"),
+		)
+		if err != nil {
+			logger.Warn("could not authenticate call", "error", err)
+			return ctx, status.Error(codes.Unauthenticated, err.Error())
+		}
+	}
+
+	ctx = context.WithValue(ctx, loggerCtxKey{}, logger.With("tenant", tenant.ID))
+	ctx = context.WithValue(ctx, tenantCtxKey{}, tenant)
+	return ctx, nil
+}
+
+func (s *Server) logCall(ctx context.Context, method string, start time.Time, err error) {
+	contextLogger(ctx, s.logger).Info("handled call",
+		"method", method,
+		"code", status.Code(err).String(),
+		"duration", time.Since(start),
+	)
+}
+
+// contextTenant returns tenant of the authenticated call
+func contextTenant(ctx context.Context) *auth.Tenant {
+	if tenant, ok := ctx.Value(tenantCtxKey{}).(*auth.Tenant); ok {
+		return tenant
+	}
+
+	return &auth.Tenant{}
+}
+
+// contextLogger returns logger of the call or fallback if there is none
+func contextLogger(ctx context.Context, fallback *slog.Logger) *slog.Logger {
+	if logger, ok := ctx.Value(loggerCtxKey{}).(*slog.Logger); ok {
+		return logger
+	}
+
+	return fallback
+}
+
+func firstMetadataValue(md metadata.MD, key string) string {
+	if values := md.Get(key); len(values) != 0 {
+		return values[0]
+	}
+
+	return ""
+}
+
+func newRequestID() string {
+	buf := make([]byte, requestIDLen)
+	if _, err := rand.Read(buf); err != nil {
+		return "unknown"
+	}
+
+	return hex.EncodeToString(buf)
+}
@@ -0,0 +1,814 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.6
+// 	protoc        (unknown)
+// source: parser/v1/parser.proto
+
+package pb
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	structpb "google.golang.org/protobuf/types/known/structpb"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type Transaction struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	Hash  string                 `protobuf:"bytes,1,opt,name=hash,proto3" json:"hash,omitempty"`
+	From  string                 `protobuf:"bytes,2,opt,name=from,proto3" json:"from,omitempty"`
+	// to is empty if transaction creates contract
+	To string `protobuf:"bytes,3,opt,name=to,proto3" json:"to,omitempty"`
+	// contract_address is set for contracts created by subscribed deployers
+	ContractAddress string `protobuf:"bytes,4,opt,name=contract_address,json=contractAddress,proto3" json:"contract_address,omitempty"`
+	// status is one of pending, mined, dropped or replaced
+	Status string `protobuf:"bytes,5,opt,name=status,proto3" json:"status,omitempty"`
+	// nonce, value in wei, block_number and timestamp in unix seconds are hex
+	// encoded as returned by nodes, block_number and timestamp are empty for
+	// pending transactions
+	Nonce       string `protobuf:"bytes,6,opt,name=nonce,proto3" json:"nonce,omitempty"`
+	Value       string `protobuf:"bytes,7,opt,name=value,proto3" json:"value,omitempty"`
+	Input       string `protobuf:"bytes,8,opt,name=input,proto3" json:"input,omitempty"`
+	BlockNumber string `protobuf:"bytes,9,opt,name=block_number,json=blockNumber,proto3" json:"block_number,omitempty"`
+	Timestamp   string `protobuf:"bytes,10,opt,name=timestamp,proto3" json:"timestamp,omitempty"`
+	// decoded is the method call decoded from input if the method is known
+	Decoded *Decoded `protobuf:"bytes,11,opt,name=decoded,proto3" json:"decoded,omitempty"`
+	// fiat maps lower case codes of fiat currencies to the value of mined
+	// transaction in them at the time of its block
+	Fiat          map[string]string `protobuf:"bytes,12,rep,name=fiat,proto3" json:"fiat,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Transaction) Reset() {
+	*x = Transaction{}
+	mi := &file_parser_v1_parser_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Transaction) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Transaction) ProtoMessage() {}
+
+func (x *Transaction) ProtoReflect() protoreflect.Message {
+	mi := &file_parser_v1_parser_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Transaction.ProtoReflect.Descriptor instead.
+func (*Transaction) Descriptor() ([]byte, []int) {
+	return file_parser_v1_parser_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *Transaction) GetHash() string {
+	if x != nil {
+		return x.Hash
+	}
+	return ""
+}
+
+func (x *Transaction) GetFrom() string {
+	if x != nil {
+		return x.From
+	}
+	return ""
+}
+
+func (x *Transaction) GetTo() string {
+	if x != nil {
+		return x.To
+	}
+	return ""
+}
+
+func (x *Transaction) GetContractAddress() string {
+	if x != nil {
+		return x.ContractAddress
+	}
+	return ""
+}
+
+func (x *Transaction) GetStatus() string {
+	if x != nil {
+		return x.Status
+	}
+	return ""
+}
+
+func (x *Transaction) GetNonce() string {
+	if x != nil {
+		return x.Nonce
+	}
+	return ""
+}
+
+func (x *Transaction) GetValue() string {
+	if x != nil {
+		return x.Value
+	}
+	return ""
+}
+
+func (x *Transaction) GetInput() string {
+	if x != nil {
+		return x.Input
+	}
+	return ""
+}
+
+func (x *Transaction) GetBlockNumber() string {
+	if x != nil {
+		return x.BlockNumber
+	}
+	return ""
+}
+
+func (x *Transaction) GetTimestamp() string {
+	if x != nil {
+		return x.Timestamp
+	}
+	return ""
+}
+
+func (x *Transaction) GetDecoded() *Decoded {
+	if x != nil {
+		return x.Decoded
+	}
+	return nil
+}
+
+func (x *Transaction) GetFiat() map[string]string {
+	if x != nil {
+		return x.Fiat
+	}
+	return nil
+}
+
+// Decoded is method call of transaction decoded with ABI
+type Decoded struct {
+	state     protoimpl.MessageState `protogen:"open.v1"`
+	Name      string                 `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	Signature string                 `protobuf:"bytes,2,opt,name=signature,proto3" json:"signature,omitempty"`
+	Args      []*DecodedArg          `protobuf:"bytes,3,rep,name=args,proto3" json:"args,omitempty"`
+	// source is one of contract, standard or selector
+	Source        string `protobuf:"bytes,4,opt,name=source,proto3" json:"source,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Decoded) Reset() {
+	*x = Decoded{}
+	mi := &file_parser_v1_parser_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Decoded) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Decoded) ProtoMessage() {}
+
+func (x *Decoded) ProtoReflect() protoreflect.Message {
+	mi := &file_parser_v1_parser_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Decoded.ProtoReflect.Descriptor instead.
+func (*Decoded) Descriptor() ([]byte, []int) {
+	return file_parser_v1_parser_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *Decoded) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *Decoded) GetSignature() string {
+	if x != nil {
+		return x.Signature
+	}
+	return ""
+}
+
+func (x *Decoded) GetArgs() []*DecodedArg {
+	if x != nil {
+		return x.Args
+	}
+	return nil
+}
+
+func (x *Decoded) GetSource() string {
+	if x != nil {
+		return x.Source
+	}
+	return ""
+}
+
+// DecodedArg is decoded argument. Integers are decimal strings, addresses and
+// bytes are hex encoded, arrays are lists and tuples are structs if all their
+// components are named and lists otherwise.
+type DecodedArg struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Name          string                 `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	Type          string                 `protobuf:"bytes,2,opt,name=type,proto3" json:"type,omitempty"`
+	Value         *structpb.Value        `protobuf:"bytes,3,opt,name=value,proto3" json:"value,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DecodedArg) Reset() {
+	*x = DecodedArg{}
+	mi := &file_parser_v1_parser_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DecodedArg) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DecodedArg) ProtoMessage() {}
+
+func (x *DecodedArg) ProtoReflect() protoreflect.Message {
+	mi := &file_parser_v1_parser_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DecodedArg.ProtoReflect.Descriptor instead.
+func (*DecodedArg) Descriptor() ([]byte, []int) {
+	return file_parser_v1_parser_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *DecodedArg) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *DecodedArg) GetType() string {
+	if x != nil {
+		return x.Type
+	}
+	return ""
+}
+
+func (x *DecodedArg) GetValue() *structpb.Value {
+	if x != nil {
+		return x.Value
+	}
+	return nil
+}
+
+type GetCurrentBlockRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	ChainId       uint64                 `protobuf:"varint,1,opt,name=chain_id,json=chainId,proto3" json:"chain_id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetCurrentBlockRequest) Reset() {
+	*x = GetCurrentBlockRequest{}
+	mi := &file_parser_v1_parser_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetCurrentBlockRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetCurrentBlockRequest) ProtoMessage() {}
+
+func (x *GetCurrentBlockRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_parser_v1_parser_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetCurrentBlockRequest.ProtoReflect.Descriptor instead.
+func (*GetCurrentBlockRequest) Descriptor() ([]byte, []int) {
+	return file_parser_v1_parser_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *GetCurrentBlockRequest) GetChainId() uint64 {
+	if x != nil {
+		return x.ChainId
+	}
+	return 0
+}
+
+type GetCurrentBlockResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	BlockNumber   int64                  `protobuf:"varint,1,opt,name=block_number,json=blockNumber,proto3" json:"block_number,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetCurrentBlockResponse) Reset() {
+	*x = GetCurrentBlockResponse{}
+	mi := &file_parser_v1_parser_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetCurrentBlockResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetCurrentBlockResponse) ProtoMessage() {}
+
+func (x *GetCurrentBlockResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_parser_v1_parser_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetCurrentBlockResponse.ProtoReflect.Descriptor instead.
+func (*GetCurrentBlockResponse) Descriptor() ([]byte, []int) {
+	return file_parser_v1_parser_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *GetCurrentBlockResponse) GetBlockNumber() int64 {
+	if x != nil {
+		return x.BlockNumber
+	}
+	return 0
+}
+
+type SubscribeRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	ChainId       uint64                 `protobuf:"varint,1,opt,name=chain_id,json=chainId,proto3" json:"chain_id,omitempty"`
+	Address       string                 `protobuf:"bytes,2,opt,name=address,proto3" json:"address,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SubscribeRequest) Reset() {
+	*x = SubscribeRequest{}
+	mi := &file_parser_v1_parser_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SubscribeRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SubscribeRequest) ProtoMessage() {}
+
+func (x *SubscribeRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_parser_v1_parser_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SubscribeRequest.ProtoReflect.Descriptor instead.
+func (*SubscribeRequest) Descriptor() ([]byte, []int) {
+	return file_parser_v1_parser_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *SubscribeRequest) GetChainId() uint64 {
+	if x != nil {
+		return x.ChainId
+	}
+	return 0
+}
+
+func (x *SubscribeRequest) GetAddress() string {
+	if x != nil {
+		return x.Address
+	}
+	return ""
+}
+
+type SubscribeResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SubscribeResponse) Reset() {
+	*x = SubscribeResponse{}
+	mi := &file_parser_v1_parser_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SubscribeResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SubscribeResponse) ProtoMessage() {}
+
+func (x *SubscribeResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_parser_v1_parser_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SubscribeResponse.ProtoReflect.Descriptor instead.
+func (*SubscribeResponse) Descriptor() ([]byte, []int) {
+	return file_parser_v1_parser_proto_rawDescGZIP(), []int{6}
+}
+
+type GetTransactionsRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	ChainId       uint64                 `protobuf:"varint,1,opt,name=chain_id,json=chainId,proto3" json:"chain_id,omitempty"`
+	Address       string                 `protobuf:"bytes,2,opt,name=address,proto3" json:"address,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetTransactionsRequest) Reset() {
+	*x = GetTransactionsRequest{}
+	mi := &file_parser_v1_parser_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetTransactionsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetTransactionsRequest) ProtoMessage() {}
+
+func (x *GetTransactionsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_parser_v1_parser_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetTransactionsRequest.ProtoReflect.Descriptor instead.
+func (*GetTransactionsRequest) Descriptor() ([]byte, []int) {
+	return file_parser_v1_parser_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *GetTransactionsRequest) GetChainId() uint64 {
+	if x != nil {
+		return x.ChainId
+	}
+	return 0
+}
+
+func (x *GetTransactionsRequest) GetAddress() string {
+	if x != nil {
+		return x.Address
+	}
+	return ""
+}
+
+type GetTransactionsResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Transactions  []*Transaction         `protobuf:"bytes,1,rep,name=transactions,proto3" json:"transactions,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetTransactionsResponse) Reset() {
+	*x = GetTransactionsResponse{}
+	mi := &file_parser_v1_parser_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetTransactionsResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetTransactionsResponse) ProtoMessage() {}
+
+func (x *GetTransactionsResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_parser_v1_parser_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetTransactionsResponse.ProtoReflect.Descriptor instead.
+func (*GetTransactionsResponse) Descriptor() ([]byte, []int) {
+	return file_parser_v1_parser_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *GetTransactionsResponse) GetTransactions() []*Transaction {
+	if x != nil {
+		return x.Transactions
+	}
+	return nil
+}
+
+type WatchTransactionsRequest struct {
+	state   protoimpl.MessageState `protogen:"open.v1"`
+	ChainId uint64                 `protobuf:"varint,1,opt,name=chain_id,json=chainId,proto3" json:"chain_id,omitempty"`
+	// addresses to watch, all subscribed addresses are watched if empty
+	Addresses     []string `protobuf:"bytes,2,rep,name=addresses,proto3" json:"addresses,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *WatchTransactionsRequest) Reset() {
+	*x = WatchTransactionsRequest{}
+	mi := &file_parser_v1_parser_proto_msgTypes[9]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *WatchTransactionsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*WatchTransactionsRequest) ProtoMessage() {}
+
+func (x *WatchTransactionsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_parser_v1_parser_proto_msgTypes[9]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use WatchTransactionsRequest.ProtoReflect.Descriptor instead.
+func (*WatchTransactionsRequest) Descriptor() ([]byte, []int) {
+	return file_parser_v1_parser_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *WatchTransactionsRequest) GetChainId() uint64 {
+	if x != nil {
+		return x.ChainId
+	}
+	return 0
+}
+
+func (x *WatchTransactionsRequest) GetAddresses() []string {
+	if x != nil {
+		return x.Addresses
+	}
+	return nil
+}
+
+type WatchTransactionsResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Address       string                 `protobuf:"bytes,1,opt,name=address,proto3" json:"address,omitempty"`
+	BlockNumber   string                 `protobuf:"bytes,2,opt,name=block_number,json=blockNumber,proto3" json:"block_number,omitempty"`
+	Transaction   *Transaction           `protobuf:"bytes,3,opt,name=transaction,proto3" json:"transaction,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *WatchTransactionsResponse) Reset() {
+	*x = WatchTransactionsResponse{}
+	mi := &file_parser_v1_parser_proto_msgTypes[10]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *WatchTransactionsResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*WatchTransactionsResponse) ProtoMessage() {}
+
+func (x *WatchTransactionsResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_parser_v1_parser_proto_msgTypes[10]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use WatchTransactionsResponse.ProtoReflect.Descriptor instead.
+func (*WatchTransactionsResponse) Descriptor() ([]byte, []int) {
+	return file_parser_v1_parser_proto_rawDescGZIP(), []int{10}
+}
+
+func (x *WatchTransactionsResponse) GetAddress() string {
+	if x != nil {
+		return x.Address
+	}
+	return ""
+}
+
+func (x *WatchTransactionsResponse) GetBlockNumber() string {
+	if x != nil {
+		return x.BlockNumber
+	}
+	return ""
+}
+
+func (x *WatchTransactionsResponse) GetTransaction() *Transaction {
+	if x != nil {
+		return x.Transaction
+	}
+	return nil
+}
+
+var File_parser_v1_parser_proto protoreflect.FileDescriptor
+
+const file_parser_v1_parser_proto_rawDesc = "" +
+	"\n" +
+	"\x16parser/v1/parser.proto\x12\tparser.v1\x1a\x1cgoogle/protobuf/struct.proto\"\xa8\x03\n" +
+	"\vTransaction\x12\x12\n" +
+	"\x04hash\x18\x01 \x01(\tR\x04hash\x12\x12\n" +
+	"\x04from\x18\x02 \x01(\tR\x04from\x12\x0e\n" +
+	"\x02to\x18\x03 \x01(\tR\x02to\x12)\n" +
+	"\x10contract_address\x18\x04 \x01(\tR\x0fcontractAddress\x12\x16\n" +
+	"\x06status\x18\x05 \x01(\tR\x06status\x12\x14\n" +
+	"\x05nonce\x18\x06 \x01(\tR\x05nonce\x12\x14\n" +
+	"\x05value\x18\a \x01(\tR\x05value\x12\x14\n" +
+	"\x05input\x18\b \x01(\tR\x05input\x12!\n" +
+	"\fblock_number\x18\t \x01(\tR\vblockNumber\x12\x1c\n" +
+	"\ttimestamp\x18\n" +
+	" \x01(\tR\ttimestamp\x12,\n" +
+	"\adecoded\x18\v \x01(\v2\x12.parser.v1.DecodedR\adecoded\x124\n" +
+	"\x04fiat\x18\f \x03(\v2 .parser.v1.Transaction.FiatEntryR\x04fiat\x1a7\n" +
+	"\tFiatEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01\"~\n" +
+	"\aDecoded\x12\x12\n" +
+	"\x04name\x18\x01 \x01(\tR\x04name\x12\x1c\n" +
+	"\tsignature\x18\x02 \x01(\tR\tsignature\x12)\n" +
+	"\x04args\x18\x03 \x03(\v2\x15.parser.v1.DecodedArgR\x04args\x12\x16\n" +
+	"\x06source\x18\x04 \x01(\tR\x06source\"b\n" +
+	"\n" +
+	"DecodedArg\x12\x12\n" +
+	"\x04name\x18\x01 \x01(\tR\x04name\x12\x12\n" +
+	"\x04type\x18\x02 \x01(\tR\x04type\x12,\n" +
+	"\x05value\x18\x03 \x01(\v2\x16.google.protobuf.ValueR\x05value\"3\n" +
+	"\x16GetCurrentBlockRequest\x12\x19\n" +
+	"\bchain_id\x18\x01 \x01(\x04R\achainId\"<\n" +
+	"\x17GetCurrentBlockResponse\x12!\n" +
+	"\fblock_number\x18\x01 \x01(\x03R\vblockNumber\"G\n" +
+	"\x10SubscribeRequest\x12\x19\n" +
+	"\bchain_id\x18\x01 \x01(\x04R\achainId\x12\x18\n" +
+	"\aaddress\x18\x02 \x01(\tR\aaddress\"\x13\n" +
+	"\x11SubscribeResponse\"M\n" +
+	"\x16GetTransactionsRequest\x12\x19\n" +
+	"\bchain_id\x18\x01 \x01(\x04R\achainId\x12\x18\n" +
+	"\aaddress\x18\x02 \x01(\tR\aaddress\"U\n" +
+	"\x17GetTransactionsResponse\x12:\n" +
+	"\ftransactions\x18\x01 \x03(\v2\x16.parser.v1.TransactionR\ftransactions\"S\n" +
+	"\x18WatchTransactionsRequest\x12\x19\n" +
+	"\bchain_id\x18\x01 \x01(\x04R\achainId\x12\x1c\n" +
+	"\taddresses\x18\x02 \x03(\tR\taddresses\"\x92\x01\n" +
+	"\x19WatchTransactionsResponse\x12\x18\n" +
+	"\aaddress\x18\x01 \x01(\tR\aaddress\x12!\n" +
+	"\fblock_number\x18\x02 \x01(\tR\vblockNumber\x128\n" +
+	"\vtransaction\x18\x03 \x01(\v2\x16.parser.v1.TransactionR\vtransaction2\xe6\x02\n" +
+	"\x06Parser\x12X\n" +
+	"\x0fGetCurrentBlock\x12!.parser.v1.GetCurrentBlockRequest\x1a\".parser.v1.GetCurrentBlockResponse\x12F\n" +
+	"\tSubscribe\x12\x1b.parser.v1.SubscribeRequest\x1a\x1c.parser.v1.SubscribeResponse\x12X\n" +
+	"\x0fGetTransactions\x12!.parser.v1.GetTransactionsRequest\x1a\".parser.v1.GetTransactionsResponse\x12`\n" +
+	"\x11WatchTransactions\x12#.parser.v1.WatchTransactionsRequest\x1a$.parser.v1.WatchTransactionsResponse0\x01B\x17Z\x15eth-parser/grpcapi/pbb\x06proto3"
+
+var (
+	file_parser_v1_parser_proto_rawDescOnce sync.Once
+	file_parser_v1_parser_proto_rawDescData []byte
+)
+
+func file_parser_v1_parser_proto_rawDescGZIP() []byte {
+	file_parser_v1_parser_proto_rawDescOnce.Do(func() {
+		file_parser_v1_parser_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_parser_v1_parser_proto_rawDesc), len(file_parser_v1_parser_proto_rawDesc)))
+	})
+	return file_parser_v1_parser_proto_rawDescData
+}
+
+var file_parser_v1_parser_proto_msgTypes = make([]protoimpl.MessageInfo, 12)
+var file_parser_v1_parser_proto_goTypes = []any{
+	(*Transaction)(nil),               // 0: parser.v1.Transaction
+	(*Decoded)(nil),                   // 1: parser.v1.Decoded
+	(*DecodedArg)(nil),                // 2: parser.v1.DecodedArg
+	(*GetCurrentBlockRequest)(nil),    // 3: parser.v1.GetCurrentBlockRequest
+	(*GetCurrentBlockResponse)(nil),   // 4: parser.v1.GetCurrentBlockResponse
+	(*SubscribeRequest)(nil),          // 5: parser.v1.SubscribeRequest
+	(*SubscribeResponse)(nil),         // 6: parser.v1.SubscribeResponse
+	(*GetTransactionsRequest)(nil),    // 7: parser.v1.GetTransactionsRequest
+	(*GetTransactionsResponse)(nil),   // 8: parser.v1.GetTransactionsResponse
+	(*WatchTransactionsRequest)(nil),  // 9: parser.v1.WatchTransactionsRequest
+	(*WatchTransactionsResponse)(nil), // 10: parser.v1.WatchTransactionsResponse
+	nil,                               // 11: parser.v1.Transaction.FiatEntry
+	(*structpb.Value)(nil),            // 12: google.protobuf.Value
+}
+var file_parser_v1_parser_proto_depIdxs = []int32{
+	1,  // 0: parser.v1.Transaction.decoded:type_name -> parser.v1.Decoded
+	11, // 1: parser.v1.Transaction.fiat:type_name -> parser.v1.Transaction.FiatEntry
+	2,  // 2: parser.v1.Decoded.args:type_name -> parser.v1.DecodedArg
+	12, // 3: parser.v1.DecodedArg.value:type_name -> google.protobuf.Value
+	0,  // 4: parser.v1.GetTransactionsResponse.transactions:type_name -> parser.v1.Transaction
+	0,  // 5: parser.v1.WatchTransactionsResponse.transaction:type_name -> parser.v1.Transaction
+	3,  // 6: parser.v1.Parser.GetCurrentBlock:input_type -> parser.v1.GetCurrentBlockRequest
+	5,  // 7: parser.v1.Parser.Subscribe:input_type -> parser.v1.SubscribeRequest
+	7,  // 8: parser.v1.Parser.GetTransactions:input_type -> parser.v1.GetTransactionsRequest
+	9,  // 9: parser.v1.Parser.WatchTransactions:input_type -> parser.v1.WatchTransactionsRequest
+	4,  // 10: parser.v1.Parser.GetCurrentBlock:output_type -> parser.v1.GetCurrentBlockResponse
+	6,  // 11: parser.v1.Parser.Subscribe:output_type -> parser.v1.SubscribeResponse
+	8,  // 12: parser.v1.Parser.GetTransactions:output_type -> parser.v1.GetTransactionsResponse
+	10, // 13: parser.v1.Parser.WatchTransactions:output_type -> parser.v1.WatchTransactionsResponse
+	10, // [10:14] is the sub-list for method output_type
+	6,  // [6:10] is the sub-list for method input_type
+	6,  // [6:6] is the sub-list for extension type_name
+	6,  // [6:6] is the sub-list for extension extendee
+	0,  // [0:6] is the sub-list for field type_name
+}
+
+func init() { file_parser_v1_parser_proto_init() }
+func file_parser_v1_parser_proto_init() {
+	if File_parser_v1_parser_proto != nil {
+		return
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_parser_v1_parser_proto_rawDesc), len(file_parser_v1_parser_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   12,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_parser_v1_parser_proto_goTypes,
+		DependencyIndexes: file_parser_v1_parser_proto_depIdxs,
+		MessageInfos:      file_parser_v1_parser_proto_msgTypes,
+	}.Build()
+	File_parser_v1_parser_proto = out.File
+	file_parser_v1_parser_proto_goTypes = nil
+	file_parser_v1_parser_proto_depIdxs = nil
+}
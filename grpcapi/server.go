@@ -0,0 +1,295 @@
+package grpcapi
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net"
+	"os"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/structpb"
+
+	"eth-parser/auth"
+	"eth-parser/eth"
+	"eth-parser/grpcapi/pb"
+	"eth-parser/parser"
+)
+
+type Config struct {
+	// Addr is the addr to listen on, gRPC server is disabled if empty
+	Addr string `yaml:"addr"`
+}
+
+// Server implements gRPC Parser service
+type Server struct {
+	pb.UnimplementedParserServer
+
+	chains        parser.Chains
+	authenticator *auth.Authenticator
+
+	logger *slog.Logger
+}
+
+func NewServer(
+	chains parser.Chains,
+	authenticator *auth.Authenticator,
+	logger *slog.Logger,
+) *Server {
+	return &Server{
+		chains:        chains,
+		authenticator: authenticator,
+		logger:        logger.With("component", "grpc_handler"),
+	}
+}
+
+func InitGRPCServer(
+	s *Server,
+	addr string,
+	logger *slog.Logger,
+) (*grpc.Server, <-chan struct{}) {
+	logger = logger.With("component", "grpc_server")
+
+	if s == nil {
+		logger.Error("got nil server")
+		os.Exit(1)
+	}
+
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		logger.Error("could not listen", "addr", addr, "error", err)
+		os.Exit(1)
+	}
+
+	server := grpc.NewServer(
+		grpc.ChainUnaryInterceptor(s.unaryInterceptor),
+		grpc.ChainStreamInterceptor(s.streamInterceptor),
+	)
+	pb.RegisterParserServer(server, s)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+
+		if err := server.Serve(listener); err != nil {
+			logger.Error("error on serve", "error", err)
+			os.Exit(1)
+		}
+
+		logger.Info("shutdown successfully")
+	}()
+
+	return server, done
+}
+
+// Shutdown stops server gracefully, if ctx is done before that, all
+// connections are closed forcibly
+func Shutdown(ctx context.Context, server *grpc.Server) {
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		server.GracefulStop()
+	}()
+
+	select {
+	case <-stopped:
+	case <-ctx.Done():
+		server.Stop()
+		<-stopped
+	}
+}
+
+// resolveChain returns parser of the chain with given ID, the ID may be 0
+// if there is only one chain
+func (s *Server) resolveChain(chainID uint64) (*parser.Parser, error) {
+	if chainID == 0 {
+		if len(s.chains) == 1 {
+			for _, p := range s.chains {
+				return p, nil
+			}
+		}
+
+		return nil, status.Error(codes.InvalidArgument, "chain_id is required")
+	}
+
+	p, ok := s.chains[chainID]
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "unknown chain: %d", chainID)
+	}
+
+	return p, nil
+}
+
+func (s *Server) GetCurrentBlock(
+	ctx context.Context,
+	req *pb.GetCurrentBlockRequest,
+) (*pb.GetCurrentBlockResponse, error) {
+	p, err := s.resolveChain(req.GetChainId())
+	if err != nil {
+		return nil, err
+	}
+
+	return &pb.GetCurrentBlockResponse{
+		BlockNumber: int64(p.GetCurrentBlock()),
+	}, nil
+}
+
+func (s *Server) Subscribe(
+	ctx context.Context,
+	req *pb.SubscribeRequest,
+) (*pb.SubscribeResponse, error) {
+	p, err := s.resolveChain(req.GetChainId())
+	if err != nil {
+		return nil, err
+	}
+	if len(req.GetAddress()) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "address is required")
+	}
+
+	tenant := contextTenant(ctx)
+	if err := p.Subscribe(tenant.ID, req.GetAddress(), tenant.MaxSubscriptions); err != nil {
+		return nil, parserError(err)
+	}
+
+	contextLogger(ctx, s.logger).Info("subscribed successfully",
+		"chain_id", p.ChainID(), "address", req.GetAddress())
+	return &pb.SubscribeResponse{}, nil
+}
+
+func (s *Server) GetTransactions(
+	ctx context.Context,
+	req *pb.GetTransactionsRequest,
+) (*pb.GetTransactionsResponse, error) {
+	p, err := s.resolveChain(req.GetChainId())
+	if err != nil {
+		return nil, err
+	}
+	if len(req.GetAddress()) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "address is required")
+	}
+
+	transactions, err := p.GetTransactions(contextTenant(ctx).ID, req.GetAddress())
+	if err != nil {
+		return nil, parserError(err)
+	}
+
+	resp := &pb.GetTransactionsResponse{
+		Transactions: make([]*pb.Transaction, 0, len(transactions)),
+	}
+	for i := range transactions {
+		resp.Transactions = append(resp.Transactions, toPBTransaction(&transactions[i]))
+	}
+
+	return resp, nil
+}
+
+func (s *Server) WatchTransactions(
+	req *pb.WatchTransactionsRequest,
+	stream pb.Parser_WatchTransactionsServer,
+) error {
+	p, err := s.resolveChain(req.GetChainId())
+	if err != nil {
+		return err
+	}
+
+	ctx := stream.Context()
+	logger := contextLogger(ctx, s.logger).With("chain_id", p.ChainID())
+
+	watcher, err := p.Watch(contextTenant(ctx).ID, req.GetAddresses())
+	if err != nil {
+		return parserError(err)
+	}
+	defer p.Unwatch(watcher)
+
+	logger.Debug("started watching transactions", "addresses", len(req.GetAddresses()))
+
+	for {
+		var watched parser.WatchedTransaction
+		var ok bool
+
+		select {
+		case <-ctx.Done():
+			return status.FromContextError(ctx.Err()).Err()
+		case watched, ok = <-watcher.Transactions():
+		}
+
+		if !ok {
+			logger.Debug("stopped watching transactions", "reason", watcher.Err())
+
+			switch {
+			case errors.Is(watcher.Err(), parser.ErrSlowWatcher):
+				return status.Error(codes.ResourceExhausted, watcher.Err().Error())
+			case errors.Is(watcher.Err(), parser.ErrShutdown):
+				return status.Error(codes.Unavailable, watcher.Err().Error())
+			default:
+				return nil
+			}
+		}
+
+		err := stream.Send(&pb.WatchTransactionsResponse{
+			Address:     watched.Address,
+			BlockNumber: watched.BlockNumber,
+			Transaction: toPBTransaction(&watched.Transaction),
+		})
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// parserError returns status of the error returned by parser
+func parserError(err error) error {
+	switch {
+	case errors.Is(err, eth.ErrInvalidAddress):
+		return status.Error(codes.InvalidArgument, err.Error())
+	case errors.Is(err, parser.ErrSubscriptionsLimitExceeded):
+		return status.Error(codes.ResourceExhausted, err.Error())
+	default:
+		return status.Error(codes.Internal, err.Error())
+	}
+}
+
+func toPBTransaction(transaction *eth.Transaction) *pb.Transaction {
+	return &pb.Transaction{
+		Hash:  transaction.Hash,
+		From:  transaction.From,
+		To:    transaction.To,
+		Nonce: transaction.Nonce,
+		Value: transaction.Value,
+		Input: transaction.Input,
+
+		ContractAddress: transaction.ContractAddress,
+		Status:          transaction.Status,
+		BlockNumber:     transaction.BlockNumber,
+		Timestamp:       transaction.Timestamp,
+		Decoded:         toPBDecoded(transaction.Decoded),
+		Fiat:            transaction.Fiat,
+	}
+}
+
+// toPBDecoded converts decoded call, values of arguments are converted to
+// protobuf values, argument which could not be converted has null value
+func toPBDecoded(decoded *eth.Decoded) *pb.Decoded {
+	if decoded == nil {
+		return nil
+	}
+
+	args := make([]*pb.DecodedArg, len(decoded.Args))
+	for i, arg := range decoded.Args {
+		value, err := structpb.NewValue(arg.Value)
+		if err != nil {
+			value = structpb.NewNullValue()
+		}
+
+		args[i] = &pb.DecodedArg{Name: arg.Name, Type: arg.Type, Value: value}
+	}
+
+	return &pb.Decoded{
+		Name:      decoded.Name,
+		Signature: decoded.Signature,
+		Args:      args,
+		Source:    decoded.Source,
+	}
+}
@@ -0,0 +1,397 @@
+package grpcapi
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/structpb"
+
+	"eth-parser/abi"
+	"eth-parser/auth"
+	"eth-parser/eth"
+	"eth-parser/grpcapi/pb"
+	"eth-parser/parser"
+	"eth-parser/poller"
+	"eth-parser/storages"
+	"eth-parser/testutil"
+)
+
+const (
+	testAddress1 = "0x00000000000000000000000000000000000000a1"
+	testAddress2 = "0x00000000000000000000000000000000000000a2"
+
+	testTimeout = 5 * time.Second
+)
+
+var testLogger = slog.New(slog.NewTextHandler(io.Discard, nil))
+
+// testServer serves the only chain, which is served by testutil.EthNode and
+// parsed with memory storages, over in-memory connection
+type testServer struct {
+	node   *testutil.EthNode
+	parser *parser.Parser
+	client pb.ParserClient
+
+	// streamErrs receives errors stream handlers return with
+	streamErrs chan error
+	// shutdown shuts the parser down once
+	shutdown func()
+}
+
+func newTestServer(t *testing.T, authConfig *auth.Config) *testServer {
+	t.Helper()
+
+	node := testutil.NewEthNode(1)
+	t.Cleanup(node.Close)
+
+	ethPoller := poller.NewEthPoller(&poller.EthPollerConfig{
+		ChainID:             1,
+		Endpoint:            node.URL(),
+		PollInterval:        5 * time.Millisecond,
+		HeadRefreshInterval: time.Hour,
+		Timeout:             time.Second,
+		NumRetries:          3,
+		QueueLen:            10,
+	}, testLogger)
+
+	p := parser.NewParser(&parser.Config{}, ethPoller, nil,
+		storages.NewTransactionsMapStorage(false, testLogger),
+		storages.NewAddressesMapStorage(testLogger),
+		storages.NewLogsMapStorage(false, testLogger),
+		abi.NewRegistry(&abi.Config{}, 1, testLogger), nil, testLogger)
+	if err := p.Init(); err != nil {
+		t.Fatalf("could not init parser: %v", err)
+	}
+	go p.Routine()
+	shutdown := sync.OnceFunc(p.Shutdown)
+	t.Cleanup(shutdown)
+
+	// blocks are mined after the parser starts from the initial one
+	waitFor(t, "initial block", func() bool {
+		return node.Calls("eth_getBlockByNumber") >= 2
+	})
+
+	if authConfig == nil {
+		authConfig = &auth.Config{}
+	}
+	s := NewServer(parser.Chains{1: p}, auth.NewAuthenticator(authConfig), testLogger)
+
+	streamErrs := make(chan error, 10)
+	server := grpc.NewServer(
+		grpc.ChainUnaryInterceptor(s.unaryInterceptor),
+		grpc.ChainStreamInterceptor(s.streamInterceptor, func(
+			srv interface{},
+			stream grpc.ServerStream,
+			info *grpc.StreamServerInfo,
+			handler grpc.StreamHandler,
+		) error {
+			err := handler(srv, stream)
+			streamErrs <- err
+			return err
+		}),
+	)
+	pb.RegisterParserServer(server, s)
+
+	listener := bufconn.Listen(1 << 20)
+	go server.Serve(listener)
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufconn",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return listener.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatalf("could not create client: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	return &testServer{
+		node:       node,
+		parser:     p,
+		client:     pb.NewParserClient(conn),
+		streamErrs: streamErrs,
+		shutdown:   shutdown,
+	}
+}
+
+// waitFor waits until condition is met or fails the test on timeout
+func waitFor(t *testing.T, what string, condition func() bool) {
+	t.Helper()
+
+	for deadline := time.Now().Add(testTimeout); !condition(); {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// nextStreamErr returns the error the next stream handler returns with
+func (s *testServer) nextStreamErr(t *testing.T) error {
+	t.Helper()
+
+	select {
+	case err := <-s.streamErrs:
+		return err
+	case <-time.After(testTimeout):
+		t.Fatalf("timed out waiting for stream handler to return")
+		return nil
+	}
+}
+
+func checkCode(t *testing.T, err error, code codes.Code) {
+	t.Helper()
+
+	if status.Code(err) != code {
+		t.Errorf("got error %v, want code %s", err, code)
+	}
+}
+
+func TestSubscribeAndGetTransactions(t *testing.T) {
+	s := newTestServer(t, nil)
+	ctx := context.Background()
+
+	_, err := s.client.Subscribe(ctx, &pb.SubscribeRequest{})
+	checkCode(t, err, codes.InvalidArgument)
+	_, err = s.client.Subscribe(ctx, &pb.SubscribeRequest{Address: "0x01"})
+	checkCode(t, err, codes.InvalidArgument)
+	_, err = s.client.Subscribe(ctx, &pb.SubscribeRequest{ChainId: 10, Address: testAddress1})
+	checkCode(t, err, codes.NotFound)
+	_, err = s.client.GetTransactions(ctx, &pb.GetTransactionsRequest{Address: "0x01"})
+	checkCode(t, err, codes.InvalidArgument)
+
+	// address is matched in lower case
+	checksummed := "0x00000000000000000000000000000000000000A1"
+	if _, err := s.client.Subscribe(ctx, &pb.SubscribeRequest{Address: checksummed}); err != nil {
+		t.Fatalf("could not subscribe: %v", err)
+	}
+
+	block := s.node.Mine(eth.Transaction{From: testAddress1, To: testAddress2, Value: "0x1"})
+	var transactions []*pb.Transaction
+	waitFor(t, "transactions", func() bool {
+		resp, err := s.client.GetTransactions(ctx,
+			&pb.GetTransactionsRequest{ChainId: 1, Address: checksummed})
+		if err != nil {
+			t.Fatalf("could not get transactions: %v", err)
+		}
+		transactions = resp.GetTransactions()
+		return len(transactions) != 0
+	})
+
+	expected := &pb.Transaction{
+		Hash:        block.Transactions[0].Hash,
+		From:        testAddress1,
+		To:          testAddress2,
+		Value:       "0x1",
+		Status:      eth.StatusMined,
+		BlockNumber: block.Number,
+		Timestamp:   block.Timestamp,
+	}
+	if len(transactions) != 1 || !proto.Equal(transactions[0], expected) {
+		t.Errorf("transactions are not equal:\nhave: %v\nwant: %v", transactions, expected)
+	}
+}
+
+func TestSubscribeAuthentication(t *testing.T) {
+	s := newTestServer(t, &auth.Config{
+		APIKeys: []auth.APIKeyConfig{{Key: "key", Tenant: "team-a", MaxSubscriptions: 1}},
+	})
+
+	_, err := s.client.Subscribe(context.Background(), &pb.SubscribeRequest{Address: testAddress1})
+	checkCode(t, err, codes.Unauthenticated)
+
+	ctx := metadata.AppendToOutgoingContext(context.Background(), "x-api-key", "key")
+	if _, err := s.client.Subscribe(ctx, &pb.SubscribeRequest{Address: testAddress1}); err != nil {
+		t.Fatalf("could not subscribe: %v", err)
+	}
+	_, err = s.client.Subscribe(ctx, &pb.SubscribeRequest{Address: testAddress2})
+	checkCode(t, err, codes.ResourceExhausted)
+
+	if subscriptions := s.parser.GetSubscriptions("team-a"); len(subscriptions) != 1 {
+		t.Errorf("got subscriptions %v of tenant, want one", subscriptions)
+	}
+}
+
+func TestWatchTransactions(t *testing.T) {
+	s := newTestServer(t, nil)
+	for _, address := range []string{testAddress1, testAddress2} {
+		if err := s.parser.Subscribe("", address, 0); err != nil {
+			t.Fatalf("could not subscribe: %v", err)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	stream, err := s.client.WatchTransactions(ctx,
+		&pb.WatchTransactionsRequest{Addresses: []string{testAddress2}})
+	if err != nil {
+		t.Fatalf("could not watch transactions: %v", err)
+	}
+	startWatching(t, s, stream)
+
+	// only watched addresses are streamed
+	s.node.Mine(eth.Transaction{From: testAddress1})
+	block := s.node.Mine(eth.Transaction{From: testAddress1, To: testAddress2})
+	for {
+		resp, err := stream.Recv()
+		if err != nil {
+			t.Fatalf("could not receive transaction: %v", err)
+		}
+		if resp.GetAddress() != testAddress2 {
+			t.Errorf("got transaction of not watched address: %v", resp)
+		}
+		if resp.GetTransaction().GetHash() == block.Transactions[0].Hash {
+			if resp.GetBlockNumber() != block.Number {
+				t.Errorf("got block number %s, want %s", resp.GetBlockNumber(), block.Number)
+			}
+			break
+		}
+	}
+
+	// canceled stream stops watching
+	cancel()
+	if _, err := stream.Recv(); status.Code(err) != codes.Canceled {
+		t.Errorf("got error %v after cancellation, want canceled", err)
+	}
+	checkCode(t, s.nextStreamErr(t), codes.Canceled)
+}
+
+// startWatching waits until the stream receives the first transaction, as
+// transactions of blocks parsed before the call is handled are not streamed.
+// Transactions to testAddress2 are mined meanwhile, the address must be
+// subscribed.
+func startWatching(
+	t *testing.T,
+	s *testServer,
+	stream grpc.ServerStreamingClient[pb.WatchTransactionsResponse],
+) {
+	t.Helper()
+
+	stop := make(chan struct{})
+	defer close(stop)
+	go func() {
+		ticker := time.NewTicker(10 * time.Millisecond)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				s.node.Mine(eth.Transaction{From: testAddress2})
+			}
+		}
+	}()
+
+	if _, err := stream.Recv(); err != nil {
+		t.Fatalf("could not receive transaction: %v", err)
+	}
+}
+
+func TestWatchTransactionsShutdown(t *testing.T) {
+	s := newTestServer(t, nil)
+	if err := s.parser.Subscribe("", testAddress2, 0); err != nil {
+		t.Fatalf("could not subscribe: %v", err)
+	}
+
+	stream, err := s.client.WatchTransactions(context.Background(),
+		&pb.WatchTransactionsRequest{})
+	if err != nil {
+		t.Fatalf("could not watch transactions: %v", err)
+	}
+	startWatching(t, s, stream)
+
+	s.shutdown()
+	for err == nil {
+		_, err = stream.Recv()
+	}
+	checkCode(t, err, codes.Unavailable)
+	checkCode(t, s.nextStreamErr(t), codes.Unavailable)
+
+	// watching is refused once the parser is shut down
+	stream, err = s.client.WatchTransactions(context.Background(),
+		&pb.WatchTransactionsRequest{})
+	if err != nil {
+		t.Fatalf("could not watch transactions: %v", err)
+	}
+	_, err = stream.Recv()
+	checkCode(t, err, codes.Unavailable)
+}
+
+func TestToPBTransaction(t *testing.T) {
+	transaction := &eth.Transaction{
+		Hash:            "0x01",
+		From:            "0xa1",
+		To:              "0xa2",
+		Nonce:           "0x2",
+		Value:           "0x64",
+		Input:           "0xa9059cbb",
+		Status:          eth.StatusMined,
+		BlockNumber:     "0x10",
+		Timestamp:       "0x64",
+		ContractAddress: "0xc1",
+		Decoded: &eth.Decoded{
+			Name:      "transfer",
+			Signature: "transfer(address,uint256)",
+			Args: []eth.DecodedArg{
+				{Name: "to", Type: "address", Value: "0xa3"},
+				{Name: "values", Type: "uint256[]", Value: []interface{}{"1", "2"}},
+				{Name: "info", Type: "(bool)", Value: map[string]interface{}{"ok": true}},
+			},
+			Source: eth.DecodedSourceStandard,
+		},
+		Fiat: map[string]string{"usd": "1.5"},
+	}
+
+	values, err := structpb.NewList([]interface{}{"1", "2"})
+	if err != nil {
+		t.Fatalf("could not create list: %v", err)
+	}
+	info, err := structpb.NewStruct(map[string]interface{}{"ok": true})
+	if err != nil {
+		t.Fatalf("could not create struct: %v", err)
+	}
+
+	expected := &pb.Transaction{
+		Hash:            "0x01",
+		From:            "0xa1",
+		To:              "0xa2",
+		Nonce:           "0x2",
+		Value:           "0x64",
+		Input:           "0xa9059cbb",
+		ContractAddress: "0xc1",
+		Status:          eth.StatusMined,
+		BlockNumber:     "0x10",
+		Timestamp:       "0x64",
+		Decoded: &pb.Decoded{
+			Name:      "transfer",
+			Signature: "transfer(address,uint256)",
+			Args: []*pb.DecodedArg{
+				{Name: "to", Type: "address", Value: structpb.NewStringValue("0xa3")},
+				{Name: "values", Type: "uint256[]", Value: structpb.NewListValue(values)},
+				{Name: "info", Type: "(bool)", Value: structpb.NewStructValue(info)},
+			},
+			Source: eth.DecodedSourceStandard,
+		},
+		Fiat: map[string]string{"usd": "1.5"},
+	}
+
+	if converted := toPBTransaction(transaction); !proto.Equal(converted, expected) {
+		t.Errorf("transactions are not equal:\nhave: %v\nwant: %v", converted, expected)
+	}
+}
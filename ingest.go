@@ -0,0 +1,248 @@
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+
+	"eth-parser/abi"
+	"eth-parser/eth"
+	"eth-parser/parser"
+	"eth-parser/poller"
+	"eth-parser/prices"
+	"eth-parser/storages"
+)
+
+// ingestedTransaction is a line of ingest and backfill output, a transaction
+// matched for the subscribed address
+type ingestedTransaction struct {
+	Address string `json:"address"`
+	eth.Transaction
+}
+
+// runIngest matches transactions of blocks read from files against the
+// passed addresses and writes the matched transactions as JSONL. The chain
+// and the parser are configured as for serving, with the poller section
+// describing the chain of the files.
+func runIngest(args []string) error {
+	fs, cfg, configPath := newConfigFlagSet("ingest")
+	fromFile := fs.String("from-file",
+		"", "comma separated block files, JSONL of eth_getBlockByNumber results, may be gzipped")
+	matchFlags := registerMatchFlags(fs)
+	fs.Parse(args)
+
+	logger, err := loadConfig(fs, cfg, *configPath)
+	if err != nil {
+		return err
+	}
+
+	chainLogger := logger.With("chain_id", cfg.Poller.ChainID)
+	fileStream := poller.NewFileStream(splitList(*fromFile), cfg.Poller.ChainID,
+		cfg.Poller.QueueLen, chainLogger)
+	p := parser.NewParser(&cfg.Parser, fileStream, nil,
+		storages.NewTransactionsMapStorage(false, chainLogger),
+		storages.NewAddressesMapStorage(chainLogger),
+		storages.NewLogsMapStorage(false, chainLogger),
+		abi.NewRegistry(&cfg.ABI, cfg.Poller.ChainID, chainLogger),
+		prices.NewProvider(&cfg.Prices, chainLogger), chainLogger)
+
+	err = matchTransactions(p, matchFlags, fileStream.Err, logger)
+	if err != nil {
+		return fmt.Errorf("could not ingest block files: %w", err)
+	}
+
+	return nil
+}
+
+// runBackfill matches transactions of the range of blocks polled from the
+// endpoint against the passed addresses and writes the matched transactions
+// as JSONL, the endpoint is configured as for serving
+func runBackfill(args []string) error {
+	fs, cfg, configPath := newConfigFlagSet("backfill")
+	from := fs.Int64("from",
+		-1, "first block of the range")
+	to := fs.Int64("to",
+		-1, "last block of the range, blocks ahead of the head are waited for")
+	matchFlags := registerMatchFlags(fs)
+	fs.Parse(args)
+
+	logger, err := loadConfig(fs, cfg, *configPath)
+	if err != nil {
+		return err
+	}
+	if *from < 0 || *to < *from {
+		return fmt.Errorf("invalid range %d..%d, pass -from and -to", *from, *to)
+	}
+
+	chainLogger := logger.With("chain_id", cfg.Poller.ChainID)
+	ethPoller := poller.NewEthPoller(&cfg.Poller, chainLogger)
+	ethPoller.SetRange(*from, *to)
+	p := parser.NewParser(&cfg.Parser, ethPoller, nil,
+		storages.NewTransactionsMapStorage(false, chainLogger),
+		storages.NewAddressesMapStorage(chainLogger),
+		storages.NewLogsMapStorage(false, chainLogger),
+		abi.NewRegistry(&cfg.ABI, cfg.Poller.ChainID, chainLogger),
+		prices.NewProvider(&cfg.Prices, chainLogger), chainLogger)
+
+	if err := matchTransactions(p, matchFlags, nil, logger); err != nil {
+		return fmt.Errorf("could not backfill: %w", err)
+	}
+
+	return nil
+}
+
+// matchFlags are flags of the commands matching transactions offline
+type matchFlags struct {
+	addresses     *string
+	addressesFile *string
+	out           *string
+}
+
+func registerMatchFlags(fs *flag.FlagSet) *matchFlags {
+	return &matchFlags{
+		addresses: fs.String("addresses",
+			"", "comma separated addresses to match"),
+		addressesFile: fs.String("addresses-file",
+			"", "CSV file with addresses to match in the first column"),
+		out: fs.String("out",
+			"", "file to write matched transactions to as JSONL, stdout if empty"),
+	}
+}
+
+// matchTransactions subscribes the parser to the addresses of flags, parses
+// all blocks of its stream and writes the matched transactions. streamErr
+// returns the error which stopped the stream, it may be nil.
+func matchTransactions(
+	p *parser.Parser,
+	flags *matchFlags,
+	streamErr func() error,
+	logger *slog.Logger,
+) error {
+	addresses, err := ingestAddresses(*flags.addresses, *flags.addressesFile)
+	if err != nil {
+		return err
+	}
+	if len(addresses) == 0 {
+		return fmt.Errorf("no addresses to match, pass -addresses or -addresses-file")
+	}
+
+	output := io.Writer(os.Stdout)
+	if len(*flags.out) != 0 {
+		file, err := os.Create(*flags.out)
+		if err != nil {
+			return fmt.Errorf("could not create output file: %w", err)
+		}
+		defer file.Close()
+		output = file
+	}
+
+	if err := p.Init(); err != nil {
+		return fmt.Errorf("could not init parser: %w", err)
+	}
+
+	errs, err := p.SubscribeBatch("", addresses, 0)
+	if err != nil {
+		return fmt.Errorf("could not subscribe: %w", err)
+	}
+	for i, err := range errs {
+		if err != nil {
+			return fmt.Errorf("could not subscribe %s: %w", addresses[i], err)
+		}
+	}
+
+	// routine returns after the last block of the stream is parsed
+	p.Routine()
+	defer p.Shutdown()
+
+	if streamErr != nil {
+		if err := streamErr(); err != nil {
+			return err
+		}
+	}
+
+	writer := bufio.NewWriter(output)
+	encoder := json.NewEncoder(writer)
+	matched := 0
+	for _, address := range addresses {
+		transactions, err := p.GetTransactions("", address)
+		if err != nil {
+			return fmt.Errorf("could not get transactions of %s: %w", address, err)
+		}
+		for _, transaction := range transactions {
+			err := encoder.Encode(&ingestedTransaction{Address: address, Transaction: transaction})
+			if err != nil {
+				return fmt.Errorf("could not write transaction: %w", err)
+			}
+			matched++
+		}
+	}
+	if err := writer.Flush(); err != nil {
+		return fmt.Errorf("could not write transactions: %w", err)
+	}
+
+	logger.Info("matched transactions", "component", "main",
+		"last_block", p.GetCurrentBlock(), "addresses", len(addresses), "transactions", matched)
+	return nil
+}
+
+// ingestAddresses returns normalized addresses of the list and the CSV file
+func ingestAddresses(list, path string) ([]string, error) {
+	rawAddresses := splitList(list)
+
+	if len(path) != 0 {
+		fileAddresses, err := readAddressesFile(path)
+		if err != nil {
+			return nil, err
+		}
+		rawAddresses = append(rawAddresses, fileAddresses...)
+	}
+
+	addresses := make([]string, 0, len(rawAddresses))
+	seen := make(map[string]struct{}, len(rawAddresses))
+	for _, rawAddress := range rawAddresses {
+		address, err := eth.NormalizeAddress(rawAddress)
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := seen[address]; ok {
+			continue
+		}
+		seen[address] = struct{}{}
+		addresses = append(addresses, address)
+	}
+
+	return addresses, nil
+}
+
+// readAddressesFile returns addresses of the first column of the CSV file,
+// the header is optional
+func readAddressesFile(path string) ([]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not open addresses file: %w", err)
+	}
+	defer file.Close()
+
+	reader := csv.NewReader(file)
+	reader.FieldsPerRecord = -1
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("could not read addresses file: %w", err)
+	}
+
+	addresses := make([]string, 0, len(records))
+	for i, record := range records {
+		if i == 0 && strings.EqualFold(strings.TrimSpace(record[0]), "address") {
+			continue
+		}
+		addresses = append(addresses, strings.TrimSpace(record[0]))
+	}
+
+	return addresses, nil
+}
@@ -1,21 +1,65 @@
 package jsonrpc
 
+import (
+	"bytes"
+	"encoding/json"
+	"strconv"
+)
+
 const (
 	Version = "2.0"
 
+	CodeParseError     = -32700
+	CodeInvalidRequest = -32600
+	CodeMethodNotFound = -32601
+	CodeInvalidParams  = -32602
+	CodeInternalError  = -32603
+
 	CodeResourceNotFoundError = -32001 // cloudflare custom error
+	CodeLimitExceededError    = -32005 // EIP-1474
+	CodeExecutionReverted     = 3      // geth, reverted eth_call
 )
 
+// Packet is request or response. ID is kept raw, so that ids of any type are
+// echoed back unchanged, it is empty for notifications and marshaled as null
+// if it is not set.
 type Packet struct {
-	JSONRPC string      `json:"jsonrpc"`
-	ID      uint        `json:"id"`
-	Method  string      `json:"method,omitempty"`
-	Params  interface{} `json:"params,omitempty"`
-	Result  interface{} `json:"result,omitempty"`
-	Error   *Error      `json:"error,omitempty"`
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Method  string          `json:"method,omitempty"`
+	Params  interface{}     `json:"params,omitempty"`
+	Result  interface{}     `json:"result,omitempty"`
+	Error   *Error          `json:"error,omitempty"`
 }
 
 type Error struct {
 	Code    int    `json:"code"`
 	Message string `json:"message"`
 }
+
+// NumericID returns raw ID of the number
+func NumericID(id uint64) json.RawMessage {
+	return json.RawMessage(strconv.FormatUint(id, 10))
+}
+
+// IsNotification reports whether request has no ID, such requests are not
+// responded to
+func (p *Packet) IsNotification() bool {
+	return len(p.ID) == 0
+}
+
+// ValidID reports whether ID is a string, a number or null as it is required
+// by the specification, empty ID of notification is valid as well
+func (p *Packet) ValidID() bool {
+	id := bytes.TrimSpace(p.ID)
+	if len(id) == 0 {
+		return true
+	}
+
+	switch id[0] {
+	case '"', 'n', '-', '0', '1', '2', '3', '4', '5', '6', '7', '8', '9':
+		return true
+	default:
+		return false
+	}
+}
@@ -2,100 +2,388 @@ package main
 
 import (
 	"context"
-	"flag"
-	"log"
+	"fmt"
+	"log/slog"
 	"os"
 	"os/signal"
+	"strings"
+	"sync"
 	"time"
 
+	"google.golang.org/grpc"
+
+	"eth-parser/abi"
+	"eth-parser/auth"
+	"eth-parser/config"
+	"eth-parser/eth"
+	"eth-parser/grpcapi"
 	"eth-parser/parser"
 	"eth-parser/poller"
+	"eth-parser/prices"
 	"eth-parser/server"
+	"eth-parser/sinks"
 	"eth-parser/storages"
 )
 
 const (
-	cloudflareEndpoint = "https://cloudflare-eth.com"
-
-	serverShutdownTimeout = 5 * time.Second
-
-	defaultServerAddr                = "localhost:8080"
-	defaultStorageReset              = false
-	defaultPollerEndpoint            = cloudflareEndpoint
-	defaultPollerPollInterval        = 1 * time.Second
-	defaultPollerTimeout             = 5 * time.Second
-	defaultPollerMaxIdleConns        = 100
-	defaultPollerMaxConnsPerHost     = 100
-	defaultPollerMaxIdleConnsPerHost = 100
-	defaultPollerNumRetries          = 3
-	defaultPollerQueueLen            = 10
+	serverShutdownTimeout   = 5 * time.Second
+	parserInitRetryInterval = 5 * time.Second
 )
 
-var (
-	serverAddr = flag.String("server.addr",
-		defaultServerAddr, "server addr to listen on")
-
-	storageReset = flag.Bool("storage.reset",
-		defaultStorageReset, "reset stored transactions after getting them")
-
-	pollerEndpoint = flag.String("poller.endpoint",
-		defaultPollerEndpoint, "endpoint for poller to get info about ETH blocks")
-	pollerPollInterval = flag.Duration("poller.interval",
-		defaultPollerPollInterval, "poll interval")
-	pollerTimeout = flag.Duration("poller.timeout",
-		defaultPollerTimeout, "endpoint request timeout")
-	pollerMaxIdleConns = flag.Int("poller.max_idle_conns",
-		defaultPollerMaxIdleConns, "max idle conns")
-	pollerMaxConnsPerHost = flag.Int("poller.max_conns_per_host",
-		defaultPollerMaxConnsPerHost, "max conns per host")
-	pollerMaxIdleConnsPerHost = flag.Int("poller.max_idle_conns_per_host",
-		defaultPollerMaxIdleConnsPerHost, "max idle conns per host")
-	pollerNumRetries = flag.Int("poller.num_retries",
-		defaultPollerNumRetries, "num retries")
-	pollerQueueLen = flag.Int("poller.queue_len",
-		defaultPollerQueueLen, "queue length")
+// transactionsBackend and addressesBackend are implemented by storages of
+// every backend
+type (
+	transactionsBackend interface {
+		Init() error
+		Shutdown() error
+		Store(tenant, address string, transaction eth.Transaction) error
+		Get(tenant, address string) ([]eth.Transaction, error)
+		GetPage(tenant, address string, offset, limit int) ([]eth.Transaction, error)
+	}
+
+	addressesBackend interface {
+		Init() error
+		Shutdown() error
+		Store(tenant, address string) error
+		StoreBatch(tenant string, addresses []string) error
+		Delete(tenant, address string) error
+		Tenants(address string) []string
+		Count(tenant string) (int, error)
+		TenantAddresses(tenant string) ([]string, error)
+		Addresses() ([]string, error)
+	}
+
+	// sharedStorage is the database of storages of all chains
+	sharedStorage interface {
+		Init() error
+		Shutdown() error
+
+		// Checkpoint returns the last block stored for the chain
+		Checkpoint(chainID uint64) (int64, bool, error)
+	}
 )
 
 func main() {
-	flag.Parse()
+	// serving is the default command, so flags may be passed without it
+	name, args := "serve", os.Args[1:]
+	if len(args) != 0 && !strings.HasPrefix(args[0], "-") {
+		name, args = args[0], args[1:]
+	}
 
-	pollerConfig := &poller.EthPollerConfig{
-		Endpoint:            *pollerEndpoint,
-		PollInterval:        *pollerPollInterval,
-		Timeout:             *pollerTimeout,
-		MaxIdleConns:        *pollerMaxIdleConns,
-		MaxConnsPerHost:     *pollerMaxConnsPerHost,
-		MaxIdleConnsPerHost: *pollerMaxIdleConnsPerHost,
-		NumRetries:          *pollerNumRetries,
-		QueueLen:            *pollerQueueLen,
+	cmd := findCommand(name)
+	if cmd == nil {
+		fmt.Fprintf(os.Stderr, "unknown command %s\n\n", name)
+		printUsage()
+		os.Exit(2)
 	}
 
-	ethPoller := poller.NewEthPoller(pollerConfig)
-	transactionsStorage := storages.NewTransactionsMapStorage(*storageReset)
-	addressesStorage := storages.NewAddressesMapStorage()
+	if err := cmd.run(args); err != nil {
+		slog.Error("command failed", "component", "main", "command", name, "error", err)
+		os.Exit(1)
+	}
+}
 
-	p := parser.NewParser(ethPoller, transactionsStorage, addressesStorage)
+// runServe polls the chains and serves the API until SIGINT
+func runServe(args []string) error {
+	fs, cfg, configPath := newConfigFlagSet("serve")
+	configPrint := fs.Bool("config.print",
+		false, "print effective configuration and exit")
+	fs.Parse(args)
 
-	if err := p.Init(); err != nil {
-		log.Fatalf("main: could not init parser: %s", err)
+	if err := cfg.Load(*configPath, fs, os.LookupEnv); err != nil {
+		return fmt.Errorf("could not load config: %w", err)
 	}
 
-	go p.Routine()
-	defer p.Shutdown()
+	if *configPrint {
+		printConfig(cfg)
+		return nil
+	}
 
-	log.Printf("main: starting HTTP server")
-	httpServer, httpServerExit := server.InitHTTPServer(server.NewHandler(p), *serverAddr)
+	logger, err := newLogger(cfg)
+	if err != nil {
+		return err
+	}
+	slog.SetDefault(logger)
+
+	mainLogger := logger.With("component", "main")
+
+	db, err := initStorage(cfg, logger)
+	if err != nil {
+		return err
+	}
+	if db != nil {
+		// the database is closed after parsers, which store to it
+		defer db.Shutdown()
+	}
+
+	sinkList, checkpoints, err := initSinks(cfg, logger)
+	if err != nil {
+		return err
+	}
+	// sinks are shut down after parsers, which publish to them
+	defer shutdownSinks(sinkList, mainLogger)
+
+	chainPollers := cfg.ChainPollers()
+	chains := make(parser.Chains, len(chainPollers))
+	for i := range chainPollers {
+		pollerConfig := &chainPollers[i]
+		chainLogger := logger.With("chain_id", pollerConfig.ChainID)
+
+		p, ethPoller := newChainParser(cfg, db, pollerConfig, chainLogger)
+		if len(sinkList) != 0 {
+			p.SetSinks(sinkList, checkpoints)
+		}
+		if ethPoller != nil {
+			// replayed blocks are not resumed
+			err := resumePoller(ethPoller, pollerConfig.ChainID, checkpoints, db, chainLogger)
+			if err != nil {
+				return err
+			}
+		}
+		chains[pollerConfig.ChainID] = p
+	}
 
-	stop := make(chan os.Signal, 1)
-	signal.Notify(stop, os.Interrupt)
-	<-stop
-	log.Printf("main: got SIGINT")
+	authenticator := auth.NewAuthenticator(&cfg.Auth)
+
+	mainLogger.Info("starting HTTP server", "addr", cfg.Server.Addr)
+	httpServer, httpServerExit := server.InitHTTPServer(
+		server.NewHandler(chains, &cfg.Health, authenticator, logger),
+		cfg.Server.Addr, logger)
+
+	var grpcServer *grpc.Server
+	var grpcServerExit <-chan struct{}
+	if len(cfg.GRPC.Addr) != 0 {
+		mainLogger.Info("starting gRPC server", "addr", cfg.GRPC.Addr)
+		grpcServer, grpcServerExit = grpcapi.InitGRPCServer(
+			grpcapi.NewServer(chains, authenticator, logger), cfg.GRPC.Addr, logger)
+	}
+
+	stopCtx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	var initWG sync.WaitGroup
+	started := make(chan *parser.Parser, len(chains))
+	for chainID, p := range chains {
+		initWG.Add(1)
+		go func(p *parser.Parser, logger *slog.Logger) {
+			defer initWG.Done()
+
+			if initParser(stopCtx, p, logger) {
+				go p.Routine()
+				started <- p
+			}
+		}(p, mainLogger.With("chain_id", chainID))
+	}
+
+	<-stopCtx.Done()
+	mainLogger.Info("got SIGINT")
+
+	initWG.Wait()
+	close(started)
 
 	ctx, cancel := context.WithTimeout(context.Background(), serverShutdownTimeout)
 	defer cancel()
 	if err := httpServer.Shutdown(ctx); err != nil {
-		log.Fatalf("main: could not shutdown server successfully: %s", err)
+		return fmt.Errorf("could not shutdown server successfully: %w", err)
 	}
 
 	<-httpServerExit
+
+	if grpcServer != nil {
+		grpcapi.Shutdown(ctx, grpcServer)
+		<-grpcServerExit
+	}
+
+	for p := range started {
+		p.Shutdown()
+	}
+
+	return nil
+}
+
+// newChainParser creates parser of a single chain with its own poller and
+// storages, the poller is nil if recorded blocks are replayed. Storages of
+// the chain are kept in db if it is not nil, and in memory otherwise.
+func newChainParser(
+	cfg *config.Config,
+	db sharedStorage,
+	pollerConfig *poller.EthPollerConfig,
+	logger *slog.Logger,
+) (*parser.Parser, *poller.EthPoller) {
+	var transactionsStorage transactionsBackend
+	var addressesStorage addressesBackend
+	switch db := db.(type) {
+	case *storages.PostgresDB:
+		transactionsStorage = storages.NewTransactionsPostgresStorage(
+			db, pollerConfig.ChainID, logger)
+		addressesStorage = storages.NewAddressesPostgresStorage(db, pollerConfig.ChainID, logger)
+	case *storages.RedisDB:
+		transactionsStorage = storages.NewTransactionsRedisStorage(db, pollerConfig.ChainID, logger)
+		addressesStorage = storages.NewAddressesRedisStorage(db, pollerConfig.ChainID, logger)
+	default:
+		transactionsStorage = storages.NewTransactionsMapStorage(cfg.Storage.Reset, logger)
+		addressesStorage = storages.NewAddressesMapStorage(logger)
+	}
+
+	logsStorage := storages.NewLogsMapStorage(cfg.Storage.Reset, logger)
+	abiRegistry := abi.NewRegistry(&cfg.ABI, pollerConfig.ChainID, logger)
+	priceProvider := prices.NewProvider(&cfg.Prices, logger)
+
+	if len(pollerConfig.Replay.File) != 0 {
+		// recorded blocks are replayed without the endpoint, so there are no
+		// pending transactions, and Bloom filter is not worth it
+		replayStream := poller.NewReplayStream(pollerConfig, logger)
+		return parser.NewParser(&cfg.Parser, replayStream, nil, transactionsStorage,
+			addressesStorage, logsStorage, abiRegistry, priceProvider, logger), nil
+	}
+
+	ethPoller := poller.NewEthPoller(pollerConfig, logger)
+	pendingPoller := poller.NewPendingPoller(ethPoller, logger)
+
+	if cfg.Storage.BloomFilter.ExpectedAddresses > 0 {
+		bloomStorage := storages.NewBloomAddressesStorage(
+			addressesStorage, &cfg.Storage.BloomFilter, logger)
+		return parser.NewParser(&cfg.Parser, ethPoller, pendingPoller, transactionsStorage,
+			bloomStorage, logsStorage, abiRegistry, priceProvider, logger), ethPoller
+	}
+
+	return parser.NewParser(&cfg.Parser, ethPoller, pendingPoller, transactionsStorage,
+		addressesStorage, logsStorage, abiRegistry, priceProvider, logger), ethPoller
+}
+
+// initSinks creates and initializes configured sinks and checkpoints storage,
+// which is nil if checkpoints are disabled. Sinks are shared by parsers of
+// all chains.
+func initSinks(
+	cfg *config.Config,
+	logger *slog.Logger,
+) ([]parser.Sink, *storages.CheckpointsFileStorage, error) {
+	sinkList := sinks.New(&cfg.Sinks, logger)
+	for i, sink := range sinkList {
+		if err := sink.Init(); err != nil {
+			shutdownSinks(sinkList[:i], logger)
+			return nil, nil, fmt.Errorf("could not initialize %s sink: %w", sink.Name(), err)
+		}
+	}
+
+	if len(sinkList) == 0 || len(cfg.Sinks.CheckpointFile) == 0 {
+		return sinkList, nil, nil
+	}
+
+	checkpoints := storages.NewCheckpointsFileStorage(cfg.Sinks.CheckpointFile, logger)
+	if err := checkpoints.Init(); err != nil {
+		shutdownSinks(sinkList, logger)
+		return nil, nil, fmt.Errorf("could not initialize checkpoints storage: %w", err)
+	}
+
+	return sinkList, checkpoints, nil
+}
+
+// initStorage connects to the database shared by storages of all chains,
+// nil is returned if storages are kept in memory
+func initStorage(cfg *config.Config, logger *slog.Logger) (sharedStorage, error) {
+	var db sharedStorage
+	switch cfg.Storage.Backend {
+	case storages.BackendPostgres:
+		db = storages.NewPostgresDB(&cfg.Storage.Postgres, logger)
+	case storages.BackendRedis:
+		db = storages.NewRedisDB(&cfg.Storage.Redis, logger)
+	default:
+		return nil, nil
+	}
+
+	if err := db.Init(); err != nil {
+		return nil, fmt.Errorf("could not initialize %s storage: %w", cfg.Storage.Backend, err)
+	}
+
+	return db, nil
+}
+
+// resumePoller makes polling resume after the last checkpointed block of the
+// chain. If blocks are checkpointed both by sinks and by the storage, polling
+// resumes after the earliest of them, so that neither misses blocks.
+func resumePoller(
+	ethPoller *poller.EthPoller,
+	chainID uint64,
+	checkpoints *storages.CheckpointsFileStorage,
+	db sharedStorage,
+	logger *slog.Logger,
+) error {
+	resumed := false
+	var last int64
+	resume := func(blockNumber int64, ok bool, err error) error {
+		if err != nil {
+			return fmt.Errorf("could not get checkpoint of chain %d: %w", chainID, err)
+		}
+		if ok && (!resumed || blockNumber < last) {
+			resumed = true
+			last = blockNumber
+		}
+		return nil
+	}
+
+	if checkpoints != nil {
+		if err := resume(checkpoints.Get(chainID)); err != nil {
+			return err
+		}
+	}
+	if db != nil {
+		if err := resume(db.Checkpoint(chainID)); err != nil {
+			return err
+		}
+	}
+
+	if resumed {
+		logger.Info("resuming after checkpoint", "component", "main", "block", last)
+		ethPoller.SetStart(last + 1)
+	}
+
+	return nil
+}
+
+func shutdownSinks(sinkList []parser.Sink, logger *slog.Logger) {
+	for _, sink := range sinkList {
+		if err := sink.Shutdown(); err != nil {
+			logger.Error("got err on sink shutdown", "sink", sink.Name(), "error", err)
+		}
+	}
+}
+
+// initParser initializes parser retrying on failures, so that readiness probe
+// could report the error meanwhile. Returns false if ctx was done before
+// parser was initialized.
+func initParser(ctx context.Context, p *parser.Parser, logger *slog.Logger) bool {
+	for {
+		err := p.Init()
+		if err == nil {
+			return true
+		}
+
+		logger.Error("could not init parser, will retry",
+			"retry_interval", parserInitRetryInterval, "error", err)
+
+		select {
+		case <-ctx.Done():
+			return false
+		case <-time.After(parserInitRetryInterval):
+			// retrying, pass
+		}
+	}
+}
+
+// printConfig prints effective configuration to stdout and problems found in it
+// to stderr, exits with non-zero code if the configuration is invalid
+func printConfig(cfg *config.Config) {
+	data, err := cfg.Marshal()
+	if err != nil {
+		slog.Error("could not marshal config", "component", "main", "error", err)
+		os.Exit(1)
+	}
+
+	fmt.Print(string(data))
+
+	if err := cfg.Validate(); err != nil {
+		fmt.Fprintf(os.Stderr, "invalid config:\n%s\n", err)
+		os.Exit(1)
+	}
 }
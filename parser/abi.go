@@ -0,0 +1,60 @@
+package parser
+
+import (
+	"fmt"
+
+	"eth-parser/eth"
+)
+
+type abiRegistry interface {
+	Init() error
+	Shutdown() error
+
+	// Store stores JSON ABI of the contract
+	Store(address string, data []byte) error
+
+	// Get returns JSON ABI stored for the contract
+	Get(address string) ([]byte, bool)
+
+	// DecodeInput decodes input of transaction, nil is returned if the method
+	// is unknown
+	DecodeInput(to, input string) *eth.Decoded
+
+	// DecodeLog decodes event of the log, nil is returned if it is unknown
+	DecodeLog(log *eth.Log) *eth.Decoded
+}
+
+// decodeTransaction decodes the method called by transaction, it is called
+// for stored transactions only
+func (p *Parser) decodeTransaction(transaction *eth.Transaction) {
+	if transaction.Decoded != nil || len(transaction.To) == 0 {
+		return
+	}
+
+	transaction.Decoded = p.abis.DecodeInput(transaction.To, transaction.Input)
+}
+
+// StoreABI stores JSON ABI used to decode transactions and logs of the
+// contract, it is shared by all tenants
+func (p *Parser) StoreABI(address string, data []byte) error {
+	if p == nil || p.abis == nil {
+		return ErrUninitialized
+	}
+
+	if err := p.abis.Store(address, data); err != nil {
+		p.logger.Warn("could not store ABI", "address", address, "error", err)
+		return fmt.Errorf("could not store ABI: %w", err)
+	}
+
+	p.logger.Info("stored ABI successfully", "address", address)
+	return nil
+}
+
+// GetABI returns JSON ABI stored for the contract
+func (p *Parser) GetABI(address string) ([]byte, bool) {
+	if p == nil || p.abis == nil {
+		return nil, false
+	}
+
+	return p.abis.Get(address)
+}
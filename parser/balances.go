@@ -0,0 +1,438 @@
+package parser
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/big"
+	"sort"
+	"sync"
+
+	"eth-parser/eth"
+)
+
+// transferTopic is topic0 of Transfer(address,address,uint256) event
+const transferTopic = "0xddf252ad1be2c89b69c2b068fc378daa952ba7f163c4a11628f55a4df523b3ef"
+
+var (
+	ErrBalancesDisabled = fmt.Errorf("balances tracking is disabled")
+	ErrNotSubscribed    = fmt.Errorf("address is not subscribed")
+	ErrInvalidToken     = fmt.Errorf("token has no balances")
+
+	// gwei is the number of wei in Gwei, withdrawals amounts are in Gwei
+	gwei = big.NewInt(1_000_000_000)
+)
+
+type BalancesConfig struct {
+	// Enabled enables tracking of balances of subscribed addresses
+	Enabled bool `yaml:"enabled"`
+
+	// Tokens enables tracking of ERC-20 balances by Transfer events
+	Tokens bool `yaml:"tokens"`
+
+	// ReconcileBlocks is the number of blocks between reconciliations of
+	// tracked balances with the chain, balances are not reconciled if zero
+	ReconcileBlocks int64 `yaml:"reconcile_blocks"`
+}
+
+// Balance is running balance of address maintained by the parser
+type Balance struct {
+	// Token is the address of ERC-20 token, it is empty for ETH
+	Token string
+	Value *big.Int
+	// BlockNumber is the number of the last block applied to the balance
+	BlockNumber int64
+
+	// ChainValue is the balance reported by the chain at the last
+	// reconciliation, Drift is its difference from the tracked balance which
+	// was replaced with ChainValue. Both are nil if balance was not
+	// reconciled yet.
+	ChainValue            *big.Int
+	Drift                 *big.Int
+	ReconciledBlockNumber int64
+}
+
+// balanceBook keeps balances tracked by the parser, they are updated by
+// Routine only
+type balanceBook struct {
+	tracked map[eth.Holding]*Balance
+	// requested are holdings to start tracking from the next block
+	requested map[eth.Holding]struct{}
+	// rejected are holdings without balance on the chain, e.g. of tokens
+	// which are not ERC-20, they are not requested again
+	rejected             map[eth.Holding]error
+	lastReconciledNumber int64
+	mu                   sync.RWMutex
+}
+
+func newBalanceBook() *balanceBook {
+	return &balanceBook{
+		tracked:   make(map[eth.Holding]*Balance),
+		requested: make(map[eth.Holding]struct{}),
+		rejected:  make(map[eth.Holding]error),
+	}
+}
+
+// processBalances applies transfers and fees of the block to the balances of
+// subscribed addresses. Balances which are not tracked yet are taken from the
+// chain at the block, they are reconciled with the chain every
+// ReconcileBlocks blocks.
+func (p *Parser) processBalances(block *eth.Block, logger *slog.Logger) {
+	number, err := eth.ParseBigQuantity(block.Number)
+	if err != nil {
+		logger.Error("could not parse block number", "error", err)
+		return
+	}
+	blockNumber := number.Int64()
+
+	deltas := make(map[eth.Holding]*big.Int)
+	p.transactionsDeltas(block, deltas, logger)
+	p.withdrawalsDeltas(block, deltas, logger)
+	if p.config.Balances.Tokens {
+		p.tokensDeltas(block, deltas, logger)
+	}
+
+	p.balances.mu.Lock()
+	var initial []eth.Holding
+	for holding, delta := range deltas {
+		if _, ok := p.balances.rejected[holding]; ok {
+			continue
+		}
+
+		balance, ok := p.balances.tracked[holding]
+		if !ok {
+			initial = append(initial, holding)
+			continue
+		}
+
+		balance.Value.Add(balance.Value, delta)
+		balance.BlockNumber = blockNumber
+	}
+	for holding := range p.balances.requested {
+		if _, ok := p.balances.tracked[holding]; !ok && deltas[holding] == nil {
+			initial = append(initial, holding)
+		}
+	}
+	clear(p.balances.requested)
+
+	reconcile := p.config.Balances.ReconcileBlocks > 0 &&
+		blockNumber-p.balances.lastReconciledNumber >= p.config.Balances.ReconcileBlocks
+	p.balances.mu.Unlock()
+
+	// balances at the block already include its deltas
+	if len(initial) != 0 {
+		p.initBalances(initial, block.Number, blockNumber, logger)
+	}
+	if reconcile {
+		p.reconcileBalances(block.Number, blockNumber, logger)
+	}
+}
+
+// transactionsDeltas adds values and fees of successful transactions and fees
+// of failed ones
+func (p *Parser) transactionsDeltas(
+	block *eth.Block,
+	deltas map[eth.Holding]*big.Int,
+	logger *slog.Logger,
+) {
+	for _, transaction := range block.Transactions {
+		fromSubscribed := len(p.subscriptions.Tenants(transaction.From)) != 0
+		toSubscribed := len(transaction.To) != 0 && len(p.subscriptions.Tenants(transaction.To)) != 0
+		if !fromSubscribed && !toSubscribed {
+			continue
+		}
+
+		receipt, err := p.receipt(transaction.Hash)
+		if err != nil {
+			logger.Error("could not get receipt, balances will drift until reconciled",
+				"tx_hash", transaction.Hash, "error", err)
+			continue
+		}
+
+		to := transaction.To
+		if len(to) == 0 {
+			to = receipt.ContractAddress
+			toSubscribed = len(to) != 0 && len(p.subscriptions.Tenants(to)) != 0
+		}
+
+		value, err := eth.ParseBigQuantity(transaction.Value)
+		if err != nil {
+			logger.Error("could not parse value", "tx_hash", transaction.Hash, "error", err)
+			continue
+		}
+		if receipt.Status != eth.ReceiptStatusSuccess {
+			value = new(big.Int)
+		}
+
+		if fromSubscribed {
+			gasUsed, err := eth.ParseBigQuantity(receipt.GasUsed)
+			if err != nil {
+				logger.Error("could not parse gas used", "tx_hash", transaction.Hash, "error", err)
+				continue
+			}
+			gasPrice, err := eth.ParseBigQuantity(receipt.EffectiveGasPrice)
+			if err != nil {
+				logger.Error("could not parse gas price", "tx_hash", transaction.Hash, "error", err)
+				continue
+			}
+
+			fee := new(big.Int).Mul(gasUsed, gasPrice)
+			addDelta(deltas, eth.Holding{Address: transaction.From}, new(big.Int).Neg(value))
+			addDelta(deltas, eth.Holding{Address: transaction.From}, fee.Neg(fee))
+		}
+		if toSubscribed {
+			addDelta(deltas, eth.Holding{Address: to}, value)
+		}
+	}
+}
+
+func (p *Parser) withdrawalsDeltas(
+	block *eth.Block,
+	deltas map[eth.Holding]*big.Int,
+	logger *slog.Logger,
+) {
+	for _, withdrawal := range block.Withdrawals {
+		if len(p.subscriptions.Tenants(withdrawal.Address)) == 0 {
+			continue
+		}
+
+		amount, err := eth.ParseBigQuantity(withdrawal.Amount)
+		if err != nil {
+			logger.Error("could not parse withdrawal amount",
+				"address", withdrawal.Address, "error", err)
+			continue
+		}
+
+		addDelta(deltas, eth.Holding{Address: withdrawal.Address}, amount.Mul(amount, gwei))
+	}
+}
+
+// tokensDeltas adds ERC-20 transfers of the block, all Transfer events of the
+// block are requested at once
+func (p *Parser) tokensDeltas(
+	block *eth.Block,
+	deltas map[eth.Holding]*big.Int,
+	logger *slog.Logger,
+) {
+	logs, err := p.ethStream.Logs(block.Hash, nil, [][]string{{transferTopic}})
+	if err != nil {
+		logger.Error("could not get transfer logs, balances will drift until reconciled",
+			"error", err)
+		return
+	}
+
+	for _, log := range logs {
+		// Transfer of ERC-721 has indexed token ID as the fourth topic
+		if len(log.Topics) != 3 || log.Removed {
+			continue
+		}
+
+		from, to := topicAddress(log.Topics[1]), topicAddress(log.Topics[2])
+		fromSubscribed := len(p.subscriptions.Tenants(from)) != 0
+		toSubscribed := len(p.subscriptions.Tenants(to)) != 0
+		if !fromSubscribed && !toSubscribed {
+			continue
+		}
+
+		value, err := eth.ParseBigQuantity(log.Data)
+		if err != nil {
+			logger.Warn("could not parse transfer value",
+				"tx_hash", log.TransactionHash, "token", log.Address, "error", err)
+			continue
+		}
+
+		if fromSubscribed {
+			addDelta(deltas, eth.Holding{Address: from, Token: log.Address}, new(big.Int).Neg(value))
+		}
+		if toSubscribed {
+			addDelta(deltas, eth.Holding{Address: to, Token: log.Address}, value)
+		}
+	}
+}
+
+// initBalances starts tracking of holdings with their balances at the block.
+// Holdings which failed to be got are retried on the next block, holdings
+// without balance are rejected.
+func (p *Parser) initBalances(
+	holdings []eth.Holding,
+	rawBlockNumber string,
+	blockNumber int64,
+	logger *slog.Logger,
+) {
+	values, errs, err := p.ethStream.BalancesAt(holdings, rawBlockNumber)
+	if err != nil {
+		logger.Error("could not get initial balances, retrying on the next block",
+			"holdings", len(holdings), "error", err)
+
+		p.balances.mu.Lock()
+		for _, holding := range holdings {
+			p.balances.requested[holding] = struct{}{}
+		}
+		p.balances.mu.Unlock()
+		return
+	}
+
+	p.balances.mu.Lock()
+	defer p.balances.mu.Unlock()
+
+	tracked := 0
+	for i, holding := range holdings {
+		switch {
+		case errors.Is(errs[i], eth.ErrNoBalance):
+			logger.Warn("holding has no balance, not tracking it",
+				"address", holding.Address, "token", holding.Token, "error", errs[i])
+			p.balances.rejected[holding] = errs[i]
+		case errs[i] != nil:
+			logger.Error("could not get initial balance, retrying on the next block",
+				"address", holding.Address, "token", holding.Token, "error", errs[i])
+			p.balances.requested[holding] = struct{}{}
+		default:
+			p.balances.tracked[holding] = &Balance{
+				Token:       holding.Token,
+				Value:       values[i],
+				BlockNumber: blockNumber,
+			}
+			tracked++
+		}
+	}
+
+	logger.Debug("started tracking balances", "holdings", tracked)
+}
+
+// reconcileBalances replaces tracked balances with the balances reported by
+// the chain at the block and records their drift. Balances of unsubscribed
+// addresses are no longer tracked.
+func (p *Parser) reconcileBalances(rawBlockNumber string, blockNumber int64, logger *slog.Logger) {
+	p.balances.mu.Lock()
+	holdings := make([]eth.Holding, 0, len(p.balances.tracked))
+	for holding := range p.balances.tracked {
+		if len(p.subscriptions.Tenants(holding.Address)) == 0 {
+			delete(p.balances.tracked, holding)
+			continue
+		}
+		holdings = append(holdings, holding)
+	}
+	if len(holdings) == 0 {
+		p.balances.lastReconciledNumber = blockNumber
+		p.balances.mu.Unlock()
+		return
+	}
+	p.balances.mu.Unlock()
+
+	values, errs, err := p.ethStream.BalancesAt(holdings, rawBlockNumber)
+	if err != nil {
+		logger.Error("could not reconcile balances", "holdings", len(holdings), "error", err)
+		return
+	}
+
+	p.balances.mu.Lock()
+	defer p.balances.mu.Unlock()
+
+	drifted, failed := 0, 0
+	for i, holding := range holdings {
+		// tracked balance is kept until the next reconciliation
+		if errs[i] != nil {
+			logger.Warn("could not reconcile balance",
+				"address", holding.Address, "token", holding.Token, "error", errs[i])
+			failed++
+			continue
+		}
+
+		balance := p.balances.tracked[holding]
+		balance.Drift = new(big.Int).Sub(values[i], balance.Value)
+		balance.ChainValue = values[i]
+		balance.Value = new(big.Int).Set(values[i])
+		balance.BlockNumber = blockNumber
+		balance.ReconciledBlockNumber = blockNumber
+
+		if balance.Drift.Sign() != 0 {
+			drifted++
+			logger.Debug("balance drifted", "address", holding.Address, "token", holding.Token,
+				"drift", balance.Drift.String())
+		}
+	}
+	p.balances.lastReconciledNumber = blockNumber
+
+	logger.Info("reconciled balances",
+		"holdings", len(holdings), "drifted", drifted, "failed", failed)
+}
+
+// GetBalances returns balances of address subscribed by tenant, ETH balance
+// goes first. Holdings which are not tracked yet are tracked from the next
+// block, token is tracked as well if it is not empty. ErrInvalidToken is
+// returned if the token has no balance of the address on the chain, and
+// eth.ErrInvalidAddress if the address or the token is not valid.
+func (p *Parser) GetBalances(tenant, address, token string) ([]Balance, error) {
+	if p == nil || p.balances == nil {
+		return nil, ErrUninitialized
+	}
+	if !p.config.Balances.Enabled {
+		return nil, ErrBalancesDisabled
+	}
+
+	address, err := eth.NormalizeAddress(address)
+	if err != nil {
+		return nil, err
+	}
+	if len(token) != 0 {
+		if token, err = eth.NormalizeAddress(token); err != nil {
+			return nil, err
+		}
+	}
+	if !p.isSubscribed(tenant, address) {
+		return nil, ErrNotSubscribed
+	}
+
+	p.balances.mu.Lock()
+	defer p.balances.mu.Unlock()
+
+	requested := []eth.Holding{{Address: address}}
+	if len(token) != 0 {
+		holding := eth.Holding{Address: address, Token: token}
+		if err, ok := p.balances.rejected[holding]; ok {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidToken, err)
+		}
+		requested = append(requested, holding)
+	}
+	for _, holding := range requested {
+		if _, ok := p.balances.tracked[holding]; !ok {
+			p.balances.requested[holding] = struct{}{}
+		}
+	}
+
+	var balances []Balance
+	for holding, balance := range p.balances.tracked {
+		if holding.Address != address {
+			continue
+		}
+
+		// value is updated in place by Routine
+		snapshot := *balance
+		snapshot.Value = new(big.Int).Set(balance.Value)
+		balances = append(balances, snapshot)
+	}
+	sort.Slice(balances, func(i, j int) bool {
+		return balances[i].Token < balances[j].Token
+	})
+
+	return balances, nil
+}
+
+func addDelta(deltas map[eth.Holding]*big.Int, holding eth.Holding, delta *big.Int) {
+	if current, ok := deltas[holding]; ok {
+		current.Add(current, delta)
+		return
+	}
+
+	deltas[holding] = new(big.Int).Set(delta)
+}
+
+// topicAddress returns address encoded in the indexed topic
+func topicAddress(topic string) string {
+	const addressHexLen = 40
+	if len(topic) < addressHexLen {
+		return ""
+	}
+
+	return "0x" + topic[len(topic)-addressHexLen:]
+}
@@ -0,0 +1,21 @@
+package parser
+
+import (
+	"sort"
+)
+
+// Chains maps chain ID to the parser of the chain
+type Chains map[uint64]*Parser
+
+// IDs returns sorted IDs of the chains
+func (c Chains) IDs() []uint64 {
+	ids := make([]uint64, 0, len(c))
+	for id := range c {
+		ids = append(ids, id)
+	}
+
+	sort.Slice(ids, func(i, j int) bool {
+		return ids[i] < ids[j]
+	})
+	return ids
+}
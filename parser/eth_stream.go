@@ -1,6 +1,9 @@
 package parser
 
 import (
+	"math/big"
+	"time"
+
 	"eth-parser/eth"
 )
 
@@ -14,6 +17,39 @@ type ethStream interface {
 	// BlocksQueue returns stream of parsed ETH blocks
 	BlocksQueue() <-chan *eth.Block
 
+	// ChainID returns ID of the chain the blocks belong to
+	ChainID() uint64
+
 	// LastBlockNumber returns number of last parsed block
 	LastBlockNumber() int64
+
+	// LastBlockUpdatedAt returns time when last parsed block number was advanced
+	LastBlockUpdatedAt() time.Time
+
+	// HeadBlockNumber returns number of the latest known block of the chain
+	HeadBlockNumber() int64
+
+	// Receipt returns receipt of mined transaction
+	Receipt(txHash string) (*eth.Receipt, error)
+
+	// Logs returns logs of the block with given hash emitted by given
+	// contracts and matched by topics, all contracts match if empty
+	Logs(blockHash string, addresses []string, topics [][]string) ([]eth.Log, error)
+
+	// BalancesAt returns balances of holdings at the block. Errors of
+	// separate holdings are returned at their indexes, they wrap
+	// eth.ErrNoBalance if the holding has no balance, the returned error
+	// fails all of them.
+	BalancesAt(holdings []eth.Holding, blockNumber string) ([]*big.Int, []error, error)
+}
+
+type pendingStream interface {
+	Init() error
+	Shutdown() error
+
+	// Routine starts getting pending transactions
+	Routine()
+
+	// PendingQueue returns stream of batches of pending transactions
+	PendingQueue() <-chan []eth.Transaction
 }
@@ -0,0 +1,109 @@
+package parser
+
+import (
+	"fmt"
+	"time"
+
+	"eth-parser/eth"
+)
+
+// exportPageSize is the number of transactions read from the storage at once
+// on export
+const exportPageSize = 1000
+
+// ExportRange limits exported transactions by numbers of their blocks, both
+// inclusive, and by time of their blocks, from inclusive and to exclusive,
+// so that consecutive months do not overlap. Zero bounds are open.
+type ExportRange struct {
+	FromBlock uint64
+	ToBlock   uint64
+	FromTime  time.Time
+	ToTime    time.Time
+}
+
+// ExportTransactions calls fn for every mined transaction stored for the
+// addresses subscribed by tenant within the range, address by address.
+// Transactions are read from the storage by pages, so that they are not
+// loaded in memory at once. Export stops on the first error of fn.
+func (p *Parser) ExportTransactions(
+	tenant string,
+	addresses []string,
+	exportRange ExportRange,
+	fn func(address string, transaction *eth.Transaction) error,
+) error {
+	if p == nil || p.transactions == nil {
+		return ErrUninitialized
+	}
+
+	for _, address := range addresses {
+		for offset := 0; ; offset += exportPageSize {
+			page, err := p.transactions.GetPage(tenant, address, offset, exportPageSize)
+			if err != nil {
+				p.logger.Error("could not get transactions",
+					"tenant", tenant, "address", address, "error", err)
+				return fmt.Errorf("could not get transactions: %w", err)
+			}
+
+			for i := range page {
+				ok, err := exportRange.contains(&page[i])
+				if err != nil {
+					return fmt.Errorf("transaction %s: %w", page[i].Hash, err)
+				}
+				if !ok {
+					continue
+				}
+
+				// transactions stored before prices were configured or
+				// when the price was unavailable are valuated on export
+				p.valuateTransaction(&page[i], p.logger)
+				if err := fn(address, &page[i]); err != nil {
+					return err
+				}
+			}
+
+			if len(page) < exportPageSize {
+				break
+			}
+		}
+	}
+
+	return nil
+}
+
+// contains reports whether transaction is mined within the range
+func (r *ExportRange) contains(transaction *eth.Transaction) (bool, error) {
+	// pending and dropped transactions have no block
+	if len(transaction.BlockNumber) == 0 {
+		return false, nil
+	}
+
+	rawNumber, err := eth.ParseBigQuantity(transaction.BlockNumber)
+	if err != nil {
+		return false, fmt.Errorf("invalid block number: %w", err)
+	}
+	number := rawNumber.Uint64()
+	if number < r.FromBlock || (r.ToBlock != 0 && number > r.ToBlock) {
+		return false, nil
+	}
+
+	if r.FromTime.IsZero() && r.ToTime.IsZero() {
+		return true, nil
+	}
+	if len(transaction.Timestamp) == 0 {
+		return false, nil
+	}
+
+	timestamp, err := eth.ParseBigQuantity(transaction.Timestamp)
+	if err != nil {
+		return false, fmt.Errorf("invalid timestamp: %w", err)
+	}
+	blockTime := time.Unix(timestamp.Int64(), 0)
+	if !r.FromTime.IsZero() && blockTime.Before(r.FromTime) {
+		return false, nil
+	}
+	if !r.ToTime.IsZero() && !blockTime.Before(r.ToTime) {
+		return false, nil
+	}
+
+	return true, nil
+}
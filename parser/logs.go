@@ -0,0 +1,202 @@
+package parser
+
+import (
+	"fmt"
+	"log/slog"
+	"time"
+
+	"eth-parser/eth"
+)
+
+// logSubscription is log filter subscribed by tenant
+type logSubscription struct {
+	tenant string
+	filter eth.LogFilter
+}
+
+// processLogs stores logs of the block matched by log filters, logs are
+// requested only for the contracts of the filters and are requested again
+// until they are got. False is returned if the parser is shut down meanwhile,
+// so that the block is not checkpointed and is processed again after restart.
+func (p *Parser) processLogs(block *eth.Block, logger *slog.Logger) bool {
+	filters, err := p.logs.AllFilters()
+	if err != nil {
+		logger.Error("could not get log filters", "error", err)
+		return true
+	}
+	if len(filters) == 0 {
+		return true
+	}
+
+	byAddress := make(map[string][]logSubscription)
+	for tenant, tenantFilters := range filters {
+		for _, filter := range tenantFilters {
+			byAddress[filter.Address] = append(byAddress[filter.Address],
+				logSubscription{tenant: tenant, filter: filter})
+		}
+	}
+
+	addresses := make([]string, 0, len(byAddress))
+	for address := range byAddress {
+		addresses = append(addresses, address)
+	}
+
+	var logs []eth.Log
+	got := p.retry(func() error {
+		logs, err = p.ethStream.Logs(block.Hash, addresses, nil)
+		return err
+	}, func(delay time.Duration, err error) {
+		logger.Warn("could not get logs, retrying",
+			"contracts", len(addresses), "delay", delay, "error", err)
+	})
+	if !got {
+		logger.Error("logs are not got on shutdown", "contracts", len(addresses))
+		return false
+	}
+
+	indexes := make(map[string]int, len(block.Transactions))
+	for i := range block.Transactions {
+		indexes[block.Transactions[i].Hash] = i
+	}
+
+	// matched logs keep copies of their transactions, so that the block is
+	// not kept with them
+	transactions := make(map[string]*eth.Transaction)
+	transactionOf := func(hash string) *eth.Transaction {
+		i, ok := indexes[hash]
+		if !ok {
+			return nil
+		}
+		if transactions[hash] == nil {
+			transaction := block.Transactions[i]
+			transactions[hash] = &transaction
+		}
+		return transactions[hash]
+	}
+
+	matched := 0
+	for _, log := range logs {
+		var decoded *eth.Decoded
+		for _, subscription := range byAddress[log.Address] {
+			if !subscription.filter.Matches(&log) {
+				continue
+			}
+
+			if decoded == nil {
+				decoded = p.abis.DecodeLog(&log)
+			}
+
+			matchedLog := eth.MatchedLog{
+				Log:         log,
+				Transaction: transactionOf(log.TransactionHash),
+				Decoded:     decoded,
+			}
+			err := p.logs.Store(subscription.tenant, subscription.filter.ID, matchedLog)
+			if err != nil {
+				logger.Error("could not store log", "tenant", subscription.tenant,
+					"filter_id", subscription.filter.ID, "tx_hash", log.TransactionHash,
+					"error", err)
+				continue
+			}
+			matched++
+		}
+	}
+
+	logger.Debug("processed logs", "logs", len(logs), "matched", matched)
+	return true
+}
+
+// SubscribeLogs subscribes tenant to the logs matched by filter and returns
+// the filter with its ID. Filters count against maxSubscriptions separately
+// from addresses.
+func (p *Parser) SubscribeLogs(
+	tenant string,
+	filter eth.LogFilter,
+	maxSubscriptions int,
+) (eth.LogFilter, error) {
+	if p == nil || p.logs == nil {
+		return eth.LogFilter{}, ErrUninitialized
+	}
+
+	filter, err := filter.Normalize()
+	if err != nil {
+		return eth.LogFilter{}, err
+	}
+
+	p.subscribeMu.Lock()
+	defer p.subscribeMu.Unlock()
+
+	filters, err := p.logs.Filters(tenant)
+	if err != nil {
+		p.logger.Error("could not get log filters", "tenant", tenant, "error", err)
+		return eth.LogFilter{}, fmt.Errorf("could not get log filters: %w", err)
+	}
+	for _, subscribed := range filters {
+		if subscribed.ID == filter.ID {
+			return filter, nil
+		}
+	}
+	if maxSubscriptions > 0 && len(filters) >= maxSubscriptions {
+		return eth.LogFilter{}, ErrSubscriptionsLimitExceeded
+	}
+
+	if err := p.logs.StoreFilter(tenant, filter); err != nil {
+		p.logger.Error("could not store log filter",
+			"tenant", tenant, "filter_id", filter.ID, "error", err)
+		return eth.LogFilter{}, fmt.Errorf("could not store log filter: %w", err)
+	}
+
+	p.logger.Info("subscribed to logs successfully",
+		"tenant", tenant, "filter_id", filter.ID, "address", filter.Address)
+	return filter, nil
+}
+
+// UnsubscribeLogs unsubscribes tenant from the logs of filter, matched logs
+// are deleted as well
+func (p *Parser) UnsubscribeLogs(tenant, filterID string) error {
+	if p == nil || p.logs == nil {
+		return ErrUninitialized
+	}
+
+	p.subscribeMu.Lock()
+	defer p.subscribeMu.Unlock()
+
+	if err := p.logs.DeleteFilter(tenant, filterID); err != nil {
+		p.logger.Error("could not delete log filter",
+			"tenant", tenant, "filter_id", filterID, "error", err)
+		return fmt.Errorf("could not delete log filter: %w", err)
+	}
+
+	p.logger.Info("unsubscribed from logs successfully", "tenant", tenant, "filter_id", filterID)
+	return nil
+}
+
+// GetLogFilters returns log filters subscribed by tenant
+func (p *Parser) GetLogFilters(tenant string) []eth.LogFilter {
+	if p == nil || p.logs == nil {
+		return nil
+	}
+
+	filters, err := p.logs.Filters(tenant)
+	if err != nil {
+		p.logger.Error("could not get log filters", "tenant", tenant, "error", err)
+		return nil
+	}
+
+	return filters
+}
+
+// GetLogs returns logs matched by filter of tenant
+func (p *Parser) GetLogs(tenant, filterID string) []eth.MatchedLog {
+	if p == nil || p.logs == nil {
+		return nil
+	}
+
+	result, err := p.logs.Get(tenant, filterID)
+	if err != nil {
+		p.logger.Error("could not get logs", "tenant", tenant, "filter_id", filterID, "error", err)
+		return nil
+	}
+
+	return result
+}
@@ -2,92 +2,527 @@ package parser
 
 import (
 	"fmt"
-	"log"
+	"log/slog"
+	"sort"
+	"sync"
+	"time"
 
 	"eth-parser/eth"
 )
 
+const (
+	retryMinDelay = 100 * time.Millisecond
+	retryMaxDelay = 30 * time.Second
+)
+
+var (
+	ErrUninitialized              = fmt.Errorf("parser is uninitialized")
+	ErrSubscriptionsLimitExceeded = fmt.Errorf("subscriptions limit exceeded")
+)
+
+type Config struct {
+	// AutoSubscribeContracts subscribes tenants to the contracts deployed by
+	// their subscribed addresses, subscriptions limits are not applied
+	AutoSubscribeContracts bool `yaml:"auto_subscribe_contracts"`
+
+	// PendingDropTimeout is the time after which pending transaction which
+	// was not mined is considered dropped
+	PendingDropTimeout time.Duration `yaml:"pending_drop_timeout"`
+
+	// WatchTimeout is the time the parser waits for the watcher whose queue
+	// is full before the watcher is closed
+	WatchTimeout time.Duration `yaml:"watch_timeout"`
+
+	Balances BalancesConfig `yaml:"balances"`
+}
+
 type Parser struct {
+	config *Config
+
 	ethStream     ethStream
+	pendingStream pendingStream
 	transactions  transactionsStorage
 	subscriptions addressesStorage
+	logs          logsStorage
+	abis          abiRegistry
+	prices        priceProvider
+
+	// blockTransactions is set if transactions storage stores whole blocks
+	blockTransactions blockTransactionsStorage
+
+	logger *slog.Logger
+
+	initialized bool
+	initErr     error
+	initMu      sync.RWMutex
+
+	// subscribeMu makes limit check and storing of subscription atomic
+	subscribeMu sync.Mutex
 
+	watchers       map[*Watcher]struct{}
+	watchersClosed bool
+	watchersMu     sync.Mutex
+
+	// pending keeps stored pending transactions by hash, pendingByNonce maps
+	// sender and nonce to the hash to detect replacements. Both are used by
+	// Routine only.
+	pending        map[string]*pendingTransaction
+	pendingByNonce map[string]string
+
+	balances *balanceBook
+
+	// blockBatch collects transactions of the processed block while batching
+	// is set, they are stored at once once the block is processed
+	blockBatch []eth.SubscribedTransaction
+	batching   bool
+
+	// receipts caches receipts fetched while the block is processed, so that
+	// every receipt is fetched once. Used by Routine only.
+	receipts map[string]*eth.Receipt
+
+	sinks       []Sink
+	checkpoints checkpointStorage
+	// sinkEvents are collected by Routine until the block or the batch of
+	// pending transactions is processed
+	sinkEvents []SinkEvent
+
+	// stopping is closed once shutdown starts to interrupt publishing
+	stopping chan struct{}
 	shutdown chan struct{}
 }
 
+// Status describes the state of the parser used for health reporting
+type Status struct {
+	Initialized bool
+	InitErr     error
+
+	LastBlockNumber    int64
+	LastBlockUpdatedAt time.Time
+	HeadBlockNumber    int64
+}
+
+// NewParser creates parser, pendingStream may be nil if pending transactions
+// are not watched and prices may be nil if transactions are not valuated
 func NewParser(
+	config *Config,
 	ethStream ethStream,
+	pendingStream pendingStream,
 	transactions transactionsStorage,
 	subscriptions addressesStorage,
+	logs logsStorage,
+	abis abiRegistry,
+	prices priceProvider,
+	logger *slog.Logger,
 ) *Parser {
+	blockTransactions, _ := transactions.(blockTransactionsStorage)
+
 	return &Parser{
+		config:        config,
 		ethStream:     ethStream,
+		pendingStream: pendingStream,
 		transactions:  transactions,
 		subscriptions: subscriptions,
-		shutdown:      make(chan struct{}),
+		logs:          logs,
+		abis:          abis,
+		prices:        prices,
+		logger:        logger.With("component", "parser"),
+		watchers:      make(map[*Watcher]struct{}),
+
+		blockTransactions: blockTransactions,
+
+		pending:        make(map[string]*pendingTransaction),
+		pendingByNonce: make(map[string]string),
+
+		receipts: make(map[string]*eth.Receipt),
+
+		balances: newBalanceBook(),
+
+		stopping: make(chan struct{}),
+		shutdown: make(chan struct{}),
 	}
 }
 
 func (p *Parser) Init() error {
-	log.Println("parser: initializing")
+	p.logger.Info("initializing")
+
+	err := p.init()
+
+	p.initMu.Lock()
+	p.initialized = err == nil
+	p.initErr = err
+	p.initMu.Unlock()
+
+	if err != nil {
+		return err
+	}
 
+	p.logger.Info("successfully initialized")
+	return nil
+}
+
+func (p *Parser) init() error {
 	if err := p.ethStream.Init(); err != nil {
 		return fmt.Errorf("could not initialize ETH stream: %w", err)
 	}
+	if p.pendingStream != nil {
+		if err := p.pendingStream.Init(); err != nil {
+			return fmt.Errorf("could not initialize pending stream: %w", err)
+		}
+	}
 	if err := p.transactions.Init(); err != nil {
 		return fmt.Errorf("could not initialize transactions storage: %w", err)
 	}
 	if err := p.subscriptions.Init(); err != nil {
 		return fmt.Errorf("could not initialize subscriptions storage: %w", err)
 	}
+	if err := p.logs.Init(); err != nil {
+		return fmt.Errorf("could not initialize logs storage: %w", err)
+	}
+	if err := p.abis.Init(); err != nil {
+		return fmt.Errorf("could not initialize ABI registry: %w", err)
+	}
+	if p.prices != nil {
+		if err := p.prices.Init(); err != nil {
+			return fmt.Errorf("could not initialize prices: %w", err)
+		}
+	}
 
-	log.Println("parser: successfully initialized")
 	return nil
 }
 
 func (p *Parser) Shutdown() {
-	log.Println("parser: starting shutdown")
+	p.logger.Info("starting shutdown")
+	close(p.stopping)
 
 	if err := p.ethStream.Shutdown(); err != nil {
-		log.Printf("parser: got err on ETH stream shutdown: %s", err)
+		p.logger.Error("got err on ETH stream shutdown", "error", err)
+	}
+	if p.pendingStream != nil {
+		if err := p.pendingStream.Shutdown(); err != nil {
+			p.logger.Error("got err on pending stream shutdown", "error", err)
+		}
 	}
 	<-p.shutdown
 
 	if err := p.transactions.Shutdown(); err != nil {
-		log.Printf("parser: got err on transactions storage shutdown: %s", err)
+		p.logger.Error("got err on transactions storage shutdown", "error", err)
 	}
 	if err := p.subscriptions.Shutdown(); err != nil {
-		log.Printf("parser: got err on subscriptions storage shutdown: %s", err)
+		p.logger.Error("got err on subscriptions storage shutdown", "error", err)
+	}
+	if err := p.logs.Shutdown(); err != nil {
+		p.logger.Error("got err on logs storage shutdown", "error", err)
+	}
+	if err := p.abis.Shutdown(); err != nil {
+		p.logger.Error("got err on ABI registry shutdown", "error", err)
+	}
+	if p.prices != nil {
+		if err := p.prices.Shutdown(); err != nil {
+			p.logger.Error("got err on prices shutdown", "error", err)
+		}
 	}
 
-	log.Println("parser: successfully shutdown")
+	p.logger.Info("successfully shutdown")
 }
 
 func (p *Parser) Routine() {
 	go p.ethStream.Routine()
 
-	for block := range p.ethStream.BlocksQueue() {
-		log.Printf("parser: got next block '%s' with %d transactions\n",
-			block.Number, len(block.Transactions))
-
-		for _, transaction := range block.Transactions {
-			for _, addr := range []string{transaction.From, transaction.To} {
-				if ok := p.subscriptions.Check(addr); !ok {
-					continue
-				}
-				if err := p.transactions.Store(addr, transaction); err != nil {
-					log.Printf("parser: could not store transaction for '%s' [%+v]: %s",
-						addr, transaction, err)
-				}
-
-				log.Printf("parser: stored transaction for '%s' [%+v]", addr, transaction)
+	// pendingQueue stays nil and is never selected if there is no stream
+	var pendingQueue <-chan []eth.Transaction
+	if p.pendingStream != nil {
+		go p.pendingStream.Routine()
+		pendingQueue = p.pendingStream.PendingQueue()
+	}
+
+	blocksQueue := p.ethStream.BlocksQueue()
+
+loop:
+	for {
+		select {
+		case block, ok := <-blocksQueue:
+			if !ok {
+				break loop
+			}
+			p.processBlock(block)
+		case transactions, ok := <-pendingQueue:
+			if !ok {
+				pendingQueue = nil
+				continue
 			}
+			p.processPending(transactions)
 		}
 	}
 
+	p.closeWatchers()
 	close(p.shutdown)
 }
 
+// processBlock stores transactions of the block for subscribed addresses
+func (p *Parser) processBlock(block *eth.Block) {
+	blockLogger := p.logger.With("block", block.Number)
+	blockLogger.Info("got next block", "transactions", len(block.Transactions))
+
+	defer clear(p.receipts)
+	p.batching = p.blockTransactions != nil
+
+	for i := range block.Transactions {
+		// block number and time are set on the block, so that transactions of
+		// matched logs carry them as well
+		block.Transactions[i].BlockNumber = block.Number
+		block.Transactions[i].Timestamp = block.Timestamp
+		transaction := block.Transactions[i]
+
+		if len(p.pending) != 0 {
+			p.reconcilePending(transaction, blockLogger)
+		}
+
+		if len(transaction.To) == 0 {
+			p.resolveContractCreation(&transaction, blockLogger)
+		}
+
+		subscriptions := p.matchSubscriptions(&transaction)
+		if len(subscriptions) == 0 {
+			continue
+		}
+
+		// transaction is decoded and valuated once, tenants store its copy
+		p.decodeTransaction(&transaction)
+		p.valuateTransaction(&transaction, blockLogger)
+		for _, subscription := range subscriptions {
+			p.storeTransaction(subscription.tenant, subscription.address, block.Number,
+				transaction, blockLogger)
+		}
+	}
+
+	if p.config.Balances.Enabled {
+		p.processBalances(block, blockLogger)
+	}
+	if !p.processLogs(block, blockLogger) {
+		p.batching = false
+		p.blockBatch = nil
+		return
+	}
+
+	if len(p.pending) != 0 {
+		p.dropStalePending(blockLogger)
+	}
+
+	if p.batching {
+		p.batching = false
+		if !p.storeBlock(block.Number, blockLogger) {
+			return
+		}
+	}
+
+	p.publishSinkEvents(block.Number, blockLogger)
+}
+
+// matchedAddresses returns addresses transaction is stored for if they are
+// subscribed: the sender and the recipient or the created contract. Address
+// of self-transfer is returned once, so that it is stored and published once.
+func matchedAddresses(transaction *eth.Transaction) []string {
+	to := transaction.To
+	if len(to) == 0 {
+		to = transaction.ContractAddress
+	}
+	if to == transaction.From {
+		return []string{transaction.From}
+	}
+
+	return []string{transaction.From, to}
+}
+
+// subscription is address of transaction subscribed by tenant
+type subscription struct {
+	tenant  string
+	address string
+}
+
+// matchSubscriptions returns subscriptions of matched addresses of transaction
+func (p *Parser) matchSubscriptions(transaction *eth.Transaction) []subscription {
+	var subscriptions []subscription
+	for _, addr := range matchedAddresses(transaction) {
+		if len(addr) == 0 {
+			continue
+		}
+
+		for _, tenant := range p.subscriptions.Tenants(addr) {
+			subscriptions = append(subscriptions, subscription{tenant: tenant, address: addr})
+		}
+	}
+
+	return subscriptions
+}
+
+// storeTransaction stores transaction for address subscribed by tenant,
+// notifies watchers and collects it for sinks, block number is empty for pending transactions.
+// While batching the transaction is only collected to be stored with the block.
+func (p *Parser) storeTransaction(
+	tenant, address, blockNumber string,
+	transaction eth.Transaction,
+	logger *slog.Logger,
+) bool {
+	if p.batching {
+		p.blockBatch = append(p.blockBatch, eth.SubscribedTransaction{
+			Tenant:      tenant,
+			Address:     address,
+			Transaction: transaction,
+		})
+		return true
+	}
+
+	if err := p.transactions.Store(tenant, address, transaction); err != nil {
+		logger.Error("could not store transaction",
+			"tenant", tenant, "address", address,
+			"tx_hash", transaction.Hash, "error", err)
+		return false
+	}
+
+	p.transactionStored(tenant, address, blockNumber, transaction, logger)
+	return true
+}
+
+// storeBlock stores transactions collected while the block was processed
+// with its number at once retrying until they are stored, and notifies
+// watchers of them. False is returned if the parser is shut down meanwhile,
+// so that the block is not checkpointed and is processed again after restart.
+func (p *Parser) storeBlock(blockNumber string, logger *slog.Logger) bool {
+	transactions := p.blockBatch
+	p.blockBatch = nil
+
+	number, err := eth.ParseBigQuantity(blockNumber)
+	if err != nil {
+		logger.Error("could not store block", "error", err)
+		return false
+	}
+
+	stored := p.retry(func() error {
+		return p.blockTransactions.StoreBlock(number.Int64(), transactions)
+	}, func(delay time.Duration, err error) {
+		logger.Warn("could not store block, retrying",
+			"transactions", len(transactions), "delay", delay, "error", err)
+	})
+	if !stored {
+		logger.Error("block is not stored on shutdown", "transactions", len(transactions))
+		return false
+	}
+
+	for _, stored := range transactions {
+		p.transactionStored(stored.Tenant, stored.Address, stored.Transaction.BlockNumber,
+			stored.Transaction, logger)
+	}
+	return true
+}
+
+// transactionStored notifies watchers of stored transaction and collects it
+// for sinks
+func (p *Parser) transactionStored(
+	tenant, address, blockNumber string,
+	transaction eth.Transaction,
+	logger *slog.Logger,
+) {
+	logger.Debug("stored transaction",
+		"tenant", tenant, "address", address, "tx_hash", transaction.Hash,
+		"from", transaction.From, "to", transaction.To, "status", transaction.Status)
+
+	p.notifyWatchers(tenant, WatchedTransaction{
+		Address:     address,
+		BlockNumber: blockNumber,
+		Transaction: transaction,
+	})
+	p.collectSinkEvent(tenant, address, blockNumber, transaction)
+}
+
+// retry calls fn with growing delay between attempts until it succeeds,
+// onErr is called on every failure. False is returned if the parser is shut
+// down meanwhile.
+func (p *Parser) retry(fn func() error, onErr func(delay time.Duration, err error)) bool {
+	delay := retryMinDelay
+	for {
+		err := fn()
+		if err == nil {
+			return true
+		}
+
+		onErr(delay, err)
+
+		select {
+		case <-p.stopping:
+			return false
+		case <-time.After(delay):
+		}
+
+		delay = min(2*delay, retryMaxDelay)
+	}
+}
+
+// receipt returns receipt of the transaction of the processed block, it is
+// fetched once and cached until the block is processed
+func (p *Parser) receipt(txHash string) (*eth.Receipt, error) {
+	if receipt, ok := p.receipts[txHash]; ok {
+		return receipt, nil
+	}
+
+	receipt, err := p.ethStream.Receipt(txHash)
+	if err != nil {
+		return nil, err
+	}
+
+	p.receipts[txHash] = receipt
+	return receipt, nil
+}
+
+// resolveContractCreation sets address of the contract created by transaction
+// if its deployer is subscribed, and subscribes tenants of the deployer to
+// the contract if enabled
+func (p *Parser) resolveContractCreation(transaction *eth.Transaction, logger *slog.Logger) {
+	tenants := p.subscriptions.Tenants(transaction.From)
+	if len(tenants) == 0 {
+		return
+	}
+
+	receipt, err := p.receipt(transaction.Hash)
+	if err == nil && len(receipt.ContractAddress) == 0 {
+		err = fmt.Errorf("transaction did not create contract")
+	}
+	if err != nil {
+		logger.Error("could not get address of created contract",
+			"tx_hash", transaction.Hash, "error", err)
+		return
+	}
+	contractAddress := receipt.ContractAddress
+	transaction.ContractAddress = contractAddress
+
+	logger.Info("detected contract creation", "tx_hash", transaction.Hash,
+		"deployer", transaction.From, "contract", contractAddress)
+
+	if !p.config.AutoSubscribeContracts {
+		return
+	}
+
+	p.subscribeMu.Lock()
+	defer p.subscribeMu.Unlock()
+
+	for _, tenant := range tenants {
+		if err := p.subscriptions.Store(tenant, contractAddress); err != nil {
+			logger.Error("could not subscribe to created contract",
+				"tenant", tenant, "address", contractAddress, "error", err)
+			continue
+		}
+
+		logger.Info("subscribed to created contract", "tenant", tenant, "address", contractAddress)
+	}
+}
+
+func (p *Parser) ChainID() uint64 {
+	if p == nil || p.ethStream == nil {
+		return 0
+	}
+
+	return p.ethStream.ChainID()
+}
+
 func (p *Parser) GetCurrentBlock() int {
 	if p == nil || p.ethStream == nil {
 		return -1
@@ -96,30 +531,188 @@ func (p *Parser) GetCurrentBlock() int {
 	return int(p.ethStream.LastBlockNumber())
 }
 
-func (p *Parser) Subscribe(address string) bool {
+// Status returns current state of the parser
+func (p *Parser) Status() Status {
+	if p == nil || p.ethStream == nil {
+		return Status{}
+	}
+
+	p.initMu.RLock()
+	status := Status{
+		Initialized: p.initialized,
+		InitErr:     p.initErr,
+	}
+	p.initMu.RUnlock()
+
+	status.LastBlockNumber = p.ethStream.LastBlockNumber()
+	status.LastBlockUpdatedAt = p.ethStream.LastBlockUpdatedAt()
+	status.HeadBlockNumber = p.ethStream.HeadBlockNumber()
+	return status
+}
+
+// Subscribe subscribes tenant to the transactions of address. If
+// maxSubscriptions is positive and tenant already has that many subscribed
+// addresses, ErrSubscriptionsLimitExceeded is returned. Address is stored in
+// lower case, eth.ErrInvalidAddress is returned if it is not valid.
+func (p *Parser) Subscribe(tenant, address string, maxSubscriptions int) error {
 	if p == nil || p.subscriptions == nil {
-		return false
+		return ErrUninitialized
 	}
 
-	if err := p.subscriptions.Store(address); err != nil {
-		log.Printf("parser: could not store subscription for '%s'", address)
-		return false
+	address, err := eth.NormalizeAddress(address)
+	if err != nil {
+		return err
 	}
 
-	log.Printf("parser: subscribed '%s' successfully", address)
-	return true
+	p.subscribeMu.Lock()
+	defer p.subscribeMu.Unlock()
+
+	if maxSubscriptions > 0 && !p.isSubscribed(tenant, address) {
+		count, err := p.subscriptions.Count(tenant)
+		if err != nil {
+			p.logger.Error("could not count subscriptions", "tenant", tenant, "error", err)
+			return fmt.Errorf("could not count subscriptions: %w", err)
+		}
+		if count >= maxSubscriptions {
+			return ErrSubscriptionsLimitExceeded
+		}
+	}
+
+	if err := p.subscriptions.Store(tenant, address); err != nil {
+		p.logger.Error("could not store subscription",
+			"tenant", tenant, "address", address, "error", err)
+		return fmt.Errorf("could not store subscription: %w", err)
+	}
+
+	p.logger.Info("subscribed successfully", "tenant", tenant, "address", address)
+	return nil
 }
 
-func (p *Parser) GetTransactions(address string) []eth.Transaction {
-	if p == nil || p.transactions == nil {
+// SubscribeBatch subscribes tenant to the transactions of all addresses at
+// once. Addresses exceeding maxSubscriptions are not subscribed and get
+// ErrSubscriptionsLimitExceeded in the returned per-address errors, invalid
+// ones get eth.ErrInvalidAddress, the error is returned if the batch could
+// not be stored at all. Addresses are stored in lower case.
+func (p *Parser) SubscribeBatch(
+	tenant string,
+	addresses []string,
+	maxSubscriptions int,
+) ([]error, error) {
+	if p == nil || p.subscriptions == nil {
+		return nil, ErrUninitialized
+	}
+
+	p.subscribeMu.Lock()
+	defer p.subscribeMu.Unlock()
+
+	count, err := p.subscriptions.Count(tenant)
+	if err != nil {
+		p.logger.Error("could not count subscriptions", "tenant", tenant, "error", err)
+		return nil, fmt.Errorf("could not count subscriptions: %w", err)
+	}
+
+	errs := make([]error, len(addresses))
+	accepted := make([]string, 0, len(addresses))
+	added := make(map[string]struct{}, len(addresses))
+	for i, address := range addresses {
+		address, err := eth.NormalizeAddress(address)
+		if err != nil {
+			errs[i] = err
+			continue
+		}
+		if _, ok := added[address]; ok || p.isSubscribed(tenant, address) {
+			continue
+		}
+		if maxSubscriptions > 0 && count >= maxSubscriptions {
+			errs[i] = ErrSubscriptionsLimitExceeded
+			continue
+		}
+
+		accepted = append(accepted, address)
+		added[address] = struct{}{}
+		count++
+	}
+
+	if err := p.subscriptions.StoreBatch(tenant, accepted); err != nil {
+		p.logger.Error("could not store subscriptions",
+			"tenant", tenant, "addresses", len(accepted), "error", err)
+		return nil, fmt.Errorf("could not store subscriptions: %w", err)
+	}
+
+	p.logger.Info("subscribed batch successfully",
+		"tenant", tenant, "addresses", len(addresses), "new", len(accepted))
+	return errs, nil
+}
+
+// Unsubscribe unsubscribes tenant from the transactions of address, already
+// stored transactions are kept
+func (p *Parser) Unsubscribe(tenant, address string) error {
+	if p == nil || p.subscriptions == nil {
+		return ErrUninitialized
+	}
+
+	address, err := eth.NormalizeAddress(address)
+	if err != nil {
+		return err
+	}
+
+	p.subscribeMu.Lock()
+	defer p.subscribeMu.Unlock()
+
+	if err := p.subscriptions.Delete(tenant, address); err != nil {
+		p.logger.Error("could not delete subscription",
+			"tenant", tenant, "address", address, "error", err)
+		return fmt.Errorf("could not delete subscription: %w", err)
+	}
+
+	p.logger.Info("unsubscribed successfully", "tenant", tenant, "address", address)
+	return nil
+}
+
+// GetSubscriptions returns addresses subscribed by tenant in sorted order
+func (p *Parser) GetSubscriptions(tenant string) []string {
+	if p == nil || p.subscriptions == nil {
 		return nil
 	}
 
-	result, err := p.transactions.Get(address)
+	addresses, err := p.subscriptions.TenantAddresses(tenant)
 	if err != nil {
-		log.Printf("parser: could not get transactions: %s", err)
+		p.logger.Error("could not get subscriptions", "tenant", tenant, "error", err)
 		return nil
 	}
 
-	return result
+	sort.Strings(addresses)
+	return addresses
+}
+
+func (p *Parser) isSubscribed(tenant, address string) bool {
+	for _, subscribed := range p.subscriptions.Tenants(address) {
+		if subscribed == tenant {
+			return true
+		}
+	}
+
+	return false
+}
+
+// GetTransactions returns transactions stored for address subscribed by
+// tenant, eth.ErrInvalidAddress is returned if address is not valid
+func (p *Parser) GetTransactions(tenant, address string) ([]eth.Transaction, error) {
+	if p == nil || p.transactions == nil {
+		return nil, ErrUninitialized
+	}
+
+	address, err := eth.NormalizeAddress(address)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := p.transactions.Get(tenant, address)
+	if err != nil {
+		p.logger.Error("could not get transactions",
+			"tenant", tenant, "address", address, "error", err)
+		return nil, fmt.Errorf("could not get transactions: %w", err)
+	}
+
+	return result, nil
 }
@@ -1,13 +1,39 @@
 package parser
 
 import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math/big"
+	"net/http"
 	"reflect"
+	"sort"
+	"strings"
 	"testing"
+	"time"
 
+	"eth-parser/abi"
 	"eth-parser/eth"
+	"eth-parser/poller"
+	"eth-parser/storages"
+	"eth-parser/testutil"
 )
 
-type dummyTransactionsStorage map[string][]eth.Transaction
+const (
+	benchmarkSubscriptions     = 1000000
+	benchmarkBlockTransactions = 300
+	// benchmarkHitRate is the share of subscribed addresses in the block
+	benchmarkHitRate = 0.02
+)
+
+type dummySubscription struct {
+	tenant  string
+	address string
+}
+
+type dummyTransactionsStorage map[dummySubscription][]eth.Transaction
 
 func (d *dummyTransactionsStorage) Init() error {
 	return nil
@@ -17,20 +43,93 @@ func (d *dummyTransactionsStorage) Shutdown() error {
 	return nil
 }
 
-func (d *dummyTransactionsStorage) Store(address string, transaction eth.Transaction) error {
-	if _, ok := (*d)[address]; !ok {
-		(*d)[address] = make([]eth.Transaction, 0)
+func (d *dummyTransactionsStorage) Store(
+	tenant, address string,
+	transaction eth.Transaction,
+) error {
+	key := dummySubscription{tenant: tenant, address: address}
+	if _, ok := (*d)[key]; !ok {
+		(*d)[key] = make([]eth.Transaction, 0)
+	}
+
+	for i := range (*d)[key] {
+		if (*d)[key][i].Hash == transaction.Hash {
+			(*d)[key][i] = transaction
+			return nil
+		}
+	}
+
+	(*d)[key] = append((*d)[key], transaction)
+	return nil
+}
+
+func (d *dummyTransactionsStorage) Get(tenant, address string) ([]eth.Transaction, error) {
+	return (*d)[dummySubscription{tenant: tenant, address: address}], nil
+}
+
+func (d *dummyTransactionsStorage) GetPage(
+	tenant, address string,
+	offset, limit int,
+) ([]eth.Transaction, error) {
+	transactions := (*d)[dummySubscription{tenant: tenant, address: address}]
+	if offset >= len(transactions) {
+		return nil, nil
+	}
+
+	return transactions[offset:min(offset+limit, len(transactions))], nil
+}
+
+// dummyBlockTransactionsStorage stores whole blocks, failing the first
+// stores, and keeps numbers of stored blocks
+type dummyBlockTransactionsStorage struct {
+	dummyTransactionsStorage
+	blocks   []int64
+	failures int
+}
+
+func (d *dummyBlockTransactionsStorage) Store(string, string, eth.Transaction) error {
+	return fmt.Errorf("transactions of blocks must be stored with StoreBlock")
+}
+
+func (d *dummyBlockTransactionsStorage) StoreBlock(
+	blockNumber int64,
+	transactions []eth.SubscribedTransaction,
+) error {
+	if d.failures != 0 {
+		d.failures--
+		return fmt.Errorf("store failed")
+	}
+
+	for _, stored := range transactions {
+		d.dummyTransactionsStorage.Store(stored.Tenant, stored.Address, stored.Transaction)
 	}
+	d.blocks = append(d.blocks, blockNumber)
+	return nil
+}
+
+type discardTransactionsStorage struct{}
+
+func (d *discardTransactionsStorage) Init() error {
+	return nil
+}
+
+func (d *discardTransactionsStorage) Shutdown() error {
+	return nil
+}
 
-	(*d)[address] = append((*d)[address], transaction)
+func (d *discardTransactionsStorage) Store(string, string, eth.Transaction) error {
 	return nil
 }
 
-func (d *dummyTransactionsStorage) Get(address string) ([]eth.Transaction, error) {
-	return (*d)[address], nil
+func (d *discardTransactionsStorage) Get(string, string) ([]eth.Transaction, error) {
+	return nil, nil
+}
+
+func (d *discardTransactionsStorage) GetPage(string, string, int, int) ([]eth.Transaction, error) {
+	return nil, nil
 }
 
-type dummyAddressesMapStorage map[string]struct{}
+type dummyAddressesMapStorage map[dummySubscription]struct{}
 
 func (d *dummyAddressesMapStorage) Init() error {
 	return nil
@@ -40,18 +139,91 @@ func (d *dummyAddressesMapStorage) Shutdown() error {
 	return nil
 }
 
-func (d *dummyAddressesMapStorage) Store(address string) error {
-	(*d)[address] = struct{}{}
+func (d *dummyAddressesMapStorage) Store(tenant, address string) error {
+	(*d)[dummySubscription{tenant: tenant, address: address}] = struct{}{}
+	return nil
+}
+
+func (d *dummyAddressesMapStorage) StoreBatch(tenant string, addresses []string) error {
+	for _, address := range addresses {
+		(*d)[dummySubscription{tenant: tenant, address: address}] = struct{}{}
+	}
+
+	return nil
+}
+
+func (d *dummyAddressesMapStorage) Delete(tenant, address string) error {
+	delete(*d, dummySubscription{tenant: tenant, address: address})
 	return nil
 }
 
-func (d *dummyAddressesMapStorage) Check(address string) bool {
-	_, ok := (*d)[address]
-	return ok
+func (d *dummyAddressesMapStorage) Tenants(address string) []string {
+	var tenants []string
+	for subscription := range *d {
+		if subscription.address == address {
+			tenants = append(tenants, subscription.tenant)
+		}
+	}
+
+	sort.Strings(tenants)
+	return tenants
+}
+
+func (d *dummyAddressesMapStorage) Count(tenant string) (int, error) {
+	count := 0
+	for subscription := range *d {
+		if subscription.tenant == tenant {
+			count++
+		}
+	}
+
+	return count, nil
+}
+
+func (d *dummyAddressesMapStorage) TenantAddresses(tenant string) ([]string, error) {
+	var addresses []string
+	for subscription := range *d {
+		if subscription.tenant == tenant {
+			addresses = append(addresses, subscription.address)
+		}
+	}
+
+	return addresses, nil
 }
 
 type dummyEthStream struct {
-	blocks []*eth.Block
+	blocks   []*eth.Block
+	receipts map[string]*eth.Receipt
+	// receiptCalls counts requested receipts by transaction hash
+	receiptCalls map[string]int
+	// balances maps block number to the balances at the block, balanceErrs
+	// to the errors of holdings at the block
+	balances    map[string]map[eth.Holding]int64
+	balanceErrs map[string]map[eth.Holding]error
+	// logs maps hash of block to its logs, logsFailures is the number of
+	// requests of logs failed before they are returned
+	logs         map[string][]eth.Log
+	logsFailures int
+	// blocksQueue is used instead of blocks if set
+	blocksQueue chan *eth.Block
+}
+
+type dummyPendingStream struct {
+	pendingQueue chan []eth.Transaction
+}
+
+func (d *dummyPendingStream) Init() error {
+	return nil
+}
+
+func (d *dummyPendingStream) Shutdown() error {
+	return nil
+}
+
+func (d *dummyPendingStream) Routine() {}
+
+func (d *dummyPendingStream) PendingQueue() <-chan []eth.Transaction {
+	return d.pendingQueue
 }
 
 func (d *dummyEthStream) Init() error {
@@ -64,11 +236,86 @@ func (d *dummyEthStream) Shutdown() error {
 
 func (d *dummyEthStream) Routine() {}
 
+func (d *dummyEthStream) ChainID() uint64 {
+	return 1
+}
+
 func (d *dummyEthStream) LastBlockNumber() int64 {
 	return 0
 }
 
+func (d *dummyEthStream) LastBlockUpdatedAt() time.Time {
+	return time.Time{}
+}
+
+func (d *dummyEthStream) HeadBlockNumber() int64 {
+	return 0
+}
+
+func (d *dummyEthStream) Receipt(txHash string) (*eth.Receipt, error) {
+	if d.receiptCalls != nil {
+		d.receiptCalls[txHash]++
+	}
+
+	receipt, ok := d.receipts[txHash]
+	if !ok {
+		return nil, fmt.Errorf("no receipt of %s", txHash)
+	}
+
+	return receipt, nil
+}
+
+func (d *dummyEthStream) Logs(
+	blockHash string,
+	addresses []string,
+	topics [][]string,
+) ([]eth.Log, error) {
+	if d.logsFailures > 0 {
+		d.logsFailures--
+		return nil, fmt.Errorf("could not get logs")
+	}
+
+	contracts := make(map[string]struct{}, len(addresses))
+	for _, address := range addresses {
+		contracts[address] = struct{}{}
+	}
+
+	var logs []eth.Log
+	for _, log := range d.logs[blockHash] {
+		if _, ok := contracts[log.Address]; !ok && len(addresses) != 0 {
+			continue
+		}
+		if len(topics) != 0 && log.Topics[0] != topics[0][0] {
+			continue
+		}
+
+		logs = append(logs, log)
+	}
+	return logs, nil
+}
+
+func (d *dummyEthStream) BalancesAt(
+	holdings []eth.Holding,
+	blockNumber string,
+) ([]*big.Int, []error, error) {
+	balances := make([]*big.Int, len(holdings))
+	errs := make([]error, len(holdings))
+	for i, holding := range holdings {
+		if err := d.balanceErrs[blockNumber][holding]; err != nil {
+			errs[i] = err
+			continue
+		}
+		balances[i] = big.NewInt(d.balances[blockNumber][holding])
+	}
+
+	return balances, errs, nil
+}
+
 func (d *dummyEthStream) BlocksQueue() <-chan *eth.Block {
+	if d.blocksQueue != nil {
+		return d.blocksQueue
+	}
+
 	ch := make(chan *eth.Block, len(d.blocks))
 	go func() {
 		for _, b := range d.blocks {
@@ -80,6 +327,85 @@ func (d *dummyEthStream) BlocksQueue() <-chan *eth.Block {
 	return ch
 }
 
+// dummyPriceProvider values 1 ETH at the unix time in every currency
+type dummyPriceProvider []string
+
+func (d dummyPriceProvider) Init() error {
+	return nil
+}
+
+func (d dummyPriceProvider) Shutdown() error {
+	return nil
+}
+
+func (d dummyPriceProvider) Currencies() []string {
+	return d
+}
+
+func (d dummyPriceProvider) Valuate(wei *big.Int, at time.Time) (map[string]string, error) {
+	if at.Unix() == 0 {
+		return nil, fmt.Errorf("no price")
+	}
+
+	amounts := make(map[string]string, len(d))
+	for _, currency := range d {
+		amounts[currency] = new(big.Rat).SetFrac(new(big.Int).Mul(wei, big.NewInt(at.Unix())),
+			big.NewInt(1e18)).FloatString(2)
+	}
+	return amounts, nil
+}
+
+// countingPriceProvider counts valuations of dummyPriceProvider
+type countingPriceProvider struct {
+	dummyPriceProvider
+	valuations int
+}
+
+func (c *countingPriceProvider) Valuate(wei *big.Int, at time.Time) (map[string]string, error) {
+	c.valuations++
+	return c.dummyPriceProvider.Valuate(wei, at)
+}
+
+// dummySink keeps published events, failing the first publishes and all
+// publishes of failBlock
+type dummySink struct {
+	published []SinkEvent
+	failures  int
+	failBlock string
+}
+
+func (d *dummySink) Init() error {
+	return nil
+}
+
+func (d *dummySink) Shutdown() error {
+	return nil
+}
+
+func (d *dummySink) Name() string {
+	return "dummy"
+}
+
+func (d *dummySink) Publish(events []SinkEvent) error {
+	if d.failures != 0 {
+		d.failures--
+		return fmt.Errorf("publish failed")
+	}
+	if events[0].BlockNumber == d.failBlock {
+		return fmt.Errorf("publish of block failed")
+	}
+
+	d.published = append(d.published, events...)
+	return nil
+}
+
+type dummyCheckpointStorage map[uint64]int64
+
+func (d dummyCheckpointStorage) Store(chainID uint64, blockNumber int64) error {
+	d[chainID] = blockNumber
+	return nil
+}
+
 func TestParse(t *testing.T) {
 	blocks := []*eth.Block{
 		{
@@ -107,7 +433,8 @@ func TestParse(t *testing.T) {
 			Transactions: nil,
 		},
 		{
-			Number: "3",
+			Number:    "3",
+			Timestamp: "0x64",
 			Transactions: []eth.Transaction{
 				{
 					Hash: "hash4",
@@ -133,52 +460,82 @@ func TestParse(t *testing.T) {
 	}
 
 	addressesStorage := &dummyAddressesMapStorage{
-		"from1": struct{}{},
-		"from2": struct{}{},
-		"to3":   struct{}{},
+		{tenant: "tenant1", address: "from1"}: struct{}{},
+		{tenant: "tenant1", address: "from2"}: struct{}{},
+		{tenant: "tenant1", address: "to3"}:   struct{}{},
+		{tenant: "tenant2", address: "to3"}:   struct{}{},
 	}
 
 	transactionsStorage := &dummyTransactionsStorage{}
 
-	p := NewParser(ethPoller, transactionsStorage, addressesStorage)
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	p := NewParser(&Config{}, ethPoller, nil, transactionsStorage, addressesStorage,
+		storages.NewLogsMapStorage(false, logger),
+		abi.NewRegistry(&abi.Config{}, 1, logger), nil, logger)
 	p.Routine()
 	p.Shutdown()
 
 	expectedTransactionsStorage := &dummyTransactionsStorage{
-		"from1": []eth.Transaction{
+		{tenant: "tenant1", address: "from1"}: []eth.Transaction{
+			{
+				Hash:        "hash1",
+				From:        "from1",
+				To:          "to1",
+				BlockNumber: "1",
+			},
+			{
+				Hash:        "hash2",
+				From:        "from1",
+				To:          "to1",
+				BlockNumber: "1",
+			},
+		},
+		{tenant: "tenant1", address: "from2"}: []eth.Transaction{
 			{
-				Hash: "hash1",
-				From: "from1",
-				To:   "to1",
+				Hash:        "hash3",
+				From:        "from2",
+				To:          "to1",
+				BlockNumber: "1",
 			},
 			{
-				Hash: "hash2",
-				From: "from1",
-				To:   "to1",
+				Hash:        "hash4",
+				From:        "from2",
+				To:          "to3",
+				BlockNumber: "3",
+				Timestamp:   "0x64",
 			},
 		},
-		"from2": []eth.Transaction{
+		{tenant: "tenant1", address: "to3"}: []eth.Transaction{
 			{
-				Hash: "hash3",
-				From: "from2",
-				To:   "to1",
+				Hash:        "hash4",
+				From:        "from2",
+				To:          "to3",
+				BlockNumber: "3",
+				Timestamp:   "0x64",
 			},
 			{
-				Hash: "hash4",
-				From: "from2",
-				To:   "to3",
+				Hash:        "hash5",
+				From:        "from3",
+				To:          "to3",
+				BlockNumber: "3",
+				Timestamp:   "0x64",
 			},
 		},
-		"to3": []eth.Transaction{
+		{tenant: "tenant2", address: "to3"}: []eth.Transaction{
 			{
-				Hash: "hash4",
-				From: "from2",
-				To:   "to3",
+				Hash:        "hash4",
+				From:        "from2",
+				To:          "to3",
+				BlockNumber: "3",
+				Timestamp:   "0x64",
 			},
 			{
-				Hash: "hash5",
-				From: "from3",
-				To:   "to3",
+				Hash:        "hash5",
+				From:        "from3",
+				To:          "to3",
+				BlockNumber: "3",
+				Timestamp:   "0x64",
 			},
 		},
 	}
@@ -191,3 +548,1221 @@ func TestParse(t *testing.T) {
 		)
 	}
 }
+
+func TestSubscribeLimit(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	p := NewParser(&Config{}, &dummyEthStream{}, nil, &dummyTransactionsStorage{},
+		&dummyAddressesMapStorage{}, storages.NewLogsMapStorage(false, logger),
+		abi.NewRegistry(&abi.Config{}, 1, logger), nil, logger)
+
+	for _, address := range []string{testAddress1, testAddress2} {
+		if err := p.Subscribe("tenant1", address, 2); err != nil {
+			t.Fatalf("could not subscribe '%s': %s", address, err)
+		}
+	}
+
+	if err := p.Subscribe("tenant1", testAddress1, 2); err != nil {
+		t.Errorf("resubscribing must not be limited, got: %s", err)
+	}
+	err := p.Subscribe("tenant1", testAddress3, 2)
+	if !errors.Is(err, ErrSubscriptionsLimitExceeded) {
+		t.Errorf("expected limit error, got: %v", err)
+	}
+	if err := p.Subscribe("tenant2", testAddress3, 2); err != nil {
+		t.Errorf("limit must be applied per tenant, got: %s", err)
+	}
+}
+
+func TestSubscribeNormalizesAddress(t *testing.T) {
+	const checksummed = "0x52908400098527886E0F7030069857D2E4169EE7"
+	const lower = "0x52908400098527886e0f7030069857d2e4169ee7"
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	addressesStorage := &dummyAddressesMapStorage{}
+	transactionsStorage := &dummyTransactionsStorage{
+		{tenant: "tenant1", address: lower}: []eth.Transaction{{Hash: "hash1"}},
+	}
+	p := NewParser(&Config{}, &dummyEthStream{}, nil, transactionsStorage,
+		addressesStorage, storages.NewLogsMapStorage(false, logger),
+		abi.NewRegistry(&abi.Config{}, 1, logger), nil, logger)
+
+	// the same address in different case is subscribed once
+	if err := p.Subscribe("tenant1", checksummed, 1); err != nil {
+		t.Fatalf("could not subscribe: %v", err)
+	}
+	if err := p.Subscribe("tenant1", " "+lower, 1); err != nil {
+		t.Errorf("resubscribing must not be limited, got: %v", err)
+	}
+	expectedStorage := &dummyAddressesMapStorage{{tenant: "tenant1", address: lower}: struct{}{}}
+	if !reflect.DeepEqual(addressesStorage, expectedStorage) {
+		t.Errorf("address storages are not equal:\nhave: %+v\nwant: %+v",
+			addressesStorage, expectedStorage)
+	}
+
+	transactions, err := p.GetTransactions("tenant1", checksummed)
+	if err != nil || len(transactions) != 1 {
+		t.Errorf("got transactions %+v, %v, want the stored one", transactions, err)
+	}
+
+	if err := p.Unsubscribe("tenant1", checksummed); err != nil {
+		t.Fatalf("could not unsubscribe: %v", err)
+	}
+	if len(*addressesStorage) != 0 {
+		t.Errorf("address is not unsubscribed: %+v", addressesStorage)
+	}
+
+	for name, call := range map[string]func() error{
+		"subscribe":   func() error { return p.Subscribe("tenant1", "addr1", 0) },
+		"unsubscribe": func() error { return p.Unsubscribe("tenant1", "0x01") },
+		"transactions": func() error {
+			_, err := p.GetTransactions("tenant1", "52908400098527886e0f7030069857d2e4169ee7")
+			return err
+		},
+		"watch": func() error {
+			_, err := p.Watch("tenant1", []string{lower, "0xzz"})
+			return err
+		},
+	} {
+		if err := call(); !errors.Is(err, eth.ErrInvalidAddress) {
+			t.Errorf("%s: got %v, want %v", name, err, eth.ErrInvalidAddress)
+		}
+	}
+}
+
+func TestSubscribeBatch(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	addressesStorage := &dummyAddressesMapStorage{
+		{tenant: "tenant1", address: testAddress1}: struct{}{},
+	}
+	p := NewParser(&Config{}, &dummyEthStream{}, nil, &dummyTransactionsStorage{},
+		addressesStorage, storages.NewLogsMapStorage(false, logger),
+		abi.NewRegistry(&abi.Config{}, 1, logger), nil, logger)
+
+	// addresses are normalized, so that the same address in different case
+	// is subscribed once
+	upper := "0x" + strings.ToUpper(testAddress2[2:])
+	errs, err := p.SubscribeBatch("tenant1",
+		[]string{testAddress1, upper, testAddress2, "addr", testAddress3, testAddress4}, 3)
+	if err != nil {
+		t.Fatalf("could not subscribe batch: %s", err)
+	}
+
+	if len(errs) != 6 || !errors.Is(errs[3], eth.ErrInvalidAddress) {
+		t.Fatalf("got errors %v, want %v of address 3", errs, eth.ErrInvalidAddress)
+	}
+	errs[3] = nil
+	expectedErrs := []error{nil, nil, nil, nil, nil, ErrSubscriptionsLimitExceeded}
+	if !reflect.DeepEqual(errs, expectedErrs) {
+		t.Errorf("errors are not equal:\nhave: %v\nwant: %v", errs, expectedErrs)
+	}
+
+	expectedStorage := &dummyAddressesMapStorage{
+		{tenant: "tenant1", address: testAddress1}: struct{}{},
+		{tenant: "tenant1", address: testAddress2}: struct{}{},
+		{tenant: "tenant1", address: testAddress3}: struct{}{},
+	}
+	if !reflect.DeepEqual(addressesStorage, expectedStorage) {
+		t.Errorf("address storages are not equal:\nhave: %+v\nwant: %+v",
+			addressesStorage, expectedStorage)
+	}
+
+	subscriptions := p.GetSubscriptions("tenant1")
+	expectedSubscriptions := []string{testAddress1, testAddress2, testAddress3}
+	if !reflect.DeepEqual(subscriptions, expectedSubscriptions) {
+		t.Errorf("subscriptions are not equal:\nhave: %v\nwant: %v",
+			subscriptions, expectedSubscriptions)
+	}
+}
+
+const (
+	testAddress1 = "0x00000000000000000000000000000000000000a1"
+	testAddress2 = "0x00000000000000000000000000000000000000a2"
+	testAddress3 = "0x00000000000000000000000000000000000000a3"
+	testAddress4 = "0x00000000000000000000000000000000000000a4"
+)
+
+func benchmarkAddress(i int) string {
+	return fmt.Sprintf("0x%040x", i)
+}
+
+// BenchmarkProcessBlock measures matching of block transactions against 1M
+// subscribed addresses, alone and with concurrent subscribes
+func BenchmarkProcessBlock(b *testing.B) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	addresses := make([]string, benchmarkSubscriptions)
+	for i := range addresses {
+		addresses[i] = benchmarkAddress(i)
+	}
+
+	mapStorage := storages.NewAddressesMapStorage(logger)
+	if err := mapStorage.StoreBatch("tenant1", addresses); err != nil {
+		b.Fatalf("could not store addresses: %s", err)
+	}
+
+	bloomStorage := storages.NewBloomAddressesStorage(mapStorage, &storages.BloomFilterConfig{
+		ExpectedAddresses: benchmarkSubscriptions,
+		FalsePositiveRate: 0.01,
+	}, logger)
+	if err := bloomStorage.Init(); err != nil {
+		b.Fatalf("could not init Bloom filter: %s", err)
+	}
+
+	block := &eth.Block{
+		Number:       "1",
+		Transactions: make([]eth.Transaction, benchmarkBlockTransactions),
+	}
+	hitEvery := int(1 / benchmarkHitRate)
+	for i := range block.Transactions {
+		block.Transactions[i] = eth.Transaction{
+			Hash: fmt.Sprintf("hash%d", i),
+			From: benchmarkAddress(benchmarkSubscriptions + 2*i),
+			To:   benchmarkAddress(benchmarkSubscriptions + 2*i + 1),
+		}
+		if i%hitEvery == 0 {
+			block.Transactions[i].To = addresses[i]
+		}
+	}
+
+	for _, bc := range []struct {
+		name    string
+		storage addressesStorage
+	}{
+		{name: "sharded", storage: mapStorage},
+		{name: "bloom", storage: bloomStorage},
+	} {
+		p := NewParser(&Config{}, &dummyEthStream{}, nil, &discardTransactionsStorage{},
+			bc.storage, storages.NewLogsMapStorage(false, logger),
+			abi.NewRegistry(&abi.Config{}, 1, logger), nil, logger)
+
+		b.Run(bc.name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				p.processBlock(block)
+			}
+
+			b.ReportMetric(float64(b.N*len(block.Transactions))/b.Elapsed().Seconds(), "txs/s")
+		})
+
+		b.Run(bc.name+"_with_subscribes", func(b *testing.B) {
+			done := make(chan struct{})
+			subscribed := make(chan struct{})
+			go func() {
+				defer close(subscribed)
+
+				for i := 0; ; i++ {
+					select {
+					case <-done:
+						return
+					default:
+					}
+
+					address := benchmarkAddress(2*benchmarkSubscriptions + i)
+					if err := p.Subscribe("tenant2", address, 0); err != nil {
+						b.Errorf("could not subscribe: %s", err)
+						return
+					}
+				}
+			}()
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				p.processBlock(block)
+			}
+			b.StopTimer()
+
+			close(done)
+			<-subscribed
+
+			b.ReportMetric(float64(b.N*len(block.Transactions))/b.Elapsed().Seconds(), "txs/s")
+		})
+	}
+}
+
+func TestWatchFlowControl(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	p := NewParser(&Config{WatchTimeout: time.Second}, &dummyEthStream{}, nil,
+		&dummyTransactionsStorage{}, &dummyAddressesMapStorage{},
+		storages.NewLogsMapStorage(false, logger), abi.NewRegistry(&abi.Config{}, 1, logger),
+		nil, logger)
+
+	watcher, err := p.Watch("tenant1", nil)
+	if err != nil {
+		t.Fatalf("could not watch: %v", err)
+	}
+	defer p.Unwatch(watcher)
+
+	watched := func(i int) WatchedTransaction {
+		return WatchedTransaction{
+			Address:     testAddress1,
+			Transaction: eth.Transaction{Hash: fmt.Sprintf("0x%02x", i)},
+		}
+	}
+	for i := 0; i < watcherQueueLen; i++ {
+		p.notifyWatchers("tenant1", watched(i))
+	}
+
+	// parser waits for the watcher with full queue instead of dropping
+	// the transaction
+	notified := make(chan struct{})
+	go func() {
+		defer close(notified)
+		p.notifyWatchers("tenant1", watched(watcherQueueLen))
+	}()
+	select {
+	case <-notified:
+		t.Fatalf("parser does not wait for watcher with full queue")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	for i := 0; i <= watcherQueueLen; i++ {
+		transaction, ok := <-watcher.Transactions()
+		if !ok {
+			t.Fatalf("watcher is closed with %v", watcher.Err())
+		}
+		if hash := watched(i).Transaction.Hash; transaction.Transaction.Hash != hash {
+			t.Fatalf("got transaction %s, want %s", transaction.Transaction.Hash, hash)
+		}
+	}
+	<-notified
+
+	// watcher which does not receive transactions within the timeout is
+	// closed
+	p.config.WatchTimeout = 10 * time.Millisecond
+	for i := 0; i <= watcherQueueLen; i++ {
+		p.notifyWatchers("tenant1", watched(i))
+	}
+	for range watcher.Transactions() {
+	}
+	if err := watcher.Err(); !errors.Is(err, ErrSlowWatcher) {
+		t.Errorf("got error %v, want %v", err, ErrSlowWatcher)
+	}
+}
+
+func TestContractCreation(t *testing.T) {
+	creation := eth.Transaction{Hash: "hash1", From: "deployer1", Value: "0x0"}
+	call := eth.Transaction{Hash: "hash2", From: "from1", To: "contract1", Value: "0x0"}
+
+	ethStream := &dummyEthStream{
+		blocks: []*eth.Block{
+			{Number: "0x1", Transactions: []eth.Transaction{creation}},
+			{Number: "0x2", Transactions: []eth.Transaction{call}},
+		},
+		receipts: map[string]*eth.Receipt{
+			"hash1": {ContractAddress: "contract1", GasUsed: "0x1", EffectiveGasPrice: "0x1"},
+			"hash2": {GasUsed: "0x1", EffectiveGasPrice: "0x1"},
+		},
+		receiptCalls: make(map[string]int),
+	}
+	addressesStorage := &dummyAddressesMapStorage{
+		{tenant: "tenant1", address: "deployer1"}: struct{}{},
+	}
+	transactionsStorage := &dummyTransactionsStorage{}
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	// balances of the deployer need the receipt of the creation as well
+	config := &Config{AutoSubscribeContracts: true, Balances: BalancesConfig{Enabled: true}}
+	p := NewParser(config, ethStream, nil, transactionsStorage, addressesStorage,
+		storages.NewLogsMapStorage(false, logger),
+		abi.NewRegistry(&abi.Config{}, 1, logger), nil, logger)
+	p.Routine()
+	p.Shutdown()
+
+	expectedCalls := map[string]int{"hash1": 1, "hash2": 1}
+	if !reflect.DeepEqual(ethStream.receiptCalls, expectedCalls) {
+		t.Errorf("receipts must be requested once:\nhave: %v\nwant: %v",
+			ethStream.receiptCalls, expectedCalls)
+	}
+
+	creation.ContractAddress = "contract1"
+	creation.BlockNumber = "0x1"
+	call.BlockNumber = "0x2"
+	expectedTransactionsStorage := &dummyTransactionsStorage{
+		{tenant: "tenant1", address: "deployer1"}: []eth.Transaction{creation},
+		{tenant: "tenant1", address: "contract1"}: []eth.Transaction{creation, call},
+	}
+	if !reflect.DeepEqual(transactionsStorage, expectedTransactionsStorage) {
+		t.Errorf("transaction storages are not equal:\nhave: %+v\nwant: %+v",
+			transactionsStorage, expectedTransactionsStorage)
+	}
+}
+
+func TestPending(t *testing.T) {
+	minedPending := eth.Transaction{Hash: "hash1", From: "from1", To: "to1", Nonce: "0x1"}
+	replacedPending := eth.Transaction{Hash: "hash2", From: "from1", To: "to2", Nonce: "0x2"}
+	droppedPending := eth.Transaction{Hash: "hash3", From: "from2", To: "to1", Nonce: "0x1"}
+	replacement := eth.Transaction{Hash: "hash4", From: "from1", To: "to3", Nonce: "0x2"}
+
+	ethStream := &dummyEthStream{blocksQueue: make(chan *eth.Block)}
+	pendingStream := &dummyPendingStream{pendingQueue: make(chan []eth.Transaction)}
+	addressesStorage := &dummyAddressesMapStorage{
+		{tenant: "tenant1", address: "from1"}: struct{}{},
+		{tenant: "tenant1", address: "to1"}:   struct{}{},
+	}
+	transactionsStorage := &dummyTransactionsStorage{}
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	const dropTimeout = 10 * time.Millisecond
+	p := NewParser(&Config{PendingDropTimeout: dropTimeout},
+		ethStream, pendingStream, transactionsStorage, addressesStorage,
+		storages.NewLogsMapStorage(false, logger),
+		abi.NewRegistry(&abi.Config{}, 1, logger), nil, logger)
+
+	routineDone := make(chan struct{})
+	go func() {
+		defer close(routineDone)
+		p.Routine()
+	}()
+
+	withStatus := func(transaction eth.Transaction, status string) eth.Transaction {
+		transaction.Status = status
+		return transaction
+	}
+	inBlock := func(transaction eth.Transaction, blockNumber string) eth.Transaction {
+		transaction.BlockNumber = blockNumber
+		return transaction
+	}
+
+	pendingStream.pendingQueue <- []eth.Transaction{
+		withStatus(minedPending, eth.StatusPending),
+		withStatus(replacedPending, eth.StatusPending),
+		withStatus(droppedPending, eth.StatusPending),
+	}
+	ethStream.blocksQueue <- &eth.Block{
+		Number: "1",
+		Transactions: []eth.Transaction{
+			withStatus(minedPending, eth.StatusMined),
+			withStatus(replacement, eth.StatusMined),
+		},
+	}
+
+	// pending transactions are dropped on the next block after the timeout
+	time.Sleep(2 * dropTimeout)
+	ethStream.blocksQueue <- &eth.Block{Number: "2"}
+
+	close(ethStream.blocksQueue)
+	<-routineDone
+	p.Shutdown()
+
+	expectedTransactionsStorage := &dummyTransactionsStorage{
+		{tenant: "tenant1", address: "from1"}: []eth.Transaction{
+			inBlock(withStatus(minedPending, eth.StatusMined), "1"),
+			withStatus(replacedPending, eth.StatusReplaced),
+			inBlock(withStatus(replacement, eth.StatusMined), "1"),
+		},
+		{tenant: "tenant1", address: "to1"}: []eth.Transaction{
+			inBlock(withStatus(minedPending, eth.StatusMined), "1"),
+			withStatus(droppedPending, eth.StatusDropped),
+		},
+	}
+	if !reflect.DeepEqual(transactionsStorage, expectedTransactionsStorage) {
+		t.Errorf("transaction storages are not equal:\nhave: %+v\nwant: %+v",
+			transactionsStorage, expectedTransactionsStorage)
+	}
+}
+
+func TestLogs(t *testing.T) {
+	const (
+		contract1 = "0x00000000000000000000000000000000000000c1"
+		contract2 = "0x00000000000000000000000000000000000000c2"
+		transfer  = "0xddf252ad1be2c89b69c2b068fc378daa952ba7f163c4a11628f55a4df523b3ef"
+		approval  = "0x8c5be1e5ebec7d5bd14f71427d1e84f3dd0314c0f7b2291e5b200ac8c7c3b925"
+		holder1   = "0x000000000000000000000000000000000000000000000000000000000000aaaa"
+		holder2   = "0x000000000000000000000000000000000000000000000000000000000000bbbb"
+	)
+
+	transaction := eth.Transaction{Hash: "hash1", From: "from1", To: contract1}
+	transferLog := eth.Log{Address: contract1, Topics: []string{transfer, holder1, holder2},
+		TransactionHash: "hash1", LogIndex: "0x0"}
+	approvalLog := eth.Log{Address: contract1, Topics: []string{approval, holder1, holder2},
+		TransactionHash: "hash1", LogIndex: "0x1"}
+	otherTransferLog := eth.Log{Address: contract1, Topics: []string{transfer, holder2, holder1},
+		TransactionHash: "hash2", LogIndex: "0x2"}
+	otherContractLog := eth.Log{Address: contract2, Topics: []string{transfer, holder1, holder2},
+		TransactionHash: "hash2", LogIndex: "0x3"}
+
+	ethStream := &dummyEthStream{
+		blocks: []*eth.Block{
+			{
+				Number: "1", Hash: "block1", Timestamp: "0x5",
+				Transactions: []eth.Transaction{transaction},
+			},
+		},
+		logs: map[string][]eth.Log{
+			"block1": {transferLog, approvalLog, otherTransferLog, otherContractLog},
+		},
+		// failed request of logs is retried
+		logsFailures: 1,
+	}
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	p := NewParser(&Config{}, ethStream, nil, &dummyTransactionsStorage{},
+		&dummyAddressesMapStorage{}, storages.NewLogsMapStorage(false, logger),
+		abi.NewRegistry(&abi.Config{}, 1, logger), nil, logger)
+
+	// transfers from holder1 and any logs of contract1 with upper case address
+	transfers, err := p.SubscribeLogs("tenant1",
+		eth.LogFilter{Address: contract1, Topics: [][]string{{transfer}, {holder1}}}, 2)
+	if err != nil {
+		t.Fatalf("could not subscribe to transfers: %v", err)
+	}
+	all, err := p.SubscribeLogs("tenant1",
+		eth.LogFilter{Address: "0x00000000000000000000000000000000000000C1"}, 2)
+	if err != nil {
+		t.Fatalf("could not subscribe to all logs: %v", err)
+	}
+
+	resubscribed, err := p.SubscribeLogs("tenant1",
+		eth.LogFilter{Address: contract1, Topics: [][]string{{transfer}, {holder1}, nil}}, 2)
+	if err != nil || resubscribed.ID != transfers.ID {
+		t.Errorf("resubscribe returned %+v, %v, want filter %s", resubscribed, err, transfers.ID)
+	}
+	_, err = p.SubscribeLogs("tenant1", eth.LogFilter{Address: contract2}, 2)
+	if !errors.Is(err, ErrSubscriptionsLimitExceeded) {
+		t.Errorf("got %v, want %v", err, ErrSubscriptionsLimitExceeded)
+	}
+	_, err = p.SubscribeLogs("tenant1",
+		eth.LogFilter{Address: contract2, Topics: [][]string{{"0x01"}}}, 0)
+	if !errors.Is(err, eth.ErrInvalidLogFilter) {
+		t.Errorf("got %v, want %v", err, eth.ErrInvalidLogFilter)
+	}
+
+	p.Routine()
+	p.Shutdown()
+
+	// transactions of logs carry their block
+	transaction.BlockNumber = "1"
+	transaction.Timestamp = "0x5"
+	expectedTransfers := []eth.MatchedLog{{Log: transferLog, Transaction: &transaction}}
+	logs := p.GetLogs("tenant1", transfers.ID)
+	if !reflect.DeepEqual(logs, expectedTransfers) {
+		t.Errorf("transfer logs are not equal:\nhave: %+v\nwant: %+v", logs, expectedTransfers)
+	}
+	if len(logs) != 0 && logs[0].Transaction == &ethStream.blocks[0].Transactions[0] {
+		t.Errorf("transaction of log is not copied from the block")
+	}
+
+	expectedAll := []eth.MatchedLog{
+		{Log: transferLog, Transaction: &transaction},
+		{Log: approvalLog, Transaction: &transaction},
+		{Log: otherTransferLog},
+	}
+	if logs := p.GetLogs("tenant1", all.ID); !reflect.DeepEqual(logs, expectedAll) {
+		t.Errorf("all logs are not equal:\nhave: %+v\nwant: %+v", logs, expectedAll)
+	}
+}
+
+func TestDecode(t *testing.T) {
+	const (
+		contract    = "0x00000000000000000000000000000000000000c1"
+		token       = "0x00000000000000000000000000000000000000c2"
+		other       = "0x00000000000000000000000000000000000000c3"
+		holder      = "0x000000000000000000000000000000000000aaaa"
+		contractABI = `[{"type": "function", "name": "setData", "inputs": [
+			{"name": "name", "type": "string"},
+			{"name": "values", "type": "uint256[]"},
+			{"name": "info", "type": "tuple", "components": [
+				{"name": "owner", "type": "address"}, {"name": "delta", "type": "int8"}]}]}]`
+	)
+
+	// word left pads hex encoded value to 32 bytes
+	word := func(value string) string {
+		return fmt.Sprintf("%064s", strings.TrimPrefix(value, "0x"))
+	}
+
+	setData, err := abi.ParseSignature("setData(string,uint256[],(address,int8))")
+	if err != nil {
+		t.Fatalf("could not parse signature: %v", err)
+	}
+	setDataInput := setData.Selector() +
+		word("80") + word("c0") + word(holder) + strings.Repeat("f", 64) +
+		word("5") + hex.EncodeToString([]byte("hello")) + strings.Repeat("0", 54) +
+		word("2") + word("1") + word("2")
+
+	transactions := []eth.Transaction{
+		{Hash: "hash1", From: holder, To: contract, Input: setDataInput},
+		{Hash: "hash2", From: holder, To: token, Input: "0xa9059cbb" + word(other) + word("3e8")},
+		{Hash: "hash3", From: holder, To: token, Input: "0x40c10f19" + word(other) + word("1")},
+		{Hash: "hash4", From: holder, To: other, Input: setDataInput},
+		{Hash: "hash5", From: holder, To: other, Input: "0xdeadbeef"},
+	}
+
+	ethStream := &dummyEthStream{
+		blocks: []*eth.Block{{Number: "1", Transactions: transactions}},
+	}
+	addressesStorage := &dummyAddressesMapStorage{
+		{tenant: "tenant1", address: holder}: struct{}{},
+	}
+	transactionsStorage := &dummyTransactionsStorage{}
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	registry := abi.NewRegistry(&abi.Config{}, 1, logger)
+
+	p := NewParser(&Config{}, ethStream, nil, transactionsStorage, addressesStorage,
+		storages.NewLogsMapStorage(false, logger), registry, nil, logger)
+	if err := p.StoreABI(contract, []byte(contractABI)); err != nil {
+		t.Fatalf("could not store ABI: %v", err)
+	}
+	p.Routine()
+	p.Shutdown()
+
+	setDataArgs := []eth.DecodedArg{
+		{Name: "name", Type: "string", Value: "hello"},
+		{Name: "values", Type: "uint256[]", Value: []interface{}{"1", "2"}},
+		{Name: "info", Type: "(address,int8)",
+			Value: map[string]interface{}{"owner": holder, "delta": "-1"}},
+	}
+	expected := []*eth.Decoded{
+		{Name: "setData", Signature: setData.Signature, Args: setDataArgs,
+			Source: eth.DecodedSourceContract},
+		{Name: "transfer", Signature: "transfer(address,uint256)", Args: []eth.DecodedArg{
+			{Name: "to", Type: "address", Value: other},
+			{Name: "value", Type: "uint256", Value: "1000"},
+		}, Source: eth.DecodedSourceStandard},
+		{Name: "mint", Signature: "mint(address,uint256)", Args: []eth.DecodedArg{
+			{Type: "address", Value: other},
+			{Type: "uint256", Value: "1"},
+		}, Source: eth.DecodedSourceSelector},
+		// methods of uploaded ABIs are known by selector for other contracts
+		{Name: "setData", Signature: setData.Signature, Args: setDataArgs,
+			Source: eth.DecodedSourceSelector},
+		nil,
+	}
+
+	stored := (*transactionsStorage)[dummySubscription{tenant: "tenant1", address: holder}]
+	if len(stored) != len(expected) {
+		t.Fatalf("got %d transactions, want %d", len(stored), len(expected))
+	}
+	for i := range expected {
+		if !reflect.DeepEqual(stored[i].Decoded, expected[i]) {
+			t.Errorf("transaction %s is decoded incorrectly:\nhave: %+v\nwant: %+v",
+				stored[i].Hash, stored[i].Decoded, expected[i])
+		}
+	}
+
+	// Transfer events of ERC-20 and ERC-721 differ in indexed arguments only
+	transferTopic := "0xddf252ad1be2c89b69c2b068fc378daa952ba7f163c4a11628f55a4df523b3ef"
+	for _, tc := range []struct {
+		log      eth.Log
+		expected []eth.DecodedArg
+	}{
+		{
+			log: eth.Log{Address: token, Data: "0x" + word("3e8"),
+				Topics: []string{transferTopic, "0x" + word(holder), "0x" + word(other)}},
+			expected: []eth.DecodedArg{
+				{Name: "from", Type: "address", Value: holder},
+				{Name: "to", Type: "address", Value: other},
+				{Name: "value", Type: "uint256", Value: "1000"},
+			},
+		},
+		{
+			log: eth.Log{Address: token, Data: "0x",
+				Topics: []string{transferTopic, "0x" + word(holder), "0x" + word(other), "0x" + word("7")}},
+			expected: []eth.DecodedArg{
+				{Name: "from", Type: "address", Value: holder},
+				{Name: "to", Type: "address", Value: other},
+				{Name: "tokenId", Type: "uint256", Value: "7"},
+			},
+		},
+	} {
+		decoded := registry.DecodeLog(&tc.log)
+		if decoded == nil || !reflect.DeepEqual(decoded.Args, tc.expected) {
+			t.Errorf("log is decoded incorrectly:\nhave: %+v\nwant: %+v", decoded, tc.expected)
+		}
+	}
+}
+
+func TestBalances(t *testing.T) {
+	const (
+		holder = "0x000000000000000000000000000000000000aaaa"
+		other  = "0x000000000000000000000000000000000000bbbb"
+		token  = "0x00000000000000000000000000000000000000c1"
+	)
+
+	// topic left pads address to 32 bytes
+	topic := func(address string) string {
+		return fmt.Sprintf("0x%064s", strings.TrimPrefix(address, "0x"))
+	}
+	transferLog := func(from, to, value string) eth.Log {
+		return eth.Log{Address: token, Topics: []string{transferTopic, topic(from), topic(to)},
+			Data: "0x" + fmt.Sprintf("%064s", value)}
+	}
+
+	ethStream := &dummyEthStream{
+		blocks: []*eth.Block{
+			{
+				Number: "0x1",
+				Hash:   "block1",
+				Transactions: []eth.Transaction{
+					{Hash: "hash1", From: holder, To: other, Value: "0x64"},
+				},
+			},
+			{
+				Number: "0x2",
+				Hash:   "block2",
+				Transactions: []eth.Transaction{
+					{Hash: "hash2", From: other, To: holder, Value: "0x1e"},
+					// failed transaction does not transfer value but pays the fee
+					{Hash: "hash3", From: holder, To: other, Value: "0x1f4"},
+					{Hash: "hash4", From: other, To: other, Value: "0x1"},
+				},
+				Withdrawals: []eth.Withdrawal{{Address: holder, Amount: "0x1"}},
+			},
+		},
+		receipts: map[string]*eth.Receipt{
+			"hash1": {Status: eth.ReceiptStatusSuccess, GasUsed: "0xa", EffectiveGasPrice: "0x2"},
+			"hash2": {Status: eth.ReceiptStatusSuccess, GasUsed: "0xa", EffectiveGasPrice: "0x2"},
+			"hash3": {Status: "0x0", GasUsed: "0x5", EffectiveGasPrice: "0x2"},
+		},
+		logs: map[string][]eth.Log{
+			"block1": {transferLog(other, holder, "32")},
+			"block2": {transferLog(holder, other, "14")},
+		},
+		balances: map[string]map[eth.Holding]int64{
+			"0x1": {
+				{Address: holder}:               1000,
+				{Address: holder, Token: token}: 50,
+			},
+			// ETH balance drifted by 5 wei, e.g. by internal transfer
+			"0x2": {
+				{Address: holder}:               1_000_001_025,
+				{Address: holder, Token: token}: 30,
+			},
+		},
+	}
+	addressesStorage := &dummyAddressesMapStorage{
+		{tenant: "tenant1", address: holder}: struct{}{},
+	}
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	config := &Config{Balances: BalancesConfig{Enabled: true, Tokens: true, ReconcileBlocks: 2}}
+	p := NewParser(config, ethStream, nil, &dummyTransactionsStorage{}, addressesStorage,
+		storages.NewLogsMapStorage(false, logger), abi.NewRegistry(&abi.Config{}, 1, logger),
+		nil, logger)
+
+	p.Routine()
+	p.Shutdown()
+
+	// balances are taken from the chain at block 1, block 2 adds 30 - 10 wei
+	// and 1 Gwei of withdrawal and is reconciled
+	balances, err := p.GetBalances("tenant1", holder, "")
+	if err != nil {
+		t.Fatalf("could not get balances: %v", err)
+	}
+	expected := []Balance{
+		{
+			Value:                 big.NewInt(1_000_001_025),
+			BlockNumber:           2,
+			ChainValue:            big.NewInt(1_000_001_025),
+			Drift:                 big.NewInt(5),
+			ReconciledBlockNumber: 2,
+		},
+		{
+			Token:                 token,
+			Value:                 big.NewInt(30),
+			BlockNumber:           2,
+			ChainValue:            big.NewInt(30),
+			Drift:                 big.NewInt(0),
+			ReconciledBlockNumber: 2,
+		},
+	}
+	// big.Int values are compared by their decimal representation
+	if fmt.Sprintf("%+v", balances) != fmt.Sprintf("%+v", expected) {
+		t.Errorf("balances are not equal:\nhave: %+v\nwant: %+v", balances, expected)
+	}
+
+	if _, err := p.GetBalances("tenant1", other, ""); !errors.Is(err, ErrNotSubscribed) {
+		t.Errorf("got %v, want %v", err, ErrNotSubscribed)
+	}
+
+	// address and token are normalized
+	upper, err := p.GetBalances("tenant1", "0x"+strings.ToUpper(holder[2:]),
+		"0x"+strings.ToUpper(token[2:]))
+	if err != nil || fmt.Sprintf("%+v", upper) != fmt.Sprintf("%+v", expected) {
+		t.Errorf("got balances %+v, %v, want %+v", upper, err, expected)
+	}
+	if _, err := p.GetBalances("tenant1", holder, "token"); !errors.Is(err, eth.ErrInvalidAddress) {
+		t.Errorf("got %v, want %v", err, eth.ErrInvalidAddress)
+	}
+}
+
+func TestBalancesErrors(t *testing.T) {
+	const (
+		holder = "0x000000000000000000000000000000000000aaaa"
+		token  = "0x00000000000000000000000000000000000000c1"
+		// invalid token is not ERC-20, balance of flaky token fails once
+		invalid = "0x00000000000000000000000000000000000000c2"
+		flaky   = "0x00000000000000000000000000000000000000c3"
+	)
+
+	ethStream := &dummyEthStream{
+		blocksQueue: make(chan *eth.Block),
+		balances: map[string]map[eth.Holding]int64{
+			"0x1": {
+				{Address: holder}:               1000,
+				{Address: holder, Token: token}: 50,
+			},
+			"0x2": {
+				{Address: holder}:               1000,
+				{Address: holder, Token: token}: 50,
+				{Address: holder, Token: flaky}: 7,
+			},
+		},
+		balanceErrs: map[string]map[eth.Holding]error{
+			"0x1": {
+				{Address: holder, Token: invalid}: fmt.Errorf("%w: reverted", eth.ErrNoBalance),
+				{Address: holder, Token: flaky}:   fmt.Errorf("timeout"),
+			},
+		},
+	}
+	addressesStorage := &dummyAddressesMapStorage{
+		{tenant: "tenant1", address: holder}: struct{}{},
+	}
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	config := &Config{Balances: BalancesConfig{Enabled: true}}
+	p := NewParser(config, ethStream, nil, &dummyTransactionsStorage{}, addressesStorage,
+		storages.NewLogsMapStorage(false, logger), abi.NewRegistry(&abi.Config{}, 1, logger),
+		nil, logger)
+
+	routineDone := make(chan struct{})
+	go func() {
+		defer close(routineDone)
+		p.Routine()
+	}()
+
+	for _, requested := range []string{token, invalid, flaky} {
+		if _, err := p.GetBalances("tenant1", holder, requested); err != nil {
+			t.Fatalf("could not request balance of %s: %v", requested, err)
+		}
+	}
+
+	// failure of a single holding does not hold back the others, failed one
+	// is retried on the next block
+	ethStream.blocksQueue <- &eth.Block{Number: "0x1"}
+	ethStream.blocksQueue <- &eth.Block{Number: "0x2"}
+	close(ethStream.blocksQueue)
+	<-routineDone
+	p.Shutdown()
+
+	balances, err := p.GetBalances("tenant1", holder, "")
+	if err != nil {
+		t.Fatalf("could not get balances: %v", err)
+	}
+	expected := []Balance{
+		{Value: big.NewInt(1000), BlockNumber: 1},
+		{Token: token, Value: big.NewInt(50), BlockNumber: 1},
+		{Token: flaky, Value: big.NewInt(7), BlockNumber: 2},
+	}
+	if fmt.Sprintf("%+v", balances) != fmt.Sprintf("%+v", expected) {
+		t.Errorf("balances are not equal:\nhave: %+v\nwant: %+v", balances, expected)
+	}
+
+	// token without balance is rejected
+	if _, err := p.GetBalances("tenant1", holder, invalid); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("got %v, want %v", err, ErrInvalidToken)
+	}
+}
+
+func TestPipeline(t *testing.T) {
+	node := testutil.NewEthNode(1)
+	defer node.Close()
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	ethPoller := poller.NewEthPoller(&poller.EthPollerConfig{
+		ChainID:             1,
+		Endpoint:            node.URL(),
+		PollInterval:        5 * time.Millisecond,
+		HeadRefreshInterval: time.Hour,
+		Timeout:             time.Second,
+		NumRetries:          3,
+		QueueLen:            10,
+	}, logger)
+
+	p := NewParser(&Config{}, ethPoller, nil,
+		storages.NewTransactionsMapStorage(false, logger),
+		storages.NewAddressesMapStorage(logger),
+		storages.NewLogsMapStorage(false, logger),
+		abi.NewRegistry(&abi.Config{}, 1, logger), nil, logger)
+	if err := p.Init(); err != nil {
+		t.Fatalf("could not init parser: %v", err)
+	}
+	if err := p.Subscribe("tenant1", testAddress1, 0); err != nil {
+		t.Fatalf("could not subscribe: %v", err)
+	}
+	go p.Routine()
+
+	// waiting for the initial block, the poller exits if it could not get it
+	deadline := time.Now().Add(5 * time.Second)
+	for node.Calls("eth_getBlockByNumber") < 2 {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for the initial block")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	// blocks are parsed in order despite failures of the endpoint
+	node.Inject(testutil.Fault{
+		Method: "eth_getBlockByNumber",
+		Kind:   testutil.FaultStatus,
+		Status: http.StatusServiceUnavailable,
+		Times:  2,
+	})
+	node.Inject(testutil.Fault{Method: "eth_getBlockByNumber", Kind: testutil.FaultReset})
+	node.SetMissing(2, true)
+
+	var expected []eth.Transaction
+	for i := 0; i < 3; i++ {
+		block := node.Mine(
+			eth.Transaction{From: testAddress1, To: testAddress2},
+			eth.Transaction{From: testAddress2, To: testAddress3},
+		)
+		transaction := block.Transactions[0]
+		transaction.Status = eth.StatusMined
+		transaction.BlockNumber = block.Number
+		transaction.Timestamp = block.Timestamp
+		expected = append(expected, transaction)
+	}
+	time.Sleep(20 * time.Millisecond)
+	node.SetMissing(2, false)
+
+	deadline = time.Now().Add(5 * time.Second)
+	for p.GetCurrentBlock() < 3 {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for blocks, current block %d", p.GetCurrentBlock())
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	p.Shutdown()
+
+	transactions, err := p.GetTransactions("tenant1", testAddress1)
+	if err != nil {
+		t.Fatalf("could not get transactions: %v", err)
+	}
+	if !reflect.DeepEqual(transactions, expected) {
+		t.Errorf("transactions are not equal:\nhave: %+v\nwant: %+v", transactions, expected)
+	}
+}
+
+func TestExportTransactions(t *testing.T) {
+	transactionsStorage := &dummyTransactionsStorage{
+		{tenant: "tenant1", address: "addr1"}: []eth.Transaction{
+			{Hash: "hash1", BlockNumber: "0x1", Timestamp: "0x64"},
+			{Hash: "hash2", BlockNumber: "0x2", Timestamp: "0xc8"},
+			{Hash: "hash3", Status: eth.StatusPending},
+			{Hash: "hash4", BlockNumber: "0x3", Timestamp: "0x12c"},
+			{Hash: "hash5", BlockNumber: "0x4"},
+		},
+		{tenant: "tenant1", address: "addr2"}: []eth.Transaction{
+			{Hash: "hash6", BlockNumber: "0x2", Timestamp: "0xc8"},
+		},
+	}
+
+	// transactions of the address span several pages
+	var pagedHashes []string
+	for i := 0; i <= exportPageSize; i++ {
+		hash := fmt.Sprintf("paged%d", i)
+		key := dummySubscription{tenant: "tenant1", address: "addr3"}
+		(*transactionsStorage)[key] = append((*transactionsStorage)[key],
+			eth.Transaction{Hash: hash, BlockNumber: "0x1"})
+		pagedHashes = append(pagedHashes, "addr3/"+hash)
+	}
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	p := NewParser(&Config{}, &dummyEthStream{}, nil, transactionsStorage,
+		&dummyAddressesMapStorage{}, storages.NewLogsMapStorage(false, logger),
+		abi.NewRegistry(&abi.Config{}, 1, logger), nil, logger)
+
+	testCases := []struct {
+		name        string
+		addresses   []string
+		exportRange ExportRange
+		expected    []string
+	}{
+		{
+			name:      "all",
+			addresses: []string{"addr1", "addr2"},
+			expected: []string{
+				"addr1/hash1", "addr1/hash2", "addr1/hash4", "addr1/hash5", "addr2/hash6",
+			},
+		},
+		{
+			name:        "blocks",
+			addresses:   []string{"addr1", "addr2"},
+			exportRange: ExportRange{FromBlock: 2, ToBlock: 3},
+			expected:    []string{"addr1/hash2", "addr1/hash4", "addr2/hash6"},
+		},
+		{
+			name:      "time",
+			addresses: []string{"addr1"},
+			exportRange: ExportRange{
+				FromTime: time.Unix(200, 0),
+				ToTime:   time.Unix(300, 0),
+			},
+			expected: []string{"addr1/hash2"},
+		},
+		{
+			name:      "pages",
+			addresses: []string{"addr3"},
+			expected:  pagedHashes,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var exported []string
+			err := p.ExportTransactions("tenant1", tc.addresses, tc.exportRange,
+				func(address string, transaction *eth.Transaction) error {
+					exported = append(exported, address+"/"+transaction.Hash)
+					return nil
+				})
+			if err != nil {
+				t.Fatalf("could not export transactions: %s", err)
+			}
+
+			if !reflect.DeepEqual(exported, tc.expected) {
+				t.Errorf("exported transactions are not equal:\nhave: %v\nwant: %v",
+					exported, tc.expected)
+			}
+		})
+	}
+}
+
+func TestValuation(t *testing.T) {
+	ethStream := &dummyEthStream{
+		blocks: []*eth.Block{
+			{Number: "0x1", Timestamp: "0x64", Transactions: []eth.Transaction{
+				{Hash: "hash1", From: "from1", To: "addr1", Value: "0xde0b6b3a7640000"},
+			}},
+			// price is unavailable for the block
+			{Number: "0x2", Timestamp: "0x0", Transactions: []eth.Transaction{
+				{Hash: "hash2", From: "from1", To: "addr1", Value: "0x1"},
+			}},
+		},
+	}
+	transactionsStorage := &dummyTransactionsStorage{
+		// transaction stored before prices were configured
+		{tenant: "tenant1", address: "addr2"}: []eth.Transaction{
+			{Hash: "hash3", Value: "0x6f05b59d3b20000", BlockNumber: "0x1", Timestamp: "0xc8"},
+		},
+	}
+	// transactions are matched by both addresses and tenants
+	addressesStorage := &dummyAddressesMapStorage{
+		{tenant: "tenant1", address: "addr1"}: struct{}{},
+		{tenant: "tenant1", address: "from1"}: struct{}{},
+		{tenant: "tenant2", address: "addr1"}: struct{}{},
+	}
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	prices := &countingPriceProvider{dummyPriceProvider: dummyPriceProvider{"usd", "eur"}}
+	p := NewParser(&Config{}, ethStream, nil, transactionsStorage, addressesStorage,
+		storages.NewLogsMapStorage(false, logger), abi.NewRegistry(&abi.Config{}, 1, logger),
+		prices, logger)
+	p.Routine()
+	p.Shutdown()
+
+	// transaction is valuated once for all of its subscriptions
+	if prices.valuations != len(ethStream.blocks) {
+		t.Errorf("got %d valuations, want %d", prices.valuations, len(ethStream.blocks))
+	}
+
+	fiat := make(map[string]map[string]string)
+	err := p.ExportTransactions("tenant1", []string{"addr1", "addr2"}, ExportRange{},
+		func(address string, transaction *eth.Transaction) error {
+			fiat[transaction.Hash] = transaction.Fiat
+			return nil
+		})
+	if err != nil {
+		t.Fatalf("could not export transactions: %s", err)
+	}
+
+	expected := map[string]map[string]string{
+		"hash1": {"usd": "100.00", "eur": "100.00"},
+		"hash2": nil,
+		"hash3": {"usd": "100.00", "eur": "100.00"},
+	}
+	if !reflect.DeepEqual(fiat, expected) {
+		t.Errorf("fiat values are not equal:\nhave: %v\nwant: %v", fiat, expected)
+	}
+
+	stored := (*transactionsStorage)[dummySubscription{tenant: "tenant1", address: "addr1"}]
+	if len(stored) != 2 || !reflect.DeepEqual(stored[0].Fiat, expected["hash1"]) {
+		t.Errorf("transaction is not stored with fiat value: %+v", stored)
+	}
+}
+
+func TestSinks(t *testing.T) {
+	transaction := eth.Transaction{Hash: "hash1", From: "from1", To: "addr1"}
+	pending := eth.Transaction{Hash: "hash2", From: "addr1", To: "to1", Status: eth.StatusPending}
+
+	ethStream := &dummyEthStream{blocksQueue: make(chan *eth.Block)}
+	pendingStream := &dummyPendingStream{pendingQueue: make(chan []eth.Transaction)}
+	addressesStorage := &dummyAddressesMapStorage{
+		{tenant: "tenant1", address: "addr1"}: struct{}{},
+		{tenant: "tenant2", address: "addr1"}: struct{}{},
+	}
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	p := NewParser(&Config{PendingDropTimeout: time.Hour}, ethStream, pendingStream,
+		&dummyTransactionsStorage{}, addressesStorage, storages.NewLogsMapStorage(false, logger),
+		abi.NewRegistry(&abi.Config{}, 1, logger), nil, logger)
+
+	// failed publishing is retried before the next block is processed, and
+	// publishing of the last block is interrupted by shutdown, so that it is
+	// not checkpointed
+	sink := &dummySink{failures: 1, failBlock: "0x3"}
+	checkpoints := dummyCheckpointStorage{}
+	p.SetSinks([]Sink{sink}, checkpoints)
+
+	routineDone := make(chan struct{})
+	go func() {
+		defer close(routineDone)
+		p.Routine()
+	}()
+
+	ethStream.blocksQueue <- &eth.Block{Number: "0x1", Transactions: []eth.Transaction{transaction}}
+	pendingStream.pendingQueue <- []eth.Transaction{pending}
+	ethStream.blocksQueue <- &eth.Block{Number: "0x2"}
+	ethStream.blocksQueue <- &eth.Block{Number: "0x3", Transactions: []eth.Transaction{transaction}}
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		close(ethStream.blocksQueue)
+	}()
+	p.Shutdown()
+	<-routineDone
+
+	mined := transaction
+	mined.BlockNumber = "0x1"
+	expected := []SinkEvent{
+		{ChainID: 1, Tenant: "tenant1", Address: "addr1", BlockNumber: "0x1", Transaction: mined},
+		{ChainID: 1, Tenant: "tenant2", Address: "addr1", BlockNumber: "0x1", Transaction: mined},
+		{ChainID: 1, Tenant: "tenant1", Address: "addr1", Transaction: pending},
+		{ChainID: 1, Tenant: "tenant2", Address: "addr1", Transaction: pending},
+	}
+	if !reflect.DeepEqual(sink.published, expected) {
+		t.Errorf("published events are not equal:\nhave: %+v\nwant: %+v",
+			sink.published, expected)
+	}
+
+	if !reflect.DeepEqual(checkpoints, dummyCheckpointStorage{1: 2}) {
+		t.Errorf("got checkpoints %v, want block 2", checkpoints)
+	}
+}
+
+func TestStoreBlock(t *testing.T) {
+	transaction := eth.Transaction{Hash: "hash1", From: "from1", To: "addr1"}
+
+	ethStream := &dummyEthStream{blocksQueue: make(chan *eth.Block)}
+	addressesStorage := &dummyAddressesMapStorage{
+		{tenant: "tenant1", address: "addr1"}: struct{}{},
+	}
+	transactionsStorage := &dummyBlockTransactionsStorage{
+		dummyTransactionsStorage: dummyTransactionsStorage{},
+		failures:                 1,
+	}
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	p := NewParser(&Config{PendingDropTimeout: time.Hour}, ethStream, nil,
+		transactionsStorage, addressesStorage, storages.NewLogsMapStorage(false, logger),
+		abi.NewRegistry(&abi.Config{}, 1, logger), nil, logger)
+
+	// events are published once the block is stored
+	sink := &dummySink{}
+	p.SetSinks([]Sink{sink}, nil)
+
+	routineDone := make(chan struct{})
+	go func() {
+		defer close(routineDone)
+		p.Routine()
+	}()
+
+	// failed store is retried before the next block is processed
+	ethStream.blocksQueue <- &eth.Block{Number: "0x1", Transactions: []eth.Transaction{transaction}}
+	ethStream.blocksQueue <- &eth.Block{Number: "0x2"}
+	close(ethStream.blocksQueue)
+	p.Shutdown()
+	<-routineDone
+
+	if !reflect.DeepEqual(transactionsStorage.blocks, []int64{1, 2}) {
+		t.Errorf("got stored blocks %v, want 1 and 2", transactionsStorage.blocks)
+	}
+
+	mined := transaction
+	mined.BlockNumber = "0x1"
+	stored, _ := transactionsStorage.Get("tenant1", "addr1")
+	if !reflect.DeepEqual(stored, []eth.Transaction{mined}) {
+		t.Errorf("stored transactions are not equal:\nhave: %+v\nwant: %+v", stored, mined)
+	}
+
+	expected := []SinkEvent{
+		{ChainID: 1, Tenant: "tenant1", Address: "addr1", BlockNumber: "0x1", Transaction: mined},
+	}
+	if !reflect.DeepEqual(sink.published, expected) {
+		t.Errorf("published events are not equal:\nhave: %+v\nwant: %+v",
+			sink.published, expected)
+	}
+}
+
+func TestSelfTransfer(t *testing.T) {
+	transaction := eth.Transaction{Hash: "hash1", From: "addr1", To: "addr1"}
+	pending := eth.Transaction{
+		Hash: "hash2", From: "addr1", To: "addr1", Nonce: "0x1", Status: eth.StatusPending,
+	}
+
+	ethStream := &dummyEthStream{blocksQueue: make(chan *eth.Block)}
+	pendingStream := &dummyPendingStream{pendingQueue: make(chan []eth.Transaction)}
+	addressesStorage := &dummyAddressesMapStorage{
+		{tenant: "tenant1", address: "addr1"}: struct{}{},
+	}
+	transactionsStorage := &dummyTransactionsStorage{}
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	p := NewParser(&Config{PendingDropTimeout: time.Hour}, ethStream, pendingStream,
+		transactionsStorage, addressesStorage, storages.NewLogsMapStorage(false, logger),
+		abi.NewRegistry(&abi.Config{}, 1, logger), nil, logger)
+
+	// pending transactions are published without block
+	sink := &dummySink{failBlock: "none"}
+	p.SetSinks([]Sink{sink}, nil)
+
+	routineDone := make(chan struct{})
+	go func() {
+		defer close(routineDone)
+		p.Routine()
+	}()
+
+	// the first block is processed again as after restart, and the pending
+	// transaction is mined in the second one
+	block := &eth.Block{Number: "0x1", Transactions: []eth.Transaction{transaction}}
+	ethStream.blocksQueue <- block
+	pendingStream.pendingQueue <- []eth.Transaction{pending}
+	ethStream.blocksQueue <- block
+	minedPending := pending
+	minedPending.Status = eth.StatusMined
+	ethStream.blocksQueue <- &eth.Block{Number: "0x2", Transactions: []eth.Transaction{minedPending}}
+	close(ethStream.blocksQueue)
+	p.Shutdown()
+	<-routineDone
+
+	mined := transaction
+	mined.BlockNumber = "0x1"
+	minedPending.BlockNumber = "0x2"
+
+	stored, _ := transactionsStorage.Get("tenant1", "addr1")
+	expected := []eth.Transaction{mined, minedPending}
+	if !reflect.DeepEqual(stored, expected) {
+		t.Errorf("stored transactions are not equal:\nhave: %+v\nwant: %+v", stored, expected)
+	}
+
+	expectedEvents := []SinkEvent{
+		{ChainID: 1, Tenant: "tenant1", Address: "addr1", BlockNumber: "0x1", Transaction: mined},
+		{ChainID: 1, Tenant: "tenant1", Address: "addr1", Transaction: pending},
+		{ChainID: 1, Tenant: "tenant1", Address: "addr1", BlockNumber: "0x1", Transaction: mined},
+		{
+			ChainID: 1, Tenant: "tenant1", Address: "addr1", BlockNumber: "0x2",
+			Transaction: minedPending,
+		},
+	}
+	if !reflect.DeepEqual(sink.published, expectedEvents) {
+		t.Errorf("published events are not equal:\nhave: %+v\nwant: %+v",
+			sink.published, expectedEvents)
+	}
+}
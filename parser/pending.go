@@ -0,0 +1,119 @@
+package parser
+
+import (
+	"log/slog"
+	"time"
+
+	"eth-parser/eth"
+)
+
+// pendingTransaction is pending transaction stored for subscribed addresses
+type pendingTransaction struct {
+	transaction eth.Transaction
+	// stored lists addresses the transaction is stored for by tenants
+	stored map[string][]string
+	seenAt time.Time
+}
+
+// processPending stores pending transactions for subscribed addresses
+func (p *Parser) processPending(transactions []eth.Transaction) {
+	for _, transaction := range transactions {
+		if _, ok := p.pending[transaction.Hash]; ok {
+			continue
+		}
+
+		pending := &pendingTransaction{
+			transaction: transaction,
+			stored:      make(map[string][]string),
+			seenAt:      time.Now(),
+		}
+
+		subscriptions := p.matchSubscriptions(&transaction)
+		if len(subscriptions) == 0 {
+			continue
+		}
+
+		// transaction is decoded once, tenants store its copy
+		p.decodeTransaction(&transaction)
+		for _, subscription := range subscriptions {
+			if p.storeTransaction(subscription.tenant, subscription.address, "",
+				transaction, p.logger) {
+				pending.stored[subscription.tenant] = append(
+					pending.stored[subscription.tenant], subscription.address)
+			}
+		}
+
+		if len(pending.stored) == 0 {
+			continue
+		}
+
+		pending.transaction = transaction
+		p.pending[transaction.Hash] = pending
+		p.pendingByNonce[nonceKey(&transaction)] = transaction.Hash
+	}
+
+	p.publishSinkEvents("", p.logger)
+}
+
+// reconcilePending forgets pending transaction once it is mined, the mined
+// transaction replaces it in the storage. If another transaction with the
+// same sender and nonce is mined, pending one is stored as replaced.
+func (p *Parser) reconcilePending(transaction eth.Transaction, logger *slog.Logger) {
+	if _, ok := p.pending[transaction.Hash]; ok {
+		logger.Debug("pending transaction is mined", "tx_hash", transaction.Hash)
+		p.forgetPending(transaction.Hash)
+		return
+	}
+
+	hash, ok := p.pendingByNonce[nonceKey(&transaction)]
+	if !ok {
+		return
+	}
+
+	logger.Debug("pending transaction is replaced",
+		"tx_hash", hash, "replaced_by", transaction.Hash)
+	p.finalizePending(hash, eth.StatusReplaced, logger)
+}
+
+// dropStalePending stores pending transactions which were not mined in time
+// as dropped
+func (p *Parser) dropStalePending(logger *slog.Logger) {
+	for hash, pending := range p.pending {
+		if time.Since(pending.seenAt) < p.config.PendingDropTimeout {
+			continue
+		}
+
+		logger.Debug("pending transaction is dropped", "tx_hash", hash)
+		p.finalizePending(hash, eth.StatusDropped, logger)
+	}
+}
+
+// finalizePending stores pending transaction with final status and forgets it
+func (p *Parser) finalizePending(hash, status string, logger *slog.Logger) {
+	pending := p.pending[hash]
+	p.forgetPending(hash)
+
+	transaction := pending.transaction
+	transaction.Status = status
+	for tenant, addresses := range pending.stored {
+		for _, addr := range addresses {
+			p.storeTransaction(tenant, addr, "", transaction, logger)
+		}
+	}
+}
+
+func (p *Parser) forgetPending(hash string) {
+	pending, ok := p.pending[hash]
+	if !ok {
+		return
+	}
+
+	delete(p.pending, hash)
+	if key := nonceKey(&pending.transaction); p.pendingByNonce[key] == hash {
+		delete(p.pendingByNonce, key)
+	}
+}
+
+func nonceKey(transaction *eth.Transaction) string {
+	return transaction.From + ":" + transaction.Nonce
+}
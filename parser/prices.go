@@ -0,0 +1,59 @@
+package parser
+
+import (
+	"log/slog"
+	"math/big"
+	"time"
+
+	"eth-parser/eth"
+)
+
+type priceProvider interface {
+	Init() error
+	Shutdown() error
+
+	// Currencies returns codes of currencies transactions are valuated in,
+	// valuation is disabled if there are none
+	Currencies() []string
+
+	// Valuate returns amount of wei in every currency at the time
+	Valuate(wei *big.Int, at time.Time) (map[string]string, error)
+}
+
+// valuateTransaction sets value of mined transaction in fiat currencies at
+// the time of its block, it is called for stored transactions only. If the
+// price is unavailable, transaction is left without fiat value.
+func (p *Parser) valuateTransaction(transaction *eth.Transaction, logger *slog.Logger) {
+	if transaction.Fiat != nil || len(transaction.Timestamp) == 0 ||
+		len(p.FiatCurrencies()) == 0 {
+		return
+	}
+
+	value, err := eth.ParseBigQuantity(transaction.Value)
+	if err != nil {
+		logger.Warn("could not valuate transaction", "tx_hash", transaction.Hash, "error", err)
+		return
+	}
+	timestamp, err := eth.ParseBigQuantity(transaction.Timestamp)
+	if err != nil {
+		logger.Warn("could not valuate transaction", "tx_hash", transaction.Hash, "error", err)
+		return
+	}
+
+	fiat, err := p.prices.Valuate(value, time.Unix(timestamp.Int64(), 0))
+	if err != nil {
+		logger.Warn("could not valuate transaction", "tx_hash", transaction.Hash, "error", err)
+		return
+	}
+
+	transaction.Fiat = fiat
+}
+
+// FiatCurrencies returns codes of fiat currencies transactions are valuated in
+func (p *Parser) FiatCurrencies() []string {
+	if p == nil || p.prices == nil {
+		return nil
+	}
+
+	return p.prices.Currencies()
+}
@@ -0,0 +1,119 @@
+package parser
+
+import (
+	"log/slog"
+	"time"
+
+	"eth-parser/eth"
+)
+
+// Sink publishes transactions stored by the parser to a message bus
+type Sink interface {
+	Init() error
+	Shutdown() error
+
+	// Name identifies the sink in logs
+	Name() string
+
+	// Publish publishes events and returns once all of them are
+	// acknowledged. Events are published again if it fails, so that they
+	// are delivered at least once.
+	Publish(events []SinkEvent) error
+}
+
+// SinkEvent is a transaction stored for the address subscribed by tenant
+type SinkEvent struct {
+	ChainID uint64 `json:"chain_id"`
+	Tenant  string `json:"tenant"`
+	Address string `json:"address"`
+	// BlockNumber is empty for pending transactions
+	BlockNumber string          `json:"block_number,omitempty"`
+	Transaction eth.Transaction `json:"transaction"`
+}
+
+type checkpointStorage interface {
+	// Store stores the last block of the chain transactions of which were
+	// published to all sinks
+	Store(chainID uint64, blockNumber int64) error
+}
+
+// SetSinks sets sinks stored transactions are published to, checkpoints may
+// be nil if published blocks are not checkpointed. Sinks are initialized and
+// shut down by caller, as they may be shared by parsers of several chains.
+// It must be called before Routine.
+func (p *Parser) SetSinks(sinks []Sink, checkpoints checkpointStorage) {
+	p.sinks = sinks
+	p.checkpoints = checkpoints
+}
+
+// collectSinkEvent collects event of stored transaction to be published once
+// the block or the batch of pending transactions is processed
+func (p *Parser) collectSinkEvent(
+	tenant, address, blockNumber string,
+	transaction eth.Transaction,
+) {
+	if len(p.sinks) == 0 {
+		return
+	}
+
+	p.sinkEvents = append(p.sinkEvents, SinkEvent{
+		ChainID:     p.ethStream.ChainID(),
+		Tenant:      tenant,
+		Address:     address,
+		BlockNumber: blockNumber,
+		Transaction: transaction,
+	})
+}
+
+// publishSinkEvents publishes collected events to every sink retrying until
+// they are acknowledged or the parser is shut down, and checkpoints the block
+// if it is not empty. The block is not checkpointed if publishing was
+// interrupted, so that it is processed again after restart.
+func (p *Parser) publishSinkEvents(blockNumber string, logger *slog.Logger) {
+	if len(p.sinks) == 0 {
+		return
+	}
+
+	events := p.sinkEvents
+	p.sinkEvents = nil
+
+	if len(events) != 0 {
+		for _, sink := range p.sinks {
+			if !p.publishToSink(sink, events, logger) {
+				return
+			}
+		}
+	}
+
+	if p.checkpoints == nil || len(blockNumber) == 0 {
+		return
+	}
+
+	number, err := eth.ParseBigQuantity(blockNumber)
+	if err != nil {
+		logger.Error("could not checkpoint block", "error", err)
+		return
+	}
+	if err := p.checkpoints.Store(p.ethStream.ChainID(), number.Int64()); err != nil {
+		logger.Error("could not checkpoint block", "error", err)
+	}
+}
+
+// publishToSink publishes events to the sink retrying with growing delay,
+// false is returned if the parser is shut down meanwhile
+func (p *Parser) publishToSink(sink Sink, events []SinkEvent, logger *slog.Logger) bool {
+	published := p.retry(func() error {
+		return sink.Publish(events)
+	}, func(delay time.Duration, err error) {
+		logger.Warn("could not publish events, retrying",
+			"sink", sink.Name(), "events", len(events), "delay", delay, "error", err)
+	})
+	if !published {
+		logger.Error("events are not published on shutdown",
+			"sink", sink.Name(), "events", len(events))
+		return false
+	}
+
+	logger.Debug("published events", "sink", sink.Name(), "events", len(events))
+	return true
+}
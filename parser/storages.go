@@ -8,20 +8,70 @@ type transactionsStorage interface {
 	Init() error
 	Shutdown() error
 
-	// Store stores transaction for address
-	Store(address string, transaction eth.Transaction) error
+	// Store stores transaction for address subscribed by tenant, transaction
+	// with the same hash is replaced
+	Store(tenant, address string, transaction eth.Transaction) error
 
-	// Get returns stored transactions for address
-	Get(address string) ([]eth.Transaction, error)
+	// Get returns transactions stored for address subscribed by tenant
+	Get(tenant, address string) ([]eth.Transaction, error)
+
+	// GetPage returns up to limit transactions stored for address subscribed
+	// by tenant starting from offset, in the order they were first stored
+	GetPage(tenant, address string, offset, limit int) ([]eth.Transaction, error)
+}
+
+// blockTransactionsStorage is implemented by transactions storages which store
+// transactions of the whole block with the checkpoint of the chain at once,
+// such storages get transactions of blocks with StoreBlock instead of Store
+type blockTransactionsStorage interface {
+	// StoreBlock stores transactions and the number of the processed block
+	// atomically, transactions with the same hash are replaced
+	StoreBlock(blockNumber int64, transactions []eth.SubscribedTransaction) error
 }
 
 type addressesStorage interface {
 	Init() error
 	Shutdown() error
 
-	// Store stores address
-	Store(address string) error
+	// Store stores address subscribed by tenant
+	Store(tenant, address string) error
+
+	// StoreBatch stores addresses subscribed by tenant at once
+	StoreBatch(tenant string, addresses []string) error
+
+	// Delete deletes address subscribed by tenant
+	Delete(tenant, address string) error
+
+	// Tenants returns tenants subscribed to the address
+	Tenants(address string) []string
+
+	// Count returns number of addresses subscribed by tenant
+	Count(tenant string) (int, error)
+
+	// TenantAddresses returns addresses subscribed by tenant
+	TenantAddresses(tenant string) ([]string, error)
+}
+
+type logsStorage interface {
+	Init() error
+	Shutdown() error
+
+	// StoreFilter stores log filter subscribed by tenant
+	StoreFilter(tenant string, filter eth.LogFilter) error
+
+	// DeleteFilter deletes log filter subscribed by tenant with its logs
+	DeleteFilter(tenant, filterID string) error
+
+	// Filters returns log filters subscribed by tenant
+	Filters(tenant string) ([]eth.LogFilter, error)
+
+	// AllFilters returns log filters of all tenants keyed by tenant
+	AllFilters() (map[string][]eth.LogFilter, error)
+
+	// Store stores log matched by filter of tenant, log with the same
+	// transaction hash and log index is replaced
+	Store(tenant, filterID string, log eth.MatchedLog) error
 
-	// Check checks if the address is saved
-	Check(address string) bool
+	// Get returns logs matched by filter of tenant
+	Get(tenant, filterID string) ([]eth.MatchedLog, error)
 }
@@ -0,0 +1,169 @@
+package parser
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"eth-parser/eth"
+)
+
+const watcherQueueLen = 256
+
+var (
+	ErrSlowWatcher = fmt.Errorf("watcher does not keep up with the parser")
+	ErrShutdown    = fmt.Errorf("parser is shut down")
+)
+
+// WatchedTransaction is a transaction stored for watched address
+type WatchedTransaction struct {
+	Address     string
+	BlockNumber string
+	Transaction eth.Transaction
+}
+
+// Watcher receives transactions stored for addresses subscribed by tenant.
+// The queue of the watcher is bounded: while it is full, the parser waits up
+// to Config.WatchTimeout for the watcher to receive the transaction, then the
+// watcher is closed with ErrSlowWatcher. Transactions are never dropped from
+// the queue of the open watcher.
+type Watcher struct {
+	tenant    string
+	addresses map[string]struct{}
+
+	queue     chan WatchedTransaction
+	err       error
+	closeOnce sync.Once
+
+	// released is closed by Unwatch, so that the parser does not wait for
+	// the released watcher
+	released    chan struct{}
+	releaseOnce sync.Once
+}
+
+// Transactions returns queue of watched transactions, it is closed when
+// watching is over, see Err for the reason
+func (w *Watcher) Transactions() <-chan WatchedTransaction {
+	return w.queue
+}
+
+// Err returns the reason why watching is over
+func (w *Watcher) Err() error {
+	return w.err
+}
+
+func (w *Watcher) close(err error) {
+	w.closeOnce.Do(func() {
+		w.err = err
+		close(w.queue)
+	})
+}
+
+func (w *Watcher) matches(tenant, address string) bool {
+	if tenant != w.tenant {
+		return false
+	}
+	if len(w.addresses) == 0 {
+		return true
+	}
+
+	_, ok := w.addresses[address]
+	return ok
+}
+
+// Watch starts watching transactions stored for the addresses subscribed by
+// tenant, all subscribed addresses are watched if addresses are empty.
+// Watcher must be released with Unwatch. eth.ErrInvalidAddress is returned if
+// any address is not valid.
+func (p *Parser) Watch(tenant string, addresses []string) (*Watcher, error) {
+	w := &Watcher{
+		tenant:    tenant,
+		addresses: make(map[string]struct{}, len(addresses)),
+		queue:     make(chan WatchedTransaction, watcherQueueLen),
+		released:  make(chan struct{}),
+	}
+	for _, address := range addresses {
+		address, err := eth.NormalizeAddress(address)
+		if err != nil {
+			return nil, err
+		}
+		w.addresses[address] = struct{}{}
+	}
+
+	p.watchersMu.Lock()
+	defer p.watchersMu.Unlock()
+
+	if p.watchersClosed {
+		w.close(ErrShutdown)
+		return w, nil
+	}
+
+	p.watchers[w] = struct{}{}
+	return w, nil
+}
+
+// Unwatch stops watching and releases watcher
+func (p *Parser) Unwatch(w *Watcher) {
+	w.releaseOnce.Do(func() { close(w.released) })
+
+	p.watchersMu.Lock()
+	defer p.watchersMu.Unlock()
+
+	delete(p.watchers, w)
+	w.close(nil)
+}
+
+func (p *Parser) notifyWatchers(tenant string, transaction WatchedTransaction) {
+	p.watchersMu.Lock()
+	defer p.watchersMu.Unlock()
+
+	for w := range p.watchers {
+		if !w.matches(tenant, transaction.Address) {
+			continue
+		}
+
+		if !p.sendWatched(w, transaction) {
+			p.logger.Warn("closing slow watcher", "tenant", tenant)
+			delete(p.watchers, w)
+			w.close(ErrSlowWatcher)
+		}
+	}
+}
+
+// sendWatched puts transaction to the queue of the watcher, it waits up to
+// WatchTimeout while the queue is full. False is returned if the watcher does
+// not receive transactions meanwhile.
+func (p *Parser) sendWatched(w *Watcher, transaction WatchedTransaction) bool {
+	select {
+	case w.queue <- transaction:
+		return true
+	default:
+	}
+
+	timer := time.NewTimer(p.config.WatchTimeout)
+	defer timer.Stop()
+
+	select {
+	case w.queue <- transaction:
+		return true
+	case <-w.released:
+		// watcher is closed by Unwatch
+		return true
+	case <-p.stopping:
+		// watcher is closed with ErrShutdown
+		return true
+	case <-timer.C:
+		return false
+	}
+}
+
+func (p *Parser) closeWatchers() {
+	p.watchersMu.Lock()
+	defer p.watchersMu.Unlock()
+
+	for w := range p.watchers {
+		delete(p.watchers, w)
+		w.close(ErrShutdown)
+	}
+	p.watchersClosed = true
+}
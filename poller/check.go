@@ -0,0 +1,207 @@
+package poller
+
+import (
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"eth-parser/eth"
+)
+
+const (
+	methodDebugTraceTransaction = "debug_traceTransaction"
+	methodTraceTransaction      = "trace_transaction"
+
+	// checkBlocksDepth is the number of latest blocks searched for a
+	// transaction to check receipts and tracing with
+	checkBlocksDepth = 10
+	// checkAddress is the address balance is requested for
+	checkAddress = "0x0000000000000000000000000000000000000000"
+
+	// websocketGUID is appended to the key of websocket handshake, see RFC 6455
+	websocketGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+)
+
+// Check is the result of checking a capability of the endpoint
+type Check struct {
+	Name string
+	// Required tells whether the parser could not work without the capability
+	Required bool
+	Detail   string
+	Err      error
+}
+
+// Check checks capabilities of the endpoint used by the parser: the chain,
+// blocks, receipts, logs, balances and pending transactions, and tracing and
+// websockets which are reported for information. Websockets are checked at
+// wsEndpoint, or at the endpoint with websocket scheme if it is empty.
+func (e *EthPoller) Check(wsEndpoint string) []Check {
+	var checks []Check
+
+	chainID, err := e.getChainID()
+	if err == nil && chainID != e.config.ChainID {
+		err = fmt.Errorf("endpoint serves chain %d, expected %d", chainID, e.config.ChainID)
+	}
+	checks = append(checks, Check{
+		Name:     "chain_id",
+		Required: true,
+		Detail:   fmt.Sprintf("chain %d", chainID),
+		Err:      err,
+	})
+
+	head, err := e.getBlockNumber()
+	if err != nil {
+		return append(checks, Check{Name: "blocks", Required: true, Err: err})
+	}
+
+	// transaction of the latest blocks is used to check receipts and tracing
+	var block *eth.Block
+	var txHash string
+	for number := head; number >= 0 && number > head-checkBlocksDepth; number-- {
+		block, err = e.fetchBlock(number)
+		if err != nil {
+			break
+		}
+		if len(block.Transactions) != 0 {
+			txHash = block.Transactions[0].Hash
+			break
+		}
+	}
+	if err != nil {
+		return append(checks, Check{Name: "blocks", Required: true, Err: err})
+	}
+	checks = append(checks, Check{
+		Name:     "blocks",
+		Required: true,
+		Detail:   fmt.Sprintf("head block %d", head),
+	})
+
+	checks = append(checks, e.checkTransaction("receipts", true, txHash,
+		methodEthGetTransactionReceipt, receiptParams(txHash)))
+
+	_, err = e.Logs(block.Hash, nil, nil)
+	checks = append(checks, Check{
+		Name:   "logs",
+		Detail: fmt.Sprintf("logs of block %s", block.Number),
+		Err:    err,
+	})
+
+	_, errs, err := e.BalancesAt([]eth.Holding{{Address: checkAddress}}, block.Number)
+	if err == nil {
+		err = errs[0]
+	}
+	checks = append(checks, Check{
+		Name:   "balances",
+		Detail: fmt.Sprintf("balance at block %s", block.Number),
+		Err:    err,
+	})
+
+	var filterID string
+	err = e.call(methodEthNewPendingTransactionFilter, nil, &filterID)
+	checks = append(checks, Check{
+		Name:   "pending",
+		Detail: "pending transaction filter",
+		Err:    err,
+	})
+
+	tracing := e.checkTransaction("tracing", false, txHash, methodDebugTraceTransaction,
+		[]interface{}{txHash, map[string]string{"tracer": "callTracer"}})
+	if tracing.Err != nil {
+		tracing = e.checkTransaction("tracing", false, txHash, methodTraceTransaction,
+			[]interface{}{txHash})
+	}
+	checks = append(checks, tracing)
+
+	if len(wsEndpoint) == 0 {
+		wsEndpoint = e.config.Endpoint
+	}
+	checks = append(checks, Check{
+		Name:   "websockets",
+		Detail: wsEndpoint,
+		Err:    e.checkWebsocket(wsEndpoint),
+	})
+
+	return checks
+}
+
+// checkTransaction checks method called with params for the transaction,
+// the check is skipped if there are no transactions in the latest blocks
+func (e *EthPoller) checkTransaction(
+	name string,
+	required bool,
+	txHash string,
+	method string,
+	params []interface{},
+) Check {
+	check := Check{
+		Name:     name,
+		Required: required,
+		Detail:   method,
+	}
+	if len(txHash) == 0 {
+		check.Detail = fmt.Sprintf("skipped, no transactions in %d latest blocks", checkBlocksDepth)
+		return check
+	}
+
+	var result interface{}
+	check.Err = e.call(method, params, &result)
+	return check
+}
+
+// checkWebsocket performs websocket handshake with the endpoint, http and
+// https schemes are replaced with ws and wss
+func (e *EthPoller) checkWebsocket(endpoint string) error {
+	endpointURL, err := url.Parse(endpoint)
+	if err != nil {
+		return fmt.Errorf("invalid endpoint: %w", err)
+	}
+	// handshake is plain HTTP request, so it is sent with HTTP scheme
+	switch endpointURL.Scheme {
+	case "ws", "http":
+		endpointURL.Scheme = "http"
+	case "wss", "https":
+		endpointURL.Scheme = "https"
+	default:
+		return fmt.Errorf("unsupported scheme %s", endpointURL.Scheme)
+	}
+
+	rawKey := make([]byte, 16)
+	if _, err := rand.Read(rawKey); err != nil {
+		return fmt.Errorf("could not generate key: %w", err)
+	}
+	key := base64.StdEncoding.EncodeToString(rawKey)
+
+	req, err := http.NewRequest(http.MethodGet, endpointURL.String(), nil)
+	if err != nil {
+		return fmt.Errorf("could not create request: %w", err)
+	}
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Upgrade", "websocket")
+	req.Header.Set("Sec-WebSocket-Version", "13")
+	req.Header.Set("Sec-WebSocket-Key", key)
+
+	client := &http.Client{Timeout: e.config.Timeout}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("could not execute handshake: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		return fmt.Errorf("got status %s on handshake", resp.Status)
+	}
+
+	accept := sha1.Sum([]byte(key + websocketGUID))
+	if resp.Header.Get("Sec-WebSocket-Accept") != base64.StdEncoding.EncodeToString(accept[:]) {
+		return fmt.Errorf("got wrong accept key on handshake")
+	}
+	if !strings.EqualFold(resp.Header.Get("Upgrade"), "websocket") {
+		return fmt.Errorf("got wrong upgrade on handshake")
+	}
+
+	return nil
+}
@@ -3,14 +3,40 @@ package poller
 import "time"
 
 type EthPollerConfig struct {
-	Endpoint     string
-	PollInterval time.Duration
+	// ChainID is the ID of the chain served by the endpoint, it is verified
+	// on initialization
+	ChainID uint64 `yaml:"chain_id"`
 
-	Timeout             time.Duration
-	MaxIdleConns        int
-	MaxConnsPerHost     int
-	MaxIdleConnsPerHost int
-	NumRetries          int
+	Endpoint     string        `yaml:"endpoint"`
+	PollInterval time.Duration `yaml:"interval"`
 
-	QueueLen int
+	// HeadRefreshInterval is how often the latest block number of the
+	// endpoint is requested to calculate the lag
+	HeadRefreshInterval time.Duration `yaml:"head_refresh_interval"`
+
+	Timeout             time.Duration `yaml:"timeout"`
+	MaxIdleConns        int           `yaml:"max_idle_conns"`
+	MaxConnsPerHost     int           `yaml:"max_conns_per_host"`
+	MaxIdleConnsPerHost int           `yaml:"max_idle_conns_per_host"`
+
+	// NumRetries is the total number of attempts of every request
+	NumRetries int `yaml:"num_retries"`
+	// RetryBackoff is the delay before the first retry, it is doubled for
+	// every next one up to MaxRetryBackoff and jittered. Retries are not
+	// delayed if zero. Retry-After of the response is honoured up to
+	// MaxRetryBackoff.
+	RetryBackoff    time.Duration `yaml:"retry_backoff"`
+	MaxRetryBackoff time.Duration `yaml:"max_retry_backoff"`
+
+	QueueLen int `yaml:"queue_len"`
+
+	// Pending configures watching of pending transactions
+	Pending PendingConfig `yaml:"pending"`
+
+	// RecordFile is JSONL file every request to the endpoint is appended to
+	// with its response, requests are not recorded if empty
+	RecordFile string `yaml:"record_file"`
+
+	// Replay configures replaying of the recording instead of polling
+	Replay ReplayConfig `yaml:"replay"`
 }
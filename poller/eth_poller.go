@@ -4,11 +4,14 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
-	"log"
+	"log/slog"
+	"math/big"
 	"net/http"
+	"os"
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"eth-parser/eth"
@@ -16,29 +19,65 @@ import (
 )
 
 const (
-	methodEthBlockNumber      = "eth_blockNumber"
-	methodEthGetBlockByNumber = "eth_getBlockByNumber"
+	methodEthBlockNumber           = "eth_blockNumber"
+	methodEthChainID               = "eth_chainId"
+	methodEthGetBlockByNumber      = "eth_getBlockByNumber"
+	methodEthGetTransactionReceipt = "eth_getTransactionReceipt"
+	methodEthGetLogs               = "eth_getLogs"
+	methodEthGetBalance            = "eth_getBalance"
+	methodEthCall                  = "eth_call"
+
+	// balanceOfSelector is selector of balanceOf(address) of ERC-20
+	balanceOfSelector = "0x70a08231"
+	// balancesBatchSize is the max number of balances requested in one batch
+	balancesBatchSize = 100
+	// maxReorgDepth is the number of latest polled blocks whose hashes are
+	// kept to detect reorgs
+	maxReorgDepth = 64
 )
 
 var (
 	ErrResourceNotFound = fmt.Errorf("resource not found")
+	// ErrExecutionReverted is returned for eth_call reverted by the contract
+	ErrExecutionReverted = fmt.Errorf("execution reverted")
+
+	errNoResponse = fmt.Errorf("no response in batch")
+	// errReorg is returned when parent of the block is not the polled block
+	errReorg = fmt.Errorf("parent block is replaced")
 )
 
 type EthPoller struct {
 	config *EthPollerConfig
+	logger *slog.Logger
 
 	httpClient *http.Client
-	reqID      uint
+	reqID      atomic.Uint64
+	// recorder records exchanges with the endpoint if RecordFile is set
+	recorder *recorder
 
 	initialBlockNumber int64
 	lastBlockNumber    int64
+	lastBlockUpdatedAt time.Time
+	headBlockNumber    int64
+	headUpdatedAt      time.Time
 	mu                 sync.RWMutex
 
+	// bounded limits polling to blocks from rangeFrom to rangeTo, see SetRange
+	bounded   bool
+	rangeFrom int64
+	rangeTo   int64
+	// resumed starts polling from resumeFrom behind the head, see SetStart
+	resumed    bool
+	resumeFrom int64
+
+	// hashes are hashes of the latest polled blocks by their numbers
+	hashes map[int64]string
+
 	blocksQueue chan *eth.Block
 	shutdown    chan struct{}
 }
 
-func NewEthPoller(config *EthPollerConfig) *EthPoller {
+func NewEthPoller(config *EthPollerConfig, logger *slog.Logger) *EthPoller {
 	httpClient := &http.Client{
 		Timeout: config.Timeout,
 		Transport: &http.Transport{
@@ -50,103 +89,394 @@ func NewEthPoller(config *EthPollerConfig) *EthPoller {
 
 	return &EthPoller{
 		config:      config,
+		logger:      logger.With("component", "eth_poller"),
 		httpClient:  httpClient,
-		reqID:       0,
 		mu:          sync.RWMutex{},
+		hashes:      make(map[int64]string),
 		blocksQueue: make(chan *eth.Block, config.QueueLen),
 		shutdown:    make(chan struct{}),
 	}
 }
 
-func (e *EthPoller) getBlockNumber() error {
-	e.reqID++
-	reqPacket := &jsonrpc.Packet{
-		JSONRPC: jsonrpc.Version,
-		ID:      e.reqID,
-		Method:  methodEthBlockNumber,
-	}
+func (e *EthPoller) getBlockNumber() (int64, error) {
+	return e.getQuantity(methodEthBlockNumber)
+}
 
-	respData, err := e.executePOSTRequestWithRetries(reqPacket)
+func (e *EthPoller) getChainID() (uint64, error) {
+	chainID, err := e.getQuantity(methodEthChainID)
 	if err != nil {
-		return fmt.Errorf("could not execute POST request: %w", err)
+		return 0, err
 	}
 
-	respPacket := &jsonrpc.Packet{}
-	if unmarshalErr := json.Unmarshal(respData, respPacket); unmarshalErr != nil {
-		return fmt.Errorf("could not unmarshal response packet: %w", unmarshalErr)
-	}
-	if respPacket.Error != nil {
-		return fmt.Errorf("got response with error: %d, %s",
-			respPacket.Error.Code, respPacket.Error.Message)
+	return uint64(chainID), nil
+}
+
+// getQuantity calls method without params which returns hex encoded quantity
+func (e *EthPoller) getQuantity(method string) (int64, error) {
+	var rawQuantity string
+	if err := e.call(method, nil, &rawQuantity); err != nil {
+		return 0, err
 	}
 
-	rawBlockNumber, ok := respPacket.Result.(string)
-	if !ok {
-		return fmt.Errorf("got wrong result type: %+v", respPacket.Result)
+	rawQuantity = strings.Replace(rawQuantity, "0x", "", -1)
+	quantity, err := strconv.ParseInt(rawQuantity, 16, 64)
+	if err != nil {
+		return 0, fmt.Errorf("could not parse quantity from response: %w", err)
 	}
 
-	rawBlockNumber = strings.Replace(rawBlockNumber, "0x", "", -1)
-	blockNumber, err := strconv.ParseInt(rawBlockNumber, 16, 64)
+	return quantity, nil
+}
+
+// getBlockByNumber pushes the block to the queue, errReorg is returned if the
+// polled block before it is not its parent
+func (e *EthPoller) getBlockByNumber(number int64) error {
+	block, err := e.fetchBlock(number)
 	if err != nil {
-		return fmt.Errorf("could not parse block number from response: %w", err)
+		return err
 	}
 
-	e.updateInitialBlockNumber(blockNumber)
+	if parentHash, ok := e.hashes[number-1]; ok && block.ParentHash != parentHash {
+		return fmt.Errorf("%w: polled %s, parent %s", errReorg, parentHash, block.ParentHash)
+	}
+
+	e.blocksQueue <- block
+	e.hashes[number] = block.Hash
+	delete(e.hashes, number-maxReorgDepth)
 	return nil
 }
 
-func (e *EthPoller) getBlockByNumber(number int64) error {
+// fetchBlock returns block with full transactions
+func (e *EthPoller) fetchBlock(number int64) (*eth.Block, error) {
 	numberAsStr := "0x" + strconv.FormatInt(number, 16)
-	reqParams := []interface{}{numberAsStr, true}
 
-	e.reqID++
+	block := &eth.Block{}
+	if err := e.call(methodEthGetBlockByNumber, []interface{}{numberAsStr, true}, block); err != nil {
+		return nil, err
+	}
+
+	for i := range block.Transactions {
+		block.Transactions[i].Status = eth.StatusMined
+	}
+
+	return block, nil
+}
+
+// Receipt returns receipt of mined transaction
+func (e *EthPoller) Receipt(txHash string) (*eth.Receipt, error) {
+	receipt := &eth.Receipt{}
+	if err := e.call(methodEthGetTransactionReceipt, receiptParams(txHash), receipt); err != nil {
+		return nil, fmt.Errorf("could not get receipt: %w", err)
+	}
+
+	return receipt, nil
+}
+
+// Logs returns logs of the block with given hash emitted by given contracts
+// and matched by topics, logs of all contracts are returned if there are no
+// addresses
+func (e *EthPoller) Logs(
+	blockHash string,
+	addresses []string,
+	topics [][]string,
+) ([]eth.Log, error) {
+	var logs []eth.Log
+	err := e.call(methodEthGetLogs, logsParams(blockHash, addresses, topics), &logs)
+	if err != nil && !errors.Is(err, ErrResourceNotFound) {
+		return nil, fmt.Errorf("could not get logs: %w", err)
+	}
+
+	return logs, nil
+}
+
+// BalancesAt returns balances of holdings at the block, ETH balances are
+// requested with eth_getBalance and token balances with balanceOf calls.
+// Errors of separate holdings are returned at their indexes, they wrap
+// eth.ErrNoBalance if the call reverts, the returned error fails all of them.
+func (e *EthPoller) BalancesAt(
+	holdings []eth.Holding,
+	blockNumber string,
+) ([]*big.Int, []error, error) {
+	balances := make([]*big.Int, len(holdings))
+	errs := make([]error, len(holdings))
+
+	for start := 0; start < len(holdings); start += balancesBatchSize {
+		end := min(start+balancesBatchSize, len(holdings))
+
+		var ethIndexes, tokenIndexes []int
+		var ethParams, tokenParams []interface{}
+		for i := start; i < end; i++ {
+			method, params := balanceCall(holdings[i], blockNumber)
+			if method == methodEthGetBalance {
+				ethIndexes = append(ethIndexes, i)
+				ethParams = append(ethParams, params)
+				continue
+			}
+
+			tokenIndexes = append(tokenIndexes, i)
+			tokenParams = append(tokenParams, params)
+		}
+
+		err := e.balancesBatch(methodEthGetBalance, ethParams, ethIndexes, balances, errs)
+		if err != nil {
+			return nil, nil, err
+		}
+		err = e.balancesBatch(methodEthCall, tokenParams, tokenIndexes, balances, errs)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	return balances, errs, nil
+}
+
+func receiptParams(txHash string) []interface{} {
+	return []interface{}{txHash}
+}
+
+func logsParams(blockHash string, addresses []string, topics [][]string) []interface{} {
+	filter := map[string]interface{}{
+		"blockHash": blockHash,
+	}
+	if len(addresses) != 0 {
+		filter["address"] = addresses
+	}
+	if len(topics) != 0 {
+		filter["topics"] = topics
+	}
+
+	return []interface{}{filter}
+}
+
+// balanceCall returns method and params requesting balance of the holding at
+// the block
+func balanceCall(holding eth.Holding, blockNumber string) (string, []interface{}) {
+	if len(holding.Token) == 0 {
+		return methodEthGetBalance, []interface{}{holding.Address, blockNumber}
+	}
+
+	holder := fmt.Sprintf("%064s", strings.TrimPrefix(holding.Address, "0x"))
+	call := map[string]string{"to": holding.Token, "data": balanceOfSelector + holder}
+	return methodEthCall, []interface{}{call, blockNumber}
+}
+
+// balancesBatch calls method returning balances with params and sets
+// balances or errors at given indexes
+func (e *EthPoller) balancesBatch(
+	method string,
+	params []interface{},
+	indexes []int,
+	balances []*big.Int,
+	errs []error,
+) error {
+	if len(params) == 0 {
+		return nil
+	}
+
+	results := make([]interface{}, len(params))
+	for i := range results {
+		results[i] = new(string)
+	}
+	callErrs, err := e.callBatch(method, params, results)
+	if err != nil {
+		return fmt.Errorf("could not get balances: %w", err)
+	}
+
+	for i, result := range results {
+		if err := callErrs[i]; err != nil {
+			if errors.Is(err, ErrExecutionReverted) {
+				err = fmt.Errorf("%w: %w", eth.ErrNoBalance, err)
+			}
+			errs[indexes[i]] = fmt.Errorf("could not get balance: %w", err)
+			continue
+		}
+
+		balance, err := eth.ParseBigQuantity(*result.(*string))
+		if err != nil {
+			errs[indexes[i]] = fmt.Errorf("could not parse balance: %w", err)
+			continue
+		}
+		balances[indexes[i]] = balance
+	}
+
+	return nil
+}
+
+// call calls method with params and unmarshals its result into result.
+// ErrResourceNotFound is returned if the result is null.
+func (e *EthPoller) call(method string, params interface{}, result interface{}) error {
 	reqPacket := &jsonrpc.Packet{
 		JSONRPC: jsonrpc.Version,
-		ID:      e.reqID,
-		Method:  methodEthGetBlockByNumber,
-		Params:  reqParams,
+		ID:      jsonrpc.NumericID(e.reqID.Add(1)),
+		Method:  method,
+		Params:  params,
 	}
 
-	respData, err := e.executePOSTRequestWithRetries(reqPacket)
+	respData, err := e.executePOSTRequestWithRetries(method, reqPacket)
 	if err != nil {
 		return fmt.Errorf("could not execute POST request: %w", err)
 	}
 
+	rawResult := json.RawMessage{}
 	respPacket := &jsonrpc.Packet{
-		Result: &eth.Block{},
+		Result: &rawResult,
 	}
 	if err := json.Unmarshal(respData, respPacket); err != nil {
 		return fmt.Errorf("could not unmarshal response packet: %w", err)
 	}
 	if respPacket.Error != nil {
-		code := respPacket.Error.Code
-		if code == jsonrpc.CodeResourceNotFoundError {
-			return ErrResourceNotFound
-		}
-		return fmt.Errorf("got response with error: %d, %s", code, respPacket.Error.Message)
+		return responseError(respPacket.Error)
 	}
 
-	block, ok := respPacket.Result.(*eth.Block)
-	if !ok {
-		return fmt.Errorf("got wrong result type: %+v", respPacket.Result)
+	// null result is unmarshaled by resetting the result to nil
+	if respPacket.Result == nil || len(rawResult) == 0 {
+		return ErrResourceNotFound
+	}
+	if err := json.Unmarshal(rawResult, result); err != nil {
+		return fmt.Errorf("got wrong result: %w", err)
 	}
 
-	e.blocksQueue <- block
 	return nil
 }
 
+// callBatch calls method with each of params in a single batch request and
+// unmarshals results into results of the same index. Results which are null
+// are left untouched. Errors of separate calls are returned at their indexes,
+// the returned error fails the whole batch.
+func (e *EthPoller) callBatch(
+	method string,
+	params []interface{},
+	results []interface{},
+) ([]error, error) {
+	reqPackets := make([]*jsonrpc.Packet, len(params))
+	indexes := make(map[uint64]int, len(params))
+	for i := range params {
+		id := e.reqID.Add(1)
+		reqPackets[i] = &jsonrpc.Packet{
+			JSONRPC: jsonrpc.Version,
+			ID:      jsonrpc.NumericID(id),
+			Method:  method,
+			Params:  params[i],
+		}
+		indexes[id] = i
+	}
+
+	respData, err := e.executePOSTRequestWithRetries(method, reqPackets)
+	if err != nil {
+		return nil, fmt.Errorf("could not execute POST request: %w", err)
+	}
+
+	var respPackets []struct {
+		ID     uint64          `json:"id"`
+		Result json.RawMessage `json:"result"`
+		Error  *jsonrpc.Error  `json:"error"`
+	}
+	if err := json.Unmarshal(respData, &respPackets); err != nil {
+		return nil, fmt.Errorf("could not unmarshal response packets: %w", err)
+	}
+
+	errs := make([]error, len(params))
+	for i := range errs {
+		errs[i] = errNoResponse
+	}
+	for _, respPacket := range respPackets {
+		i, ok := indexes[respPacket.ID]
+		if !ok {
+			return nil, fmt.Errorf("got response with unknown ID %d", respPacket.ID)
+		}
+		errs[i] = nil
+
+		if respPacket.Error != nil {
+			errs[i] = responseError(respPacket.Error)
+			continue
+		}
+		if len(respPacket.Result) == 0 || string(respPacket.Result) == "null" {
+			continue
+		}
+
+		if err := json.Unmarshal(respPacket.Result, results[i]); err != nil {
+			errs[i] = fmt.Errorf("got wrong result: %w", err)
+		}
+	}
+
+	return errs, nil
+}
+
+// responseError returns error of JSON-RPC error in response, reverted calls
+// are told apart from the failures of the node
+func responseError(respError *jsonrpc.Error) error {
+	switch {
+	case respError.Code == jsonrpc.CodeResourceNotFoundError:
+		return ErrResourceNotFound
+	case respError.Code == jsonrpc.CodeExecutionReverted ||
+		strings.Contains(respError.Message, "revert"):
+		return fmt.Errorf("%w: %d, %s", ErrExecutionReverted, respError.Code, respError.Message)
+	}
+
+	return fmt.Errorf("got response with error: %d, %s", respError.Code, respError.Message)
+}
+
 func (e *EthPoller) Init() error {
-	log.Println("eth_poller: initializing")
+	e.logger.Info("initializing")
+
+	if len(e.config.RecordFile) != 0 && e.recorder == nil {
+		recorder, err := newRecorder(e.config.RecordFile)
+		if err != nil {
+			return err
+		}
+		e.recorder = recorder
+		e.logger.Info("recording requests", "file", e.config.RecordFile)
+	}
+
+	chainID, err := e.getChainID()
+	if err != nil {
+		return fmt.Errorf("could not get chain ID: %w", err)
+	}
+	if chainID != e.config.ChainID {
+		return fmt.Errorf("endpoint serves chain %d, expected %d", chainID, e.config.ChainID)
+	}
 
-	if err := e.getBlockNumber(); err != nil {
+	blockNumber, err := e.getBlockNumber()
+	if err != nil {
 		return err
 	}
 
-	log.Printf("eth_poller: initial block #%d\n", e.initialBlockNumber)
-	log.Println("eth_poller: successfully initialized")
+	e.updateHeadBlockNumber(blockNumber)
+	if e.bounded {
+		if e.rangeFrom > blockNumber {
+			return fmt.Errorf("block %d is ahead of head block %d", e.rangeFrom, blockNumber)
+		}
+		blockNumber = e.rangeFrom
+	} else if e.resumed {
+		blockNumber = min(max(e.resumeFrom, 0), blockNumber)
+	}
+	e.updateInitialBlockNumber(blockNumber)
+
+	e.logger.Info("successfully initialized", "initial_block", e.initialBlockNumber)
 	return nil
 }
 
+// SetRange limits polling to blocks from..to inclusive instead of following
+// the head from the latest block, Routine closes the queue after the last
+// block of the range. It must be called before Init.
+func (e *EthPoller) SetRange(from, to int64) {
+	e.bounded = true
+	e.rangeFrom = from
+	e.rangeTo = to
+}
+
+// SetStart starts polling from the block instead of the latest block, e.g.
+// to resume after the last checkpointed block, blocks behind the head are
+// polled without waiting. The head is polled if the block is ahead of it. It
+// must be called before Init and is ignored if the range is set.
+func (e *EthPoller) SetStart(from int64) {
+	e.resumed = true
+	e.resumeFrom = from
+}
+
+func (e *EthPoller) ChainID() uint64 {
+	return e.config.ChainID
+}
+
 func (e *EthPoller) InitialBlockNumber() int64 {
 	e.mu.RLock()
 	defer e.mu.RUnlock()
@@ -173,6 +503,51 @@ func (e *EthPoller) updateLastBlockNumber(newNumber int64) {
 	defer e.mu.Unlock()
 
 	e.lastBlockNumber = newNumber
+	e.lastBlockUpdatedAt = time.Now()
+}
+
+// LastBlockUpdatedAt returns time when last block number was advanced
+func (e *EthPoller) LastBlockUpdatedAt() time.Time {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	return e.lastBlockUpdatedAt
+}
+
+// HeadBlockNumber returns number of the latest block known to the endpoint
+func (e *EthPoller) HeadBlockNumber() int64 {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	return e.headBlockNumber
+}
+
+func (e *EthPoller) updateHeadBlockNumber(newNumber int64) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if newNumber > e.headBlockNumber {
+		e.headBlockNumber = newNumber
+	}
+	e.headUpdatedAt = time.Now()
+}
+
+func (e *EthPoller) refreshHeadBlockNumber() {
+	e.mu.RLock()
+	headUpdatedAt := e.headUpdatedAt
+	e.mu.RUnlock()
+
+	if time.Since(headUpdatedAt) < e.config.HeadRefreshInterval {
+		return
+	}
+
+	blockNumber, err := e.getBlockNumber()
+	if err != nil {
+		e.logger.Warn("could not refresh head block number", "error", err)
+		return
+	}
+
+	e.updateHeadBlockNumber(blockNumber)
 }
 
 func (e *EthPoller) BlocksQueue() <-chan *eth.Block {
@@ -183,38 +558,74 @@ func (e *EthPoller) Routine() {
 	defer close(e.blocksQueue)
 
 	if err := e.getBlockByNumber(e.initialBlockNumber); err != nil {
-		log.Fatalf("eth_poller: could not start routine: %s", err)
+		e.logger.Error("could not start routine",
+			"block", e.initialBlockNumber, "error", err)
+		os.Exit(1)
 	}
 
 	e.updateLastBlockNumber(e.initialBlockNumber)
 
+	reorged := false
 	for {
+		if e.bounded && e.lastBlockNumber >= e.rangeTo {
+			e.logger.Info("polled all blocks of the range", "from", e.rangeFrom, "to", e.rangeTo)
+			return
+		}
+
+		// blocks of the range, resumed blocks behind the head and replaced
+		// blocks are polled without waiting
+		wait := e.config.PollInterval
+		if (e.bounded || e.resumed) && e.lastBlockNumber < e.HeadBlockNumber() {
+			wait = 0
+		}
+		if reorged {
+			wait = 0
+		}
+		reorged = false
+
 		select {
 		case <-e.shutdown:
 			return
-		case <-time.After(e.config.PollInterval):
+		case <-time.After(wait):
 			// polling, pass
 		}
 
+		e.refreshHeadBlockNumber()
+
 		nextBlockNumber := e.lastBlockNumber + 1
 		if err := e.getBlockByNumber(nextBlockNumber); err != nil {
 			if errors.Is(err, ErrResourceNotFound) {
-				log.Printf("eth_poller: waiting for block #%d", nextBlockNumber)
+				e.logger.Debug("waiting for block", "block", nextBlockNumber)
 				continue
 			}
-			log.Printf("eth_poller: could not get block #%d: %s", nextBlockNumber, err)
+			if errors.Is(err, errReorg) {
+				// the parent is polled again from the new fork, and so are
+				// its replaced ancestors as their children are polled
+				e.logger.Warn("detected reorg, polling parent block again",
+					"block", nextBlockNumber, "error", err)
+				delete(e.hashes, nextBlockNumber-1)
+				e.updateLastBlockNumber(nextBlockNumber - 2)
+				reorged = true
+				continue
+			}
+			e.logger.Warn("could not get block", "block", nextBlockNumber, "error", err)
 			continue
 		}
 
 		e.updateLastBlockNumber(nextBlockNumber)
+		e.updateHeadBlockNumber(nextBlockNumber)
 	}
 }
 
 func (e *EthPoller) Shutdown() error {
-	log.Println("eth_poller: starting shutdown")
+	e.logger.Info("starting shutdown")
 
 	close(e.shutdown)
 
-	log.Println("eth_poller: successfully shutdown")
+	if err := e.recorder.Close(); err != nil {
+		return fmt.Errorf("could not close record file: %w", err)
+	}
+
+	e.logger.Info("successfully shutdown")
 	return nil
 }
@@ -0,0 +1,714 @@
+package poller
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math/big"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"eth-parser/eth"
+	"eth-parser/jsonrpc"
+	"eth-parser/testutil"
+)
+
+const testTimeout = 5 * time.Second
+
+func newTestPoller(node *testutil.EthNode) *EthPoller {
+	config := &EthPollerConfig{
+		ChainID:             1,
+		Endpoint:            node.URL(),
+		PollInterval:        5 * time.Millisecond,
+		HeadRefreshInterval: time.Hour,
+		Timeout:             200 * time.Millisecond,
+		NumRetries:          3,
+		QueueLen:            10,
+		Pending: PendingConfig{
+			Enabled:   true,
+			Interval:  5 * time.Millisecond,
+			BatchSize: 2,
+		},
+	}
+
+	return NewEthPoller(config, slog.New(slog.NewTextHandler(io.Discard, nil)))
+}
+
+// nextBlock returns the next block of the queue or fails the test on timeout
+func nextBlock(t *testing.T, queue <-chan *eth.Block) *eth.Block {
+	t.Helper()
+
+	select {
+	case block := <-queue:
+		return block
+	case <-time.After(testTimeout):
+		t.Fatalf("timed out waiting for block")
+		return nil
+	}
+}
+
+func TestInit(t *testing.T) {
+	node := testutil.NewEthNode(10)
+	defer node.Close()
+	node.Mine()
+
+	poller := newTestPoller(node)
+	if err := poller.Init(); err == nil {
+		t.Errorf("expected error on chain ID mismatch")
+	}
+
+	poller.config.ChainID = 10
+	if err := poller.Init(); err != nil {
+		t.Fatalf("could not init: %v", err)
+	}
+	if number := poller.InitialBlockNumber(); number != 1 {
+		t.Errorf("got initial block %d, want 1", number)
+	}
+}
+
+func TestRoutine(t *testing.T) {
+	node := testutil.NewEthNode(1)
+	defer node.Close()
+
+	poller := newTestPoller(node)
+	if err := poller.Init(); err != nil {
+		t.Fatalf("could not init: %v", err)
+	}
+	go poller.Routine()
+	defer poller.Shutdown()
+
+	queue := poller.BlocksQueue()
+	if block := nextBlock(t, queue); block.Number != "0x0" {
+		t.Fatalf("got initial block %s, want 0x0", block.Number)
+	}
+
+	// missing block is waited for instead of skipped
+	node.SetMissing(1, true)
+	mined := node.Mine(eth.Transaction{From: "0x01", To: "0x02"})
+	time.Sleep(50 * time.Millisecond)
+	node.SetMissing(1, false)
+
+	block := nextBlock(t, queue)
+	if block.Hash != mined.Hash {
+		t.Errorf("got block %s, want %s", block.Hash, mined.Hash)
+	}
+	if status := block.Transactions[0].Status; status != eth.StatusMined {
+		t.Errorf("got status %s, want %s", status, eth.StatusMined)
+	}
+
+	// polled block replaced by reorg is polled again from the new fork
+	// when its child is polled
+	orphaned := node.Mine()
+	block = nextBlock(t, queue)
+	if block.Hash != orphaned.Hash {
+		t.Fatalf("got block %s, want %s", block.Hash, orphaned.Hash)
+	}
+
+	node.Reorg(1)
+	replacing := node.Mine()
+	child := node.Mine()
+
+	block = nextBlock(t, queue)
+	if block.Hash != replacing.Hash {
+		t.Errorf("got block %s, want %s", block.Hash, replacing.Hash)
+	}
+	block = nextBlock(t, queue)
+	if block.Hash != child.Hash || block.ParentHash != replacing.Hash {
+		t.Errorf("got block %s with parent %s, want %s with parent %s",
+			block.Hash, block.ParentHash, child.Hash, replacing.Hash)
+	}
+
+	// last block number is updated after the block is pushed
+	deadline := time.Now().Add(time.Second)
+	for poller.LastBlockNumber() != 3 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if number := poller.LastBlockNumber(); number != 3 {
+		t.Errorf("got last block %d, want 3", number)
+	}
+}
+
+func TestRange(t *testing.T) {
+	node := testutil.NewEthNode(1)
+	defer node.Close()
+	for i := 0; i < 5; i++ {
+		node.Mine()
+	}
+
+	poller := newTestPoller(node)
+	poller.SetRange(6, 7)
+	if err := poller.Init(); err == nil {
+		t.Errorf("expected error on range ahead of head")
+	}
+
+	poller.SetRange(2, 7)
+	if err := poller.Init(); err != nil {
+		t.Fatalf("could not init: %v", err)
+	}
+	go poller.Routine()
+	defer poller.Shutdown()
+
+	queue := poller.BlocksQueue()
+	for number := 2; number <= 5; number++ {
+		if block := nextBlock(t, queue); block.Number != fmt.Sprintf("0x%x", number) {
+			t.Fatalf("got block %s, want %d", block.Number, number)
+		}
+	}
+
+	// blocks of the range ahead of head are waited for
+	node.Mine()
+	node.Mine()
+	node.Mine()
+	for number := 6; number <= 7; number++ {
+		if block := nextBlock(t, queue); block.Number != fmt.Sprintf("0x%x", number) {
+			t.Fatalf("got block %s, want %d", block.Number, number)
+		}
+	}
+
+	select {
+	case block, ok := <-queue:
+		if ok {
+			t.Errorf("got block %s beyond the range", block.Number)
+		}
+	case <-time.After(testTimeout):
+		t.Errorf("queue is not closed after the range")
+	}
+}
+
+func TestStart(t *testing.T) {
+	node := testutil.NewEthNode(1)
+	defer node.Close()
+	for i := 0; i < 3; i++ {
+		node.Mine()
+	}
+
+	// start ahead of head falls back to the head
+	poller := newTestPoller(node)
+	poller.SetStart(10)
+	if err := poller.Init(); err != nil {
+		t.Fatalf("could not init: %v", err)
+	}
+	if number := poller.InitialBlockNumber(); number != 3 {
+		t.Errorf("got initial block %d, want 3", number)
+	}
+
+	poller = newTestPoller(node)
+	poller.SetStart(2)
+	if err := poller.Init(); err != nil {
+		t.Fatalf("could not init: %v", err)
+	}
+	go poller.Routine()
+	defer poller.Shutdown()
+
+	queue := poller.BlocksQueue()
+	node.Mine()
+	for number := 2; number <= 4; number++ {
+		if block := nextBlock(t, queue); block.Number != fmt.Sprintf("0x%x", number) {
+			t.Fatalf("got block %s, want %d", block.Number, number)
+		}
+	}
+}
+
+func TestCheck(t *testing.T) {
+	node := testutil.NewEthNode(1)
+	defer node.Close()
+
+	block := node.Mine(eth.Transaction{From: "0x01"})
+	node.SetReceipt(&eth.Receipt{TransactionHash: block.Transactions[0].Hash, Status: "0x1"})
+	node.Mine()
+
+	checks := newTestPoller(node).Check("")
+
+	// the node supports neither tracing nor websockets
+	expected := map[string]bool{
+		"chain_id":   true,
+		"blocks":     true,
+		"receipts":   true,
+		"logs":       true,
+		"balances":   true,
+		"pending":    true,
+		"tracing":    false,
+		"websockets": false,
+	}
+	passed := make(map[string]bool, len(checks))
+	for _, check := range checks {
+		passed[check.Name] = check.Err == nil
+	}
+	if !reflect.DeepEqual(passed, expected) {
+		t.Errorf("checks are not equal:\nhave: %v\nwant: %v", passed, expected)
+	}
+}
+
+func TestRetries(t *testing.T) {
+	testCases := []struct {
+		name  string
+		fault testutil.Fault
+		// calls is the expected number of eth_blockNumber calls
+		calls int
+		fails bool
+		// expectedErr is the error the request fails with if it is not nil
+		expectedErr error
+	}{
+		{
+			name: "server error is retried",
+			fault: testutil.Fault{
+				Kind:   testutil.FaultStatus,
+				Status: http.StatusBadGateway,
+				Times:  2,
+			},
+			calls: 3,
+		},
+		{
+			name: "retries are limited",
+			fault: testutil.Fault{
+				Kind:   testutil.FaultStatus,
+				Status: http.StatusServiceUnavailable,
+				Times:  3,
+			},
+			calls:       3,
+			fails:       true,
+			expectedErr: errBadHTTPStatusCode,
+		},
+		{
+			name:  "rate limited request is retried",
+			fault: testutil.Fault{Kind: testutil.FaultRateLimit},
+			calls: 2,
+		},
+		{
+			name:  "timeout is retried",
+			fault: testutil.Fault{Kind: testutil.FaultDelay, Delay: 300 * time.Millisecond},
+			calls: 2,
+		},
+		{
+			name:  "connection reset is retried",
+			fault: testutil.Fault{Kind: testutil.FaultReset},
+			calls: 2,
+		},
+		{
+			name:  "client error is not retried",
+			fault: testutil.Fault{Kind: testutil.FaultStatus, Status: http.StatusBadRequest},
+			calls: 1,
+			fails: true,
+		},
+		{
+			name: "resource not found is not retried",
+			fault: testutil.Fault{
+				Kind: testutil.FaultRPCError,
+				Code: jsonrpc.CodeResourceNotFoundError,
+			},
+			calls:       1,
+			fails:       true,
+			expectedErr: ErrResourceNotFound,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			node := testutil.NewEthNode(1)
+			defer node.Close()
+
+			poller := newTestPoller(node)
+			tc.fault.Method = methodEthBlockNumber
+			node.Inject(tc.fault)
+
+			_, err := poller.getBlockNumber()
+			if (err != nil) != tc.fails {
+				t.Errorf("got error %v, want failure: %t", err, tc.fails)
+			}
+			if tc.expectedErr != nil && !errors.Is(err, tc.expectedErr) {
+				t.Errorf("got error %v, want %v", err, tc.expectedErr)
+			}
+			if calls := node.Calls(methodEthBlockNumber); calls != tc.calls {
+				t.Errorf("got %d calls, want %d", calls, tc.calls)
+			}
+		})
+	}
+}
+
+func TestRetryBackoff(t *testing.T) {
+	testCases := []struct {
+		name            string
+		retryBackoff    time.Duration
+		maxRetryBackoff time.Duration
+		fault           testutil.Fault
+		// minElapsed and maxElapsed bound the duration of the request
+		minElapsed time.Duration
+		maxElapsed time.Duration
+	}{
+		{
+			name:            "backoff grows",
+			retryBackoff:    40 * time.Millisecond,
+			maxRetryBackoff: time.Second,
+			fault: testutil.Fault{
+				Kind:   testutil.FaultStatus,
+				Status: http.StatusServiceUnavailable,
+				Times:  2,
+			},
+			// jittered delays are at least 20ms and 40ms
+			minElapsed: 60 * time.Millisecond,
+			maxElapsed: time.Second,
+		},
+		{
+			name:            "retry after is honoured",
+			retryBackoff:    time.Millisecond,
+			maxRetryBackoff: 2 * time.Second,
+			fault:           testutil.Fault{Kind: testutil.FaultRateLimit, RetryAfter: "1"},
+			minElapsed:      time.Second,
+			maxElapsed:      2 * time.Second,
+		},
+		{
+			name:            "retry after is capped",
+			retryBackoff:    time.Millisecond,
+			maxRetryBackoff: 50 * time.Millisecond,
+			fault:           testutil.Fault{Kind: testutil.FaultRateLimit, RetryAfter: "3600"},
+			minElapsed:      50 * time.Millisecond,
+			maxElapsed:      time.Second,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			node := testutil.NewEthNode(1)
+			defer node.Close()
+
+			poller := newTestPoller(node)
+			poller.config.RetryBackoff = tc.retryBackoff
+			poller.config.MaxRetryBackoff = tc.maxRetryBackoff
+			tc.fault.Method = methodEthBlockNumber
+			node.Inject(tc.fault)
+
+			start := time.Now()
+			if _, err := poller.getBlockNumber(); err != nil {
+				t.Fatalf("could not get block number: %v", err)
+			}
+			if elapsed := time.Since(start); elapsed < tc.minElapsed || elapsed > tc.maxElapsed {
+				t.Errorf("request took %s, want from %s to %s", elapsed, tc.minElapsed, tc.maxElapsed)
+			}
+		})
+	}
+}
+
+func TestRetryBackoffShutdown(t *testing.T) {
+	node := testutil.NewEthNode(1)
+	defer node.Close()
+
+	poller := newTestPoller(node)
+	poller.config.RetryBackoff = time.Hour
+	poller.config.MaxRetryBackoff = time.Hour
+	node.Inject(testutil.Fault{Method: methodEthBlockNumber, Kind: testutil.FaultRateLimit})
+
+	time.AfterFunc(50*time.Millisecond, func() { poller.Shutdown() })
+
+	start := time.Now()
+	if _, err := poller.getBlockNumber(); !errors.Is(err, errBadHTTPStatusCode) {
+		t.Errorf("got error %v, want %v", err, errBadHTTPStatusCode)
+	}
+	if elapsed := time.Since(start); elapsed > testTimeout {
+		t.Errorf("waiting for retry is not interrupted by shutdown, took %s", elapsed)
+	}
+}
+
+func TestRetryDelay(t *testing.T) {
+	for attempt, expected := range []time.Duration{
+		100 * time.Millisecond,
+		200 * time.Millisecond,
+		400 * time.Millisecond,
+		800 * time.Millisecond,
+		time.Second,
+		time.Second,
+	} {
+		for range 100 {
+			delay := retryDelay(100*time.Millisecond, time.Second, attempt)
+			if delay < expected/2 || delay > expected {
+				t.Fatalf("attempt %d got delay %s, want from %s to %s",
+					attempt, delay, expected/2, expected)
+			}
+		}
+	}
+
+	if delay := retryDelay(0, time.Second, 3); delay != 0 {
+		t.Errorf("got delay %s without backoff", delay)
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	date := time.Now().Add(time.Minute).UTC().Format(http.TimeFormat)
+
+	for value, expected := range map[string]time.Duration{
+		"":                              0,
+		"3":                             3 * time.Second,
+		"-1":                            0,
+		"later":                         0,
+		"Wed, 21 Oct 2015 07:28:00 GMT": 0,
+	} {
+		if retryAfter := parseRetryAfter(value); retryAfter != expected {
+			t.Errorf("'%s' got %s, want %s", value, retryAfter, expected)
+		}
+	}
+
+	if retryAfter := parseRetryAfter(date); retryAfter <= 58*time.Second ||
+		retryAfter > time.Minute {
+		t.Errorf("'%s' got %s, want about a minute", date, retryAfter)
+	}
+}
+
+func TestReceiptsLogsBalances(t *testing.T) {
+	node := testutil.NewEthNode(1)
+	defer node.Close()
+
+	block := node.Mine(eth.Transaction{From: "0x01"})
+	txHash := block.Transactions[0].Hash
+	node.SetReceipt(&eth.Receipt{TransactionHash: txHash, ContractAddress: "0xc1", Status: "0x1"})
+
+	transfer := eth.Log{
+		Address:   "0xc1",
+		Topics:    []string{"0xaa", "0xbb"},
+		BlockHash: block.Hash,
+		LogIndex:  "0x0",
+	}
+	approval := eth.Log{
+		Address:   "0xc1",
+		Topics:    []string{"0xcc"},
+		BlockHash: block.Hash,
+		LogIndex:  "0x1",
+	}
+	node.AddLog(transfer)
+	node.AddLog(approval)
+	node.AddLog(eth.Log{Address: "0xc2", Topics: []string{"0xaa"}, BlockHash: block.Hash})
+
+	holder := "0x52908400098527886e0f7030069857d2e4169ee7"
+	node.SetBalance(eth.Holding{Address: holder}, big.NewInt(1000))
+	node.SetBalance(eth.Holding{Address: holder, Token: "0xc1"}, big.NewInt(30))
+
+	poller := newTestPoller(node)
+
+	receipt, err := poller.Receipt(txHash)
+	if err != nil || receipt.ContractAddress != "0xc1" {
+		t.Errorf("got receipt %+v, %v", receipt, err)
+	}
+	if _, err := poller.Receipt("0xunknown"); !errors.Is(err, ErrResourceNotFound) {
+		t.Errorf("got error %v, want %v", err, ErrResourceNotFound)
+	}
+
+	logs, err := poller.Logs(block.Hash, []string{"0xc1"}, [][]string{{"0xaa", "0xcc"}})
+	if err != nil {
+		t.Fatalf("could not get logs: %v", err)
+	}
+	if expected := []eth.Log{transfer, approval}; !reflect.DeepEqual(logs, expected) {
+		t.Errorf("logs are not equal:\nhave: %+v\nwant: %+v", logs, expected)
+	}
+
+	// reverted call fails only its own holding
+	node.SetReverting("0xc2")
+	balances, errs, err := poller.BalancesAt([]eth.Holding{
+		{Address: holder, Token: "0xc1"},
+		{Address: holder, Token: "0xc2"},
+		{Address: holder},
+		{Address: "0x01"},
+	}, block.Number)
+	if err != nil {
+		t.Fatalf("could not get balances: %v", err)
+	}
+	if have, want := fmt.Sprint(balances), "[30 <nil> 1000 0]"; have != want {
+		t.Errorf("got balances %s, want %s", have, want)
+	}
+	for i, err := range errs {
+		if reverted := errors.Is(err, eth.ErrNoBalance); reverted != (i == 1) || !reverted && err != nil {
+			t.Errorf("got error %v of holding %d", err, i)
+		}
+	}
+}
+
+func TestPendingPoller(t *testing.T) {
+	node := testutil.NewEthNode(1)
+	defer node.Close()
+
+	poller := newTestPoller(node)
+	pendingPoller := NewPendingPoller(poller, poller.logger)
+	if err := pendingPoller.Init(); err != nil {
+		t.Fatalf("could not init: %v", err)
+	}
+	go pendingPoller.Routine()
+	defer pendingPoller.Shutdown()
+
+	mined := node.Mine(eth.Transaction{From: "0x01", Nonce: "0x1"})
+	node.AddPending(mined.Transactions[0])
+	node.AddPending(eth.Transaction{Hash: "0xp1", From: "0x01", Nonce: "0x2"})
+	node.AddPending(eth.Transaction{Hash: "0xp2", From: "0x02", Nonce: "0x1"})
+
+	var transactions []eth.Transaction
+	for len(transactions) < 2 {
+		select {
+		case batch := <-pendingPoller.PendingQueue():
+			transactions = append(transactions, batch...)
+		case <-time.After(testTimeout):
+			t.Fatalf("timed out waiting for pending transactions")
+		}
+	}
+
+	expected := []eth.Transaction{
+		{Hash: "0xp1", From: "0x01", Nonce: "0x2", Status: eth.StatusPending},
+		{Hash: "0xp2", From: "0x02", Nonce: "0x1", Status: eth.StatusPending},
+	}
+	if !reflect.DeepEqual(transactions, expected) {
+		t.Errorf("pending transactions are not equal:\nhave: %+v\nwant: %+v",
+			transactions, expected)
+	}
+}
+
+func TestRecordReplay(t *testing.T) {
+	node := testutil.NewEthNode(1)
+	defer node.Close()
+
+	recordFile := filepath.Join(t.TempDir(), "rpc.jsonl")
+	poller := newTestPoller(node)
+	poller.config.RecordFile = recordFile
+	if err := poller.Init(); err != nil {
+		t.Fatalf("could not init: %v", err)
+	}
+	go poller.Routine()
+
+	// failed exchanges are recorded as well, but not replayed
+	node.Inject(testutil.Fault{
+		Method: methodEthGetBlockByNumber,
+		Kind:   testutil.FaultStatus,
+		Status: http.StatusBadGateway,
+	})
+
+	var recorded []*eth.Block
+	recorded = append(recorded, nextBlock(t, poller.BlocksQueue()))
+	for i := 0; i < 2; i++ {
+		block := node.Mine(eth.Transaction{From: "0x01", To: "0x02"})
+		node.AddLog(eth.Log{Address: "0xc1", Topics: []string{"0xaa"}, BlockHash: block.Hash})
+		recorded = append(recorded, nextBlock(t, poller.BlocksQueue()))
+	}
+
+	txHash := recorded[1].Transactions[0].Hash
+	node.SetReceipt(&eth.Receipt{TransactionHash: txHash, Status: "0x1"})
+	node.SetBalance(eth.Holding{Address: "0x01"}, big.NewInt(7))
+
+	receipt, err := poller.Receipt(txHash)
+	if err != nil {
+		t.Fatalf("could not get receipt: %v", err)
+	}
+	logs, err := poller.Logs(recorded[2].Hash, []string{"0xc1"}, nil)
+	if err != nil {
+		t.Fatalf("could not get logs: %v", err)
+	}
+	balances, _, err := poller.BalancesAt([]eth.Holding{{Address: "0x01"}}, recorded[2].Number)
+	if err != nil {
+		t.Fatalf("could not get balances: %v", err)
+	}
+	poller.Shutdown()
+
+	replay := NewReplayStream(&EthPollerConfig{
+		ChainID:  1,
+		QueueLen: 1,
+		Replay:   ReplayConfig{File: recordFile},
+	}, poller.logger)
+	if err := replay.Init(); err != nil {
+		t.Fatalf("could not init replay: %v", err)
+	}
+	go replay.Routine()
+	defer replay.Shutdown()
+
+	var replayed []*eth.Block
+	for block := range replay.BlocksQueue() {
+		replayed = append(replayed, block)
+	}
+	if !reflect.DeepEqual(replayed, recorded) {
+		t.Errorf("replayed blocks are not equal:\nhave: %+v\nwant: %+v", replayed, recorded)
+	}
+	if number := replay.LastBlockNumber(); number != 2 {
+		t.Errorf("got last block %d, want 2", number)
+	}
+
+	replayedReceipt, err := replay.Receipt(txHash)
+	if err != nil || !reflect.DeepEqual(replayedReceipt, receipt) {
+		t.Errorf("got receipt %+v, %v, want %+v", replayedReceipt, err, receipt)
+	}
+	replayedLogs, err := replay.Logs(recorded[2].Hash, []string{"0xc1"}, nil)
+	if err != nil || !reflect.DeepEqual(replayedLogs, logs) {
+		t.Errorf("got logs %+v, %v, want %+v", replayedLogs, err, logs)
+	}
+	replayedBalances, errs, err := replay.BalancesAt([]eth.Holding{{Address: "0x01"}},
+		recorded[2].Number)
+	if err != nil || errs[0] != nil || fmt.Sprint(replayedBalances) != fmt.Sprint(balances) {
+		t.Errorf("got balances %v, %v, %v, want %v", replayedBalances, errs, err, balances)
+	}
+	// holdings which are not recorded fail separately
+	_, errs, err = replay.BalancesAt([]eth.Holding{{Address: "0x01"}, {Address: "0x02"}},
+		recorded[2].Number)
+	if err != nil || errs[0] != nil || !errors.Is(errs[1], ErrNotRecorded) {
+		t.Errorf("got errors %v, %v, want %v of the second holding", errs, err, ErrNotRecorded)
+	}
+	if _, err := replay.Receipt("0xunknown"); !errors.Is(err, ErrNotRecorded) {
+		t.Errorf("got error %v, want %v", err, ErrNotRecorded)
+	}
+}
+
+func TestFileStream(t *testing.T) {
+	dir := t.TempDir()
+	plainFile := filepath.Join(dir, "blocks.jsonl")
+	plain := `{"number":"0x1","hash":"0xb1","transactions":[{"hash":"0xt1","from":"0x01"}]}
+
+null
+{"jsonrpc":"2.0","id":1,"result":{"number":"0x2","hash":"0xb2","transactions":[]}}
+`
+	if err := os.WriteFile(plainFile, []byte(plain), 0o644); err != nil {
+		t.Fatalf("could not write file: %v", err)
+	}
+
+	gzipFile := filepath.Join(dir, "blocks.jsonl.gz")
+	compressed := &bytes.Buffer{}
+	gzipWriter := gzip.NewWriter(compressed)
+	gzipWriter.Write([]byte(`{"number":"0x3","hash":"0xb3","transactions":[]}`))
+	gzipWriter.Close()
+	if err := os.WriteFile(gzipFile, compressed.Bytes(), 0o644); err != nil {
+		t.Fatalf("could not write file: %v", err)
+	}
+
+	invalidFile := filepath.Join(dir, "invalid.jsonl")
+	if err := os.WriteFile(invalidFile, []byte(`{"hash":"0xb4"}`), 0o644); err != nil {
+		t.Fatalf("could not write file: %v", err)
+	}
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	stream := NewFileStream([]string{plainFile, gzipFile, invalidFile}, 1, 1, logger)
+	if err := stream.Init(); err != nil {
+		t.Fatalf("could not init: %v", err)
+	}
+	go stream.Routine()
+	defer stream.Shutdown()
+
+	var hashes []string
+	for block := range stream.BlocksQueue() {
+		hashes = append(hashes, block.Hash)
+		for _, transaction := range block.Transactions {
+			if transaction.Status != eth.StatusMined {
+				t.Errorf("got status %s, want %s", transaction.Status, eth.StatusMined)
+			}
+		}
+	}
+	if expected := []string{"0xb1", "0xb2", "0xb3"}; !reflect.DeepEqual(hashes, expected) {
+		t.Errorf("got blocks %v, want %v", hashes, expected)
+	}
+	if number := stream.LastBlockNumber(); number != 3 {
+		t.Errorf("got last block %d, want 3", number)
+	}
+	if err := stream.Err(); err == nil || !strings.Contains(err.Error(), "invalid.jsonl: line 1") {
+		t.Errorf("got error %v, want error of invalid file", err)
+	}
+
+	if err := NewFileStream([]string{filepath.Join(dir, "missing")}, 1, 1, logger).Init(); err == nil {
+		t.Errorf("expected error on missing file")
+	}
+}
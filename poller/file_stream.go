@@ -0,0 +1,267 @@
+package poller
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math/big"
+	"os"
+	"sync"
+	"time"
+
+	"eth-parser/eth"
+)
+
+var (
+	// ErrOffline is returned for data which is not available in block files
+	ErrOffline = fmt.Errorf("not available offline")
+
+	errShutdown = fmt.Errorf("shutdown")
+
+	// gzipMagic are the first bytes of gzip stream
+	gzipMagic = []byte{0x1f, 0x8b}
+)
+
+// FileStream streams blocks from local files instead of polling the endpoint.
+// Every line of the files is a block as returned by eth_getBlockByNumber with
+// full transactions, or JSON-RPC response with such result. Files may be gzip
+// compressed. Blocks are read lazily, so files may exceed memory, and the
+// stream ends after the last block of the last file.
+type FileStream struct {
+	paths   []string
+	chainID uint64
+	logger  *slog.Logger
+
+	lastBlockNumber    int64
+	lastBlockUpdatedAt time.Time
+	// err is the error which stopped the stream before the last block
+	err error
+	mu  sync.RWMutex
+
+	blocksQueue chan *eth.Block
+	shutdown    chan struct{}
+}
+
+// fileLine is a line of block file, JSON-RPC responses have Result
+type fileLine struct {
+	JSONRPC string          `json:"jsonrpc"`
+	Result  json.RawMessage `json:"result"`
+}
+
+func NewFileStream(paths []string, chainID uint64, queueLen int, logger *slog.Logger) *FileStream {
+	return &FileStream{
+		paths:       paths,
+		chainID:     chainID,
+		logger:      logger.With("component", "file_stream"),
+		blocksQueue: make(chan *eth.Block, queueLen),
+		shutdown:    make(chan struct{}),
+	}
+}
+
+// Init checks that all files exist, they are read by Routine
+func (f *FileStream) Init() error {
+	f.logger.Info("initializing", "files", len(f.paths))
+
+	if len(f.paths) == 0 {
+		return fmt.Errorf("no block files")
+	}
+	for _, path := range f.paths {
+		if _, err := os.Stat(path); err != nil {
+			return fmt.Errorf("could not read block file: %w", err)
+		}
+	}
+
+	f.logger.Info("successfully initialized")
+	return nil
+}
+
+func (f *FileStream) ChainID() uint64 {
+	return f.chainID
+}
+
+func (f *FileStream) LastBlockNumber() int64 {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	return f.lastBlockNumber
+}
+
+func (f *FileStream) LastBlockUpdatedAt() time.Time {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	return f.lastBlockUpdatedAt
+}
+
+// HeadBlockNumber returns number of the last streamed block, there are no
+// blocks known beyond the files
+func (f *FileStream) HeadBlockNumber() int64 {
+	return f.LastBlockNumber()
+}
+
+// Err returns the error which stopped the stream before the last block of
+// the files, it is valid after BlocksQueue is closed
+func (f *FileStream) Err() error {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	return f.err
+}
+
+func (f *FileStream) BlocksQueue() <-chan *eth.Block {
+	return f.blocksQueue
+}
+
+func (f *FileStream) Receipt(txHash string) (*eth.Receipt, error) {
+	return nil, fmt.Errorf("could not get receipt: %w", ErrOffline)
+}
+
+func (f *FileStream) Logs(
+	blockHash string,
+	addresses []string,
+	topics [][]string,
+) ([]eth.Log, error) {
+	return nil, fmt.Errorf("could not get logs: %w", ErrOffline)
+}
+
+func (f *FileStream) BalancesAt(
+	holdings []eth.Holding,
+	blockNumber string,
+) ([]*big.Int, []error, error) {
+	return nil, nil, fmt.Errorf("could not get balances: %w", ErrOffline)
+}
+
+// Routine streams blocks of the files in order and closes the queue after the
+// last one or on the first invalid line
+func (f *FileStream) Routine() {
+	defer close(f.blocksQueue)
+
+	for _, path := range f.paths {
+		blocks, err := f.streamFile(path)
+		if errors.Is(err, errShutdown) {
+			return
+		}
+		if err != nil {
+			f.logger.Error("could not stream block file", "file", path, "error", err)
+
+			f.mu.Lock()
+			f.err = fmt.Errorf("%s: %w", path, err)
+			f.mu.Unlock()
+			return
+		}
+
+		f.logger.Info("streamed block file", "file", path, "blocks", blocks)
+	}
+}
+
+// streamFile streams blocks of the file and returns their number
+func (f *FileStream) streamFile(path string) (int, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+
+	// lines are read without limit of their length, blocks may take megabytes
+	reader := bufio.NewReader(file)
+	if magic, _ := reader.Peek(len(gzipMagic)); bytes.Equal(magic, gzipMagic) {
+		gzipReader, err := gzip.NewReader(reader)
+		if err != nil {
+			return 0, err
+		}
+		defer gzipReader.Close()
+
+		reader = bufio.NewReader(gzipReader)
+	}
+
+	blocks := 0
+	for lineNumber := 1; ; lineNumber++ {
+		line, readErr := reader.ReadBytes('\n')
+		if readErr != nil && !errors.Is(readErr, io.EOF) {
+			return blocks, readErr
+		}
+
+		block, err := parseBlockLine(line)
+		if err != nil {
+			return blocks, fmt.Errorf("line %d: %w", lineNumber, err)
+		}
+		if block != nil {
+			if err := f.send(block); err != nil {
+				return blocks, err
+			}
+			blocks++
+		}
+
+		if readErr != nil {
+			return blocks, nil
+		}
+	}
+}
+
+// parseBlockLine parses block of the line, nil is returned for empty lines
+// and null results
+func parseBlockLine(line []byte) (*eth.Block, error) {
+	line = bytes.TrimSpace(line)
+	if len(line) == 0 || string(line) == "null" {
+		return nil, nil
+	}
+
+	response := &fileLine{}
+	if err := json.Unmarshal(line, response); err != nil {
+		return nil, err
+	}
+	if len(response.JSONRPC) != 0 {
+		line = response.Result
+		if isNull(line) {
+			return nil, nil
+		}
+	}
+
+	block := &eth.Block{}
+	if err := json.Unmarshal(line, block); err != nil {
+		return nil, err
+	}
+	if len(block.Number) == 0 {
+		return nil, fmt.Errorf("block without number")
+	}
+
+	for i := range block.Transactions {
+		block.Transactions[i].Status = eth.StatusMined
+	}
+
+	return block, nil
+}
+
+func (f *FileStream) send(block *eth.Block) error {
+	number, err := eth.ParseBigQuantity(block.Number)
+	if err != nil {
+		return fmt.Errorf("invalid block number: %w", err)
+	}
+
+	select {
+	case <-f.shutdown:
+		return errShutdown
+	case f.blocksQueue <- block:
+	}
+
+	f.mu.Lock()
+	f.lastBlockNumber = number.Int64()
+	f.lastBlockUpdatedAt = time.Now()
+	f.mu.Unlock()
+
+	return nil
+}
+
+func (f *FileStream) Shutdown() error {
+	f.logger.Info("starting shutdown")
+
+	close(f.shutdown)
+
+	f.logger.Info("successfully shutdown")
+	return nil
+}
@@ -0,0 +1,211 @@
+package poller
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"eth-parser/eth"
+)
+
+const (
+	methodEthNewPendingTransactionFilter = "eth_newPendingTransactionFilter"
+	methodEthGetFilterChanges            = "eth_getFilterChanges"
+	methodEthGetTransactionByHash        = "eth_getTransactionByHash"
+)
+
+var errFilterNotFound = fmt.Errorf("filter not found")
+
+type PendingConfig struct {
+	// Enabled enables watching of pending transactions, the endpoint must
+	// support eth_newPendingTransactionFilter
+	Enabled  bool          `yaml:"enabled"`
+	Interval time.Duration `yaml:"interval"`
+
+	// BatchSize is the max number of transactions requested in one batch
+	BatchSize int `yaml:"batch_size"`
+}
+
+// pendingTransaction is a transaction returned by eth_getTransactionByHash,
+// it has block number once it is mined
+type pendingTransaction struct {
+	eth.Transaction
+	BlockNumber *string `json:"blockNumber"`
+}
+
+// PendingPoller polls pending transactions of the endpoint with filter, it
+// shares the client and the config with the poller of the chain. Nil
+// PendingPoller is disabled and produces no transactions.
+type PendingPoller struct {
+	poller *EthPoller
+	config *PendingConfig
+	logger *slog.Logger
+
+	filterID string
+
+	pendingQueue chan []eth.Transaction
+	shutdown     chan struct{}
+}
+
+// NewPendingPoller returns nil if pending transactions are not enabled in the
+// config of the poller
+func NewPendingPoller(poller *EthPoller, logger *slog.Logger) *PendingPoller {
+	if !poller.config.Pending.Enabled {
+		return nil
+	}
+
+	return &PendingPoller{
+		poller:       poller,
+		config:       &poller.config.Pending,
+		logger:       logger.With("component", "pending_poller"),
+		pendingQueue: make(chan []eth.Transaction, poller.config.QueueLen),
+		shutdown:     make(chan struct{}),
+	}
+}
+
+func (p *PendingPoller) Init() error {
+	if p == nil {
+		return nil
+	}
+
+	p.logger.Info("initializing")
+
+	if err := p.newFilter(); err != nil {
+		return err
+	}
+
+	p.logger.Info("successfully initialized", "filter_id", p.filterID)
+	return nil
+}
+
+func (p *PendingPoller) newFilter() error {
+	if err := p.poller.call(methodEthNewPendingTransactionFilter, nil, &p.filterID); err != nil {
+		return fmt.Errorf("could not create pending transactions filter: %w", err)
+	}
+
+	return nil
+}
+
+// PendingQueue returns stream of batches of pending transactions
+func (p *PendingPoller) PendingQueue() <-chan []eth.Transaction {
+	if p == nil {
+		return nil
+	}
+
+	return p.pendingQueue
+}
+
+func (p *PendingPoller) Routine() {
+	if p == nil {
+		return
+	}
+
+	defer close(p.pendingQueue)
+
+	for {
+		select {
+		case <-p.shutdown:
+			return
+		case <-time.After(p.config.Interval):
+			// polling, pass
+		}
+
+		hashes, err := p.getFilterChanges()
+		if errors.Is(err, errFilterNotFound) {
+			// filters are removed by nodes if they are not polled for a while
+			p.logger.Warn("pending transactions filter expired, creating new one")
+			if err := p.newFilter(); err != nil {
+				p.logger.Warn("could not recreate filter", "error", err)
+			}
+			continue
+		}
+		if err != nil {
+			p.logger.Warn("could not get pending transactions", "error", err)
+			continue
+		}
+
+		for start := 0; start < len(hashes); start += p.config.BatchSize {
+			end := min(start+p.config.BatchSize, len(hashes))
+
+			transactions, err := p.getTransactions(hashes[start:end])
+			if err != nil {
+				p.logger.Warn("could not get pending transactions",
+					"transactions", end-start, "error", err)
+				continue
+			}
+			if len(transactions) == 0 {
+				continue
+			}
+
+			select {
+			case p.pendingQueue <- transactions:
+			case <-p.shutdown:
+				return
+			}
+		}
+	}
+}
+
+func (p *PendingPoller) getFilterChanges() ([]string, error) {
+	var hashes []string
+	err := p.poller.call(methodEthGetFilterChanges, []interface{}{p.filterID}, &hashes)
+	if err != nil && strings.Contains(err.Error(), errFilterNotFound.Error()) {
+		return nil, fmt.Errorf("%w: %s", errFilterNotFound, err)
+	}
+	if errors.Is(err, ErrResourceNotFound) {
+		return nil, nil
+	}
+
+	return hashes, err
+}
+
+// getTransactions returns transactions with given hashes which are still
+// pending, transactions which are already mined, unknown or failed to be
+// got are skipped
+func (p *PendingPoller) getTransactions(hashes []string) ([]eth.Transaction, error) {
+	params := make([]interface{}, len(hashes))
+	results := make([]interface{}, len(hashes))
+	for i, hash := range hashes {
+		params[i] = []interface{}{hash}
+		results[i] = &pendingTransaction{}
+	}
+
+	errs, err := p.poller.callBatch(methodEthGetTransactionByHash, params, results)
+	if err != nil {
+		return nil, err
+	}
+
+	transactions := make([]eth.Transaction, 0, len(hashes))
+	for i, result := range results {
+		if errs[i] != nil {
+			p.logger.Debug("could not get pending transaction", "tx_hash", hashes[i],
+				"error", errs[i])
+			continue
+		}
+
+		transaction := result.(*pendingTransaction)
+		if len(transaction.Hash) == 0 || transaction.BlockNumber != nil {
+			continue
+		}
+
+		transaction.Status = eth.StatusPending
+		transactions = append(transactions, transaction.Transaction)
+	}
+
+	return transactions, nil
+}
+
+func (p *PendingPoller) Shutdown() error {
+	if p == nil {
+		return nil
+	}
+
+	p.logger.Info("starting shutdown")
+
+	close(p.shutdown)
+
+	p.logger.Info("successfully shutdown")
+	return nil
+}
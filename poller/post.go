@@ -6,26 +6,44 @@ import (
 	"errors"
 	"fmt"
 	"io/ioutil"
-	"log"
+	"math/rand/v2"
 	"net"
 	"net/http"
 	"os"
+	"strconv"
 	"syscall"
-
-	"eth-parser/jsonrpc"
+	"time"
 )
 
 var (
 	errBadHTTPStatusCode = fmt.Errorf("bad http status code")
 )
 
+// statusError is error of response with retryable status code, the server
+// may ask to retry not earlier than after retryAfter
+type statusError struct {
+	statusCode int
+	retryAfter time.Duration
+}
+
+func (e *statusError) Error() string {
+	return fmt.Sprintf("got %s: %d", errBadHTTPStatusCode, e.statusCode)
+}
+
+func (e *statusError) Unwrap() error {
+	return errBadHTTPStatusCode
+}
+
+// executePOSTRequestWithRetries sends single packet or batch of packets of
+// the method
 func (e *EthPoller) executePOSTRequestWithRetries(
-	packet *jsonrpc.Packet,
+	method string,
+	payload interface{},
 ) (
 	respData []byte,
 	err error,
 ) {
-	data, marshalErr := json.Marshal(packet)
+	data, marshalErr := json.Marshal(payload)
 	if marshalErr != nil {
 		return nil, fmt.Errorf("could not marshal packet: %w", marshalErr)
 	}
@@ -39,12 +57,16 @@ func (e *EthPoller) executePOSTRequestWithRetries(
 			return nil, err
 		}
 
-		log.Printf(
-			"eth_poller: executePOSTRequestWithRetries(): error on executing POST request [%d/%d]: %s",
-			i+1, e.config.NumRetries, err,
+		e.logger.Warn("error on executing POST request",
+			"method", method,
+			"attempt", i+1,
+			"num_retries", e.config.NumRetries,
+			"will_retry", i < e.config.NumRetries-1,
+			"error", err,
 		)
-		if i < e.config.NumRetries-1 {
-			log.Printf("eth_poller: executePOSTRequestWithRetries(): will retry")
+
+		if i < e.config.NumRetries-1 && !e.waitRetry(i, err) {
+			return nil, err
 		}
 	}
 
@@ -56,18 +78,28 @@ func (e *EthPoller) executePOSTRequest(data []byte) ([]byte, error) {
 
 	resp, err := e.httpClient.Post(e.config.Endpoint, "application/json", buf)
 	if err != nil {
-		return nil, fmt.Errorf("could not make request: %w", err)
+		err = fmt.Errorf("could not make request: %w", err)
+		e.record(data, 0, nil, err)
+		return nil, err
 	}
 
 	defer resp.Body.Close()
 
 	body, err := ioutil.ReadAll(resp.Body)
 	if err != nil {
-		return nil, fmt.Errorf("could not read response body: %w", err)
+		err = fmt.Errorf("could not read response body: %w", err)
+		e.record(data, resp.StatusCode, nil, err)
+		return nil, err
 	}
+	e.record(data, resp.StatusCode, body, nil)
 
-	if resp.StatusCode >= http.StatusInternalServerError {
-		return nil, fmt.Errorf("got %w: %d", errBadHTTPStatusCode, resp.StatusCode)
+	// rate limited requests are retried as well as failed ones
+	if resp.StatusCode >= http.StatusInternalServerError ||
+		resp.StatusCode == http.StatusTooManyRequests {
+		return nil, &statusError{
+			statusCode: resp.StatusCode,
+			retryAfter: parseRetryAfter(resp.Header.Get("Retry-After")),
+		}
 	}
 	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
 		return nil, fmt.Errorf("got unexpected http status code: %d", resp.StatusCode)
@@ -76,6 +108,68 @@ func (e *EthPoller) executePOSTRequest(data []byte) ([]byte, error) {
 	return body, nil
 }
 
+// waitRetry waits before the retry following the attempt, it returns false
+// if the poller is shut down meanwhile
+func (e *EthPoller) waitRetry(attempt int, err error) bool {
+	delay := retryDelay(e.config.RetryBackoff, e.config.MaxRetryBackoff, attempt)
+
+	// the server knows better when it is ready to serve again, but it is not
+	// waited for longer than the max backoff
+	var statusErr *statusError
+	if errors.As(err, &statusErr) && statusErr.retryAfter > delay {
+		delay = max(min(statusErr.retryAfter, e.config.MaxRetryBackoff), delay)
+	}
+	if delay <= 0 {
+		return true
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return true
+	case <-e.shutdown:
+		return false
+	}
+}
+
+// retryDelay returns exponential backoff of the attempt capped with maxDelay,
+// it is jittered within its upper half so that retries of concurrent requests
+// are spread
+func retryDelay(base, maxDelay time.Duration, attempt int) time.Duration {
+	if base <= 0 {
+		return 0
+	}
+
+	delay := base
+	for i := 0; i < attempt && delay < maxDelay; i++ {
+		delay *= 2
+	}
+	delay = min(delay, maxDelay)
+	if delay <= 0 {
+		return 0
+	}
+
+	return delay/2 + rand.N(delay/2+1)
+}
+
+// parseRetryAfter parses Retry-After header given either in seconds or as
+// HTTP date, zero is returned if it is not set or invalid
+func parseRetryAfter(value string) time.Duration {
+	if len(value) == 0 {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		return max(time.Duration(seconds)*time.Second, 0)
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		return max(time.Until(date), 0)
+	}
+
+	return 0
+}
+
 func (e *EthPoller) shouldRetry(httpErr error) bool {
 	if errors.Is(httpErr, errBadHTTPStatusCode) {
 		return true
@@ -91,3 +185,11 @@ func (e *EthPoller) shouldRetry(httpErr error) bool {
 
 	return false
 }
+
+// record records the exchange if recording is enabled, errors of recording
+// do not fail the request
+func (e *EthPoller) record(request []byte, status int, body []byte, exchangeErr error) {
+	if err := e.recorder.record(request, status, body, exchangeErr); err != nil {
+		e.logger.Warn("could not record request", "error", err)
+	}
+}
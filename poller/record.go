@@ -0,0 +1,84 @@
+package poller
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// recordEntry is a line of the recording, a single HTTP exchange with the
+// endpoint. Request and Response are raw JSON-RPC packets or batches of them.
+type recordEntry struct {
+	Time    time.Time       `json:"time"`
+	Request json.RawMessage `json:"request"`
+	// Status is the HTTP status of the response, it is zero if the request
+	// failed before the response
+	Status   int             `json:"status,omitempty"`
+	Response json.RawMessage `json:"response,omitempty"`
+	Error    string          `json:"error,omitempty"`
+}
+
+// recorder appends exchanges with the endpoint to JSONL file, nil recorder
+// records nothing
+type recorder struct {
+	file *os.File
+	mu   sync.Mutex
+}
+
+func newRecorder(path string) (*recorder, error) {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("could not open record file: %w", err)
+	}
+
+	return &recorder{file: file}, nil
+}
+
+// record writes the exchange, response body which is not JSON is kept in
+// Error
+func (r *recorder) record(request []byte, status int, body []byte, exchangeErr error) error {
+	if r == nil {
+		return nil
+	}
+
+	entry := &recordEntry{
+		Time:    time.Now().UTC(),
+		Request: request,
+		Status:  status,
+	}
+	if json.Valid(body) {
+		entry.Response = body
+	} else if len(body) != 0 {
+		entry.Error = string(body)
+	}
+	if exchangeErr != nil {
+		entry.Error = exchangeErr.Error()
+	}
+
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("could not marshal record: %w", err)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, err := r.file.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("could not write record: %w", err)
+	}
+
+	return nil
+}
+
+func (r *recorder) Close() error {
+	if r == nil {
+		return nil
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.file.Close()
+}
@@ -0,0 +1,375 @@
+package poller
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math/big"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"eth-parser/eth"
+	"eth-parser/jsonrpc"
+)
+
+var ErrNotRecorded = fmt.Errorf("request is not recorded")
+
+type ReplayConfig struct {
+	// File is the recording of the poller which is replayed instead of
+	// polling the endpoint, see RecordFile
+	File string `yaml:"file"`
+
+	// RealTime replays blocks with the recorded intervals between them,
+	// blocks are replayed as fast as they are parsed otherwise
+	RealTime bool `yaml:"real_time"`
+}
+
+// ReplayStream replays blocks recorded by the poller and answers requests of
+// the parser with the recorded responses, so that the parser processes the
+// same data as it did when recording. The stream ends after the last block.
+type ReplayStream struct {
+	config *EthPollerConfig
+	logger *slog.Logger
+
+	blocks []recordedBlock
+	// results maps method and params of recorded requests to their results
+	results map[string]json.RawMessage
+
+	headBlockNumber    int64
+	lastBlockNumber    int64
+	lastBlockUpdatedAt time.Time
+	mu                 sync.RWMutex
+
+	blocksQueue chan *eth.Block
+	shutdown    chan struct{}
+}
+
+type recordedBlock struct {
+	block  *eth.Block
+	number int64
+	time   time.Time
+}
+
+// recordedPacket is a packet of recorded request or response
+type recordedPacket struct {
+	ID     uint            `json:"id"`
+	Method string          `json:"method"`
+	Params json.RawMessage `json:"params"`
+	Result json.RawMessage `json:"result"`
+	Error  *jsonrpc.Error  `json:"error"`
+}
+
+func NewReplayStream(config *EthPollerConfig, logger *slog.Logger) *ReplayStream {
+	return &ReplayStream{
+		config:      config,
+		logger:      logger.With("component", "replay_stream"),
+		results:     make(map[string]json.RawMessage),
+		blocksQueue: make(chan *eth.Block, config.QueueLen),
+		shutdown:    make(chan struct{}),
+	}
+}
+
+// Init reads the recording, only successful exchanges are replayed
+func (r *ReplayStream) Init() error {
+	r.logger.Info("initializing", "file", r.config.Replay.File)
+
+	file, err := os.Open(r.config.Replay.File)
+	if err != nil {
+		return fmt.Errorf("could not open replay file: %w", err)
+	}
+	defer file.Close()
+
+	r.blocks = nil
+	clear(r.results)
+	seen := make(map[int64]struct{})
+
+	// lines are read without limit of their length, blocks may take megabytes
+	reader := bufio.NewReader(file)
+	for lineNumber := 1; ; lineNumber++ {
+		line, err := reader.ReadBytes('\n')
+		if len(bytes.TrimSpace(line)) != 0 {
+			if err := r.addEntry(line, seen); err != nil {
+				return fmt.Errorf("could not read replay file line %d: %w", lineNumber, err)
+			}
+		}
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("could not read replay file: %w", err)
+		}
+	}
+
+	if len(r.blocks) == 0 {
+		return fmt.Errorf("no blocks are recorded in %s", r.config.Replay.File)
+	}
+
+	r.lastBlockNumber = r.blocks[0].number - 1
+	r.headBlockNumber = max(r.headBlockNumber, r.blocks[len(r.blocks)-1].number)
+
+	r.logger.Info("successfully initialized", "blocks", len(r.blocks),
+		"first_block", r.blocks[0].number, "last_block", r.blocks[len(r.blocks)-1].number)
+	return nil
+}
+
+// addEntry indexes results of the exchange, blocks are replayed in the order
+// they were first received
+func (r *ReplayStream) addEntry(line []byte, seen map[int64]struct{}) error {
+	entry := &recordEntry{}
+	if err := json.Unmarshal(line, entry); err != nil {
+		return err
+	}
+	if len(entry.Response) == 0 || len(entry.Error) != 0 {
+		return nil
+	}
+
+	requests, err := unmarshalPackets(entry.Request)
+	if err != nil {
+		return fmt.Errorf("invalid request: %w", err)
+	}
+	responses, err := unmarshalPackets(entry.Response)
+	if err != nil {
+		return fmt.Errorf("invalid response: %w", err)
+	}
+	results := make(map[uint]*recordedPacket, len(responses))
+	for _, response := range responses {
+		results[response.ID] = response
+	}
+
+	for _, request := range requests {
+		response, ok := results[request.ID]
+		if !ok || response.Error != nil {
+			continue
+		}
+		r.results[resultKey(request.Method, request.Params)] = response.Result
+
+		switch request.Method {
+		case methodEthChainID:
+			var rawChainID string
+			json.Unmarshal(response.Result, &rawChainID)
+			chainID, err := eth.ParseBigQuantity(rawChainID)
+			if err != nil || chainID.Uint64() != r.config.ChainID {
+				return fmt.Errorf("recorded chain %s, expected %d", rawChainID, r.config.ChainID)
+			}
+		case methodEthBlockNumber:
+			var rawNumber string
+			json.Unmarshal(response.Result, &rawNumber)
+			if number, err := strconv.ParseInt(strings.TrimPrefix(rawNumber, "0x"), 16, 64); err == nil {
+				r.headBlockNumber = max(r.headBlockNumber, number)
+			}
+		case methodEthGetBlockByNumber:
+			if isNull(response.Result) {
+				continue
+			}
+
+			block := &eth.Block{}
+			if err := json.Unmarshal(response.Result, block); err != nil {
+				return fmt.Errorf("invalid block: %w", err)
+			}
+			number, err := eth.ParseBigQuantity(block.Number)
+			if err != nil {
+				return fmt.Errorf("invalid block number: %w", err)
+			}
+			if _, ok := seen[number.Int64()]; ok {
+				continue
+			}
+			seen[number.Int64()] = struct{}{}
+
+			for i := range block.Transactions {
+				block.Transactions[i].Status = eth.StatusMined
+			}
+			r.blocks = append(r.blocks, recordedBlock{
+				block:  block,
+				number: number.Int64(),
+				time:   entry.Time,
+			})
+		}
+	}
+
+	return nil
+}
+
+// unmarshalPackets unmarshals single packet or batch of packets
+func unmarshalPackets(data json.RawMessage) ([]*recordedPacket, error) {
+	data = bytes.TrimSpace(data)
+	if len(data) != 0 && data[0] == '[' {
+		var packets []*recordedPacket
+		err := json.Unmarshal(data, &packets)
+		return packets, err
+	}
+
+	packet := &recordedPacket{}
+	if err := json.Unmarshal(data, packet); err != nil {
+		return nil, err
+	}
+
+	return []*recordedPacket{packet}, nil
+}
+
+// resultKey is the key of recorded result of the method called with params,
+// params are compared as marshaled by the poller
+func resultKey(method string, params []byte) string {
+	compacted := &bytes.Buffer{}
+	if err := json.Compact(compacted, params); err != nil {
+		return method + " " + string(params)
+	}
+
+	return method + " " + compacted.String()
+}
+
+func isNull(data json.RawMessage) bool {
+	return len(data) == 0 || string(data) == "null"
+}
+
+// lookup unmarshals recorded result of the method called with params into
+// result. ErrResourceNotFound is returned if the result is null.
+func (r *ReplayStream) lookup(method string, params interface{}, result interface{}) error {
+	data, err := json.Marshal(params)
+	if err != nil {
+		return fmt.Errorf("could not marshal params: %w", err)
+	}
+
+	recorded, ok := r.results[resultKey(method, data)]
+	if !ok {
+		return fmt.Errorf("%w: %s %s", ErrNotRecorded, method, data)
+	}
+	if isNull(recorded) {
+		return ErrResourceNotFound
+	}
+	if err := json.Unmarshal(recorded, result); err != nil {
+		return fmt.Errorf("got wrong result: %w", err)
+	}
+
+	return nil
+}
+
+func (r *ReplayStream) ChainID() uint64 {
+	return r.config.ChainID
+}
+
+func (r *ReplayStream) LastBlockNumber() int64 {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return r.lastBlockNumber
+}
+
+func (r *ReplayStream) LastBlockUpdatedAt() time.Time {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return r.lastBlockUpdatedAt
+}
+
+// HeadBlockNumber returns number of the latest block known to the endpoint
+// when recording
+func (r *ReplayStream) HeadBlockNumber() int64 {
+	return r.headBlockNumber
+}
+
+func (r *ReplayStream) BlocksQueue() <-chan *eth.Block {
+	return r.blocksQueue
+}
+
+// Receipt returns recorded receipt of the transaction
+func (r *ReplayStream) Receipt(txHash string) (*eth.Receipt, error) {
+	receipt := &eth.Receipt{}
+	err := r.lookup(methodEthGetTransactionReceipt, receiptParams(txHash), receipt)
+	if err != nil {
+		return nil, fmt.Errorf("could not get receipt: %w", err)
+	}
+
+	return receipt, nil
+}
+
+// Logs returns recorded logs of the block requested with the same contracts
+// and topics
+func (r *ReplayStream) Logs(
+	blockHash string,
+	addresses []string,
+	topics [][]string,
+) ([]eth.Log, error) {
+	var logs []eth.Log
+	err := r.lookup(methodEthGetLogs, logsParams(blockHash, addresses, topics), &logs)
+	if err != nil && !errors.Is(err, ErrResourceNotFound) {
+		return nil, fmt.Errorf("could not get logs: %w", err)
+	}
+
+	return logs, nil
+}
+
+// BalancesAt returns recorded balances of holdings at the block, they may
+// have been requested in different batches. Holdings which are not recorded
+// get errors at their indexes.
+func (r *ReplayStream) BalancesAt(
+	holdings []eth.Holding,
+	blockNumber string,
+) ([]*big.Int, []error, error) {
+	balances := make([]*big.Int, len(holdings))
+	errs := make([]error, len(holdings))
+	for i, holding := range holdings {
+		method, params := balanceCall(holding, blockNumber)
+
+		var rawBalance string
+		if err := r.lookup(method, params, &rawBalance); err != nil {
+			errs[i] = fmt.Errorf("could not get balance: %w", err)
+			continue
+		}
+
+		balance, err := eth.ParseBigQuantity(rawBalance)
+		if err != nil {
+			errs[i] = fmt.Errorf("could not parse balance: %w", err)
+			continue
+		}
+		balances[i] = balance
+	}
+
+	return balances, errs, nil
+}
+
+// Routine feeds recorded blocks to the queue and closes it after the last one
+func (r *ReplayStream) Routine() {
+	defer close(r.blocksQueue)
+
+	var previous time.Time
+	for _, recorded := range r.blocks {
+		if r.config.Replay.RealTime && !previous.IsZero() {
+			select {
+			case <-r.shutdown:
+				return
+			case <-time.After(recorded.time.Sub(previous)):
+				// keeping recorded pace, pass
+			}
+		}
+		previous = recorded.time
+
+		select {
+		case <-r.shutdown:
+			return
+		case r.blocksQueue <- recorded.block:
+		}
+
+		r.mu.Lock()
+		r.lastBlockNumber = recorded.number
+		r.lastBlockUpdatedAt = time.Now()
+		r.mu.Unlock()
+	}
+
+	r.logger.Info("replayed all blocks", "blocks", len(r.blocks))
+}
+
+func (r *ReplayStream) Shutdown() error {
+	r.logger.Info("starting shutdown")
+
+	close(r.shutdown)
+
+	r.logger.Info("successfully shutdown")
+	return nil
+}
@@ -0,0 +1,52 @@
+package prices
+
+import (
+	"time"
+
+	"eth-parser/auth"
+)
+
+// sources of prices
+const (
+	SourceFile = "file"
+	SourceHTTP = "http"
+)
+
+type Config struct {
+	// Currencies are codes of fiat currencies, e.g. usd and eur, matched
+	// transactions are valuated in. Valuation is disabled if empty.
+	Currencies []string `yaml:"currencies"`
+
+	// Source is the source of prices, file or http
+	Source string `yaml:"source"`
+
+	// File is CSV file of historical prices of file source
+	File string `yaml:"file"`
+
+	HTTP HTTPConfig `yaml:"http"`
+
+	// Resolution is the period prices are cached for, e.g. 1h or 24h.
+	// Transactions are valuated at the price of the start of the period of
+	// their block.
+	Resolution time.Duration `yaml:"resolution"`
+
+	// FailureTTL is the time failure to get price of a period is cached for,
+	// so that unavailable source does not delay valuation of every
+	// transaction. Failures are not cached if zero.
+	FailureTTL time.Duration `yaml:"failure_ttl"`
+}
+
+// HTTPConfig configures http source, which requests CoinGecko compatible API
+type HTTPConfig struct {
+	// URL is the base URL of the API
+	URL string `yaml:"url"`
+
+	// Coin is ID of the native coin of the chain in the API
+	Coin string `yaml:"coin"`
+
+	// APIKey is passed in APIKeyHeader if not empty
+	APIKey       auth.Secret `yaml:"api_key"`
+	APIKeyHeader string      `yaml:"api_key_header"`
+
+	Timeout time.Duration `yaml:"timeout"`
+}
@@ -0,0 +1,106 @@
+package prices
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// fileSource keeps historical prices loaded from CSV file with time,
+// currency and price of 1 ETH columns and optional header, e.g.
+//
+//	time,currency,price
+//	2024-01-01,usd,2281.47
+//	2024-01-01T12:00:00Z,eur,2079.1
+//
+// Time is RFC 3339 time or date in UTC.
+type fileSource struct {
+	// prices are sorted by time for every currency
+	prices map[string][]filePrice
+}
+
+type filePrice struct {
+	time  time.Time
+	price *big.Rat
+}
+
+func loadFileSource(path string) (*fileSource, error) {
+	file, err := os.Open(filepath.Clean(path))
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	source := &fileSource{prices: make(map[string][]filePrice)}
+
+	reader := csv.NewReader(file)
+	reader.FieldsPerRecord = 3
+	reader.TrimLeadingSpace = true
+	for line := 1; ; line++ {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if line == 1 && record[0] == "time" {
+			continue
+		}
+
+		at, err := parseTime(record[0])
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid time '%s'", line, record[0])
+		}
+		price, ok := new(big.Rat).SetString(record[2])
+		if !ok || price.Sign() < 0 {
+			return nil, fmt.Errorf("line %d: invalid price '%s'", line, record[2])
+		}
+
+		currency := strings.ToLower(record[1])
+		source.prices[currency] = append(source.prices[currency],
+			filePrice{time: at, price: price})
+	}
+
+	for _, prices := range source.prices {
+		sort.SliceStable(prices, func(i, j int) bool {
+			return prices[i].time.Before(prices[j].time)
+		})
+	}
+
+	return source, nil
+}
+
+// price returns the first price within the period or the latest price before
+// it, so that daily prices serve shorter periods as well
+func (f *fileSource) price(currency string, from, to time.Time) (*big.Rat, error) {
+	prices := f.prices[currency]
+
+	i := sort.Search(len(prices), func(i int) bool {
+		return !prices[i].time.Before(from)
+	})
+	if i < len(prices) && prices[i].time.Before(to) {
+		return prices[i].price, nil
+	}
+	if i == 0 {
+		return nil, ErrNoPrice
+	}
+
+	return prices[i-1].price, nil
+}
+
+// parseTime parses RFC 3339 time or date in UTC
+func parseTime(value string) (time.Time, error) {
+	if at, err := time.Parse(time.RFC3339, value); err == nil {
+		return at, nil
+	}
+
+	return time.Parse(time.DateOnly, value)
+}
@@ -0,0 +1,76 @@
+package prices
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// httpSource gets prices with market chart of the coin from CoinGecko
+// compatible API, the price of the period is the first price of the chart of
+// the period
+type httpSource struct {
+	config *HTTPConfig
+	client *http.Client
+}
+
+type marketChartResponse struct {
+	// Prices are pairs of unix time in milliseconds and price
+	Prices [][2]json.Number `json:"prices"`
+}
+
+func newHTTPSource(config *HTTPConfig) *httpSource {
+	return &httpSource{
+		config: config,
+		client: &http.Client{Timeout: config.Timeout},
+	}
+}
+
+func (h *httpSource) price(currency string, from, to time.Time) (*big.Rat, error) {
+	query := url.Values{
+		"vs_currency": {currency},
+		"from":        {strconv.FormatInt(from.Unix(), 10)},
+		"to":          {strconv.FormatInt(to.Unix(), 10)},
+	}
+	endpoint := fmt.Sprintf("%s/coins/%s/market_chart/range?%s",
+		strings.TrimSuffix(h.config.URL, "/"), url.PathEscape(h.config.Coin), query.Encode())
+
+	req, err := http.NewRequest(http.MethodGet, endpoint, nil)
+	if err != nil {
+		return nil, fmt.Errorf("could not create request: %w", err)
+	}
+	req.Header.Set("Accept", "application/json")
+	if len(h.config.APIKey) != 0 {
+		req.Header.Set(h.config.APIKeyHeader, string(h.config.APIKey))
+	}
+
+	resp, err := h.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("could not execute request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("got status %s", resp.Status)
+	}
+
+	response := &marketChartResponse{}
+	if err := json.NewDecoder(resp.Body).Decode(response); err != nil {
+		return nil, fmt.Errorf("could not unmarshal response: %w", err)
+	}
+	if len(response.Prices) == 0 {
+		return nil, ErrNoPrice
+	}
+
+	price, ok := new(big.Rat).SetString(response.Prices[0][1].String())
+	if !ok {
+		return nil, fmt.Errorf("invalid price '%s'", response.Prices[0][1])
+	}
+
+	return price, nil
+}
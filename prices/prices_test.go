@@ -0,0 +1,197 @@
+package prices
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestFileSource(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "prices.csv")
+	data := "time,currency,price\n" +
+		"2024-01-02,usd,2400\n" +
+		"2024-01-01,USD,2000.5\n" +
+		"2024-01-01T12:00:00Z,usd,2200\n" +
+		"2024-01-01,eur,1800\n"
+	if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+		t.Fatalf("could not write prices: %v", err)
+	}
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	provider := NewProvider(&Config{
+		Currencies: []string{"USD", "eur"},
+		Source:     SourceFile,
+		File:       path,
+		Resolution: time.Hour,
+	}, logger)
+	if err := provider.Init(); err != nil {
+		t.Fatalf("could not init provider: %v", err)
+	}
+
+	testCases := []struct {
+		name     string
+		wei      string
+		at       time.Time
+		expected map[string]string
+	}{
+		{
+			name:     "start of day",
+			wei:      "1000000000000000000",
+			at:       time.Date(2024, 1, 1, 0, 30, 0, 0, time.UTC),
+			expected: map[string]string{"usd": "2000.50", "eur": "1800.00"},
+		},
+		{
+			// daily price of eur serves hourly periods
+			name:     "within period",
+			wei:      "500000000000000000",
+			at:       time.Date(2024, 1, 1, 12, 59, 59, 0, time.UTC),
+			expected: map[string]string{"usd": "1100.00", "eur": "900.00"},
+		},
+		{
+			name:     "rounding",
+			wei:      "1234567",
+			at:       time.Date(2024, 1, 3, 0, 0, 0, 0, time.UTC),
+			expected: map[string]string{"usd": "0.00", "eur": "0.00"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			wei, _ := new(big.Int).SetString(tc.wei, 10)
+			amounts, err := provider.Valuate(wei, tc.at)
+			if err != nil {
+				t.Fatalf("could not valuate: %v", err)
+			}
+
+			if !reflect.DeepEqual(amounts, tc.expected) {
+				t.Errorf("amounts are not equal:\nhave: %v\nwant: %v", amounts, tc.expected)
+			}
+		})
+	}
+
+	_, err := provider.Valuate(big.NewInt(1), time.Date(2023, 12, 31, 0, 0, 0, 0, time.UTC))
+	if !errors.Is(err, ErrNoPrice) {
+		t.Errorf("got error %v, want no price", err)
+	}
+}
+
+func TestHTTPSource(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+
+		query := r.URL.Query()
+		if r.URL.Path != "/coins/ethereum/market_chart/range" ||
+			query.Get("vs_currency") != "usd" || r.Header.Get("x-api-key") != "key" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		if query.Get("from") == "0" {
+			fmt.Fprint(w, `{"prices":[]}`)
+			return
+		}
+
+		fmt.Fprintf(w, `{"prices":[[%s000,2000.125],[%s000,2100]]}`,
+			query.Get("from"), query.Get("to"))
+	}))
+	defer server.Close()
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	provider := NewProvider(&Config{
+		Currencies: []string{"usd"},
+		Source:     SourceHTTP,
+		HTTP: HTTPConfig{
+			URL:          server.URL + "/",
+			Coin:         "ethereum",
+			APIKey:       "key",
+			APIKeyHeader: "x-api-key",
+			Timeout:      time.Second,
+		},
+		Resolution: 24 * time.Hour,
+	}, logger)
+	if err := provider.Init(); err != nil {
+		t.Fatalf("could not init provider: %v", err)
+	}
+
+	// both times are in the same day, so the price is requested once
+	for _, at := range []time.Time{time.Unix(1700000000, 0), time.Unix(1700006000, 0)} {
+		price, err := provider.Price("usd", at)
+		if err != nil {
+			t.Fatalf("could not get price: %v", err)
+		}
+		if price.FloatString(3) != "2000.125" {
+			t.Errorf("got price %s, want 2000.125", price.FloatString(3))
+		}
+	}
+	if requests.Load() != 1 {
+		t.Errorf("got %d requests, want 1", requests.Load())
+	}
+
+	if _, err := provider.Price("usd", time.Unix(0, 0)); !errors.Is(err, ErrNoPrice) {
+		t.Errorf("got error %v, want no price", err)
+	}
+	if _, err := provider.Price("eur", time.Unix(1700000000, 0)); err == nil {
+		t.Errorf("expected error of unknown currency")
+	}
+}
+
+func TestHTTPSourceFailure(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	provider := NewProvider(&Config{
+		Currencies: []string{"usd"},
+		Source:     SourceHTTP,
+		HTTP: HTTPConfig{
+			URL:     server.URL + "/",
+			Coin:    "ethereum",
+			Timeout: time.Second,
+		},
+		Resolution: 24 * time.Hour,
+		FailureTTL: 100 * time.Millisecond,
+	}, logger)
+	if err := provider.Init(); err != nil {
+		t.Fatalf("could not init provider: %v", err)
+	}
+
+	// failure is cached for the period until it expires
+	for range 3 {
+		if _, err := provider.Price("usd", time.Unix(1700000000, 0)); err == nil {
+			t.Fatalf("expected error of unavailable source")
+		}
+	}
+	if requests.Load() != 1 {
+		t.Errorf("got %d requests, want 1", requests.Load())
+	}
+
+	// other periods are requested separately
+	if _, err := provider.Price("usd", time.Unix(1800000000, 0)); err == nil {
+		t.Fatalf("expected error of unavailable source")
+	}
+	if requests.Load() != 2 {
+		t.Errorf("got %d requests, want 2", requests.Load())
+	}
+
+	time.Sleep(150 * time.Millisecond)
+	if _, err := provider.Price("usd", time.Unix(1700000000, 0)); err == nil {
+		t.Fatalf("expected error of unavailable source")
+	}
+	if requests.Load() != 3 {
+		t.Errorf("got %d requests after failure expired, want 3", requests.Load())
+	}
+}
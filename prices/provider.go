@@ -0,0 +1,171 @@
+package prices
+
+import (
+	"fmt"
+	"log/slog"
+	"math/big"
+	"strings"
+	"sync"
+	"time"
+
+	"eth-parser/eth"
+)
+
+// fiatDecimals is the number of decimals of fiat amounts
+const fiatDecimals = 2
+
+var (
+	ErrNoPrice       = fmt.Errorf("no price")
+	errUnknownSource = fmt.Errorf("unknown source")
+)
+
+// source gets price of 1 ETH in the currency for the period [from, to)
+type source interface {
+	price(currency string, from, to time.Time) (*big.Rat, error)
+}
+
+// Provider valuates amounts of ETH in fiat currencies with prices of the
+// configured source, prices are cached by periods of the resolution
+type Provider struct {
+	config     *Config
+	currencies []string
+
+	source source
+
+	// cache keeps prices by currency and start of the period
+	cache map[cacheKey]*big.Rat
+	// failures keeps failures to get prices until they expire
+	failures map[cacheKey]failure
+	// mu guards the caches, it is held while the price is got from the
+	// source, so that a period is not requested concurrently
+	mu sync.Mutex
+
+	logger *slog.Logger
+}
+
+type cacheKey struct {
+	currency string
+	period   int64
+}
+
+type failure struct {
+	err       error
+	expiresAt time.Time
+}
+
+func NewProvider(config *Config, logger *slog.Logger) *Provider {
+	currencies := make([]string, 0, len(config.Currencies))
+	for _, currency := range config.Currencies {
+		currencies = append(currencies, strings.ToLower(currency))
+	}
+
+	return &Provider{
+		config:     config,
+		currencies: currencies,
+		cache:      make(map[cacheKey]*big.Rat),
+		failures:   make(map[cacheKey]failure),
+		logger:     logger.With("component", "prices"),
+	}
+}
+
+// Init creates the source of prices if valuation is enabled, file source
+// loads the file
+func (p *Provider) Init() error {
+	if len(p.currencies) == 0 {
+		return nil
+	}
+
+	switch p.config.Source {
+	case SourceFile:
+		source, err := loadFileSource(p.config.File)
+		if err != nil {
+			return fmt.Errorf("could not load prices file: %w", err)
+		}
+		p.source = source
+	case SourceHTTP:
+		p.source = newHTTPSource(&p.config.HTTP)
+	default:
+		return fmt.Errorf("%w '%s'", errUnknownSource, p.config.Source)
+	}
+
+	p.logger.Info("initialized prices",
+		"source", p.config.Source, "currencies", p.currencies)
+	return nil
+}
+
+func (p *Provider) Shutdown() error {
+	return nil
+}
+
+// Currencies returns lower case codes of currencies amounts are valuated in
+func (p *Provider) Currencies() []string {
+	if p == nil {
+		return nil
+	}
+
+	return p.currencies
+}
+
+// Price returns price of 1 ETH in the currency at the start of the period of
+// the time. Failure is returned again without requesting the source until
+// FailureTTL passes.
+func (p *Provider) Price(currency string, at time.Time) (*big.Rat, error) {
+	if p == nil || p.source == nil {
+		return nil, ErrNoPrice
+	}
+
+	from := at.UTC().Truncate(p.config.Resolution)
+	key := cacheKey{currency: currency, period: from.Unix()}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if price, ok := p.cache[key]; ok {
+		return price, nil
+	}
+	if failure, ok := p.failures[key]; ok {
+		if time.Now().Before(failure.expiresAt) {
+			return nil, failure.err
+		}
+		delete(p.failures, key)
+	}
+
+	price, err := p.source.price(currency, from, from.Add(p.config.Resolution))
+	if err != nil {
+		err = fmt.Errorf("could not get %s price at %s: %w",
+			currency, from.Format(time.RFC3339), err)
+		if p.config.FailureTTL > 0 {
+			p.failures[key] = failure{err: err, expiresAt: time.Now().Add(p.config.FailureTTL)}
+		}
+		return nil, err
+	}
+
+	p.logger.Debug("got price",
+		"currency", currency, "period", from, "price", price.FloatString(fiatDecimals))
+
+	p.cache[key] = price
+	return price, nil
+}
+
+// Valuate returns amount of wei in every currency at the time as decimal
+// strings, rounded to cents
+func (p *Provider) Valuate(wei *big.Int, at time.Time) (map[string]string, error) {
+	if len(p.Currencies()) == 0 {
+		return nil, nil
+	}
+
+	ether := new(big.Rat).SetFrac(wei, new(big.Int).Exp(big.NewInt(10),
+		big.NewInt(eth.EtherDecimals), nil))
+
+	amounts := make(map[string]string, len(p.currencies))
+	for _, currency := range p.currencies {
+		price, err := p.Price(currency, at)
+		if err != nil {
+			return nil, err
+		}
+
+		amounts[currency] = new(big.Rat).Mul(ether, price).FloatString(fiatDecimals)
+	}
+
+	return amounts, nil
+}
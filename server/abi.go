@@ -0,0 +1,85 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+
+	"eth-parser/abi"
+	"eth-parser/eth"
+)
+
+// maxABIBodySize limits uploaded ABIs, ABIs of large contracts take ~100KB
+const maxABIBodySize = 2 << 20
+
+type abiResponse struct {
+	ChainID uint64 `json:"chain_id"`
+	Address string `json:"address"`
+	// ABI is JSON ABI of the contract
+	ABI json.RawMessage `json:"abi"`
+}
+
+// storeABIHandler stores JSON ABI passed in the body for the contract, the ABI
+// is used to decode transactions and logs of the contract for all tenants
+func (h *Handler) storeABIHandler(w http.ResponseWriter, r *http.Request) {
+	chainID, err := queryChainID(r)
+	if err != nil {
+		h.writeError(w, r, http.StatusBadRequest, codeInvalidArgument, err.Error())
+		return
+	}
+	p, ok := h.requestChain(w, r, chainID)
+	if !ok {
+		return
+	}
+
+	address, err := eth.NormalizeAddress(r.URL.Query().Get("address"))
+	if err != nil {
+		h.writeError(w, r, http.StatusBadRequest, codeInvalidArgument, err.Error())
+		return
+	}
+
+	data, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxABIBodySize))
+	if err != nil {
+		h.writeError(w, r, http.StatusBadRequest, codeInvalidArgument, err.Error())
+		return
+	}
+
+	if err := p.StoreABI(address, data); err != nil {
+		if errors.Is(err, abi.ErrInvalidABI) {
+			h.writeError(w, r, http.StatusBadRequest, codeInvalidArgument, err.Error())
+			return
+		}
+
+		h.writeError(w, r, http.StatusInternalServerError, codeInternal, err.Error())
+		return
+	}
+
+	h.requestLogger(r).Info("stored ABI successfully", "chain_id", p.ChainID(), "address", address)
+
+	stored, _ := p.GetABI(address)
+	h.writeJSON(w, r, http.StatusOK, &abiResponse{
+		ChainID: p.ChainID(),
+		Address: address,
+		ABI:     stored,
+	})
+}
+
+func (h *Handler) getABIHandler(w http.ResponseWriter, r *http.Request) {
+	p, params, ok := h.parseRequest(w, r, true)
+	if !ok {
+		return
+	}
+
+	stored, ok := p.GetABI(params.Address)
+	if !ok {
+		h.writeError(w, r, http.StatusNotFound, codeNotFound, "ABI of the contract is not stored")
+		return
+	}
+
+	h.writeJSON(w, r, http.StatusOK, &abiResponse{
+		ChainID: p.ChainID(),
+		Address: params.Address,
+		ABI:     stored,
+	})
+}
@@ -0,0 +1,98 @@
+package server
+
+import (
+	"errors"
+	"math/big"
+	"net/http"
+
+	"eth-parser/eth"
+	"eth-parser/parser"
+)
+
+type balanceResponse struct {
+	ChainID  uint64          `json:"chain_id"`
+	Address  string          `json:"address"`
+	Balances []balanceRecord `json:"balances"`
+}
+
+// balanceRecord is a tracked balance with values as decimal strings in wei or
+// the smallest units of the token
+type balanceRecord struct {
+	// Token is the address of ERC-20 token, it is omitted for ETH
+	Token       string `json:"token,omitempty"`
+	Balance     string `json:"balance"`
+	BlockNumber int64  `json:"block_number"`
+	// ChainBalance and Drift are set once the balance is reconciled with the
+	// chain, drift is the chain balance minus the tracked one
+	ChainBalance          string `json:"chain_balance,omitempty"`
+	Drift                 string `json:"drift,omitempty"`
+	ReconciledBlockNumber int64  `json:"reconciled_block_number,omitempty"`
+}
+
+// balanceHandler returns running balances of the subscribed address. Balances
+// are tracked from the next block after the first request, so the list may be
+// empty right after subscribe.
+func (h *Handler) balanceHandler(w http.ResponseWriter, r *http.Request) {
+	p, params, ok := h.parseRequest(w, r, true)
+	if !ok {
+		return
+	}
+
+	address, err := eth.NormalizeAddress(params.Address)
+	if err != nil {
+		h.writeError(w, r, http.StatusBadRequest, codeInvalidArgument, err.Error())
+		return
+	}
+
+	token := r.FormValue("token")
+	if len(token) != 0 {
+		if token, err = eth.NormalizeAddress(token); err != nil {
+			h.writeError(w, r, http.StatusBadRequest, codeInvalidArgument, err.Error())
+			return
+		}
+	}
+
+	balances, err := p.GetBalances(requestTenant(r).ID, address, token)
+	switch {
+	case errors.Is(err, parser.ErrBalancesDisabled), errors.Is(err, parser.ErrInvalidToken):
+		h.writeError(w, r, http.StatusBadRequest, codeInvalidArgument, err.Error())
+		return
+	case errors.Is(err, parser.ErrNotSubscribed):
+		h.writeError(w, r, http.StatusNotFound, codeNotFound, err.Error())
+		return
+	case err != nil:
+		h.writeError(w, r, http.StatusInternalServerError, codeInternal, err.Error())
+		return
+	}
+
+	records := make([]balanceRecord, len(balances))
+	for i, balance := range balances {
+		records[i] = balanceRecord{
+			Token:                 balance.Token,
+			Balance:               balance.Value.String(),
+			BlockNumber:           balance.BlockNumber,
+			ChainBalance:          formatBig(balance.ChainValue),
+			Drift:                 formatBig(balance.Drift),
+			ReconciledBlockNumber: balance.ReconciledBlockNumber,
+		}
+	}
+
+	h.requestLogger(r).Debug("got balances",
+		"chain_id", p.ChainID(), "address", address, "balances", len(records))
+
+	h.writeJSON(w, r, http.StatusOK, &balanceResponse{
+		ChainID:  p.ChainID(),
+		Address:  address,
+		Balances: records,
+	})
+}
+
+// formatBig formats value as decimal string, nil value is formatted as empty
+// string
+func formatBig(value *big.Int) string {
+	if value == nil {
+		return ""
+	}
+
+	return value.String()
+}
@@ -0,0 +1,190 @@
+package server
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"eth-parser/eth"
+	"eth-parser/parser"
+)
+
+const (
+	maxBulkBodySize  = 4 << 20
+	maxBulkAddresses = 10000
+
+	// bulkFileField is the form field of the uploaded CSV file
+	bulkFileField = "file"
+)
+
+type bulkSubscribeResponse struct {
+	ChainID    uint64                `json:"chain_id"`
+	Subscribed int                   `json:"subscribed"`
+	Failed     int                   `json:"failed"`
+	Results    []bulkSubscribeResult `json:"results"`
+}
+
+type bulkSubscribeResult struct {
+	Address    string    `json:"address"`
+	Subscribed bool      `json:"subscribed"`
+	Error      *apiError `json:"error,omitempty"`
+}
+
+// bulkSubscribeHandler subscribes to the list of addresses passed as JSON
+// array of strings or as CSV with addresses in the first column, every
+// address is validated and reported separately
+func (h *Handler) bulkSubscribeHandler(w http.ResponseWriter, r *http.Request) {
+	chainID, err := queryChainID(r)
+	if err != nil {
+		h.writeError(w, r, http.StatusBadRequest, codeInvalidArgument, err.Error())
+		return
+	}
+	p, ok := h.requestChain(w, r, chainID)
+	if !ok {
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxBulkBodySize)
+	addresses, err := readBulkAddresses(r)
+	if err != nil {
+		h.writeError(w, r, http.StatusBadRequest, codeInvalidArgument, err.Error())
+		return
+	}
+	if len(addresses) == 0 {
+		h.writeError(w, r, http.StatusBadRequest, codeInvalidArgument, "no addresses passed")
+		return
+	}
+	if len(addresses) > maxBulkAddresses {
+		h.writeError(w, r, http.StatusBadRequest, codeInvalidArgument,
+			fmt.Sprintf("too many addresses, max number is %d", maxBulkAddresses))
+		return
+	}
+
+	response := &bulkSubscribeResponse{
+		ChainID: p.ChainID(),
+		Results: make([]bulkSubscribeResult, len(addresses)),
+	}
+
+	// valid keeps indexes of the results of valid addresses
+	valid := make([]int, 0, len(addresses))
+	normalized := make([]string, 0, len(addresses))
+	for i, address := range addresses {
+		response.Results[i].Address = address
+
+		address, err := eth.NormalizeAddress(address)
+		if err != nil {
+			response.Results[i].Error = &apiError{Code: codeInvalidArgument, Message: err.Error()}
+			continue
+		}
+
+		response.Results[i].Address = address
+		valid = append(valid, i)
+		normalized = append(normalized, address)
+	}
+
+	tenant := requestTenant(r)
+	errs, err := p.SubscribeBatch(tenant.ID, normalized, tenant.MaxSubscriptions)
+	if err != nil {
+		h.writeError(w, r, http.StatusInternalServerError, codeInternal, err.Error())
+		return
+	}
+
+	for i, err := range errs {
+		result := &response.Results[valid[i]]
+		switch {
+		case errors.Is(err, parser.ErrSubscriptionsLimitExceeded):
+			result.Error = &apiError{Code: codeLimitExceeded, Message: err.Error()}
+		case err != nil:
+			result.Error = &apiError{Code: codeInternal, Message: err.Error()}
+		default:
+			result.Subscribed = true
+		}
+	}
+
+	for _, result := range response.Results {
+		if result.Subscribed {
+			response.Subscribed++
+		} else {
+			response.Failed++
+		}
+	}
+
+	h.requestLogger(r).Info("subscribed in bulk",
+		"chain_id", p.ChainID(), "subscribed", response.Subscribed, "failed", response.Failed)
+
+	h.writeJSON(w, r, http.StatusOK, response)
+}
+
+// readBulkAddresses reads addresses from JSON array, CSV body or CSV file
+// uploaded with multipart form
+func readBulkAddresses(r *http.Request) ([]string, error) {
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+
+	switch mediaType {
+	case "application/json":
+		var addresses []string
+		if err := json.NewDecoder(r.Body).Decode(&addresses); err != nil {
+			return nil, fmt.Errorf("could not parse body, expected array of addresses: %w", err)
+		}
+		return addresses, nil
+	case "text/csv":
+		return readCSVAddresses(r.Body)
+	case "multipart/form-data":
+		file, _, err := r.FormFile(bulkFileField)
+		if err != nil {
+			return nil, fmt.Errorf("could not read file from '%s' field: %w", bulkFileField, err)
+		}
+		defer file.Close()
+
+		return readCSVAddresses(file)
+	default:
+		return nil, fmt.Errorf(
+			"unsupported content type '%s', expected application/json, text/csv "+
+				"or multipart/form-data", mediaType)
+	}
+}
+
+// readCSVAddresses reads addresses from the first column of CSV, the header
+// is skipped if present
+func readCSVAddresses(reader io.Reader) ([]string, error) {
+	csvReader := csv.NewReader(reader)
+	csvReader.FieldsPerRecord = -1
+	csvReader.TrimLeadingSpace = true
+
+	var addresses []string
+	for line := 1; ; line++ {
+		record, err := csvReader.Read()
+		if errors.Is(err, io.EOF) {
+			return addresses, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("could not parse CSV: %w", err)
+		}
+
+		if line == 1 && strings.EqualFold(strings.TrimSpace(record[0]), "address") {
+			continue
+		}
+		addresses = append(addresses, record[0])
+	}
+}
+
+// queryChainID parses optional chain_id param passed in query
+func queryChainID(r *http.Request) (*uint64, error) {
+	rawChainID := r.URL.Query().Get("chain_id")
+	if len(rawChainID) == 0 {
+		return nil, nil
+	}
+
+	chainID, err := strconv.ParseUint(rawChainID, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid chain_id '%s'", rawChainID)
+	}
+
+	return &chainID, nil
+}
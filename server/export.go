@@ -0,0 +1,179 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"eth-parser/eth"
+	"eth-parser/export"
+	"eth-parser/parser"
+)
+
+// exportRequest is the validated request of export
+type exportRequest struct {
+	addresses   []string
+	format      string
+	exportRange parser.ExportRange
+}
+
+// exportHandler streams mined transactions of the addresses in the requested
+// format. Transactions are written as they are read from the storage, so the
+// errors after the start of the response abort it instead of writing JSON.
+func (h *Handler) exportHandler(w http.ResponseWriter, r *http.Request) {
+	chainID, err := queryChainID(r)
+	if err != nil {
+		h.writeError(w, r, http.StatusBadRequest, codeInvalidArgument, err.Error())
+		return
+	}
+	p, ok := h.requestChain(w, r, chainID)
+	if !ok {
+		return
+	}
+
+	request, err := readExportRequest(r.URL.Query())
+	if err != nil {
+		h.writeError(w, r, http.StatusBadRequest, codeInvalidArgument, err.Error())
+		return
+	}
+
+	tenant := requestTenant(r)
+	if len(request.addresses) == 0 {
+		request.addresses = p.GetSubscriptions(tenant.ID)
+	}
+
+	w.Header().Set("Content-Type", export.ContentType(request.format))
+	w.Header().Set("Content-Disposition", fmt.Sprintf(
+		`attachment; filename="transactions-%d.%s"`, p.ChainID(), request.format))
+
+	logger := h.requestLogger(r).With("chain_id", p.ChainID(), "format", request.format)
+
+	writer, err := export.NewWriter(w, request.format, p.FiatCurrencies())
+	if err != nil {
+		logger.Error("could not start export", "error", err)
+		panic(http.ErrAbortHandler)
+	}
+
+	rows := 0
+	err = p.ExportTransactions(tenant.ID, request.addresses, request.exportRange,
+		func(address string, transaction *eth.Transaction) error {
+			row, err := export.NewRow(address, transaction)
+			if err != nil {
+				return fmt.Errorf("transaction %s: %w", transaction.Hash, err)
+			}
+
+			rows++
+			return writer.Write(row)
+		})
+	if err == nil {
+		err = writer.Close()
+	}
+	if err != nil {
+		logger.Error("could not export transactions", "rows", rows, "error", err)
+		panic(http.ErrAbortHandler)
+	}
+
+	logger.Info("exported transactions", "addresses", len(request.addresses), "rows", rows)
+}
+
+// readExportRequest reads and validates params of export request
+func readExportRequest(query url.Values) (*exportRequest, error) {
+	request := &exportRequest{format: query.Get("format")}
+	if len(request.format) == 0 {
+		request.format = export.FormatJSONL
+	}
+	if !isExportFormat(request.format) {
+		return nil, fmt.Errorf("unknown format '%s', must be one of %v",
+			request.format, export.Formats)
+	}
+
+	for _, list := range query["addresses"] {
+		for _, address := range strings.Split(list, ",") {
+			if address = strings.TrimSpace(address); len(address) == 0 {
+				continue
+			}
+
+			address, err := eth.NormalizeAddress(address)
+			if err != nil {
+				return nil, err
+			}
+			request.addresses = append(request.addresses, address)
+		}
+	}
+	if len(request.addresses) > maxBulkAddresses {
+		return nil, fmt.Errorf("too many addresses, max number is %d", maxBulkAddresses)
+	}
+
+	exportRange := &request.exportRange
+	var err error
+	if exportRange.FromBlock, err = queryBlock(query, "from_block"); err != nil {
+		return nil, err
+	}
+	if exportRange.ToBlock, err = queryBlock(query, "to_block"); err != nil {
+		return nil, err
+	}
+	if exportRange.ToBlock != 0 && exportRange.FromBlock > exportRange.ToBlock {
+		return nil, fmt.Errorf("from_block %d is greater than to_block %d",
+			exportRange.FromBlock, exportRange.ToBlock)
+	}
+
+	if exportRange.FromTime, err = queryTime(query, "from_time"); err != nil {
+		return nil, err
+	}
+	if exportRange.ToTime, err = queryTime(query, "to_time"); err != nil {
+		return nil, err
+	}
+	if !exportRange.ToTime.IsZero() && !exportRange.FromTime.Before(exportRange.ToTime) {
+		return nil, fmt.Errorf("from_time must be before to_time")
+	}
+
+	return request, nil
+}
+
+func isExportFormat(format string) bool {
+	for _, known := range export.Formats {
+		if format == known {
+			return true
+		}
+	}
+
+	return false
+}
+
+// queryBlock reads block number param, missing param is zero
+func queryBlock(query url.Values, name string) (uint64, error) {
+	raw := query.Get(name)
+	if len(raw) == 0 {
+		return 0, nil
+	}
+
+	number, err := strconv.ParseUint(raw, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s '%s'", name, raw)
+	}
+
+	return number, nil
+}
+
+// queryTime reads time param as RFC 3339 or as date in UTC, missing param is
+// zero time
+func queryTime(query url.Values, name string) (time.Time, error) {
+	raw := query.Get(name)
+	if len(raw) == 0 {
+		return time.Time{}, nil
+	}
+
+	if value, err := time.Parse(time.RFC3339, raw); err == nil {
+		return value, nil
+	}
+	value, err := time.Parse(time.DateOnly, raw)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid %s '%s', must be RFC 3339 time or date %s",
+			name, raw, time.DateOnly)
+	}
+
+	return value, nil
+}
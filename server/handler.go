@@ -2,69 +2,292 @@ package server
 
 import (
 	"encoding/json"
-	"io"
-	"log"
+	"errors"
+	"fmt"
+	"log/slog"
+	"mime"
 	"net/http"
 	"strconv"
 
+	"eth-parser/auth"
+	"eth-parser/eth"
 	"eth-parser/parser"
 )
 
+const maxRequestBodySize = 1 << 20
+
+var (
+	errChainIDRequired = fmt.Errorf("chain_id is required")
+	errUnknownChain    = fmt.Errorf("unknown chain")
+)
+
 type Handler struct {
-	parser *parser.Parser
+	chains        parser.Chains
+	healthConfig  *HealthConfig
+	authenticator *auth.Authenticator
+
+	// openAPI is the spec of the routes served by the handler
+	openAPI *openAPISpec
+
+	logger *slog.Logger
 }
 
-func NewHandler(parser *parser.Parser) *Handler {
+func NewHandler(
+	chains parser.Chains,
+	healthConfig *HealthConfig,
+	authenticator *auth.Authenticator,
+	logger *slog.Logger,
+) *Handler {
 	return &Handler{
-		parser: parser,
+		chains:        chains,
+		healthConfig:  healthConfig,
+		authenticator: authenticator,
+		openAPI:       newOpenAPISpec(routes),
+		logger:        logger.With("component", "http_handler"),
 	}
 }
 
-func (h *Handler) currentBlockHandler(w http.ResponseWriter, r *http.Request) {
-	_, err := io.WriteString(w, strconv.FormatInt(int64(h.parser.GetCurrentBlock()), 10))
+// requestParams are params of API requests, they are passed either in query
+// or form, or in JSON body
+type requestParams struct {
+	ChainID  *uint64 `json:"chain_id,omitempty"`
+	Address  string  `json:"address,omitempty"`
+	FilterID string  `json:"filter_id,omitempty"`
+}
+
+type currentBlockResponse struct {
+	ChainID     uint64 `json:"chain_id"`
+	BlockNumber int    `json:"block_number"`
+}
+
+type subscriptionResponse struct {
+	ChainID    uint64 `json:"chain_id"`
+	Address    string `json:"address"`
+	Subscribed bool   `json:"subscribed"`
+}
+
+type subscriptionsResponse struct {
+	ChainID   uint64   `json:"chain_id"`
+	Addresses []string `json:"addresses"`
+}
+
+type transactionsResponse struct {
+	ChainID      uint64            `json:"chain_id"`
+	Address      string            `json:"address"`
+	Transactions []eth.Transaction `json:"transactions"`
+}
+
+// readParams reads params from JSON body if request has one or from query
+// and form otherwise
+func readParams(w http.ResponseWriter, r *http.Request) (*requestParams, error) {
+	params := &requestParams{}
+
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType == "application/json" {
+		decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxRequestBodySize))
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(params); err != nil {
+			return nil, fmt.Errorf("could not parse body: %w", err)
+		}
+
+		return params, nil
+	}
+
+	if rawChainID := r.FormValue("chain_id"); len(rawChainID) != 0 {
+		chainID, err := strconv.ParseUint(rawChainID, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid chain_id '%s'", rawChainID)
+		}
+		params.ChainID = &chainID
+	}
+	params.Address = r.FormValue("address")
+	params.FilterID = r.FormValue("filter_id")
+
+	return params, nil
+}
+
+// resolveChain returns parser of the chain with given ID, the ID may be nil
+// if there is only one chain
+func (h *Handler) resolveChain(chainID *uint64) (*parser.Parser, error) {
+	if chainID == nil {
+		if len(h.chains) == 1 {
+			for _, p := range h.chains {
+				return p, nil
+			}
+		}
+
+		return nil, errChainIDRequired
+	}
+
+	p, ok := h.chains[*chainID]
+	if !ok {
+		return nil, fmt.Errorf("%w: %d", errUnknownChain, *chainID)
+	}
+
+	return p, nil
+}
+
+// parseRequest reads params of the request and resolves the requested chain.
+// Writes error and returns false if the request is invalid.
+func (h *Handler) parseRequest(
+	w http.ResponseWriter,
+	r *http.Request,
+	addressRequired bool,
+) (*parser.Parser, *requestParams, bool) {
+	params, err := readParams(w, r)
 	if err != nil {
-		log.Printf("http_handler: could not write current block\n")
+		h.writeError(w, r, http.StatusBadRequest, codeInvalidArgument, err.Error())
+		return nil, nil, false
+	}
+	if addressRequired && len(params.Address) == 0 {
+		h.writeError(w, r, http.StatusBadRequest, codeInvalidArgument, "address is required")
+		return nil, nil, false
+	}
+
+	p, ok := h.requestChain(w, r, params.ChainID)
+	if !ok {
+		return nil, nil, false
+	}
+
+	return p, params, true
+}
+
+// requestChain resolves the requested chain. Writes error and returns false
+// if the chain could not be resolved.
+func (h *Handler) requestChain(
+	w http.ResponseWriter,
+	r *http.Request,
+	chainID *uint64,
+) (*parser.Parser, bool) {
+	p, err := h.resolveChain(chainID)
+	switch {
+	case errors.Is(err, errUnknownChain):
+		h.writeError(w, r, http.StatusNotFound, codeNotFound, err.Error())
+		return nil, false
+	case err != nil:
+		h.writeError(w, r, http.StatusBadRequest, codeInvalidArgument, err.Error())
+		return nil, false
 	}
+
+	return p, true
+}
+
+func (h *Handler) currentBlockHandler(w http.ResponseWriter, r *http.Request) {
+	p, _, ok := h.parseRequest(w, r, false)
+	if !ok {
+		return
+	}
+
+	h.writeJSON(w, r, http.StatusOK, &currentBlockResponse{
+		ChainID:     p.ChainID(),
+		BlockNumber: p.GetCurrentBlock(),
+	})
 }
 
 func (h *Handler) subscribeHandler(w http.ResponseWriter, r *http.Request) {
-	address := r.FormValue("address")
-	if len(address) == 0 {
-		w.WriteHeader(http.StatusBadRequest)
+	p, params, ok := h.parseRequest(w, r, true)
+	if !ok {
 		return
 	}
 
-	if ok := h.parser.Subscribe(address); !ok {
-		w.WriteHeader(http.StatusInternalServerError)
+	// the address is echoed as it is stored
+	address, err := eth.NormalizeAddress(params.Address)
+	if err != nil {
+		h.writeError(w, r, http.StatusBadRequest, codeInvalidArgument, err.Error())
 		return
 	}
 
-	log.Printf("http_handler: subscribed for '%s' successfully\n", address)
+	tenant := requestTenant(r)
+	if err := p.Subscribe(tenant.ID, address, tenant.MaxSubscriptions); err != nil {
+		switch {
+		case errors.Is(err, parser.ErrSubscriptionsLimitExceeded):
+			h.writeError(w, r, http.StatusForbidden, codeLimitExceeded, err.Error())
+		default:
+			h.writeError(w, r, http.StatusInternalServerError, codeInternal, err.Error())
+		}
+		return
+	}
+
+	h.requestLogger(r).Info("subscribed successfully",
+		"chain_id", p.ChainID(), "address", address)
+
+	h.writeJSON(w, r, http.StatusOK, &subscriptionResponse{
+		ChainID:    p.ChainID(),
+		Address:    address,
+		Subscribed: true,
+	})
 }
 
-func (h *Handler) transactionsHandler(w http.ResponseWriter, r *http.Request) {
-	address := r.FormValue("address")
-	if len(address) == 0 {
-		w.WriteHeader(http.StatusBadRequest)
+func (h *Handler) unsubscribeHandler(w http.ResponseWriter, r *http.Request) {
+	p, params, ok := h.parseRequest(w, r, true)
+	if !ok {
+		return
+	}
+
+	address, err := eth.NormalizeAddress(params.Address)
+	if err != nil {
+		h.writeError(w, r, http.StatusBadRequest, codeInvalidArgument, err.Error())
 		return
 	}
 
-	transactions := h.parser.GetTransactions(address)
-	if len(transactions) == 0 {
-		w.WriteHeader(http.StatusNoContent)
+	if err := p.Unsubscribe(requestTenant(r).ID, address); err != nil {
+		h.writeError(w, r, http.StatusInternalServerError, codeInternal, err.Error())
 		return
 	}
 
-	log.Printf("http_handler: got %d transactions for '%s'\n", len(transactions), address)
+	h.requestLogger(r).Info("unsubscribed successfully",
+		"chain_id", p.ChainID(), "address", address)
 
-	data, err := json.Marshal(transactions)
-	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
+	h.writeJSON(w, r, http.StatusOK, &subscriptionResponse{
+		ChainID:    p.ChainID(),
+		Address:    address,
+		Subscribed: false,
+	})
+}
+
+func (h *Handler) subscriptionsHandler(w http.ResponseWriter, r *http.Request) {
+	p, _, ok := h.parseRequest(w, r, false)
+	if !ok {
+		return
+	}
+
+	addresses := p.GetSubscriptions(requestTenant(r).ID)
+	if addresses == nil {
+		addresses = []string{}
+	}
+
+	h.writeJSON(w, r, http.StatusOK, &subscriptionsResponse{
+		ChainID:   p.ChainID(),
+		Addresses: addresses,
+	})
+}
+
+func (h *Handler) transactionsHandler(w http.ResponseWriter, r *http.Request) {
+	p, params, ok := h.parseRequest(w, r, true)
+	if !ok {
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	if _, err := w.Write(data); err != nil {
-		log.Printf("http_handler: could not write transactions for '%s'\n", address)
+	transactions, err := p.GetTransactions(requestTenant(r).ID, params.Address)
+	if err != nil {
+		if errors.Is(err, eth.ErrInvalidAddress) {
+			h.writeError(w, r, http.StatusBadRequest, codeInvalidArgument, err.Error())
+			return
+		}
+
+		h.writeError(w, r, http.StatusInternalServerError, codeInternal, err.Error())
+		return
+	}
+	if transactions == nil {
+		transactions = []eth.Transaction{}
 	}
+
+	h.requestLogger(r).Debug("got transactions",
+		"chain_id", p.ChainID(), "address", params.Address, "transactions", len(transactions))
+
+	h.writeJSON(w, r, http.StatusOK, &transactionsResponse{
+		ChainID:      p.ChainID(),
+		Address:      params.Address,
+		Transactions: transactions,
+	})
 }
@@ -0,0 +1,556 @@
+package server
+
+import (
+	"bytes"
+	"encoding/csv"
+	"math/big"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"eth-parser/auth"
+	"eth-parser/eth"
+	"eth-parser/export"
+)
+
+// checkError checks status and envelope of error response, message is not
+// checked if empty
+func checkError(t *testing.T, w *httptest.ResponseRecorder, status int, code, message string) {
+	t.Helper()
+
+	var response errorResponse
+	decodeResponse(t, w, status, &response)
+	if response.Error.Code != code {
+		t.Errorf("got code %s, want %s", response.Error.Code, code)
+	}
+	if len(message) != 0 && response.Error.Message != message {
+		t.Errorf("messages are not equal:\nhave: %s\nwant: %s", response.Error.Message, message)
+	}
+}
+
+func TestSubscribeAndGetTransactions(t *testing.T) {
+	s := newTestServer(t, nil)
+	s.start(t)
+
+	// params are accepted both in JSON body and in form
+	var subscription subscriptionResponse
+	decodeResponse(t, s.do(t, http.MethodPost, "/v1/subscribe",
+		`{"address":"`+testAddress1+`"}`, nil), http.StatusOK, &subscription)
+	expected := subscriptionResponse{ChainID: 1, Address: testAddress1, Subscribed: true}
+	if subscription != expected {
+		t.Errorf("responses are not equal:\nhave: %+v\nwant: %+v", subscription, expected)
+	}
+
+	// the address is echoed in lower case as it is stored
+	decodeResponse(t, s.do(t, http.MethodPost, "/v1/subscribe",
+		"chain_id=1&address=0x"+strings.ToUpper(testAddress2[2:]),
+		http.Header{"Content-Type": {"application/x-www-form-urlencoded"}}),
+		http.StatusOK, &subscription)
+	expected = subscriptionResponse{ChainID: 1, Address: testAddress2, Subscribed: true}
+	if subscription != expected {
+		t.Errorf("responses are not equal:\nhave: %+v\nwant: %+v", subscription, expected)
+	}
+
+	var subscriptions subscriptionsResponse
+	decodeResponse(t, s.do(t, http.MethodGet, "/v1/subscriptions", "", nil),
+		http.StatusOK, &subscriptions)
+	expectedSubscriptions := subscriptionsResponse{
+		ChainID: 1, Addresses: []string{testAddress1, testAddress2},
+	}
+	if !reflect.DeepEqual(subscriptions, expectedSubscriptions) {
+		t.Errorf("subscriptions are not equal:\nhave: %+v\nwant: %+v",
+			subscriptions, expectedSubscriptions)
+	}
+
+	block := s.node.Mine(eth.Transaction{From: testAddress1, To: testAddress2, Value: "0x64"})
+	waitFor(t, "transaction", func() bool {
+		transactions, _ := s.parser.GetTransactions("", testAddress1)
+		return len(transactions) == 1
+	})
+
+	var transactions transactionsResponse
+	decodeResponse(t, s.do(t, http.MethodGet, "/v1/transactions?address="+testAddress1, "", nil),
+		http.StatusOK, &transactions)
+	if len(transactions.Transactions) != 1 ||
+		transactions.Transactions[0].Hash != block.Transactions[0].Hash ||
+		transactions.Transactions[0].Status != eth.StatusMined {
+		t.Errorf("got transactions %+v, want mined %s",
+			transactions.Transactions, block.Transactions[0].Hash)
+	}
+
+	var currentBlock currentBlockResponse
+	decodeResponse(t, s.do(t, http.MethodGet, "/v1/current_block", "", nil),
+		http.StatusOK, &currentBlock)
+	if expected := (currentBlockResponse{ChainID: 1, BlockNumber: 1}); currentBlock != expected {
+		t.Errorf("responses are not equal:\nhave: %+v\nwant: %+v", currentBlock, expected)
+	}
+
+	decodeResponse(t, s.do(t, http.MethodPost, "/v1/unsubscribe",
+		`{"chain_id":1,"address":"0x`+strings.ToUpper(testAddress1[2:])+`"}`, nil),
+		http.StatusOK, &subscription)
+	expected = subscriptionResponse{ChainID: 1, Address: testAddress1}
+	if subscription != expected {
+		t.Errorf("responses are not equal:\nhave: %+v\nwant: %+v", subscription, expected)
+	}
+
+	decodeResponse(t, s.do(t, http.MethodGet, "/v1/subscriptions", "", nil),
+		http.StatusOK, &subscriptions)
+	if expected := []string{testAddress2}; !reflect.DeepEqual(subscriptions.Addresses, expected) {
+		t.Errorf("subscriptions are not equal:\nhave: %v\nwant: %v",
+			subscriptions.Addresses, expected)
+	}
+}
+
+func TestErrors(t *testing.T) {
+	s := newTestServer(t, nil)
+
+	testCases := []struct {
+		name    string
+		method  string
+		target  string
+		body    string
+		header  http.Header
+		status  int
+		code    string
+		message string
+		// allow is the expected Allow header
+		allow string
+	}{
+		{
+			name:   "no address",
+			method: http.MethodPost, target: "/v1/subscribe", body: `{}`,
+			status: http.StatusBadRequest, code: codeInvalidArgument,
+			message: "address is required",
+		},
+		{
+			name:   "invalid address",
+			method: http.MethodPost, target: "/v1/subscribe", body: `{"address":"0x01"}`,
+			status: http.StatusBadRequest, code: codeInvalidArgument,
+			message: "invalid address '0x01': must be 20 hex encoded bytes",
+		},
+		{
+			name:   "unknown field",
+			method: http.MethodPost, target: "/v1/subscribe", body: `{"addr":"0x01"}`,
+			status: http.StatusBadRequest, code: codeInvalidArgument,
+			message: `could not parse body: json: unknown field "addr"`,
+		},
+		{
+			name:   "invalid chain id",
+			method: http.MethodGet, target: "/v1/current_block?chain_id=one",
+			status: http.StatusBadRequest, code: codeInvalidArgument,
+			message: "invalid chain_id 'one'",
+		},
+		{
+			name:   "unknown chain",
+			method: http.MethodGet, target: "/v1/subscriptions?chain_id=5",
+			status: http.StatusNotFound, code: codeNotFound,
+			message: "unknown chain: 5",
+		},
+		{
+			name:   "unknown path",
+			method: http.MethodGet, target: "/v1/blocks",
+			status: http.StatusNotFound, code: codeNotFound,
+			message: "path /v1/blocks not found",
+		},
+		{
+			name:   "method not allowed",
+			method: http.MethodGet, target: "/v1/subscribe",
+			status: http.StatusMethodNotAllowed, code: codeMethodNotAllowed,
+			message: "method GET is not allowed, allowed: POST",
+			allow:   "POST",
+		},
+		{
+			name:   "method not allowed of several",
+			method: http.MethodDelete, target: "/v1/abi",
+			status: http.StatusMethodNotAllowed, code: codeMethodNotAllowed,
+			message: "method DELETE is not allowed, allowed: GET, PUT",
+			allow:   "GET, PUT",
+		},
+		{
+			name:   "no filter id",
+			method: http.MethodGet, target: "/v1/logs",
+			status: http.StatusBadRequest, code: codeInvalidArgument,
+			message: "filter_id is required",
+		},
+		{
+			name:   "invalid log filter",
+			method: http.MethodPost, target: "/v1/logs/subscribe", body: `{"address":"0x01"}`,
+			status: http.StatusBadRequest, code: codeInvalidArgument,
+		},
+		{
+			name:   "no abi",
+			method: http.MethodGet, target: "/v1/abi?address=" + testAddress1,
+			status: http.StatusNotFound, code: codeNotFound,
+			message: "ABI of the contract is not stored",
+		},
+		{
+			name:   "invalid abi",
+			method: http.MethodPut, target: "/v1/abi?address=" + testAddress1, body: `{}`,
+			status: http.StatusBadRequest, code: codeInvalidArgument,
+		},
+		{
+			name:   "balance of not subscribed address",
+			method: http.MethodGet, target: "/v1/balance?address=" + testAddress1,
+			status: http.StatusNotFound, code: codeNotFound,
+			message: "address is not subscribed",
+		},
+		{
+			name:   "balance of invalid token",
+			method: http.MethodGet, target: "/v1/balance?address=" + testAddress1 + "&token=0x01",
+			status: http.StatusBadRequest, code: codeInvalidArgument,
+			message: "invalid address '0x01': must be 20 hex encoded bytes",
+		},
+		{
+			name:   "bulk without addresses",
+			method: http.MethodPost, target: "/v1/subscribe/bulk", body: `[]`,
+			status: http.StatusBadRequest, code: codeInvalidArgument,
+			message: "no addresses passed",
+		},
+		{
+			name:   "bulk of unsupported content type",
+			method: http.MethodPost, target: "/v1/subscribe/bulk", body: testAddress1,
+			header: http.Header{"Content-Type": {"text/plain"}},
+			status: http.StatusBadRequest, code: codeInvalidArgument,
+			message: "unsupported content type 'text/plain', expected application/json, " +
+				"text/csv or multipart/form-data",
+		},
+		{
+			name:   "export of unknown format",
+			method: http.MethodGet, target: "/v1/export?format=xml",
+			status: http.StatusBadRequest, code: codeInvalidArgument,
+			message: "unknown format 'xml', must be one of [csv jsonl parquet]",
+		},
+		{
+			name:   "export of invalid range",
+			method: http.MethodGet, target: "/v1/export?from_block=5&to_block=3",
+			status: http.StatusBadRequest, code: codeInvalidArgument,
+			message: "from_block 5 is greater than to_block 3",
+		},
+		{
+			name:   "export of invalid address",
+			method: http.MethodGet, target: "/v1/export?addresses=" + testAddress1 + ",0x01",
+			status: http.StatusBadRequest, code: codeInvalidArgument,
+			message: "invalid address '0x01': must be 20 hex encoded bytes",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			w := s.do(t, tc.method, tc.target, tc.body, tc.header)
+			checkError(t, w, tc.status, tc.code, tc.message)
+			if allow := w.Header().Get("Allow"); allow != tc.allow {
+				t.Errorf("got Allow header '%s', want '%s'", allow, tc.allow)
+			}
+		})
+	}
+}
+
+func TestAuthentication(t *testing.T) {
+	s := newTestServer(t, &auth.Config{
+		APIKeys: []auth.APIKeyConfig{
+			{Key: "key-a", Tenant: "team-a", MaxSubscriptions: 1},
+			{Key: "key-b", Tenant: "team-b"},
+		},
+	})
+	keyA := http.Header{auth.APIKeyHeader: {"key-a"}}
+	keyB := http.Header{auth.APIKeyHeader: {"key-b"}}
+
+	checkError(t, s.do(t, http.MethodGet, "/v1/subscriptions", "", nil),
+		http.StatusUnauthorized, codeUnauthenticated, "")
+	checkError(t, s.do(t, http.MethodGet, "/v1/subscriptions", "",
+		http.Header{auth.APIKeyHeader: {"key-c"}}), http.StatusUnauthorized, codeUnauthenticated, "")
+
+	// spec and probes are public
+	decodeResponse(t, s.do(t, http.MethodGet, "/v1/openapi.json", "", nil), http.StatusOK, nil)
+	decodeResponse(t, s.do(t, http.MethodGet, "/healthz", "", nil), http.StatusOK, nil)
+
+	decodeResponse(t, s.do(t, http.MethodPost, "/v1/subscribe",
+		`{"address":"`+testAddress1+`"}`, keyA), http.StatusOK, nil)
+	checkError(t, s.do(t, http.MethodPost, "/v1/subscribe",
+		`{"address":"`+testAddress2+`"}`, keyA), http.StatusForbidden, codeLimitExceeded, "")
+
+	// tenants see only their own subscriptions
+	decodeResponse(t, s.do(t, http.MethodPost, "/v1/subscribe",
+		`{"address":"`+testAddress2+`"}`, keyB), http.StatusOK, nil)
+	for _, tc := range []struct {
+		header   http.Header
+		expected []string
+	}{
+		{header: keyA, expected: []string{testAddress1}},
+		{header: keyB, expected: []string{testAddress2}},
+	} {
+		var subscriptions subscriptionsResponse
+		decodeResponse(t, s.do(t, http.MethodGet, "/v1/subscriptions", "", tc.header),
+			http.StatusOK, &subscriptions)
+		if !reflect.DeepEqual(subscriptions.Addresses, tc.expected) {
+			t.Errorf("subscriptions of %s are not equal:\nhave: %v\nwant: %v",
+				tc.header.Get(auth.APIKeyHeader), subscriptions.Addresses, tc.expected)
+		}
+	}
+}
+
+func TestBulkSubscribe(t *testing.T) {
+	upperAddress := strings.ToUpper(testAddress2[2:])
+
+	var multipartBody bytes.Buffer
+	writer := multipart.NewWriter(&multipartBody)
+	file, err := writer.CreateFormFile(bulkFileField, "addresses.csv")
+	if err != nil {
+		t.Fatalf("could not create form file: %v", err)
+	}
+	file.Write([]byte("address\n" + testAddress1 + "\n0x" + upperAddress + "\n0x01\n"))
+	writer.Close()
+
+	testCases := []struct {
+		name        string
+		contentType string
+		body        string
+	}{
+		{
+			name:        "json",
+			contentType: "application/json",
+			body:        `["` + testAddress1 + `","0x` + upperAddress + `","0x01"]`,
+		},
+		{
+			name:        "csv",
+			contentType: "text/csv",
+			body:        "address,label\n" + testAddress1 + ",a\n0x" + upperAddress + ",b\n0x01,c\n",
+		},
+		{
+			name:        "multipart",
+			contentType: writer.FormDataContentType(),
+			body:        multipartBody.String(),
+		},
+	}
+
+	// invalid addresses are reported along with subscribed ones
+	expected := bulkSubscribeResponse{
+		ChainID:    1,
+		Subscribed: 2,
+		Failed:     1,
+		Results: []bulkSubscribeResult{
+			{Address: testAddress1, Subscribed: true},
+			{Address: testAddress2, Subscribed: true},
+			{
+				Address: "0x01",
+				Error: &apiError{
+					Code:    codeInvalidArgument,
+					Message: "invalid address '0x01': must be 20 hex encoded bytes",
+				},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			s := newTestServer(t, nil)
+
+			var response bulkSubscribeResponse
+			decodeResponse(t, s.do(t, http.MethodPost, "/v1/subscribe/bulk", tc.body,
+				http.Header{"Content-Type": {tc.contentType}}), http.StatusOK, &response)
+			if !reflect.DeepEqual(response, expected) {
+				t.Errorf("responses are not equal:\nhave: %+v\nwant: %+v", response, expected)
+			}
+
+			subscriptions := s.parser.GetSubscriptions("")
+			if expected := []string{testAddress1, testAddress2}; !reflect.DeepEqual(
+				subscriptions, expected) {
+				t.Errorf("subscriptions are not equal:\nhave: %v\nwant: %v",
+					subscriptions, expected)
+			}
+		})
+	}
+}
+
+func TestBulkSubscribeLimit(t *testing.T) {
+	s := newTestServer(t, &auth.Config{
+		APIKeys: []auth.APIKeyConfig{{Key: "key-a", Tenant: "team-a", MaxSubscriptions: 1}},
+	})
+
+	var response bulkSubscribeResponse
+	decodeResponse(t, s.do(t, http.MethodPost, "/v1/subscribe/bulk",
+		`["`+testAddress1+`","`+testAddress2+`"]`, http.Header{auth.APIKeyHeader: {"key-a"}}),
+		http.StatusOK, &response)
+	if response.Subscribed != 1 || response.Failed != 1 || response.Results[1].Error == nil ||
+		response.Results[1].Error.Code != codeLimitExceeded {
+		t.Errorf("got response %+v, want the second address over the limit", response)
+	}
+}
+
+func TestLogs(t *testing.T) {
+	const (
+		contract = "0x00000000000000000000000000000000000000c1"
+		topic    = "0xddf252ad1be2c89b69c2b068fc378daa952ba7f163c4a11628f55a4df523b3ef"
+	)
+
+	s := newTestServer(t, nil)
+	s.start(t)
+
+	var subscription logFilterResponse
+	decodeResponse(t, s.do(t, http.MethodPost, "/v1/logs/subscribe",
+		`{"address":"`+strings.ToUpper(contract)+`","topics":[["`+topic+`"]]}`, nil),
+		http.StatusOK, &subscription)
+	filter := subscription.Filter
+	if !subscription.Subscribed || len(filter.ID) == 0 || filter.Address != contract ||
+		!reflect.DeepEqual(filter.Topics, [][]string{{topic}}) {
+		t.Fatalf("got response %+v, want normalized filter with ID", subscription)
+	}
+
+	var filters logFiltersResponse
+	decodeResponse(t, s.do(t, http.MethodGet, "/v1/logs/filters", "", nil),
+		http.StatusOK, &filters)
+	if expected := []eth.LogFilter{filter}; !reflect.DeepEqual(filters.Filters, expected) {
+		t.Errorf("filters are not equal:\nhave: %+v\nwant: %+v", filters.Filters, expected)
+	}
+
+	// logs are added before the block is polled
+	s.node.SetMissing(1, true)
+	block := s.node.Mine(eth.Transaction{From: testAddress1, To: contract})
+	hash := block.Transactions[0].Hash
+	s.node.AddLog(eth.Log{Address: contract, Topics: []string{topic},
+		BlockHash: block.Hash, TransactionHash: hash})
+	s.node.AddLog(eth.Log{Address: contract, Topics: []string{"0x01"},
+		BlockHash: block.Hash, TransactionHash: hash})
+	s.node.SetMissing(1, false)
+
+	waitFor(t, "logs", func() bool {
+		return len(s.parser.GetLogs("", filter.ID)) != 0
+	})
+
+	var logs logsResponse
+	decodeResponse(t, s.do(t, http.MethodGet, "/v1/logs?filter_id="+filter.ID, "", nil),
+		http.StatusOK, &logs)
+	if len(logs.Logs) != 1 || logs.Logs[0].TransactionHash != hash ||
+		logs.Logs[0].Transaction == nil || logs.Logs[0].Transaction.Hash != hash {
+		t.Errorf("got logs %+v, want a log of transaction %s", logs.Logs, hash)
+	}
+
+	decodeResponse(t, s.do(t, http.MethodPost, "/v1/logs/unsubscribe",
+		`{"filter_id":"`+filter.ID+`"}`, nil), http.StatusOK, &subscription)
+	if subscription.Subscribed || subscription.Filter.ID != filter.ID {
+		t.Errorf("got response %+v, want unsubscribed filter %s", subscription, filter.ID)
+	}
+
+	decodeResponse(t, s.do(t, http.MethodGet, "/v1/logs/filters", "", nil),
+		http.StatusOK, &filters)
+	if len(filters.Filters) != 0 {
+		t.Errorf("got filters %+v after unsubscribe", filters.Filters)
+	}
+}
+
+func TestABI(t *testing.T) {
+	const contractABI = `[{"type":"function","name":"transfer","inputs":[` +
+		`{"name":"to","type":"address"},{"name":"value","type":"uint256"}]}]`
+
+	s := newTestServer(t, nil)
+
+	// ABI is stored as passed under normalized address
+	var response abiResponse
+	decodeResponse(t, s.do(t, http.MethodPut, "/v1/abi?address=0x"+strings.ToUpper(testAddress1[2:]),
+		contractABI, nil), http.StatusOK, &response)
+	if response.Address != testAddress1 || string(response.ABI) != contractABI {
+		t.Errorf("got response %+v, want ABI of %s", response, testAddress1)
+	}
+
+	decodeResponse(t, s.do(t, http.MethodGet, "/v1/abi?address="+testAddress1, "", nil),
+		http.StatusOK, &response)
+	if response.ChainID != 1 || response.Address != testAddress1 ||
+		string(response.ABI) != contractABI {
+		t.Errorf("got response %+v, want ABI of %s", response, testAddress1)
+	}
+}
+
+func TestBalance(t *testing.T) {
+	s := newTestServer(t, nil)
+	s.start(t)
+	s.node.SetBalance(eth.Holding{Address: testAddress1}, big.NewInt(1000))
+	// the token is not ERC-20
+	s.node.SetReverting(testAddress2)
+	tokenTarget := "/v1/balance?address=" + testAddress1 + "&token=" + testAddress2
+
+	decodeResponse(t, s.do(t, http.MethodPost, "/v1/subscribe",
+		`{"address":"`+testAddress1+`"}`, nil), http.StatusOK, nil)
+
+	// balance is tracked from the next block after the first request
+	var response balanceResponse
+	decodeResponse(t, s.do(t, http.MethodGet, tokenTarget, "", nil), http.StatusOK, &response)
+	expected := balanceResponse{ChainID: 1, Address: testAddress1, Balances: []balanceRecord{}}
+	if !reflect.DeepEqual(response, expected) {
+		t.Errorf("responses are not equal:\nhave: %+v\nwant: %+v", response, expected)
+	}
+
+	s.node.Mine()
+	waitFor(t, "balance", func() bool {
+		balances, _ := s.parser.GetBalances("", testAddress1, "")
+		return len(balances) != 0
+	})
+
+	decodeResponse(t, s.do(t, http.MethodGet, "/v1/balance?address="+testAddress1, "", nil),
+		http.StatusOK, &response)
+	expected.Balances = []balanceRecord{{Balance: "1000", BlockNumber: 1}}
+	if !reflect.DeepEqual(response, expected) {
+		t.Errorf("responses are not equal:\nhave: %+v\nwant: %+v", response, expected)
+	}
+
+	checkError(t, s.do(t, http.MethodGet, tokenTarget, "", nil),
+		http.StatusBadRequest, codeInvalidArgument, "")
+}
+
+func TestExport(t *testing.T) {
+	s := newTestServer(t, nil)
+	s.start(t)
+
+	decodeResponse(t, s.do(t, http.MethodPost, "/v1/subscribe",
+		`{"address":"`+testAddress1+`"}`, nil), http.StatusOK, nil)
+	block := s.node.Mine(eth.Transaction{From: testAddress1, To: testAddress2, Value: "0x64"})
+	waitFor(t, "transaction", func() bool {
+		transactions, _ := s.parser.GetTransactions("", testAddress1)
+		return len(transactions) == 1
+	})
+	hash := block.Transactions[0].Hash
+
+	testCases := []struct {
+		name   string
+		query  string
+		hashes []string
+	}{
+		{name: "subscriptions", hashes: []string{hash}},
+		{name: "addresses", query: "&addresses=" + testAddress1, hashes: []string{hash}},
+		{name: "from block", query: "&from_block=2", hashes: []string{}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			w := s.do(t, http.MethodGet, "/v1/export?format=csv"+tc.query, "", nil)
+			decodeResponse(t, w, http.StatusOK, nil)
+			if contentType := w.Header().Get("Content-Type"); contentType !=
+				export.ContentType(export.FormatCSV) {
+				t.Errorf("got Content-Type %s, want %s", contentType,
+					export.ContentType(export.FormatCSV))
+			}
+			disposition := `attachment; filename="transactions-1.csv"`
+			if header := w.Header().Get("Content-Disposition"); header != disposition {
+				t.Errorf("got Content-Disposition %s, want %s", header, disposition)
+			}
+
+			records, err := csv.NewReader(w.Body).ReadAll()
+			if err != nil {
+				t.Fatalf("could not read CSV: %v", err)
+			}
+			// hashes follow the header
+			hashes := make([]string, 0, len(records))
+			for _, record := range records[1:] {
+				if record[0] != testAddress1 || record[1] != export.DirectionOut {
+					t.Errorf("got row %v, want outgoing transaction of %s", record, testAddress1)
+				}
+				hashes = append(hashes, record[4])
+			}
+			if !reflect.DeepEqual(hashes, tc.hashes) {
+				t.Errorf("hashes are not equal:\nhave: %v\nwant: %v", hashes, tc.hashes)
+			}
+		})
+	}
+}
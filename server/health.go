@@ -0,0 +1,74 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"eth-parser/parser"
+)
+
+type HealthConfig struct {
+	// MaxBlockAge is the max time since last block number was advanced
+	// after which the service is considered not ready
+	MaxBlockAge time.Duration `yaml:"max_block_age"`
+
+	// MaxBlockLag is the max difference between the latest block of the
+	// chain and the last parsed block after which the service is
+	// considered not ready
+	MaxBlockLag int64 `yaml:"max_block_lag"`
+}
+
+type healthResponse struct {
+	Status string `json:"status"`
+}
+
+func (h *Handler) healthzHandler(w http.ResponseWriter, r *http.Request) {
+	h.writeJSON(w, r, http.StatusOK, &healthResponse{Status: "ok"})
+}
+
+func (h *Handler) readyzHandler(w http.ResponseWriter, r *http.Request) {
+	var errs []error
+	for _, chainID := range h.chains.IDs() {
+		if err := h.checkReadiness(h.chains[chainID].Status()); err != nil {
+			errs = append(errs, fmt.Errorf("chain %d: %w", chainID, err))
+		}
+	}
+
+	if err := errors.Join(errs...); err != nil {
+		h.requestLogger(r).Warn("not ready", "reason", err)
+		h.writeError(w, r, http.StatusServiceUnavailable, codeUnavailable, err.Error())
+		return
+	}
+
+	h.writeJSON(w, r, http.StatusOK, &healthResponse{Status: "ok"})
+}
+
+func (h *Handler) checkReadiness(status parser.Status) error {
+	if status.InitErr != nil {
+		return fmt.Errorf("parser is not initialized: %s", status.InitErr)
+	}
+	if !status.Initialized {
+		return fmt.Errorf("parser is not initialized yet")
+	}
+
+	if status.LastBlockUpdatedAt.IsZero() {
+		return fmt.Errorf("no blocks were parsed yet")
+	}
+	if h.healthConfig.MaxBlockAge > 0 {
+		if age := time.Since(status.LastBlockUpdatedAt); age > h.healthConfig.MaxBlockAge {
+			return fmt.Errorf("last block #%d was parsed %s ago",
+				status.LastBlockNumber, age.Truncate(time.Second))
+		}
+	}
+
+	if h.healthConfig.MaxBlockLag > 0 {
+		if lag := status.HeadBlockNumber - status.LastBlockNumber; lag > h.healthConfig.MaxBlockLag {
+			return fmt.Errorf("last block #%d lags behind head block #%d by %d blocks",
+				status.LastBlockNumber, status.HeadBlockNumber, lag)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,153 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"eth-parser/eth"
+	"eth-parser/parser"
+)
+
+type logSubscribeRequest struct {
+	ChainID *uint64 `json:"chain_id,omitempty"`
+	// Address is the address of the contract emitting the logs
+	Address string `json:"address"`
+	// Topics lists alternatives of every topic position, empty position
+	// matches any topic
+	Topics [][]string `json:"topics,omitempty"`
+}
+
+type logFilterResponse struct {
+	ChainID    uint64        `json:"chain_id"`
+	Filter     eth.LogFilter `json:"filter"`
+	Subscribed bool          `json:"subscribed"`
+}
+
+type logFiltersResponse struct {
+	ChainID uint64          `json:"chain_id"`
+	Filters []eth.LogFilter `json:"filters"`
+}
+
+type logsResponse struct {
+	ChainID  uint64           `json:"chain_id"`
+	FilterID string           `json:"filter_id"`
+	Logs     []eth.MatchedLog `json:"logs"`
+}
+
+func (h *Handler) logSubscribeHandler(w http.ResponseWriter, r *http.Request) {
+	request := &logSubscribeRequest{}
+	decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxRequestBodySize))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(request); err != nil {
+		h.writeError(w, r, http.StatusBadRequest, codeInvalidArgument,
+			fmt.Sprintf("could not parse body: %s", err))
+		return
+	}
+
+	p, ok := h.requestChain(w, r, request.ChainID)
+	if !ok {
+		return
+	}
+
+	tenant := requestTenant(r)
+	filter, err := p.SubscribeLogs(tenant.ID,
+		eth.LogFilter{Address: request.Address, Topics: request.Topics}, tenant.MaxSubscriptions)
+	switch {
+	case errors.Is(err, eth.ErrInvalidLogFilter):
+		h.writeError(w, r, http.StatusBadRequest, codeInvalidArgument, err.Error())
+		return
+	case errors.Is(err, parser.ErrSubscriptionsLimitExceeded):
+		h.writeError(w, r, http.StatusForbidden, codeLimitExceeded, err.Error())
+		return
+	case err != nil:
+		h.writeError(w, r, http.StatusInternalServerError, codeInternal, err.Error())
+		return
+	}
+
+	h.requestLogger(r).Info("subscribed to logs successfully",
+		"chain_id", p.ChainID(), "filter_id", filter.ID, "address", filter.Address)
+
+	h.writeJSON(w, r, http.StatusOK, &logFilterResponse{
+		ChainID:    p.ChainID(),
+		Filter:     filter,
+		Subscribed: true,
+	})
+}
+
+func (h *Handler) logUnsubscribeHandler(w http.ResponseWriter, r *http.Request) {
+	p, params, ok := h.parseFilterRequest(w, r)
+	if !ok {
+		return
+	}
+
+	if err := p.UnsubscribeLogs(requestTenant(r).ID, params.FilterID); err != nil {
+		h.writeError(w, r, http.StatusInternalServerError, codeInternal, err.Error())
+		return
+	}
+
+	h.requestLogger(r).Info("unsubscribed from logs successfully",
+		"chain_id", p.ChainID(), "filter_id", params.FilterID)
+
+	h.writeJSON(w, r, http.StatusOK, &logFilterResponse{
+		ChainID:    p.ChainID(),
+		Filter:     eth.LogFilter{ID: params.FilterID},
+		Subscribed: false,
+	})
+}
+
+func (h *Handler) logFiltersHandler(w http.ResponseWriter, r *http.Request) {
+	p, _, ok := h.parseRequest(w, r, false)
+	if !ok {
+		return
+	}
+
+	filters := p.GetLogFilters(requestTenant(r).ID)
+	if filters == nil {
+		filters = []eth.LogFilter{}
+	}
+
+	h.writeJSON(w, r, http.StatusOK, &logFiltersResponse{
+		ChainID: p.ChainID(),
+		Filters: filters,
+	})
+}
+
+func (h *Handler) logsHandler(w http.ResponseWriter, r *http.Request) {
+	p, params, ok := h.parseFilterRequest(w, r)
+	if !ok {
+		return
+	}
+
+	logs := p.GetLogs(requestTenant(r).ID, params.FilterID)
+	if logs == nil {
+		logs = []eth.MatchedLog{}
+	}
+
+	h.requestLogger(r).Debug("got logs",
+		"chain_id", p.ChainID(), "filter_id", params.FilterID, "logs", len(logs))
+
+	h.writeJSON(w, r, http.StatusOK, &logsResponse{
+		ChainID:  p.ChainID(),
+		FilterID: params.FilterID,
+		Logs:     logs,
+	})
+}
+
+// parseFilterRequest is parseRequest of requests to the log filter
+func (h *Handler) parseFilterRequest(
+	w http.ResponseWriter,
+	r *http.Request,
+) (*parser.Parser, *requestParams, bool) {
+	p, params, ok := h.parseRequest(w, r, false)
+	if !ok {
+		return nil, nil, false
+	}
+	if len(params.FilterID) == 0 {
+		h.writeError(w, r, http.StatusBadRequest, codeInvalidArgument, "filter_id is required")
+		return nil, nil, false
+	}
+
+	return p, params, true
+}
@@ -0,0 +1,116 @@
+package server
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"eth-parser/auth"
+)
+
+const (
+	requestIDHeader = "X-Request-ID"
+	requestIDLen    = 8
+)
+
+type loggerCtxKey struct{}
+
+type tenantCtxKey struct{}
+
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (s *statusRecorder) WriteHeader(status int) {
+	s.status = status
+	s.ResponseWriter.WriteHeader(status)
+}
+
+// withRequestLogging assigns ID to every request, puts the logger with this
+// ID into the request context and logs the result of the request
+func (h *Handler) withRequestLogging(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestID := r.Header.Get(requestIDHeader)
+		if len(requestID) == 0 {
+			requestID = newRequestID()
+		}
+		w.Header().Set(requestIDHeader, requestID)
+
+		logger := h.logger.With("request_id", requestID)
+		ctx := context.WithValue(r.Context(), loggerCtxKey{}, logger)
+
+		recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		start := time.Now()
+
+		next.ServeHTTP(recorder, r.WithContext(ctx))
+
+		level := slog.LevelInfo
+		if r.URL.Path == "/healthz" || r.URL.Path == "/readyz" {
+			// probes are too frequent to be logged by default
+			level = slog.LevelDebug
+		}
+
+		logger.Log(ctx, level, "handled request",
+			"method", r.Method,
+			"path", r.URL.Path,
+			"status", recorder.status,
+			"duration", time.Since(start),
+		)
+	})
+}
+
+// withAuth authenticates request and puts its tenant into the request
+// context. If authentication is disabled, all requests belong to the
+// default tenant without limits.
+func (h *Handler) withAuth(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		tenant := &auth.Tenant{}
+
+		if h.authenticator.Enabled() {
+			var err error
+			tenant, err = h.authenticator.Authenticate(r)
+			if err != nil {
+				h.requestLogger(r).Warn("could not authenticate request", "error", err)
+				h.writeError(w, r, http.StatusUnauthorized, codeUnauthenticated, err.Error())
+				return
+			}
+		}
+
+		logger := h.requestLogger(r).With("tenant", tenant.ID)
+		ctx := context.WithValue(r.Context(), loggerCtxKey{}, logger)
+		ctx = context.WithValue(ctx, tenantCtxKey{}, tenant)
+
+		next(w, r.WithContext(ctx))
+	}
+}
+
+// requestTenant returns tenant of the authenticated request
+func requestTenant(r *http.Request) *auth.Tenant {
+	if tenant, ok := r.Context().Value(tenantCtxKey{}).(*auth.Tenant); ok {
+		return tenant
+	}
+
+	return &auth.Tenant{}
+}
+
+// requestLogger returns logger of the request or handler's logger if there is none
+func (h *Handler) requestLogger(r *http.Request) *slog.Logger {
+	if logger, ok := r.Context().Value(loggerCtxKey{}).(*slog.Logger); ok {
+		return logger
+	}
+
+	return h.logger
+}
+
+func newRequestID() string {
+	buf := make([]byte, requestIDLen)
+	if _, err := rand.Read(buf); err != nil {
+		return "unknown"
+	}
+
+	return hex.EncodeToString(buf)
+}
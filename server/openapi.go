@@ -0,0 +1,257 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"eth-parser/auth"
+)
+
+const openAPIVersion = "3.0.3"
+
+// openAPISpec is a subset of OpenAPI 3 document used to describe the API
+type openAPISpec struct {
+	OpenAPI    string                                  `json:"openapi"`
+	Info       openAPIInfo                             `json:"info"`
+	Paths      map[string]map[string]*openAPIOperation `json:"paths"`
+	Components openAPIComponents                       `json:"components"`
+}
+
+type openAPIInfo struct {
+	Title   string `json:"title"`
+	Version string `json:"version"`
+}
+
+type openAPIOperation struct {
+	Summary     string                      `json:"summary"`
+	Parameters  []*openAPIParameter         `json:"parameters,omitempty"`
+	RequestBody *openAPIRequestBody         `json:"requestBody,omitempty"`
+	Responses   map[string]*openAPIResponse `json:"responses"`
+	Security    []map[string][]string       `json:"security,omitempty"`
+}
+
+type openAPIParameter struct {
+	Name        string         `json:"name"`
+	In          string         `json:"in"`
+	Description string         `json:"description,omitempty"`
+	Required    bool           `json:"required"`
+	Schema      *openAPISchema `json:"schema"`
+}
+
+type openAPIRequestBody struct {
+	Content map[string]*openAPIMediaType `json:"content"`
+}
+
+type openAPIResponse struct {
+	Description string                       `json:"description"`
+	Content     map[string]*openAPIMediaType `json:"content,omitempty"`
+}
+
+type openAPIMediaType struct {
+	Schema *openAPISchema `json:"schema"`
+}
+
+type openAPISchema struct {
+	Type        string                    `json:"type,omitempty"`
+	Format      string                    `json:"format,omitempty"`
+	Description string                    `json:"description,omitempty"`
+	Properties  map[string]*openAPISchema `json:"properties,omitempty"`
+	Items       *openAPISchema            `json:"items,omitempty"`
+	Required    []string                  `json:"required,omitempty"`
+}
+
+type openAPIComponents struct {
+	SecuritySchemes map[string]*openAPISecurityScheme `json:"securitySchemes"`
+}
+
+type openAPISecurityScheme struct {
+	Type         string `json:"type"`
+	Name         string `json:"name,omitempty"`
+	In           string `json:"in,omitempty"`
+	Scheme       string `json:"scheme,omitempty"`
+	BearerFormat string `json:"bearerFormat,omitempty"`
+}
+
+// newOpenAPISpec generates spec of the given routes
+func newOpenAPISpec(routes []*route) *openAPISpec {
+	spec := &openAPISpec{
+		OpenAPI: openAPIVersion,
+		Info: openAPIInfo{
+			Title:   "ETH Parser API",
+			Version: strings.TrimPrefix(apiVersion, "/"),
+		},
+		Paths: make(map[string]map[string]*openAPIOperation),
+		Components: openAPIComponents{
+			SecuritySchemes: map[string]*openAPISecurityScheme{
+				"apiKey": {Type: "apiKey", Name: auth.APIKeyHeader, In: "header"},
+				"bearer": {Type: "http", Scheme: "bearer", BearerFormat: "JWT"},
+			},
+		},
+	}
+
+	errorContent := map[string]*openAPIMediaType{
+		"application/json": {Schema: schemaOf(reflect.TypeOf(errorResponse{}))},
+	}
+
+	for _, rt := range routes {
+		okResponse := &openAPIResponse{Description: "successful response"}
+		if rt.response != nil {
+			okResponse.Content = map[string]*openAPIMediaType{
+				"application/json": {Schema: schemaOf(reflect.TypeOf(rt.response))},
+			}
+		}
+
+		operation := &openAPIOperation{
+			Summary: rt.summary,
+			Responses: map[string]*openAPIResponse{
+				strconv.Itoa(http.StatusOK): okResponse,
+				"default":                   {Description: "error", Content: errorContent},
+			},
+		}
+
+		switch {
+		case rt.requestBody != nil:
+			operation.Parameters = queryParameters(rt.params)
+			operation.RequestBody = &openAPIRequestBody{
+				Content: map[string]*openAPIMediaType{
+					"application/json": {Schema: schemaOf(reflect.TypeOf(rt.requestBody))},
+				},
+			}
+			if rt.csv {
+				operation.RequestBody.Content["text/csv"] = &openAPIMediaType{
+					Schema: &openAPISchema{Type: "string"},
+				}
+			}
+		case rt.method == http.MethodPost:
+			operation.RequestBody = paramsRequestBody(rt.params)
+		default:
+			operation.Parameters = queryParameters(rt.params)
+		}
+
+		if rt.auth {
+			operation.Security = []map[string][]string{
+				{"apiKey": {}},
+				{"bearer": {}},
+			}
+		}
+
+		if spec.Paths[rt.path] == nil {
+			spec.Paths[rt.path] = make(map[string]*openAPIOperation)
+		}
+		spec.Paths[rt.path][strings.ToLower(rt.method)] = operation
+	}
+
+	return spec
+}
+
+// queryParameters describes params passed in query
+func queryParameters(params []routeParam) []*openAPIParameter {
+	parameters := make([]*openAPIParameter, 0, len(params))
+	for _, param := range params {
+		parameters = append(parameters, &openAPIParameter{
+			Name:        param.name,
+			In:          "query",
+			Description: param.description,
+			Required:    param.required,
+			Schema:      param.schema(),
+		})
+	}
+
+	return parameters
+}
+
+// paramsRequestBody describes params passed in JSON or form body, query is
+// accepted as well but it is not described
+func paramsRequestBody(params []routeParam) *openAPIRequestBody {
+	if len(params) == 0 {
+		return nil
+	}
+
+	schema := &openAPISchema{
+		Type:       "object",
+		Properties: make(map[string]*openAPISchema, len(params)),
+	}
+	for _, param := range params {
+		schema.Properties[param.name] = param.schema()
+		if param.required {
+			schema.Required = append(schema.Required, param.name)
+		}
+	}
+
+	return &openAPIRequestBody{
+		Content: map[string]*openAPIMediaType{
+			"application/json":                  {Schema: schema},
+			"application/x-www-form-urlencoded": {Schema: schema},
+		},
+	}
+}
+
+func (p *routeParam) schema() *openAPISchema {
+	if p.integer {
+		return &openAPISchema{Type: "integer", Format: "uint64", Description: p.description}
+	}
+
+	return &openAPISchema{Type: "string", Description: p.description}
+}
+
+// schemaOf returns JSON schema of the type based on its JSON encoding
+func schemaOf(t reflect.Type) *openAPISchema {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t == reflect.TypeOf(json.RawMessage{}) {
+		// raw JSON may be any value
+		return &openAPISchema{}
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return &openAPISchema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &openAPISchema{Type: "integer"}
+	case reflect.Float32, reflect.Float64:
+		return &openAPISchema{Type: "number"}
+	case reflect.String:
+		return &openAPISchema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		return &openAPISchema{Type: "array", Items: schemaOf(t.Elem())}
+	case reflect.Struct:
+		schema := &openAPISchema{
+			Type:       "object",
+			Properties: make(map[string]*openAPISchema, t.NumField()),
+		}
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			if !field.IsExported() {
+				continue
+			}
+
+			name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+			if name == "-" {
+				continue
+			}
+			if field.Anonymous && len(name) == 0 {
+				// fields of embedded struct are encoded inline
+				for embeddedName, embedded := range schemaOf(field.Type).Properties {
+					schema.Properties[embeddedName] = embedded
+				}
+				continue
+			}
+			if len(name) == 0 {
+				name = field.Name
+			}
+			schema.Properties[name] = schemaOf(field.Type)
+		}
+		return schema
+	default:
+		return &openAPISchema{}
+	}
+}
+
+func (h *Handler) openAPIHandler(w http.ResponseWriter, r *http.Request) {
+	h.writeJSON(w, r, http.StatusOK, h.openAPI)
+}
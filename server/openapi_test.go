@@ -0,0 +1,126 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestOpenAPI(t *testing.T) {
+	s := newTestServer(t, nil)
+
+	var spec openAPISpec
+	decodeResponse(t, s.do(t, http.MethodGet, "/v1/openapi.json", "", nil), http.StatusOK, &spec)
+	if spec.OpenAPI != openAPIVersion || spec.Info.Version != "v1" {
+		t.Errorf("got spec version %s of API %s, want %s of v1",
+			spec.OpenAPI, spec.Info.Version, openAPIVersion)
+	}
+
+	// every route is described and nothing else is
+	var described, expected []string
+	for path, operations := range spec.Paths {
+		for method := range operations {
+			described = append(described, strings.ToUpper(method)+" "+path)
+		}
+	}
+	for _, rt := range routes {
+		expected = append(expected, rt.method+" "+rt.path)
+	}
+	sort.Strings(described)
+	sort.Strings(expected)
+	if !reflect.DeepEqual(described, expected) {
+		t.Fatalf("operations are not equal to routes:\nhave: %v\nwant: %v", described, expected)
+	}
+
+	for _, rt := range routes {
+		t.Run(rt.method+" "+rt.path, func(t *testing.T) {
+			operation := spec.Paths[rt.path][strings.ToLower(rt.method)]
+			if operation.Summary != rt.summary {
+				t.Errorf("got summary '%s', want '%s'", operation.Summary, rt.summary)
+			}
+			if secured := len(operation.Security) != 0; secured != rt.auth {
+				t.Errorf("got security %v, want auth %v", operation.Security, rt.auth)
+			}
+			if _, ok := operation.Responses["200"]; !ok {
+				t.Errorf("successful response is not described")
+			}
+			if rt.response != nil {
+				checkResponseSchema(t, operation, rt.response)
+			}
+
+			// required params are described either in query or in body
+			for _, param := range rt.params {
+				if param.required && !isRequired(operation, param.name) {
+					t.Errorf("param %s is not described as required", param.name)
+				}
+			}
+			if rt.csv && operation.RequestBody.Content["text/csv"] == nil {
+				t.Errorf("CSV body is not described")
+			}
+
+			// the described operation is served by the mux
+			w := s.do(t, rt.method, rt.path, "", nil)
+			if w.Code == http.StatusMethodNotAllowed {
+				t.Errorf("operation is not served, got status %d", w.Code)
+			}
+			var response errorResponse
+			if json.Unmarshal(w.Body.Bytes(), &response) == nil &&
+				strings.HasPrefix(response.Error.Message, "path ") {
+				t.Errorf("operation is not served: %s", response.Error.Message)
+			}
+		})
+	}
+}
+
+// checkResponseSchema checks that properties of successful response schema are
+// the JSON fields of the sample response
+func checkResponseSchema(t *testing.T, operation *openAPIOperation, sample interface{}) {
+	t.Helper()
+
+	content := operation.Responses["200"].Content["application/json"]
+	if content == nil {
+		t.Errorf("JSON of successful response is not described")
+		return
+	}
+
+	data, err := json.Marshal(sample)
+	if err != nil {
+		t.Fatalf("could not marshal sample response: %v", err)
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		t.Fatalf("could not unmarshal sample response: %v", err)
+	}
+
+	// omitted empty fields of the sample are not checked
+	for name := range fields {
+		if content.Schema.Properties[name] == nil {
+			t.Errorf("field %s of response is not described", name)
+		}
+	}
+}
+
+// isRequired tells whether param is described as required in query or body
+func isRequired(operation *openAPIOperation, name string) bool {
+	for _, parameter := range operation.Parameters {
+		if parameter.Name == name {
+			return parameter.Required
+		}
+	}
+
+	if operation.RequestBody == nil {
+		return false
+	}
+	for _, content := range operation.RequestBody.Content {
+		for _, required := range content.Schema.Required {
+			if required == name {
+				return true
+			}
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,62 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// codes of API errors
+const (
+	codeInvalidArgument  = "invalid_argument"
+	codeNotFound         = "not_found"
+	codeMethodNotAllowed = "method_not_allowed"
+	codeUnauthenticated  = "unauthenticated"
+	codeLimitExceeded    = "limit_exceeded"
+	codeUnavailable      = "unavailable"
+	codeInternal         = "internal"
+)
+
+type errorResponse struct {
+	Error apiError `json:"error"`
+}
+
+type apiError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// writeJSON writes response marshaled to JSON with given status
+func (h *Handler) writeJSON(
+	w http.ResponseWriter,
+	r *http.Request,
+	status int,
+	response interface{},
+) {
+	data, err := json.Marshal(response)
+	if err != nil {
+		h.requestLogger(r).Error("could not marshal response", "error", err)
+		status = http.StatusInternalServerError
+		data = []byte(`{"error":{"code":"internal","message":"could not marshal response"}}`)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if _, err := w.Write(data); err != nil {
+		h.requestLogger(r).Error("could not write response", "error", err)
+	}
+}
+
+// writeError writes JSON error with given status and code
+func (h *Handler) writeError(
+	w http.ResponseWriter,
+	r *http.Request,
+	status int,
+	code, message string,
+) {
+	h.writeJSON(w, r, status, &errorResponse{
+		Error: apiError{
+			Code:    code,
+			Message: message,
+		},
+	})
+}
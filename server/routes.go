@@ -0,0 +1,279 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// apiVersion is the prefix of all API routes
+const apiVersion = "/v1"
+
+type routeParam struct {
+	name        string
+	description string
+	required    bool
+	// integer tells whether param is integer, it is string otherwise
+	integer bool
+}
+
+// route describes a single API route, the routes are used both to build the
+// mux and to generate OpenAPI spec
+type route struct {
+	method  string
+	path    string
+	summary string
+	// auth tells whether requests to the route must be authenticated
+	auth   bool
+	params []routeParam
+	// requestBody is a sample of JSON body used for its schema, params are
+	// passed in the body if nil
+	requestBody interface{}
+	// csv tells whether the body may be passed as CSV as well
+	csv bool
+	// response is a sample of successful response used for its schema, the
+	// response is not JSON if nil
+	response interface{}
+
+	handler func(h *Handler, w http.ResponseWriter, r *http.Request)
+}
+
+var (
+	chainIDParam = routeParam{
+		name:        "chain_id",
+		description: "ID of the chain, may be omitted if only one chain is served",
+		integer:     true,
+	}
+	addressParam = routeParam{
+		name:        "address",
+		description: "address of the account",
+		required:    true,
+	}
+	tokenParam = routeParam{
+		name:        "token",
+		description: "address of ERC-20 token to track the balance of in addition to ETH",
+	}
+	filterIDParam = routeParam{
+		name:        "filter_id",
+		description: "ID of the log filter returned on subscribe",
+		required:    true,
+	}
+)
+
+var routes = []*route{
+	{
+		method:   http.MethodGet,
+		path:     apiVersion + "/current_block",
+		summary:  "Get the last parsed block",
+		auth:     true,
+		params:   []routeParam{chainIDParam},
+		response: &currentBlockResponse{},
+		handler:  (*Handler).currentBlockHandler,
+	},
+	{
+		method:   http.MethodPost,
+		path:     apiVersion + "/subscribe",
+		summary:  "Subscribe to the transactions of the address",
+		auth:     true,
+		params:   []routeParam{addressParam, chainIDParam},
+		response: &subscriptionResponse{},
+		handler:  (*Handler).subscribeHandler,
+	},
+	{
+		method:  http.MethodPost,
+		path:    apiVersion + "/subscribe/bulk",
+		summary: "Subscribe to the transactions of the list of addresses",
+		auth:    true,
+		params:  []routeParam{chainIDParam},
+		// body is JSON array of addresses or CSV with addresses in the first column
+		requestBody: []string{},
+		csv:         true,
+		response:    &bulkSubscribeResponse{},
+		handler:     (*Handler).bulkSubscribeHandler,
+	},
+	{
+		method:   http.MethodPost,
+		path:     apiVersion + "/unsubscribe",
+		summary:  "Unsubscribe from the transactions of the address",
+		auth:     true,
+		params:   []routeParam{addressParam, chainIDParam},
+		response: &subscriptionResponse{},
+		handler:  (*Handler).unsubscribeHandler,
+	},
+	{
+		method:   http.MethodGet,
+		path:     apiVersion + "/subscriptions",
+		summary:  "Get the subscribed addresses",
+		auth:     true,
+		params:   []routeParam{chainIDParam},
+		response: &subscriptionsResponse{},
+		handler:  (*Handler).subscriptionsHandler,
+	},
+	{
+		method:   http.MethodGet,
+		path:     apiVersion + "/transactions",
+		summary:  "Get the transactions stored for the subscribed address",
+		auth:     true,
+		params:   []routeParam{addressParam, chainIDParam},
+		response: &transactionsResponse{},
+		handler:  (*Handler).transactionsHandler,
+	},
+	{
+		method:  http.MethodGet,
+		path:    apiVersion + "/export",
+		summary: "Export the mined transactions of the addresses as CSV, JSONL or Parquet",
+		auth:    true,
+		params: []routeParam{
+			{
+				name:        "addresses",
+				description: "comma separated addresses, all subscribed addresses if omitted",
+			},
+			{
+				name:        "format",
+				description: "format of the export: csv, jsonl or parquet, jsonl by default",
+			},
+			{name: "from_block", description: "first block of the range", integer: true},
+			{name: "to_block", description: "last block of the range", integer: true},
+			{
+				name:        "from_time",
+				description: "inclusive start of the range as RFC 3339 time or date in UTC",
+			},
+			{
+				name:        "to_time",
+				description: "exclusive end of the range as RFC 3339 time or date in UTC",
+			},
+			chainIDParam,
+		},
+		handler: (*Handler).exportHandler,
+	},
+	{
+		method:   http.MethodGet,
+		path:     apiVersion + "/balance",
+		summary:  "Get the running balances of the subscribed address with their drift",
+		auth:     true,
+		params:   []routeParam{addressParam, tokenParam, chainIDParam},
+		response: &balanceResponse{},
+		handler:  (*Handler).balanceHandler,
+	},
+	{
+		method:      http.MethodPost,
+		path:        apiVersion + "/logs/subscribe",
+		summary:     "Subscribe to the logs of the contract matched by topics",
+		auth:        true,
+		requestBody: &logSubscribeRequest{},
+		response:    &logFilterResponse{},
+		handler:     (*Handler).logSubscribeHandler,
+	},
+	{
+		method:   http.MethodPost,
+		path:     apiVersion + "/logs/unsubscribe",
+		summary:  "Unsubscribe from the logs of the filter and delete its logs",
+		auth:     true,
+		params:   []routeParam{filterIDParam, chainIDParam},
+		response: &logFilterResponse{},
+		handler:  (*Handler).logUnsubscribeHandler,
+	},
+	{
+		method:   http.MethodGet,
+		path:     apiVersion + "/logs/filters",
+		summary:  "Get the subscribed log filters",
+		auth:     true,
+		params:   []routeParam{chainIDParam},
+		response: &logFiltersResponse{},
+		handler:  (*Handler).logFiltersHandler,
+	},
+	{
+		method:   http.MethodGet,
+		path:     apiVersion + "/logs",
+		summary:  "Get the logs matched by the log filter with their blocks and transactions",
+		auth:     true,
+		params:   []routeParam{filterIDParam, chainIDParam},
+		response: &logsResponse{},
+		handler:  (*Handler).logsHandler,
+	},
+	{
+		method:  http.MethodPut,
+		path:    apiVersion + "/abi",
+		summary: "Store JSON ABI of the contract used to decode its transactions and logs",
+		auth:    true,
+		params:  []routeParam{addressParam, chainIDParam},
+		// body is JSON ABI as produced by compilers
+		requestBody: []map[string]interface{}{},
+		response:    &abiResponse{},
+		handler:     (*Handler).storeABIHandler,
+	},
+	{
+		method:   http.MethodGet,
+		path:     apiVersion + "/abi",
+		summary:  "Get JSON ABI stored for the contract",
+		auth:     true,
+		params:   []routeParam{addressParam, chainIDParam},
+		response: &abiResponse{},
+		handler:  (*Handler).getABIHandler,
+	},
+	{
+		method:  http.MethodGet,
+		path:    apiVersion + "/openapi.json",
+		summary: "Get OpenAPI spec of the API",
+		handler: (*Handler).openAPIHandler,
+	},
+	{
+		method:  http.MethodPost,
+		path:    "/rpc",
+		summary: "Call parser methods with JSON-RPC 2.0, see README for the methods",
+		auth:    true,
+		handler: (*Handler).rpcHandler,
+	},
+	{
+		method:   http.MethodGet,
+		path:     "/healthz",
+		summary:  "Check liveness of the service",
+		response: &healthResponse{},
+		handler:  (*Handler).healthzHandler,
+	},
+	{
+		method:   http.MethodGet,
+		path:     "/readyz",
+		summary:  "Check readiness of the service, i.e. all chains are parsed in time",
+		response: &healthResponse{},
+		handler:  (*Handler).readyzHandler,
+	},
+}
+
+// newMux registers all routes in a new mux. Requests with methods not
+// allowed for the path and requests to unknown paths get JSON errors.
+func (h *Handler) newMux() *http.ServeMux {
+	mux := http.NewServeMux()
+
+	allowed := make(map[string][]string)
+	for _, rt := range routes {
+		handler := func(w http.ResponseWriter, r *http.Request) {
+			rt.handler(h, w, r)
+		}
+		if rt.auth {
+			handler = h.withAuth(handler)
+		}
+
+		mux.HandleFunc(rt.method+" "+rt.path, handler)
+		allowed[rt.path] = append(allowed[rt.path], rt.method)
+	}
+
+	for path, methods := range allowed {
+		sort.Strings(methods)
+		allow := strings.Join(methods, ", ")
+
+		mux.HandleFunc(path, func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Allow", allow)
+			h.writeError(w, r, http.StatusMethodNotAllowed, codeMethodNotAllowed,
+				fmt.Sprintf("method %s is not allowed, allowed: %s", r.Method, allow))
+		})
+	}
+
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		h.writeError(w, r, http.StatusNotFound, codeNotFound,
+			fmt.Sprintf("path %s not found", r.URL.Path))
+	})
+
+	return mux
+}
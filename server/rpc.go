@@ -0,0 +1,296 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"eth-parser/eth"
+	"eth-parser/jsonrpc"
+	"eth-parser/parser"
+)
+
+const (
+	maxRPCBodySize  = 1 << 20
+	maxRPCBatchSize = 100
+)
+
+// rpcParams are params of all parser methods, they are passed either by
+// name or by position
+type rpcParams struct {
+	ChainID *uint64 `json:"chain_id"`
+	Address string  `json:"address"`
+}
+
+type rpcMethod struct {
+	// positional lists names of params in order of their positions
+	positional []string
+	// addressRequired tells whether method must have non-empty address
+	addressRequired bool
+
+	call func(h *Handler, r *http.Request, p *parser.Parser, params *rpcParams) (
+		interface{}, *jsonrpc.Error)
+}
+
+var rpcMethods = map[string]*rpcMethod{
+	"parser_getCurrentBlock": {
+		positional: []string{"chain_id"},
+		call: func(_ *Handler, _ *http.Request, p *parser.Parser, _ *rpcParams) (
+			interface{}, *jsonrpc.Error,
+		) {
+			return p.GetCurrentBlock(), nil
+		},
+	},
+	"parser_subscribe": {
+		positional:      []string{"address", "chain_id"},
+		addressRequired: true,
+		call:            (*Handler).rpcSubscribe,
+	},
+	"parser_unsubscribe": {
+		positional:      []string{"address", "chain_id"},
+		addressRequired: true,
+		call:            (*Handler).rpcUnsubscribe,
+	},
+	"parser_getTransactions": {
+		positional:      []string{"address", "chain_id"},
+		addressRequired: true,
+		call: func(_ *Handler, r *http.Request, p *parser.Parser, params *rpcParams) (
+			interface{}, *jsonrpc.Error,
+		) {
+			transactions, err := p.GetTransactions(requestTenant(r).ID, params.Address)
+			if err != nil {
+				return nil, rpcParserError(err)
+			}
+			if transactions == nil {
+				return []interface{}{}, nil
+			}
+
+			return transactions, nil
+		},
+	},
+}
+
+// rpcHandler serves JSON-RPC 2.0 requests to the parser, both single and batch
+func (h *Handler) rpcHandler(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxRPCBodySize))
+	if err != nil {
+		h.writeRPCResponse(w, r, rpcErrorPacket(nil, jsonrpc.CodeParseError,
+			fmt.Sprintf("could not read request: %s", err)))
+		return
+	}
+
+	body = bytes.TrimSpace(body)
+	if len(body) == 0 || body[0] != '[' {
+		response := h.handleRPCRequest(r, body)
+		if response == nil {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		h.writeRPCResponse(w, r, response)
+		return
+	}
+
+	var batch []json.RawMessage
+	if err := json.Unmarshal(body, &batch); err != nil {
+		h.writeRPCResponse(w, r, rpcErrorPacket(nil, jsonrpc.CodeParseError, err.Error()))
+		return
+	}
+	if len(batch) == 0 {
+		h.writeRPCResponse(w, r, rpcErrorPacket(nil, jsonrpc.CodeInvalidRequest, "empty batch"))
+		return
+	}
+	if len(batch) > maxRPCBatchSize {
+		h.writeRPCResponse(w, r, rpcErrorPacket(nil, jsonrpc.CodeInvalidRequest,
+			fmt.Sprintf("batch is too large, max size is %d", maxRPCBatchSize)))
+		return
+	}
+
+	responses := make([]*jsonrpc.Packet, 0, len(batch))
+	for _, data := range batch {
+		if response := h.handleRPCRequest(r, data); response != nil {
+			responses = append(responses, response)
+		}
+	}
+
+	// batch of notifications is not responded to at all
+	if len(responses) == 0 {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	h.writeRPCResponse(w, r, responses)
+}
+
+// handleRPCRequest handles a single request and returns its response, nil is
+// returned for notifications, which are handled but not responded to
+func (h *Handler) handleRPCRequest(r *http.Request, data []byte) *jsonrpc.Packet {
+	params := json.RawMessage{}
+	reqPacket := &jsonrpc.Packet{
+		Params: &params,
+	}
+	if err := json.Unmarshal(data, reqPacket); err != nil {
+		var syntaxErr *json.SyntaxError
+		if errors.As(err, &syntaxErr) {
+			return rpcErrorPacket(nil, jsonrpc.CodeParseError, err.Error())
+		}
+		return rpcErrorPacket(nil, jsonrpc.CodeInvalidRequest, err.Error())
+	}
+	if reqPacket.JSONRPC != jsonrpc.Version || len(reqPacket.Method) == 0 ||
+		!reqPacket.ValidID() {
+		return rpcErrorPacket(nil, jsonrpc.CodeInvalidRequest,
+			"request must have jsonrpc version 2.0, method and id of string or number")
+	}
+
+	response := h.callRPCMethod(r, reqPacket, params)
+	if reqPacket.IsNotification() {
+		return nil
+	}
+
+	return response
+}
+
+// callRPCMethod calls method of valid request and returns its response
+func (h *Handler) callRPCMethod(
+	r *http.Request,
+	reqPacket *jsonrpc.Packet,
+	params json.RawMessage,
+) *jsonrpc.Packet {
+	method, ok := rpcMethods[reqPacket.Method]
+	if !ok {
+		return rpcErrorPacket(reqPacket.ID, jsonrpc.CodeMethodNotFound,
+			fmt.Sprintf("method '%s' not found", reqPacket.Method))
+	}
+
+	parsedParams, err := parseRPCParams(params, method.positional)
+	if err != nil {
+		return rpcErrorPacket(reqPacket.ID, jsonrpc.CodeInvalidParams, err.Error())
+	}
+	if method.addressRequired && len(parsedParams.Address) == 0 {
+		return rpcErrorPacket(reqPacket.ID, jsonrpc.CodeInvalidParams, "address is required")
+	}
+
+	p, err := h.resolveChain(parsedParams.ChainID)
+	if err != nil {
+		return rpcErrorPacket(reqPacket.ID, jsonrpc.CodeInvalidParams, err.Error())
+	}
+
+	result, rpcErr := method.call(h, r, p, parsedParams)
+	if rpcErr != nil {
+		return &jsonrpc.Packet{
+			JSONRPC: jsonrpc.Version,
+			ID:      reqPacket.ID,
+			Error:   rpcErr,
+		}
+	}
+
+	return &jsonrpc.Packet{
+		JSONRPC: jsonrpc.Version,
+		ID:      reqPacket.ID,
+		Result:  result,
+	}
+}
+
+func (h *Handler) rpcSubscribe(r *http.Request, p *parser.Parser, params *rpcParams) (
+	interface{}, *jsonrpc.Error,
+) {
+	tenant := requestTenant(r)
+	if err := p.Subscribe(tenant.ID, params.Address, tenant.MaxSubscriptions); err != nil {
+		return nil, rpcParserError(err)
+	}
+
+	h.requestLogger(r).Info("subscribed successfully",
+		"chain_id", p.ChainID(), "address", params.Address)
+	return true, nil
+}
+
+func (h *Handler) rpcUnsubscribe(r *http.Request, p *parser.Parser, params *rpcParams) (
+	interface{}, *jsonrpc.Error,
+) {
+	if err := p.Unsubscribe(requestTenant(r).ID, params.Address); err != nil {
+		return nil, rpcParserError(err)
+	}
+
+	h.requestLogger(r).Info("unsubscribed successfully",
+		"chain_id", p.ChainID(), "address", params.Address)
+	return true, nil
+}
+
+// rpcParserError returns JSON-RPC error of the error returned by parser
+func rpcParserError(err error) *jsonrpc.Error {
+	switch {
+	case errors.Is(err, eth.ErrInvalidAddress):
+		return &jsonrpc.Error{Code: jsonrpc.CodeInvalidParams, Message: err.Error()}
+	case errors.Is(err, parser.ErrSubscriptionsLimitExceeded):
+		return &jsonrpc.Error{Code: jsonrpc.CodeLimitExceededError, Message: err.Error()}
+	default:
+		return &jsonrpc.Error{Code: jsonrpc.CodeInternalError, Message: err.Error()}
+	}
+}
+
+// parseRPCParams parses params passed by name as object or by position as
+// array with names of positions given by positional
+func parseRPCParams(raw json.RawMessage, positional []string) (*rpcParams, error) {
+	params := &rpcParams{}
+
+	raw = bytes.TrimSpace(raw)
+	if len(raw) == 0 || bytes.Equal(raw, []byte("null")) {
+		return params, nil
+	}
+
+	if raw[0] == '[' {
+		var values []json.RawMessage
+		if err := json.Unmarshal(raw, &values); err != nil {
+			return nil, fmt.Errorf("could not parse params: %w", err)
+		}
+		if len(values) > len(positional) {
+			return nil, fmt.Errorf("too many params, expected at most %d", len(positional))
+		}
+
+		named := make(map[string]json.RawMessage, len(values))
+		for i, value := range values {
+			named[positional[i]] = value
+		}
+
+		var err error
+		if raw, err = json.Marshal(named); err != nil {
+			return nil, fmt.Errorf("could not parse params: %w", err)
+		}
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(params); err != nil {
+		return nil, fmt.Errorf("could not parse params: %w", err)
+	}
+
+	return params, nil
+}
+
+func rpcErrorPacket(id json.RawMessage, code int, message string) *jsonrpc.Packet {
+	return &jsonrpc.Packet{
+		JSONRPC: jsonrpc.Version,
+		ID:      id,
+		Error: &jsonrpc.Error{
+			Code:    code,
+			Message: message,
+		},
+	}
+}
+
+func (h *Handler) writeRPCResponse(w http.ResponseWriter, r *http.Request, response interface{}) {
+	data, err := json.Marshal(response)
+	if err != nil {
+		h.requestLogger(r).Error("could not marshal JSON-RPC response", "error", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if _, err := w.Write(data); err != nil {
+		h.requestLogger(r).Error("could not write JSON-RPC response", "error", err)
+	}
+}
@@ -0,0 +1,165 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"testing"
+
+	"eth-parser/jsonrpc"
+)
+
+// rpcResponse is response packet with raw ID and result
+type rpcResponse struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   *jsonrpc.Error  `json:"error,omitempty"`
+}
+
+func TestRPC(t *testing.T) {
+	s := newTestServer(t, nil)
+
+	testCases := []struct {
+		name     string
+		body     string
+		expected rpcResponse
+	}{
+		{
+			name: "number id",
+			body: `{"jsonrpc":"2.0","id":7,"method":"parser_getCurrentBlock"}`,
+			expected: rpcResponse{
+				JSONRPC: jsonrpc.Version, ID: json.RawMessage(`7`), Result: json.RawMessage(`0`),
+			},
+		},
+		{
+			name: "string id",
+			body: `{"jsonrpc":"2.0","id":"req-1","method":"parser_subscribe",` +
+				`"params":["` + testAddress1 + `"]}`,
+			expected: rpcResponse{
+				JSONRPC: jsonrpc.Version, ID: json.RawMessage(`"req-1"`),
+				Result: json.RawMessage(`true`),
+			},
+		},
+		{
+			name: "null id",
+			body: `{"jsonrpc":"2.0","id":null,"method":"parser_getCurrentBlock"}`,
+			expected: rpcResponse{
+				JSONRPC: jsonrpc.Version, ID: json.RawMessage(`null`), Result: json.RawMessage(`0`),
+			},
+		},
+		{
+			name: "method not found",
+			body: `{"jsonrpc":"2.0","id":"a","method":"eth_blockNumber"}`,
+			expected: rpcResponse{
+				JSONRPC: jsonrpc.Version, ID: json.RawMessage(`"a"`),
+				Error: &jsonrpc.Error{
+					Code: jsonrpc.CodeMethodNotFound, Message: "method 'eth_blockNumber' not found",
+				},
+			},
+		},
+		{
+			name: "invalid address",
+			body: `{"jsonrpc":"2.0","id":1,"method":"parser_subscribe","params":["0x01"]}`,
+			expected: rpcResponse{
+				JSONRPC: jsonrpc.Version, ID: json.RawMessage(`1`),
+				Error: &jsonrpc.Error{
+					Code:    jsonrpc.CodeInvalidParams,
+					Message: "invalid address '0x01': must be 20 hex encoded bytes",
+				},
+			},
+		},
+		{
+			name: "parse error",
+			body: `{"jsonrpc":"2.0","id":1,`,
+			expected: rpcResponse{
+				JSONRPC: jsonrpc.Version, ID: json.RawMessage(`null`),
+				Error: &jsonrpc.Error{
+					Code: jsonrpc.CodeParseError, Message: "unexpected end of JSON input",
+				},
+			},
+		},
+		{
+			name: "object id",
+			body: `{"jsonrpc":"2.0","id":{},"method":"parser_getCurrentBlock"}`,
+			expected: rpcResponse{
+				JSONRPC: jsonrpc.Version, ID: json.RawMessage(`null`),
+				Error: &jsonrpc.Error{
+					Code: jsonrpc.CodeInvalidRequest,
+					Message: "request must have jsonrpc version 2.0, method and id of " +
+						"string or number",
+				},
+			},
+		},
+		{
+			name: "no version",
+			body: `{"id":3,"method":"parser_getCurrentBlock"}`,
+			expected: rpcResponse{
+				JSONRPC: jsonrpc.Version, ID: json.RawMessage(`null`),
+				Error: &jsonrpc.Error{
+					Code: jsonrpc.CodeInvalidRequest,
+					Message: "request must have jsonrpc version 2.0, method and id of " +
+						"string or number",
+				},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var response rpcResponse
+			decodeResponse(t, s.do(t, http.MethodPost, "/rpc", tc.body, nil),
+				http.StatusOK, &response)
+			if !reflect.DeepEqual(response, tc.expected) {
+				t.Errorf("responses are not equal:\nhave: %+v\nwant: %+v", response, tc.expected)
+			}
+		})
+	}
+}
+
+func TestRPCNotifications(t *testing.T) {
+	s := newTestServer(t, nil)
+
+	// notification is handled without response
+	w := s.do(t, http.MethodPost, "/rpc",
+		`{"jsonrpc":"2.0","method":"parser_subscribe","params":{"address":"`+testAddress1+`"}}`,
+		nil)
+	decodeResponse(t, w, http.StatusNoContent, nil)
+	if w.Body.Len() != 0 {
+		t.Errorf("notification is responded to: %s", w.Body.String())
+	}
+	if subscriptions := s.parser.GetSubscriptions(""); len(subscriptions) != 1 {
+		t.Errorf("notification is not handled, subscriptions: %v", subscriptions)
+	}
+
+	// notifications are skipped in batch, even failed ones, while invalid
+	// requests are responded to
+	var responses []rpcResponse
+	decodeResponse(t, s.do(t, http.MethodPost, "/rpc", `[
+		{"jsonrpc":"2.0","method":"parser_unknown"},
+		{"jsonrpc":"2.0","id":"b","method":"parser_getTransactions","params":["`+testAddress1+`"]},
+		{"jsonrpc":"2.0","id":"c","method":"parser_getCurrentBlock"},
+		1
+	]`, nil), http.StatusOK, &responses)
+
+	ids := make([]string, 0, len(responses))
+	for _, response := range responses {
+		ids = append(ids, string(response.ID))
+	}
+	if expected := []string{`"b"`, `"c"`, `null`}; !reflect.DeepEqual(ids, expected) {
+		t.Errorf("ids of responses are not equal:\nhave: %v\nwant: %v", ids, expected)
+	}
+	if responses[2].Error == nil || responses[2].Error.Code != jsonrpc.CodeInvalidRequest {
+		t.Errorf("invalid request got %+v", responses[2])
+	}
+
+	// batch of notifications is not responded to at all
+	w = s.do(t, http.MethodPost, "/rpc", `[
+		{"jsonrpc":"2.0","method":"parser_getCurrentBlock"},
+		{"jsonrpc":"2.0","method":"parser_unsubscribe","params":["`+testAddress1+`"]}
+	]`, nil)
+	decodeResponse(t, w, http.StatusNoContent, nil)
+	if subscriptions := s.parser.GetSubscriptions(""); len(subscriptions) != 0 {
+		t.Errorf("notification is not handled, subscriptions: %v", subscriptions)
+	}
+}
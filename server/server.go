@@ -2,32 +2,38 @@ package server
 
 import (
 	"errors"
-	"log"
+	"log/slog"
 	"net/http"
+	"os"
 )
 
-func InitHTTPServer(h *Handler, addr string) (*http.Server, <-chan struct{}) {
+func InitHTTPServer(
+	h *Handler,
+	addr string,
+	logger *slog.Logger,
+) (*http.Server, <-chan struct{}) {
+	logger = logger.With("component", "server")
+
 	if h == nil {
-		log.Fatalf("server: got nil handler")
+		logger.Error("got nil handler")
+		os.Exit(1)
 	}
 
 	server := &http.Server{
-		Addr: addr,
+		Addr:    addr,
+		Handler: h.withRequestLogging(h.newMux()),
 	}
 
-	http.HandleFunc("/current_block", h.currentBlockHandler)
-	http.HandleFunc("/subscribe", h.subscribeHandler)
-	http.HandleFunc("/transactions", h.transactionsHandler)
-
 	done := make(chan struct{})
 	go func() {
 		defer close(done)
 
 		if err := server.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
-			log.Fatalf("server: error on listen and serve: %s", err)
+			logger.Error("error on listen and serve", "error", err)
+			os.Exit(1)
 		}
 
-		log.Println("server: shutdown successfully")
+		logger.Info("shutdown successfully")
 	}()
 
 	return server, done
@@ -0,0 +1,138 @@
+package server
+
+import (
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"eth-parser/abi"
+	"eth-parser/auth"
+	"eth-parser/parser"
+	"eth-parser/poller"
+	"eth-parser/storages"
+	"eth-parser/testutil"
+)
+
+const (
+	testAddress1 = "0x00000000000000000000000000000000000000a1"
+	testAddress2 = "0x00000000000000000000000000000000000000a2"
+
+	testTimeout = 5 * time.Second
+)
+
+var testLogger = slog.New(slog.NewTextHandler(io.Discard, nil))
+
+// testServer serves handler of a single chain backed by memory storages, the
+// chain is served by testutil.EthNode. The parser tracks balances and it does
+// not process blocks until start.
+type testServer struct {
+	node    *testutil.EthNode
+	parser  *parser.Parser
+	handler http.Handler
+}
+
+func newTestServer(t *testing.T, authConfig *auth.Config) *testServer {
+	t.Helper()
+
+	node := testutil.NewEthNode(1)
+	t.Cleanup(node.Close)
+
+	ethPoller := poller.NewEthPoller(&poller.EthPollerConfig{
+		ChainID:             1,
+		Endpoint:            node.URL(),
+		PollInterval:        time.Millisecond,
+		HeadRefreshInterval: time.Hour,
+		Timeout:             time.Second,
+		NumRetries:          1,
+		QueueLen:            10,
+	}, testLogger)
+
+	config := &parser.Config{Balances: parser.BalancesConfig{Enabled: true}}
+	p := parser.NewParser(config, ethPoller, nil,
+		storages.NewTransactionsMapStorage(false, testLogger),
+		storages.NewAddressesMapStorage(testLogger),
+		storages.NewLogsMapStorage(false, testLogger),
+		abi.NewRegistry(&abi.Config{}, 1, testLogger), nil, testLogger)
+
+	if authConfig == nil {
+		authConfig = &auth.Config{}
+	}
+	h := NewHandler(parser.Chains{1: p}, &HealthConfig{}, auth.NewAuthenticator(authConfig),
+		testLogger)
+
+	return &testServer{
+		node:    node,
+		parser:  p,
+		handler: h.withRequestLogging(h.newMux()),
+	}
+}
+
+// start starts processing of blocks by the parser and waits until it is past
+// the initial block
+func (s *testServer) start(t *testing.T) {
+	t.Helper()
+
+	if err := s.parser.Init(); err != nil {
+		t.Fatalf("could not init parser: %v", err)
+	}
+	go s.parser.Routine()
+	t.Cleanup(s.parser.Shutdown)
+
+	waitFor(t, "initial block", func() bool {
+		return s.node.Calls("eth_getBlockByNumber") >= 2
+	})
+}
+
+// waitFor waits until condition is met or fails the test on timeout
+func waitFor(t *testing.T, what string, condition func() bool) {
+	t.Helper()
+
+	for deadline := time.Now().Add(testTimeout); !condition(); {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// do serves request with JSON body if body is not empty and returns the
+// response
+func (s *testServer) do(
+	t *testing.T,
+	method, target, body string,
+	header http.Header,
+) *httptest.ResponseRecorder {
+	t.Helper()
+
+	r := httptest.NewRequest(method, target, strings.NewReader(body))
+	if len(body) != 0 {
+		r.Header.Set("Content-Type", "application/json")
+	}
+	for name, values := range header {
+		r.Header[http.CanonicalHeaderKey(name)] = values
+	}
+
+	w := httptest.NewRecorder()
+	s.handler.ServeHTTP(w, r)
+	return w
+}
+
+// decodeResponse checks status of the response and unmarshals its body
+func decodeResponse(t *testing.T, w *httptest.ResponseRecorder, status int, body interface{}) {
+	t.Helper()
+
+	if w.Code != status {
+		t.Fatalf("got status %d, want %d, body: %s", w.Code, status, w.Body.String())
+	}
+	if body == nil {
+		return
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), body); err != nil {
+		t.Fatalf("could not unmarshal body %s: %v", w.Body.String(), err)
+	}
+}
@@ -0,0 +1,83 @@
+package sinks
+
+import (
+	"time"
+
+	"eth-parser/auth"
+)
+
+type Config struct {
+	// CheckpointFile keeps the last blocks published to all sinks by chains,
+	// parsing resumes after them on restart. Blocks are not checkpointed if
+	// empty, so that parsing starts from the latest block.
+	CheckpointFile string `yaml:"checkpoint_file"`
+
+	Kafka KafkaConfig `yaml:"kafka"`
+	NATS  NATSConfig  `yaml:"nats"`
+	Redis RedisConfig `yaml:"redis"`
+}
+
+// Routing maps events to topics of the sink: Kafka topics, NATS subjects or
+// Redis streams
+type Routing struct {
+	// Topic is the topic of events which are not matched by routes. It may
+	// contain {chain_id}, {tenant} and {address} placeholders.
+	Topic string `yaml:"topic"`
+
+	// Routes route events of subscriptions to their own topics, the first
+	// matching route is applied
+	Routes []Route `yaml:"routes,omitempty"`
+}
+
+// Route matches events of the tenant and the address, any tenant or address
+// is matched if it is empty
+type Route struct {
+	Tenant  string `yaml:"tenant,omitempty"`
+	Address string `yaml:"address,omitempty"`
+
+	// Topic may contain the same placeholders as the default one
+	Topic string `yaml:"topic"`
+}
+
+// KafkaConfig configures Kafka sink, it is enabled if Brokers are set
+type KafkaConfig struct {
+	// Brokers are host:port addresses of bootstrap brokers
+	Brokers  []string `yaml:"brokers"`
+	ClientID string   `yaml:"client_id"`
+
+	// Timeout limits every request to a broker, produce requests are
+	// acknowledged by all in-sync replicas
+	Timeout time.Duration `yaml:"timeout"`
+
+	Routing `yaml:",inline"`
+}
+
+// NATSConfig configures NATS sink, it is enabled if URL is set
+type NATSConfig struct {
+	// URL is comma separated list of servers
+	URL string `yaml:"url"`
+
+	// JetStream waits for acknowledgements of streams capturing subjects,
+	// otherwise events are published with core NATS and flushed
+	JetStream bool `yaml:"jetstream"`
+
+	Timeout time.Duration `yaml:"timeout"`
+
+	Routing `yaml:",inline"`
+}
+
+// RedisConfig configures Redis Streams sink, it is enabled if Addr is set
+type RedisConfig struct {
+	Addr     string      `yaml:"addr"`
+	Username string      `yaml:"username"`
+	Password auth.Secret `yaml:"password"`
+	DB       int         `yaml:"db"`
+
+	// MaxLen approximately trims streams to the number of entries, streams
+	// are not trimmed if zero
+	MaxLen int64 `yaml:"max_len"`
+
+	Timeout time.Duration `yaml:"timeout"`
+
+	Routing `yaml:",inline"`
+}
@@ -0,0 +1,155 @@
+package sinks
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+
+	"eth-parser/parser"
+)
+
+// kafkaBatchTimeout is the delay before records buffered for a partition are
+// produced. Events of a block are published at once and Publish waits for
+// them, so that records are not buffered for long.
+const kafkaBatchTimeout = 10 * time.Millisecond
+
+// kafkaBalancer chooses partitions as the default partitioner of Java
+// clients, keys of all events are set, so that partitions are never chosen
+// at random
+var kafkaBalancer = kafka.Murmur2Balancer{Consistent: true}
+
+// KafkaSink produces events to Kafka topics with a writer of every topic,
+// partitions are chosen by the address as by the default partitioner of Java
+// clients. Records are acknowledged by all in-sync replicas.
+type KafkaSink struct {
+	config *KafkaConfig
+	router *router
+	dialer *kafka.Dialer
+
+	// writers are writers of topics, they are created on the first event
+	// of the topic
+	writers map[string]*kafka.Writer
+	// mu serializes publishing of parsers of all chains
+	mu sync.Mutex
+
+	logger *slog.Logger
+}
+
+func NewKafkaSink(config *KafkaConfig, logger *slog.Logger) *KafkaSink {
+	return &KafkaSink{
+		config: config,
+		router: newRouter(&config.Routing),
+		dialer: &kafka.Dialer{
+			ClientID: config.ClientID,
+			Timeout:  config.Timeout,
+		},
+		writers: make(map[string]*kafka.Writer),
+		logger:  logger.With("component", "kafka_sink"),
+	}
+}
+
+// Init connects to any of the bootstrap brokers
+func (k *KafkaSink) Init() error {
+	var errs []error
+	for _, addr := range k.config.Brokers {
+		conn, err := k.dialer.Dial("tcp", addr)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		conn.Close()
+
+		k.logger.Info("initialized kafka sink", "broker", addr)
+		return nil
+	}
+
+	return fmt.Errorf("could not connect to brokers: %w", errors.Join(errs...))
+}
+
+func (k *KafkaSink) Shutdown() error {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	k.reset()
+	return nil
+}
+
+func (k *KafkaSink) Name() string {
+	return "kafka"
+}
+
+func (k *KafkaSink) Publish(events []parser.SinkEvent) error {
+	messages, err := encodeEvents(k.router, events)
+	if err != nil {
+		return err
+	}
+
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	for topic, records := range kafkaRecords(messages) {
+		if err := k.writer(topic).WriteMessages(context.Background(), records...); err != nil {
+			// partitions of topics may be stale, writers get them again
+			// when the events are published again
+			k.reset()
+			return fmt.Errorf("could not produce to %s: %w", topic, err)
+		}
+	}
+
+	return nil
+}
+
+// writer returns writer of the topic, it is created if there is none
+func (k *KafkaSink) writer(topic string) *kafka.Writer {
+	if writer, ok := k.writers[topic]; ok {
+		return writer
+	}
+
+	writer := kafka.NewWriter(kafka.WriterConfig{
+		Brokers:      k.config.Brokers,
+		Topic:        topic,
+		Dialer:       k.dialer,
+		Balancer:     kafkaBalancer,
+		RequiredAcks: -1,
+		// failed events are published again by the parser
+		MaxAttempts:  1,
+		BatchTimeout: kafkaBatchTimeout,
+		ReadTimeout:  k.config.Timeout,
+		WriteTimeout: k.config.Timeout,
+		ErrorLogger: kafka.LoggerFunc(func(format string, args ...interface{}) {
+			k.logger.Warn(fmt.Sprintf(format, args...), "topic", topic)
+		}),
+	})
+	k.writers[topic] = writer
+	return writer
+}
+
+// reset closes writers of all topics
+func (k *KafkaSink) reset() {
+	for topic, writer := range k.writers {
+		if err := writer.Close(); err != nil {
+			k.logger.Error("could not close writer", "topic", topic, "error", err)
+		}
+		delete(k.writers, topic)
+	}
+}
+
+// kafkaRecords groups records of messages by their topics, the ID of the
+// event is passed in id header
+func kafkaRecords(messages []message) map[string][]kafka.Message {
+	records := make(map[string][]kafka.Message)
+	for _, message := range messages {
+		records[message.topic] = append(records[message.topic], kafka.Message{
+			Key:     message.key,
+			Value:   message.value,
+			Headers: []kafka.Header{{Key: "id", Value: []byte(message.id)}},
+		})
+	}
+
+	return records
+}
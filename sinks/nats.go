@@ -0,0 +1,144 @@
+package sinks
+
+import (
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/nats-io/nats.go"
+
+	"eth-parser/parser"
+)
+
+const (
+	// natsClientName identifies the connection in monitoring of NATS servers
+	natsClientName = "eth-parser"
+	// natsKeyHeader passes the key of messages, the address of the event
+	natsKeyHeader = "Eth-Address"
+)
+
+// NATSSink publishes events to NATS subjects. With JetStream publishing waits
+// for acknowledgements of streams and the ID of the event is passed in
+// Nats-Msg-Id header, so that streams deduplicate events published again.
+type NATSSink struct {
+	config *NATSConfig
+	router *router
+
+	conn *nats.Conn
+	js   nats.JetStreamContext
+
+	logger *slog.Logger
+}
+
+func NewNATSSink(config *NATSConfig, logger *slog.Logger) *NATSSink {
+	return &NATSSink{
+		config: config,
+		router: newRouter(&config.Routing),
+		logger: logger.With("component", "nats_sink"),
+	}
+}
+
+func (n *NATSSink) Init() error {
+	conn, err := nats.Connect(n.config.URL,
+		nats.Name(natsClientName),
+		nats.Timeout(n.config.Timeout),
+		nats.MaxReconnects(-1),
+	)
+	if err != nil {
+		return fmt.Errorf("could not connect: %w", err)
+	}
+	n.conn = conn
+
+	if n.config.JetStream {
+		js, err := conn.JetStream(nats.MaxWait(n.config.Timeout))
+		if err != nil {
+			conn.Close()
+			return fmt.Errorf("could not create JetStream context: %w", err)
+		}
+		n.js = js
+	}
+
+	n.logger.Info("initialized nats sink",
+		"server", conn.ConnectedUrlRedacted(), "jetstream", n.config.JetStream)
+	return nil
+}
+
+func (n *NATSSink) Shutdown() error {
+	if n.conn == nil {
+		return nil
+	}
+
+	if err := n.conn.Drain(); err != nil {
+		return fmt.Errorf("could not drain connection: %w", err)
+	}
+
+	return nil
+}
+
+func (n *NATSSink) Name() string {
+	return "nats"
+}
+
+func (n *NATSSink) Publish(events []parser.SinkEvent) error {
+	if n.conn == nil {
+		return ErrUninitialized
+	}
+
+	messages, err := encodeEvents(n.router, events)
+	if err != nil {
+		return err
+	}
+
+	msgs := make([]*nats.Msg, 0, len(messages))
+	for _, message := range messages {
+		msg := nats.NewMsg(message.topic)
+		msg.Header.Set(nats.MsgIdHdr, message.id)
+		msg.Header.Set(natsKeyHeader, string(message.key))
+		msg.Data = message.value
+		msgs = append(msgs, msg)
+	}
+
+	if n.js != nil {
+		return n.publishJetStream(msgs)
+	}
+
+	for _, msg := range msgs {
+		if err := n.conn.PublishMsg(msg); err != nil {
+			return fmt.Errorf("could not publish to %s: %w", msg.Subject, err)
+		}
+	}
+
+	// core NATS does not acknowledge messages, flush ensures at least that
+	// the server got them
+	if err := n.conn.FlushTimeout(n.config.Timeout); err != nil {
+		return fmt.Errorf("could not flush: %w", err)
+	}
+
+	return nil
+}
+
+// publishJetStream publishes messages asynchronously and waits for all
+// acknowledgements
+func (n *NATSSink) publishJetStream(msgs []*nats.Msg) error {
+	futures := make([]nats.PubAckFuture, 0, len(msgs))
+	for _, msg := range msgs {
+		future, err := n.js.PublishMsgAsync(msg)
+		if err != nil {
+			return fmt.Errorf("could not publish to %s: %w", msg.Subject, err)
+		}
+		futures = append(futures, future)
+	}
+
+	timeout := time.After(n.config.Timeout)
+	for _, future := range futures {
+		select {
+		case <-future.Ok():
+		case err := <-future.Err():
+			return fmt.Errorf("could not publish to %s: %w", future.Msg().Subject, err)
+		case <-timeout:
+			return fmt.Errorf("timed out waiting for acknowledgements")
+		}
+	}
+
+	return nil
+}
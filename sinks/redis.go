@@ -0,0 +1,100 @@
+package sinks
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	"github.com/redis/go-redis/v9"
+
+	"eth-parser/parser"
+)
+
+// RedisSink appends events to Redis streams, entries have id, address and
+// event fields
+type RedisSink struct {
+	config *RedisConfig
+	router *router
+
+	client *redis.Client
+
+	logger *slog.Logger
+}
+
+func NewRedisSink(config *RedisConfig, logger *slog.Logger) *RedisSink {
+	return &RedisSink{
+		config: config,
+		router: newRouter(&config.Routing),
+		logger: logger.With("component", "redis_sink"),
+	}
+}
+
+func (r *RedisSink) Init() error {
+	r.client = redis.NewClient(&redis.Options{
+		Addr:         r.config.Addr,
+		Username:     r.config.Username,
+		Password:     string(r.config.Password),
+		DB:           r.config.DB,
+		DialTimeout:  r.config.Timeout,
+		ReadTimeout:  r.config.Timeout,
+		WriteTimeout: r.config.Timeout,
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), r.config.Timeout)
+	defer cancel()
+
+	if err := r.client.Ping(ctx).Err(); err != nil {
+		r.client.Close()
+		return fmt.Errorf("could not ping: %w", err)
+	}
+
+	r.logger.Info("initialized redis sink", "addr", r.config.Addr)
+	return nil
+}
+
+func (r *RedisSink) Shutdown() error {
+	if r.client == nil {
+		return nil
+	}
+
+	return r.client.Close()
+}
+
+func (r *RedisSink) Name() string {
+	return "redis"
+}
+
+// Publish appends events with a single pipeline
+func (r *RedisSink) Publish(events []parser.SinkEvent) error {
+	if r.client == nil {
+		return ErrUninitialized
+	}
+
+	messages, err := encodeEvents(r.router, events)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), r.config.Timeout)
+	defer cancel()
+
+	pipe := r.client.Pipeline()
+	for _, message := range messages {
+		pipe.XAdd(ctx, &redis.XAddArgs{
+			Stream: message.topic,
+			MaxLen: r.config.MaxLen,
+			Approx: r.config.MaxLen > 0,
+			Values: []interface{}{
+				"id", message.id,
+				"address", string(message.key),
+				"event", message.value,
+			},
+		})
+	}
+
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("could not append events: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,105 @@
+// Package sinks publishes transactions stored by the parser to Kafka, NATS
+// and Redis Streams
+package sinks
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+
+	"eth-parser/parser"
+)
+
+var (
+	ErrUninitialized = fmt.Errorf("sink is uninitialized")
+)
+
+// router resolves topics of events with routing of the sink
+type router struct {
+	routing *Routing
+}
+
+func newRouter(routing *Routing) *router {
+	return &router{routing: routing}
+}
+
+// topic returns topic of the first route matching the event or the default
+// one with placeholders replaced
+func (r *router) topic(event *parser.SinkEvent) string {
+	topic := r.routing.Topic
+	for _, route := range r.routing.Routes {
+		if route.matches(event) {
+			topic = route.Topic
+			break
+		}
+	}
+
+	return strings.NewReplacer(
+		"{chain_id}", strconv.FormatUint(event.ChainID, 10),
+		"{tenant}", event.Tenant,
+		"{address}", event.Address,
+	).Replace(topic)
+}
+
+func (r *Route) matches(event *parser.SinkEvent) bool {
+	if len(r.Tenant) != 0 && r.Tenant != event.Tenant {
+		return false
+	}
+
+	return len(r.Address) == 0 || strings.EqualFold(r.Address, event.Address)
+}
+
+// message is an event encoded for publishing, events of an address are
+// published with the same key so that they keep their order. ID is the same
+// when the event is published again, so that consumers may deduplicate it.
+type message struct {
+	topic string
+	id    string
+	key   []byte
+	value []byte
+}
+
+// encodeEvents encodes events to messages routed to their topics
+func encodeEvents(router *router, events []parser.SinkEvent) ([]message, error) {
+	messages := make([]message, 0, len(events))
+	for i := range events {
+		value, err := json.Marshal(&events[i])
+		if err != nil {
+			return nil, fmt.Errorf("could not marshal event: %w", err)
+		}
+
+		messages = append(messages, message{
+			topic: router.topic(&events[i]),
+			id:    eventID(&events[i]),
+			key:   []byte(events[i].Address),
+			value: value,
+		})
+	}
+
+	return messages, nil
+}
+
+// eventID identifies the event by the subscription, the transaction and its
+// status, which changes when pending transaction is mined or dropped
+func eventID(event *parser.SinkEvent) string {
+	return fmt.Sprintf("%d:%s:%s:%s:%s", event.ChainID, event.Tenant, event.Address,
+		event.Transaction.Hash, event.Transaction.Status)
+}
+
+// New creates configured sinks, they must be initialized before use
+func New(config *Config, logger *slog.Logger) []parser.Sink {
+	var sinks []parser.Sink
+	if len(config.Kafka.Brokers) != 0 {
+		sinks = append(sinks, NewKafkaSink(&config.Kafka, logger))
+	}
+	if len(config.NATS.URL) != 0 {
+		sinks = append(sinks, NewNATSSink(&config.NATS, logger))
+	}
+	if len(config.Redis.Addr) != 0 {
+		sinks = append(sinks, NewRedisSink(&config.Redis, logger))
+	}
+
+	return sinks
+}
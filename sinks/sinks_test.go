@@ -0,0 +1,418 @@
+package sinks
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/nats-io/nats.go"
+	"github.com/segmentio/kafka-go"
+
+	"eth-parser/eth"
+	"eth-parser/parser"
+)
+
+// kafkaBrokersEnv and natsURLEnv name the variables with comma separated
+// Kafka brokers and NATS URL the sinks are tested against, the tests are
+// skipped if they are not set
+const (
+	kafkaBrokersEnv = "ETH_PARSER_TEST_KAFKA_BROKERS"
+	natsURLEnv      = "ETH_PARSER_TEST_NATS_URL"
+)
+
+const testTimeout = time.Second
+
+var testLogger = slog.New(slog.NewTextHandler(io.Discard, nil))
+
+func testEvents() []parser.SinkEvent {
+	return []parser.SinkEvent{
+		{
+			ChainID:     1,
+			Tenant:      "alice",
+			Address:     "0x01",
+			BlockNumber: "0x10",
+			Transaction: eth.Transaction{Hash: "0xaa", From: "0x01", To: "0x02", Status: "mined"},
+		},
+		{
+			ChainID:     1,
+			Tenant:      "bob",
+			Address:     "0x02",
+			BlockNumber: "0x10",
+			Transaction: eth.Transaction{Hash: "0xaa", From: "0x01", To: "0x02", Status: "mined"},
+		},
+		{
+			ChainID:     1,
+			Tenant:      "alice",
+			Address:     "0x01",
+			Transaction: eth.Transaction{Hash: "0xbb", From: "0x01", To: "0x03", Status: "pending"},
+		},
+	}
+}
+
+func TestRouter(t *testing.T) {
+	router := newRouter(&Routing{
+		Topic: "txs.{chain_id}.{tenant}",
+		Routes: []Route{
+			{Tenant: "alice", Address: "0xAB", Topic: "alice-ab"},
+			{Address: "0xab", Topic: "ab.{tenant}"},
+			{Tenant: "bob", Topic: "bob"},
+		},
+	})
+
+	testCases := []struct {
+		tenant   string
+		address  string
+		expected string
+	}{
+		{tenant: "alice", address: "0xab", expected: "alice-ab"},
+		{tenant: "carol", address: "0xab", expected: "ab.carol"},
+		{tenant: "bob", address: "0xab", expected: "ab.bob"},
+		{tenant: "bob", address: "0xcd", expected: "bob"},
+		{tenant: "carol", address: "0xcd", expected: "txs.5.carol"},
+	}
+
+	for _, tc := range testCases {
+		event := &parser.SinkEvent{ChainID: 5, Tenant: tc.tenant, Address: tc.address}
+		if topic := router.topic(event); topic != tc.expected {
+			t.Errorf("got topic %s of %s/%s, want %s", topic, tc.tenant, tc.address, tc.expected)
+		}
+	}
+}
+
+// TestKafkaRecords checks that records are routed to topics, keyed by the
+// address and partitioned as by the default partitioner of Java clients
+func TestKafkaRecords(t *testing.T) {
+	router := newRouter(&Routing{
+		Topic:  "txs-{tenant}",
+		Routes: []Route{{Address: "0x02", Topic: "watched"}},
+	})
+	messages, err := encodeEvents(router, testEvents())
+	if err != nil {
+		t.Fatalf("could not encode events: %v", err)
+	}
+
+	topics := make(map[string]int)
+	for topic, records := range kafkaRecords(messages) {
+		topics[topic] += len(records)
+
+		for _, record := range records {
+			event := parser.SinkEvent{}
+			if err := json.Unmarshal(record.Value, &event); err != nil {
+				t.Fatalf("could not unmarshal event: %v", err)
+			}
+			if event.Address != string(record.Key) || len(record.Headers) != 1 ||
+				string(record.Headers[0].Value) != eventID(&event) {
+				t.Errorf("record %s/%v does not match event %+v", record.Key, record.Headers, event)
+			}
+		}
+	}
+
+	expected := map[string]int{"txs-alice": 2, "watched": 1}
+	if !reflect.DeepEqual(topics, expected) {
+		t.Errorf("topics are not equal:\nhave: %v\nwant: %v", topics, expected)
+	}
+
+	// values of Utils.murmur2 of Kafka clients
+	hashes := map[string]int32{
+		"21":                         -973932308,
+		"foobar":                     -790332482,
+		"a-little-bit-long-string":   -985981536,
+		"a-little-bit-longer-string": -1486304829,
+		"abc":                        479470107,
+	}
+	partitions := []int{0, 1, 2, 3, 4, 5, 6}
+	for key, hash := range hashes {
+		expected := int(hash&0x7fffffff) % len(partitions)
+		partition := kafkaBalancer.Balance(kafka.Message{Key: []byte(key)}, partitions...)
+		if partition != expected {
+			t.Errorf("got partition %d of %s, want %d", partition, key, expected)
+		}
+	}
+}
+
+// TestKafkaSink produces to topics created on the brokers from
+// kafkaBrokersEnv, it is skipped if it is not set
+func TestKafkaSink(t *testing.T) {
+	brokers := os.Getenv(kafkaBrokersEnv)
+	if len(brokers) == 0 {
+		t.Skipf("%s is not set", kafkaBrokersEnv)
+	}
+	addrs := strings.Split(brokers, ",")
+
+	// topics are unique, so that records of previous runs are not seen
+	prefix := fmt.Sprintf("eth-parser-test-%d", time.Now().UnixNano())
+	topics := []string{prefix + "-alice", prefix + "-watched"}
+	createKafkaTopics(t, addrs[0], topics, 4)
+
+	// unavailable bootstrap broker is skipped
+	sink := NewKafkaSink(&KafkaConfig{
+		Brokers: append([]string{"127.0.0.1:1"}, addrs...),
+		Timeout: 10 * testTimeout,
+		Routing: Routing{
+			Topic:  prefix + "-{tenant}",
+			Routes: []Route{{Address: "0x02", Topic: prefix + "-watched"}},
+		},
+	}, testLogger)
+	if err := sink.Init(); err != nil {
+		t.Fatalf("could not init: %v", err)
+	}
+	defer sink.Shutdown()
+
+	events := testEvents()
+	if err := sink.Publish(events); err != nil {
+		t.Fatalf("could not publish: %v", err)
+	}
+
+	read := make(map[string]int)
+	for _, topic := range topics {
+		for partition := 0; partition < 4; partition++ {
+			for _, record := range readKafkaPartition(t, addrs[0], topic, partition) {
+				read[topic]++
+
+				event := parser.SinkEvent{}
+				if err := json.Unmarshal(record.Value, &event); err != nil {
+					t.Fatalf("could not unmarshal event: %v", err)
+				}
+				if event.Address != string(record.Key) || len(record.Headers) != 1 ||
+					string(record.Headers[0].Value) != eventID(&event) {
+					t.Errorf("record %s/%v does not match event %+v",
+						record.Key, record.Headers, event)
+				}
+
+				expected := kafkaBalancer.Balance(record, 0, 1, 2, 3)
+				if partition != expected {
+					t.Errorf("record %s is in partition %d, want %d", record.Key, partition, expected)
+				}
+			}
+		}
+	}
+
+	expected := map[string]int{prefix + "-alice": 2, prefix + "-watched": 1}
+	if !reflect.DeepEqual(read, expected) {
+		t.Errorf("topics are not equal:\nhave: %v\nwant: %v", read, expected)
+	}
+}
+
+// createKafkaTopics creates topics with the controller of the cluster
+func createKafkaTopics(t *testing.T, addr string, topics []string, partitions int) {
+	t.Helper()
+
+	conn, err := kafka.Dial("tcp", addr)
+	if err != nil {
+		t.Fatalf("could not connect: %v", err)
+	}
+	defer conn.Close()
+
+	controller, err := conn.Controller()
+	if err != nil {
+		t.Fatalf("could not get controller: %v", err)
+	}
+	controllerConn, err := kafka.Dial("tcp",
+		net.JoinHostPort(controller.Host, strconv.Itoa(controller.Port)))
+	if err != nil {
+		t.Fatalf("could not connect to controller: %v", err)
+	}
+	defer controllerConn.Close()
+
+	configs := make([]kafka.TopicConfig, len(topics))
+	for i, topic := range topics {
+		configs[i] = kafka.TopicConfig{
+			Topic:             topic,
+			NumPartitions:     partitions,
+			ReplicationFactor: 1,
+		}
+	}
+	if err := controllerConn.CreateTopics(configs...); err != nil {
+		t.Fatalf("could not create topics: %v", err)
+	}
+}
+
+// readKafkaPartition reads all records of the partition from its leader
+func readKafkaPartition(t *testing.T, addr, topic string, partition int) []kafka.Message {
+	t.Helper()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*testTimeout)
+	defer cancel()
+
+	conn, err := kafka.DialLeader(ctx, "tcp", addr, topic, partition)
+	if err != nil {
+		t.Fatalf("could not connect to leader of %s/%d: %v", topic, partition, err)
+	}
+	defer conn.Close()
+
+	last, err := conn.ReadLastOffset()
+	if err != nil {
+		t.Fatalf("could not read offset of %s/%d: %v", topic, partition, err)
+	}
+	if last == 0 {
+		return nil
+	}
+
+	conn.SetReadDeadline(time.Now().Add(10 * testTimeout))
+	batch := conn.ReadBatch(1, 1<<20)
+	defer batch.Close()
+
+	var records []kafka.Message
+	for int64(len(records)) < last {
+		record, err := batch.ReadMessage()
+		if err != nil {
+			t.Fatalf("could not read %s/%d: %v", topic, partition, err)
+		}
+		record.Partition = partition
+		records = append(records, record)
+	}
+
+	return records
+}
+
+// TestNATSSink publishes to the server from natsURLEnv with core NATS and
+// with JetStream, it is skipped if it is not set
+func TestNATSSink(t *testing.T) {
+	url := os.Getenv(natsURLEnv)
+	if len(url) == 0 {
+		t.Skipf("%s is not set", natsURLEnv)
+	}
+
+	conn, err := nats.Connect(url)
+	if err != nil {
+		t.Fatalf("could not connect: %v", err)
+	}
+	defer conn.Close()
+
+	// subjects are unique, so that messages of previous runs are not seen
+	prefix := fmt.Sprintf("eth-parser-test-%d", time.Now().UnixNano())
+	subscription, err := conn.SubscribeSync(prefix + ".>")
+	if err != nil {
+		t.Fatalf("could not subscribe: %v", err)
+	}
+	if err := conn.Flush(); err != nil {
+		t.Fatalf("could not flush: %v", err)
+	}
+
+	js, err := conn.JetStream()
+	if err != nil {
+		t.Fatalf("could not create JetStream context: %v", err)
+	}
+	if _, err := js.AddStream(&nats.StreamConfig{
+		Name:     prefix,
+		Subjects: []string{prefix + ".>"},
+	}); err != nil {
+		t.Fatalf("could not add stream: %v", err)
+	}
+	defer js.DeleteStream(prefix)
+
+	// events published again with JetStream are deduplicated, so that every
+	// event is stored once by core publishing and once by JetStream
+	events := testEvents()
+	for _, jetStream := range []bool{false, true, true} {
+		sink := NewNATSSink(&NATSConfig{
+			URL:       url,
+			JetStream: jetStream,
+			Timeout:   5 * testTimeout,
+			Routing:   Routing{Topic: prefix + ".{chain_id}.{tenant}"},
+		}, testLogger)
+		if err := sink.Init(); err != nil {
+			t.Fatalf("could not init: %v", err)
+		}
+		if err := sink.Publish(events); err != nil {
+			t.Fatalf("could not publish: %v", err)
+		}
+		if err := sink.Shutdown(); err != nil {
+			t.Errorf("could not shutdown: %v", err)
+		}
+	}
+
+	info, err := js.StreamInfo(prefix)
+	if err != nil {
+		t.Fatalf("could not get stream info: %v", err)
+	}
+	if info.State.Msgs != 2*uint64(len(events)) {
+		t.Errorf("got %d messages in stream, want %d", info.State.Msgs, 2*len(events))
+	}
+
+	subjects := make(map[string]int)
+	for i := 0; i < 3*len(events); i++ {
+		message, err := subscription.NextMsg(5 * testTimeout)
+		if err != nil {
+			t.Fatalf("could not get message %d: %v", i, err)
+		}
+		subjects[strings.TrimPrefix(message.Subject, prefix+".")]++
+
+		event := parser.SinkEvent{}
+		if err := json.Unmarshal(message.Data, &event); err != nil {
+			t.Fatalf("could not unmarshal event: %v", err)
+		}
+		if message.Header.Get(nats.MsgIdHdr) != eventID(&event) ||
+			message.Header.Get(natsKeyHeader) != event.Address {
+			t.Errorf("headers %v do not match event %+v", message.Header, event)
+		}
+	}
+
+	expected := map[string]int{"1.alice": 6, "1.bob": 3}
+	if !reflect.DeepEqual(subjects, expected) {
+		t.Errorf("subjects are not equal:\nhave: %v\nwant: %v", subjects, expected)
+	}
+}
+
+func TestRedisSink(t *testing.T) {
+	redis := miniredis.RunT(t)
+
+	sink := NewRedisSink(&RedisConfig{
+		Addr:    redis.Addr(),
+		MaxLen:  2,
+		Timeout: testTimeout,
+		Routing: Routing{
+			Topic:  "txs",
+			Routes: []Route{{Tenant: "bob", Topic: "bob:{address}"}},
+		},
+	}, testLogger)
+	if err := sink.Init(); err != nil {
+		t.Fatalf("could not init: %v", err)
+	}
+	defer sink.Shutdown()
+
+	events := testEvents()
+	if err := sink.Publish(events); err != nil {
+		t.Fatalf("could not publish: %v", err)
+	}
+
+	entries, err := redis.Stream("txs")
+	if err != nil {
+		t.Fatalf("could not get stream: %v", err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("got %d entries, want 2", len(entries))
+	}
+
+	entries, err = redis.Stream("bob:0x02")
+	if err != nil {
+		t.Fatalf("could not get stream: %v", err)
+	}
+	if len(entries) != 1 {
+		t.Fatalf("got %d entries, want 1", len(entries))
+	}
+
+	values := entries[0].Values
+	if len(values) != 6 || values[0] != "id" || values[1] != eventID(&events[1]) ||
+		values[3] != "0x02" {
+		t.Errorf("got entry %v of event %+v", values, events[1])
+	}
+
+	event := parser.SinkEvent{}
+	if err := json.Unmarshal([]byte(values[5]), &event); err != nil {
+		t.Fatalf("could not unmarshal event: %v", err)
+	}
+	if !reflect.DeepEqual(event, events[1]) {
+		t.Errorf("events are not equal:\nhave: %+v\nwant: %+v", event, events[1])
+	}
+}
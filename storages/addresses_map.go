@@ -1,19 +1,40 @@
 package storages
 
 import (
+	"log/slog"
 	"sync"
 )
 
+// addressesShardsNum is the number of shards of addresses storage, lookups
+// of the parser and subscribes contend only within a shard
+const addressesShardsNum = 256
+
+type addressesShard struct {
+	// storage maps address to the set of tenants subscribed to it
+	storage map[string]map[string]struct{}
+	mu      sync.RWMutex
+}
+
 type AddressesMapStorage struct {
-	storage   map[string]struct{}
-	storageMu sync.RWMutex
+	shards [addressesShardsNum]addressesShard
+
+	counts   map[string]int
+	countsMu sync.RWMutex
+
+	logger *slog.Logger
 }
 
-func NewAddressesMapStorage() *AddressesMapStorage {
-	return &AddressesMapStorage{
-		storage:   make(map[string]struct{}, initialStorageCap),
-		storageMu: sync.RWMutex{},
+func NewAddressesMapStorage(logger *slog.Logger) *AddressesMapStorage {
+	m := &AddressesMapStorage{
+		counts: make(map[string]int),
+		logger: logger.With("component", "addresses_map_storage"),
 	}
+	for i := range m.shards {
+		m.shards[i].storage = make(map[string]map[string]struct{},
+			initialStorageCap/addressesShardsNum)
+	}
+
+	return m
 }
 
 func (m *AddressesMapStorage) Init() error {
@@ -24,26 +45,210 @@ func (m *AddressesMapStorage) Shutdown() error {
 	return nil
 }
 
-func (m *AddressesMapStorage) Store(address string) error {
+func (m *AddressesMapStorage) Store(tenant, address string) error {
 	if m == nil {
 		return ErrUninitialized
 	}
 
-	m.storageMu.Lock()
-	defer m.storageMu.Unlock()
+	shard := m.shard(address)
+	shard.mu.Lock()
+	stored := shard.store(tenant, address)
+	shard.mu.Unlock()
+
+	if !stored {
+		return nil
+	}
+
+	m.countsMu.Lock()
+	m.counts[tenant]++
+	count := m.counts[tenant]
+	m.countsMu.Unlock()
 
-	m.storage[address] = struct{}{}
+	m.logger.Debug("stored address", "tenant", tenant, "address", address, "addresses", count)
 	return nil
 }
 
-func (m *AddressesMapStorage) Check(address string) bool {
+// StoreBatch stores addresses grouped by shards, so that every shard is
+// locked once
+func (m *AddressesMapStorage) StoreBatch(tenant string, addresses []string) error {
 	if m == nil {
+		return ErrUninitialized
+	}
+
+	byShard := make(map[*addressesShard][]string)
+	for _, address := range addresses {
+		shard := m.shard(address)
+		byShard[shard] = append(byShard[shard], address)
+	}
+
+	stored := 0
+	for shard, shardAddresses := range byShard {
+		shard.mu.Lock()
+		for _, address := range shardAddresses {
+			if shard.store(tenant, address) {
+				stored++
+			}
+		}
+		shard.mu.Unlock()
+	}
+
+	m.countsMu.Lock()
+	m.counts[tenant] += stored
+	count := m.counts[tenant]
+	m.countsMu.Unlock()
+
+	m.logger.Debug("stored batch of addresses",
+		"tenant", tenant, "stored", stored, "addresses", count)
+	return nil
+}
+
+func (m *AddressesMapStorage) Delete(tenant, address string) error {
+	if m == nil {
+		return ErrUninitialized
+	}
+
+	shard := m.shard(address)
+	shard.mu.Lock()
+	deleted := shard.delete(tenant, address)
+	shard.mu.Unlock()
+
+	if !deleted {
+		return nil
+	}
+
+	m.countsMu.Lock()
+	m.counts[tenant]--
+	count := m.counts[tenant]
+	m.countsMu.Unlock()
+
+	m.logger.Debug("deleted address", "tenant", tenant, "address", address, "addresses", count)
+	return nil
+}
+
+func (m *AddressesMapStorage) Tenants(address string) []string {
+	if m == nil {
+		return nil
+	}
+
+	shard := m.shard(address)
+	shard.mu.RLock()
+	defer shard.mu.RUnlock()
+
+	tenants, ok := shard.storage[address]
+	if !ok {
+		return nil
+	}
+
+	result := make([]string, 0, len(tenants))
+	for tenant := range tenants {
+		result = append(result, tenant)
+	}
+	return result
+}
+
+func (m *AddressesMapStorage) Count(tenant string) (int, error) {
+	if m == nil {
+		return 0, ErrUninitialized
+	}
+
+	m.countsMu.RLock()
+	defer m.countsMu.RUnlock()
+
+	return m.counts[tenant], nil
+}
+
+// TenantAddresses returns addresses subscribed by tenant, all shards are
+// scanned
+func (m *AddressesMapStorage) TenantAddresses(tenant string) ([]string, error) {
+	if m == nil {
+		return nil, ErrUninitialized
+	}
+
+	var addresses []string
+	for i := range m.shards {
+		shard := &m.shards[i]
+
+		shard.mu.RLock()
+		for address, tenants := range shard.storage {
+			if _, ok := tenants[tenant]; ok {
+				addresses = append(addresses, address)
+			}
+		}
+		shard.mu.RUnlock()
+	}
+
+	return addresses, nil
+}
+
+// Addresses returns all stored addresses
+func (m *AddressesMapStorage) Addresses() ([]string, error) {
+	if m == nil {
+		return nil, ErrUninitialized
+	}
+
+	var addresses []string
+	for i := range m.shards {
+		shard := &m.shards[i]
+
+		shard.mu.RLock()
+		for address := range shard.storage {
+			addresses = append(addresses, address)
+		}
+		shard.mu.RUnlock()
+	}
+
+	return addresses, nil
+}
+
+func (m *AddressesMapStorage) shard(address string) *addressesShard {
+	return &m.shards[fnv32a(address)%addressesShardsNum]
+}
+
+// store stores address and reports whether it was not stored before, must be
+// called under lock
+func (s *addressesShard) store(tenant, address string) bool {
+	tenants, ok := s.storage[address]
+	if !ok {
+		tenants = make(map[string]struct{}, 1)
+		s.storage[address] = tenants
+	}
+	if _, ok := tenants[tenant]; ok {
 		return false
 	}
 
-	m.storageMu.RLock()
-	defer m.storageMu.RUnlock()
+	tenants[tenant] = struct{}{}
+	return true
+}
 
-	_, ok := m.storage[address]
-	return ok
+// delete deletes address and reports whether it was stored, must be called
+// under lock
+func (s *addressesShard) delete(tenant, address string) bool {
+	tenants, ok := s.storage[address]
+	if !ok {
+		return false
+	}
+	if _, ok := tenants[tenant]; !ok {
+		return false
+	}
+
+	delete(tenants, tenant)
+	if len(tenants) == 0 {
+		delete(s.storage, address)
+	}
+	return true
+}
+
+// fnv32a is FNV-1a hash of the string, it does not allocate unlike hash/fnv
+func fnv32a(s string) uint32 {
+	const (
+		offset32 = 2166136261
+		prime32  = 16777619
+	)
+
+	hash := uint32(offset32)
+	for i := 0; i < len(s); i++ {
+		hash ^= uint32(s[i])
+		hash *= prime32
+	}
+	return hash
 }
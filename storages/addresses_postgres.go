@@ -0,0 +1,382 @@
+package storages
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"sync/atomic"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// postgresNotifyPayloadLimit is the max size of NOTIFY payload, changes with
+// larger payloads are notified without addresses
+const postgresNotifyPayloadLimit = 7999
+
+// postgresListenRetryInterval is the delay before listening again after the
+// listening connection fails
+const postgresListenRetryInterval = time.Second
+
+// AddressesPostgresStorage keeps addresses of the chain subscribed by tenants
+// in PostgreSQL, so that they are shared by replicas. Lookups of the parser
+// are served from memory: every change is notified on the subscriptions
+// channel, and every replica applies notified changes to its copy. The copy
+// is reloaded whenever the channel is listened again after the connection
+// fails, as changes notified meanwhile are missed. Channels are not supported
+// by connection poolers in transaction mode.
+type AddressesPostgresStorage struct {
+	db      *PostgresDB
+	chainID uint64
+
+	// onStore is called with every address stored in memory
+	onStore func(address string)
+
+	// cache is replaced on reload, changes are applied to the current one
+	cache  atomic.Pointer[AddressesMapStorage]
+	cancel context.CancelFunc
+	done   chan struct{}
+
+	logger *slog.Logger
+}
+
+func NewAddressesPostgresStorage(
+	db *PostgresDB,
+	chainID uint64,
+	logger *slog.Logger,
+) *AddressesPostgresStorage {
+	return &AddressesPostgresStorage{
+		db:      db,
+		chainID: chainID,
+		logger:  logger.With("component", "addresses_postgres_storage"),
+	}
+}
+
+// Init listens to the subscriptions channel and loads subscriptions, the
+// database is initialized by caller as it is shared by storages of all chains
+func (s *AddressesPostgresStorage) Init() error {
+	if s == nil || s.db == nil || s.db.pool == nil {
+		return ErrUninitialized
+	}
+	if s.cancel != nil {
+		// parser retries initialization of all storages if any one fails
+		return nil
+	}
+
+	conn, err := s.listenConn()
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	s.cancel = cancel
+	s.done = make(chan struct{})
+	go s.listen(ctx, conn)
+
+	return nil
+}
+
+func (s *AddressesPostgresStorage) Shutdown() error {
+	if s.cancel == nil {
+		return nil
+	}
+
+	s.cancel()
+	<-s.done
+	return nil
+}
+
+func (s *AddressesPostgresStorage) Store(tenant, address string) error {
+	return s.StoreBatch(tenant, []string{address})
+}
+
+// StoreBatch stores addresses with a single query, already stored ones are
+// skipped, and notifies the change in the same transaction
+func (s *AddressesPostgresStorage) StoreBatch(tenant string, addresses []string) error {
+	if s == nil || s.db == nil || s.db.pool == nil {
+		return ErrUninitialized
+	}
+	if len(addresses) == 0 {
+		return nil
+	}
+
+	var stored int64
+	err := s.change(subscriptionsMessage{Tenant: tenant, Addresses: addresses},
+		func(ctx context.Context, tx pgx.Tx) error {
+			tag, err := tx.Exec(ctx, `INSERT INTO subscriptions (chain_id, tenant, address)
+				SELECT $1, $2, unnest($3::TEXT[])
+				ON CONFLICT DO NOTHING`,
+				int64(s.chainID), tenant, addresses)
+			stored = tag.RowsAffected()
+			return err
+		})
+	if err != nil {
+		return fmt.Errorf("could not store addresses: %w", err)
+	}
+
+	s.logger.Debug("stored addresses",
+		"tenant", tenant, "addresses", len(addresses), "stored", stored)
+	return nil
+}
+
+func (s *AddressesPostgresStorage) Delete(tenant, address string) error {
+	if s == nil || s.db == nil || s.db.pool == nil {
+		return ErrUninitialized
+	}
+
+	err := s.change(
+		subscriptionsMessage{Tenant: tenant, Addresses: []string{address}, Deleted: true},
+		func(ctx context.Context, tx pgx.Tx) error {
+			_, err := tx.Exec(ctx, `DELETE FROM subscriptions
+				WHERE chain_id = $1 AND tenant = $2 AND address = $3`,
+				int64(s.chainID), tenant, address)
+			return err
+		})
+	if err != nil {
+		return fmt.Errorf("could not delete address: %w", err)
+	}
+
+	s.logger.Debug("deleted address", "tenant", tenant, "address", address)
+	return nil
+}
+
+// Tenants returns tenants subscribed to the address from memory
+func (s *AddressesPostgresStorage) Tenants(address string) []string {
+	if s == nil {
+		return nil
+	}
+
+	return s.cache.Load().Tenants(address)
+}
+
+func (s *AddressesPostgresStorage) Count(tenant string) (int, error) {
+	if s == nil || s.db == nil || s.db.pool == nil {
+		return 0, ErrUninitialized
+	}
+
+	ctx, cancel := s.db.context()
+	defer cancel()
+
+	var count int
+	if err := s.db.pool.QueryRow(ctx, `SELECT count(*) FROM subscriptions
+		WHERE chain_id = $1 AND tenant = $2`,
+		int64(s.chainID), tenant).Scan(&count); err != nil {
+		return 0, fmt.Errorf("could not count addresses: %w", err)
+	}
+
+	return count, nil
+}
+
+func (s *AddressesPostgresStorage) TenantAddresses(tenant string) ([]string, error) {
+	if s == nil || s.db == nil || s.db.pool == nil {
+		return nil, ErrUninitialized
+	}
+
+	return s.strings(`SELECT address FROM subscriptions
+		WHERE chain_id = $1 AND tenant = $2`,
+		int64(s.chainID), tenant)
+}
+
+// Addresses returns all stored addresses from memory
+func (s *AddressesPostgresStorage) Addresses() ([]string, error) {
+	if s == nil || s.cache.Load() == nil {
+		return nil, ErrUninitialized
+	}
+
+	return s.cache.Load().Addresses()
+}
+
+// change runs the queries of the change and notifies it in one transaction.
+// The change is applied to memory as soon as it is committed, so that it is
+// seen by the following lookups, and once again when the notification is
+// received.
+func (s *AddressesPostgresStorage) change(
+	message subscriptionsMessage,
+	queries func(ctx context.Context, tx pgx.Tx) error,
+) error {
+	payload, err := json.Marshal(message)
+	if err != nil {
+		return fmt.Errorf("could not marshal change: %w", err)
+	}
+	if len(payload) > postgresNotifyPayloadLimit {
+		// replicas load addresses of the tenant instead
+		payload, err = json.Marshal(subscriptionsMessage{Tenant: message.Tenant})
+		if err != nil {
+			return fmt.Errorf("could not marshal change: %w", err)
+		}
+	}
+
+	ctx, cancel := s.db.context()
+	defer cancel()
+
+	err = pgx.BeginFunc(ctx, s.db.pool, func(tx pgx.Tx) error {
+		if err := queries(ctx, tx); err != nil {
+			return err
+		}
+		_, err := tx.Exec(ctx, `SELECT pg_notify($1, $2)`, s.channel(), string(payload))
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	s.apply(&message)
+	return nil
+}
+
+// listen applies notified changes until ctx is canceled on shutdown, conn
+// is closed and listened again if it fails
+func (s *AddressesPostgresStorage) listen(ctx context.Context, conn *pgx.Conn) {
+	defer close(s.done)
+
+	for {
+		err := s.receive(ctx, conn)
+		conn.Close(context.Background())
+		if ctx.Err() != nil {
+			return
+		}
+		s.logger.Error("could not receive changes, listening again", "error", err)
+
+		for conn = nil; conn == nil; {
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(postgresListenRetryInterval):
+			}
+
+			if conn, err = s.listenConn(); err != nil {
+				s.logger.Error("could not listen to changes, subscriptions may be stale",
+					"error", err)
+			}
+		}
+		s.logger.Info("listened to changes again, reloaded subscriptions")
+	}
+}
+
+// receive applies notifications received by conn until it fails
+func (s *AddressesPostgresStorage) receive(ctx context.Context, conn *pgx.Conn) error {
+	for {
+		notification, err := conn.WaitForNotification(ctx)
+		if err != nil {
+			return err
+		}
+
+		change := subscriptionsMessage{}
+		if err := json.Unmarshal([]byte(notification.Payload), &change); err != nil {
+			s.logger.Error("could not unmarshal change", "error", err)
+			continue
+		}
+		if len(change.Addresses) == 0 {
+			if change.Addresses, err = s.TenantAddresses(change.Tenant); err != nil {
+				s.logger.Error("could not load addresses of change, they may be stale",
+					"tenant", change.Tenant, "error", err)
+				continue
+			}
+		}
+		s.apply(&change)
+	}
+}
+
+// listenConn takes a connection out of the pool, listens to the
+// subscriptions channel with it and reloads subscriptions, as changes
+// notified before are missed
+func (s *AddressesPostgresStorage) listenConn() (*pgx.Conn, error) {
+	ctx, cancel := s.db.context()
+	defer cancel()
+
+	pooled, err := s.db.pool.Acquire(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("could not acquire connection: %w", err)
+	}
+	conn := pooled.Hijack()
+
+	if _, err := conn.Exec(ctx, "LISTEN "+pgx.Identifier{s.channel()}.Sanitize()); err != nil {
+		conn.Close(context.Background())
+		return nil, fmt.Errorf("could not listen to changes: %w", err)
+	}
+
+	if err := s.reload(); err != nil {
+		conn.Close(context.Background())
+		return nil, err
+	}
+
+	return conn, nil
+}
+
+// apply applies change to memory, changes may be applied more than once
+func (s *AddressesPostgresStorage) apply(change *subscriptionsMessage) {
+	cache := s.cache.Load()
+	if change.Deleted {
+		for _, address := range change.Addresses {
+			cache.Delete(change.Tenant, address)
+		}
+		return
+	}
+
+	s.stored(change.Addresses...)
+	cache.StoreBatch(change.Tenant, change.Addresses)
+}
+
+// OnStore sets fn called with every address stored by any replica before it
+// is applied to memory, and with every reloaded address, it must be called
+// before Init
+func (s *AddressesPostgresStorage) OnStore(fn func(address string)) {
+	s.onStore = fn
+}
+
+func (s *AddressesPostgresStorage) stored(addresses ...string) {
+	if s.onStore == nil {
+		return
+	}
+	for _, address := range addresses {
+		s.onStore(address)
+	}
+}
+
+// reload loads all subscriptions of the chain into new memory storage, which
+// replaces the current one
+func (s *AddressesPostgresStorage) reload() error {
+	ctx, cancel := s.db.context()
+	defer cancel()
+
+	rows, err := s.db.pool.Query(ctx, `SELECT tenant, address FROM subscriptions
+		WHERE chain_id = $1`, int64(s.chainID))
+	if err != nil {
+		return fmt.Errorf("could not load subscriptions: %w", err)
+	}
+
+	cache := NewAddressesMapStorage(s.logger)
+	subscriptions := 0
+	var tenant, address string
+	if _, err := pgx.ForEachRow(rows, []any{&tenant, &address}, func() error {
+		subscriptions++
+		s.stored(address)
+		return cache.Store(tenant, address)
+	}); err != nil {
+		return fmt.Errorf("could not load subscriptions: %w", err)
+	}
+
+	s.cache.Store(cache)
+
+	s.logger.Info("loaded subscriptions", "subscriptions", subscriptions)
+	return nil
+}
+
+// channel returns the channel changes of subscriptions are notified on
+func (s *AddressesPostgresStorage) channel() string {
+	return fmt.Sprintf("eth_parser_subscriptions_%d", s.chainID)
+}
+
+// strings returns values of the single text column selected by query
+func (s *AddressesPostgresStorage) strings(query string, args ...any) ([]string, error) {
+	ctx, cancel := s.db.context()
+	defer cancel()
+
+	rows, err := s.db.pool.Query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("could not query: %w", err)
+	}
+
+	return pgx.CollectRows(rows, pgx.RowTo[string])
+}
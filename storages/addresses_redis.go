@@ -0,0 +1,350 @@
+package storages
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync/atomic"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// redisScanCount is the number of keys requested by every SCAN of reload
+const redisScanCount = 1000
+
+// subscriptionsMessage is published on every change of subscriptions
+type subscriptionsMessage struct {
+	Tenant    string   `json:"tenant"`
+	Addresses []string `json:"addresses"`
+	Deleted   bool     `json:"deleted,omitempty"`
+}
+
+// AddressesRedisStorage keeps addresses of the chain subscribed by tenants in
+// Redis sets of tenants by addresses and of addresses by tenants, so that
+// they are shared by replicas. Lookups of the parser are served from memory:
+// every change is published to the subscriptions channel, and every replica
+// applies published changes to its copy. The copy is reloaded whenever the
+// channel is subscribed again after reconnection, as changes published
+// meanwhile are missed.
+type AddressesRedisStorage struct {
+	db      *RedisDB
+	chainID uint64
+
+	// onStore is called with every address stored in memory
+	onStore func(address string)
+
+	// cache is replaced on reload, changes are applied to the current one
+	cache  atomic.Pointer[AddressesMapStorage]
+	pubsub *redis.PubSub
+	done   chan struct{}
+
+	logger *slog.Logger
+}
+
+func NewAddressesRedisStorage(
+	db *RedisDB,
+	chainID uint64,
+	logger *slog.Logger,
+) *AddressesRedisStorage {
+	return &AddressesRedisStorage{
+		db:      db,
+		chainID: chainID,
+		logger:  logger.With("component", "addresses_redis_storage"),
+	}
+}
+
+// Init subscribes to the subscriptions channel and loads subscriptions, the
+// database is initialized by caller as it is shared by storages of all chains
+func (s *AddressesRedisStorage) Init() error {
+	if s == nil || s.db == nil || s.db.client == nil {
+		return ErrUninitialized
+	}
+	if s.pubsub != nil {
+		// parser retries initialization of all storages if any one fails
+		return nil
+	}
+
+	ctx, cancel := s.db.context()
+	defer cancel()
+
+	// changes are buffered once the channel is subscribed, so that changes
+	// made while subscriptions are loaded are applied after that
+	pubsub := s.db.client.Subscribe(ctx, s.channel())
+	if _, err := pubsub.Receive(ctx); err != nil {
+		pubsub.Close()
+		return fmt.Errorf("could not subscribe to changes: %w", err)
+	}
+
+	if err := s.reload(); err != nil {
+		pubsub.Close()
+		return err
+	}
+
+	s.pubsub = pubsub
+	s.done = make(chan struct{})
+	go s.listen(pubsub.ChannelWithSubscriptions())
+
+	return nil
+}
+
+func (s *AddressesRedisStorage) Shutdown() error {
+	if s.pubsub == nil {
+		return nil
+	}
+
+	err := s.pubsub.Close()
+	<-s.done
+	return err
+}
+
+func (s *AddressesRedisStorage) Store(tenant, address string) error {
+	return s.StoreBatch(tenant, []string{address})
+}
+
+// StoreBatch stores addresses and publishes the change in one MULTI/EXEC
+// transaction
+func (s *AddressesRedisStorage) StoreBatch(tenant string, addresses []string) error {
+	if s == nil || s.db == nil || s.db.client == nil {
+		return ErrUninitialized
+	}
+	if len(addresses) == 0 {
+		return nil
+	}
+
+	err := s.change(subscriptionsMessage{Tenant: tenant, Addresses: addresses},
+		func(ctx context.Context, pipe redis.Pipeliner) {
+			pipe.SAdd(ctx, s.tenantKey(tenant), stringsToArgs(addresses)...)
+			for _, address := range addresses {
+				pipe.SAdd(ctx, s.addressKey(address), tenant)
+			}
+		})
+	if err != nil {
+		return fmt.Errorf("could not store addresses: %w", err)
+	}
+
+	s.logger.Debug("stored addresses", "tenant", tenant, "addresses", len(addresses))
+	return nil
+}
+
+func (s *AddressesRedisStorage) Delete(tenant, address string) error {
+	if s == nil || s.db == nil || s.db.client == nil {
+		return ErrUninitialized
+	}
+
+	err := s.change(
+		subscriptionsMessage{Tenant: tenant, Addresses: []string{address}, Deleted: true},
+		func(ctx context.Context, pipe redis.Pipeliner) {
+			pipe.SRem(ctx, s.tenantKey(tenant), address)
+			pipe.SRem(ctx, s.addressKey(address), tenant)
+		})
+	if err != nil {
+		return fmt.Errorf("could not delete address: %w", err)
+	}
+
+	s.logger.Debug("deleted address", "tenant", tenant, "address", address)
+	return nil
+}
+
+// Tenants returns tenants subscribed to the address from memory
+func (s *AddressesRedisStorage) Tenants(address string) []string {
+	if s == nil {
+		return nil
+	}
+
+	return s.cache.Load().Tenants(address)
+}
+
+func (s *AddressesRedisStorage) Count(tenant string) (int, error) {
+	if s == nil || s.db == nil || s.db.client == nil {
+		return 0, ErrUninitialized
+	}
+
+	ctx, cancel := s.db.context()
+	defer cancel()
+
+	count, err := s.db.client.SCard(ctx, s.tenantKey(tenant)).Result()
+	if err != nil {
+		return 0, fmt.Errorf("could not count addresses: %w", err)
+	}
+
+	return int(count), nil
+}
+
+func (s *AddressesRedisStorage) TenantAddresses(tenant string) ([]string, error) {
+	if s == nil || s.db == nil || s.db.client == nil {
+		return nil, ErrUninitialized
+	}
+
+	ctx, cancel := s.db.context()
+	defer cancel()
+
+	addresses, err := s.db.client.SMembers(ctx, s.tenantKey(tenant)).Result()
+	if err != nil {
+		return nil, fmt.Errorf("could not get addresses: %w", err)
+	}
+
+	return addresses, nil
+}
+
+// Addresses returns all stored addresses from memory
+func (s *AddressesRedisStorage) Addresses() ([]string, error) {
+	if s == nil || s.cache.Load() == nil {
+		return nil, ErrUninitialized
+	}
+
+	return s.cache.Load().Addresses()
+}
+
+// change runs commands of the change with its message published in one
+// MULTI/EXEC transaction. The change is applied to memory as soon as it is
+// written, so that it is seen by the following lookups, and once again when
+// the message is received, so that changes of all replicas are applied in
+// the same order.
+func (s *AddressesRedisStorage) change(
+	message subscriptionsMessage,
+	commands func(ctx context.Context, pipe redis.Pipeliner),
+) error {
+	payload, err := json.Marshal(message)
+	if err != nil {
+		return fmt.Errorf("could not marshal change: %w", err)
+	}
+
+	ctx, cancel := s.db.context()
+	defer cancel()
+
+	_, err = s.db.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		commands(ctx, pipe)
+		pipe.Publish(ctx, s.channel(), payload)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	s.apply(&message)
+	return nil
+}
+
+// listen applies published changes until the channel is closed on shutdown
+func (s *AddressesRedisStorage) listen(messages <-chan interface{}) {
+	defer close(s.done)
+
+	for message := range messages {
+		switch message := message.(type) {
+		case *redis.Subscription:
+			s.logger.Info("resubscribed to changes, reloading subscriptions")
+			if err := s.reload(); err != nil {
+				s.logger.Error("could not reload subscriptions, they may be stale",
+					"error", err)
+			}
+		case *redis.Message:
+			change := subscriptionsMessage{}
+			if err := json.Unmarshal([]byte(message.Payload), &change); err != nil {
+				s.logger.Error("could not unmarshal change", "error", err)
+				continue
+			}
+			s.apply(&change)
+		}
+	}
+}
+
+// apply applies change to memory, changes made before the last reload may
+// be applied again
+func (s *AddressesRedisStorage) apply(change *subscriptionsMessage) {
+	cache := s.cache.Load()
+	if change.Deleted {
+		for _, address := range change.Addresses {
+			cache.Delete(change.Tenant, address)
+		}
+		return
+	}
+
+	s.stored(change.Addresses...)
+	cache.StoreBatch(change.Tenant, change.Addresses)
+}
+
+// OnStore sets fn called with every address stored by any replica before it
+// is applied to memory, and with every reloaded address, it must be called
+// before Init
+func (s *AddressesRedisStorage) OnStore(fn func(address string)) {
+	s.onStore = fn
+}
+
+func (s *AddressesRedisStorage) stored(addresses ...string) {
+	if s.onStore == nil {
+		return
+	}
+	for _, address := range addresses {
+		s.onStore(address)
+	}
+}
+
+// reload loads all subscriptions of the chain into new memory storage, which
+// replaces the current one
+func (s *AddressesRedisStorage) reload() error {
+	cache := NewAddressesMapStorage(s.logger)
+	prefix := s.tenantKey("")
+
+	subscriptions := 0
+	var cursor uint64
+	for {
+		ctx, cancel := s.db.context()
+		keys, next, err := s.db.client.Scan(ctx, cursor, prefix+"*", redisScanCount).Result()
+		if err != nil {
+			cancel()
+			return fmt.Errorf("could not scan subscriptions: %w", err)
+		}
+
+		cmds, err := s.db.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+			for _, key := range keys {
+				pipe.SMembers(ctx, key)
+			}
+			return nil
+		})
+		cancel()
+		if err != nil {
+			return fmt.Errorf("could not load subscriptions: %w", err)
+		}
+
+		for i, cmd := range cmds {
+			addresses := cmd.(*redis.StringSliceCmd).Val()
+			s.stored(addresses...)
+			cache.StoreBatch(strings.TrimPrefix(keys[i], prefix), addresses)
+			subscriptions += len(addresses)
+		}
+
+		if cursor = next; cursor == 0 {
+			break
+		}
+	}
+
+	s.cache.Store(cache)
+
+	s.logger.Info("loaded subscriptions", "subscriptions", subscriptions)
+	return nil
+}
+
+// channel returns the channel changes of subscriptions are published to
+func (s *AddressesRedisStorage) channel() string {
+	return s.db.key(s.chainID, "subscriptions")
+}
+
+// tenantKey returns key of the set of addresses subscribed by tenant
+func (s *AddressesRedisStorage) tenantKey(tenant string) string {
+	return s.db.key(s.chainID, "tenant", tenant)
+}
+
+// addressKey returns key of the set of tenants subscribed to address
+func (s *AddressesRedisStorage) addressKey(address string) string {
+	return s.db.key(s.chainID, "address", address)
+}
+
+func stringsToArgs(values []string) []interface{} {
+	args := make([]interface{}, len(values))
+	for i, value := range values {
+		args[i] = value
+	}
+	return args
+}
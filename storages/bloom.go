@@ -0,0 +1,231 @@
+package storages
+
+import (
+	"hash/maphash"
+	"log/slog"
+	"math"
+	"sync/atomic"
+)
+
+type BloomFilterConfig struct {
+	// ExpectedAddresses is the number of addresses the filter is sized for,
+	// the filter is disabled if zero
+	ExpectedAddresses uint `yaml:"expected_addresses"`
+
+	// FalsePositiveRate is the rate of lookups of not subscribed addresses
+	// passed to the backend when ExpectedAddresses are stored
+	FalsePositiveRate float64 `yaml:"false_positive_rate"`
+}
+
+// addressesBackend is addresses storage fronted by Bloom filter
+type addressesBackend interface {
+	Init() error
+	Shutdown() error
+	Store(tenant, address string) error
+	StoreBatch(tenant string, addresses []string) error
+	Delete(tenant, address string) error
+	Tenants(address string) []string
+	Count(tenant string) (int, error)
+	TenantAddresses(tenant string) ([]string, error)
+	Addresses() ([]string, error)
+}
+
+// sharedAddressesBackend is addresses storage shared by replicas, which
+// reports addresses stored by any replica
+type sharedAddressesBackend interface {
+	OnStore(fn func(address string))
+}
+
+// BloomAddressesStorage fronts addresses storage with Bloom filter, so that
+// lookups of not subscribed addresses, which are the vast majority of
+// addresses in blocks, do not reach the backend. Deleted addresses stay in
+// the filter and are passed to the backend as false positives. The filter
+// learns addresses stored through it, and addresses stored by other replicas
+// if the backend is shared by them, as the backend reports every address it
+// receives on changes and reloads.
+type BloomAddressesStorage struct {
+	backend addressesBackend
+	filter  *bloomFilter
+	config  *BloomFilterConfig
+	shared  bool
+
+	logger *slog.Logger
+}
+
+func NewBloomAddressesStorage(
+	backend addressesBackend,
+	config *BloomFilterConfig,
+	logger *slog.Logger,
+) *BloomAddressesStorage {
+	return &BloomAddressesStorage{
+		backend: backend,
+		filter:  newBloomFilter(config.ExpectedAddresses, config.FalsePositiveRate),
+		config:  config,
+		logger:  logger.With("component", "bloom_addresses_storage"),
+	}
+}
+
+// Init initializes the backend and fills the filter with stored addresses
+func (b *BloomAddressesStorage) Init() error {
+	if b == nil || b.backend == nil {
+		return ErrUninitialized
+	}
+
+	// addresses are reported by the backend since it is initialized
+	if shared, ok := b.backend.(sharedAddressesBackend); ok && !b.shared {
+		shared.OnStore(b.filter.add)
+		b.shared = true
+	}
+
+	if err := b.backend.Init(); err != nil {
+		return err
+	}
+
+	addresses, err := b.backend.Addresses()
+	if err != nil {
+		return err
+	}
+	for _, address := range addresses {
+		b.filter.add(address)
+	}
+
+	if uint(len(addresses)) > b.config.ExpectedAddresses {
+		b.logger.Warn("stored addresses exceed expected, false positive rate is higher",
+			"addresses", len(addresses), "expected_addresses", b.config.ExpectedAddresses)
+	}
+
+	b.logger.Info("filled Bloom filter", "addresses", len(addresses),
+		"bits", b.filter.bits, "hashes", b.filter.hashes)
+	return nil
+}
+
+func (b *BloomAddressesStorage) Shutdown() error {
+	if b == nil || b.backend == nil {
+		return ErrUninitialized
+	}
+
+	return b.backend.Shutdown()
+}
+
+func (b *BloomAddressesStorage) Store(tenant, address string) error {
+	if b == nil || b.backend == nil {
+		return ErrUninitialized
+	}
+
+	// address is added before it is stored, so that it could not be missed
+	b.filter.add(address)
+	return b.backend.Store(tenant, address)
+}
+
+func (b *BloomAddressesStorage) StoreBatch(tenant string, addresses []string) error {
+	if b == nil || b.backend == nil {
+		return ErrUninitialized
+	}
+
+	for _, address := range addresses {
+		b.filter.add(address)
+	}
+	return b.backend.StoreBatch(tenant, addresses)
+}
+
+func (b *BloomAddressesStorage) Delete(tenant, address string) error {
+	if b == nil || b.backend == nil {
+		return ErrUninitialized
+	}
+
+	return b.backend.Delete(tenant, address)
+}
+
+func (b *BloomAddressesStorage) Tenants(address string) []string {
+	if b == nil || b.backend == nil {
+		return nil
+	}
+
+	if !b.filter.mayContain(address) {
+		return nil
+	}
+	return b.backend.Tenants(address)
+}
+
+func (b *BloomAddressesStorage) Count(tenant string) (int, error) {
+	if b == nil || b.backend == nil {
+		return 0, ErrUninitialized
+	}
+
+	return b.backend.Count(tenant)
+}
+
+func (b *BloomAddressesStorage) TenantAddresses(tenant string) ([]string, error) {
+	if b == nil || b.backend == nil {
+		return nil, ErrUninitialized
+	}
+
+	return b.backend.TenantAddresses(tenant)
+}
+
+func (b *BloomAddressesStorage) Addresses() ([]string, error) {
+	if b == nil || b.backend == nil {
+		return nil, ErrUninitialized
+	}
+
+	return b.backend.Addresses()
+}
+
+// bloomFilter is lock-free Bloom filter of strings, it may be read and
+// written concurrently
+type bloomFilter struct {
+	words  []atomic.Uint64
+	bits   uint64
+	hashes uint64
+	seed   maphash.Seed
+}
+
+// newBloomFilter creates filter sized for expected number of elements with
+// given false positive rate
+func newBloomFilter(expected uint, falsePositiveRate float64) *bloomFilter {
+	n := math.Max(float64(expected), 1)
+	bits := math.Ceil(-n * math.Log(falsePositiveRate) / (math.Ln2 * math.Ln2))
+	hashes := math.Max(math.Round(bits/n*math.Ln2), 1)
+
+	words := (uint64(bits) + 63) / 64
+	return &bloomFilter{
+		words:  make([]atomic.Uint64, words),
+		bits:   words * 64,
+		hashes: uint64(hashes),
+		seed:   maphash.MakeSeed(),
+	}
+}
+
+func (f *bloomFilter) add(s string) {
+	h1, h2 := f.hash(s)
+	for i := uint64(0); i < f.hashes; i++ {
+		bit := (h1 + i*h2) % f.bits
+		word, mask := &f.words[bit/64], uint64(1)<<(bit%64)
+
+		for {
+			old := word.Load()
+			if old&mask != 0 || word.CompareAndSwap(old, old|mask) {
+				break
+			}
+		}
+	}
+}
+
+func (f *bloomFilter) mayContain(s string) bool {
+	h1, h2 := f.hash(s)
+	for i := uint64(0); i < f.hashes; i++ {
+		bit := (h1 + i*h2) % f.bits
+		if f.words[bit/64].Load()&(uint64(1)<<(bit%64)) == 0 {
+			return false
+		}
+	}
+
+	return true
+}
+
+// hash returns two 32-bit hashes of the string used to derive all hashes of
+// the filter with double hashing
+func (f *bloomFilter) hash(s string) (uint64, uint64) {
+	h := maphash.String(f.seed, s)
+	return h & math.MaxUint32, h>>32 | 1
+}
@@ -0,0 +1,101 @@
+package storages
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+)
+
+// CheckpointsFileStorage keeps the last blocks published to sinks by chains
+// in JSON file, which is rewritten atomically on every store. It is shared by
+// parsers of all chains.
+type CheckpointsFileStorage struct {
+	path string
+
+	// checkpoints maps chain ID to the last block number
+	checkpoints map[string]int64
+	mu          sync.Mutex
+
+	logger *slog.Logger
+}
+
+func NewCheckpointsFileStorage(path string, logger *slog.Logger) *CheckpointsFileStorage {
+	return &CheckpointsFileStorage{
+		path:        path,
+		checkpoints: make(map[string]int64),
+		logger:      logger.With("component", "checkpoints_file_storage"),
+	}
+}
+
+// Init loads checkpoints from the file if it exists
+func (f *CheckpointsFileStorage) Init() error {
+	data, err := os.ReadFile(filepath.Clean(f.path))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("could not read checkpoints: %w", err)
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := json.Unmarshal(data, &f.checkpoints); err != nil {
+		return fmt.Errorf("could not unmarshal checkpoints: %w", err)
+	}
+
+	f.logger.Info("loaded checkpoints", "path", f.path, "checkpoints", f.checkpoints)
+	return nil
+}
+
+func (f *CheckpointsFileStorage) Shutdown() error {
+	return nil
+}
+
+// Get returns the last block of the chain, false is returned if there is none
+func (f *CheckpointsFileStorage) Get(chainID uint64) (int64, bool, error) {
+	if f == nil {
+		return 0, false, ErrUninitialized
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	blockNumber, ok := f.checkpoints[strconv.FormatUint(chainID, 10)]
+	return blockNumber, ok, nil
+}
+
+func (f *CheckpointsFileStorage) Store(chainID uint64, blockNumber int64) error {
+	if f == nil {
+		return ErrUninitialized
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.checkpoints[strconv.FormatUint(chainID, 10)] = blockNumber
+
+	data, err := json.Marshal(f.checkpoints)
+	if err != nil {
+		return fmt.Errorf("could not marshal checkpoints: %w", err)
+	}
+
+	// the file is replaced by renaming, so that it is never left partially
+	// written on crash
+	tmpPath := f.path + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0o600); err != nil {
+		return fmt.Errorf("could not write checkpoints: %w", err)
+	}
+	if err := os.Rename(tmpPath, f.path); err != nil {
+		return fmt.Errorf("could not replace checkpoints: %w", err)
+	}
+
+	f.logger.Debug("stored checkpoint", "chain_id", chainID, "block", blockNumber)
+	return nil
+}
@@ -0,0 +1,184 @@
+package storages
+
+import (
+	"log/slog"
+	"sync"
+
+	"eth-parser/eth"
+)
+
+// logFilterKey identifies log filter subscribed by tenant
+type logFilterKey struct {
+	tenant   string
+	filterID string
+}
+
+// logKey identifies log within the chain
+type logKey struct {
+	transactionHash string
+	logIndex        string
+}
+
+// LogsMapStorage keeps logs matched by filters in memory, they are identified
+// by transaction hashes and log indexes, so that log stored again replaces the
+// stored one in place
+type LogsMapStorage struct {
+	// filters maps tenant to its log filters by ID
+	filters map[string]map[string]eth.LogFilter
+
+	storage map[logFilterKey][]eth.MatchedLog
+	// indexes maps keys of stored logs to their positions
+	indexes       map[logFilterKey]map[logKey]int
+	mu            sync.RWMutex
+	resetAfterGet bool
+
+	logger *slog.Logger
+}
+
+func NewLogsMapStorage(resetAfterGet bool, logger *slog.Logger) *LogsMapStorage {
+	return &LogsMapStorage{
+		filters:       make(map[string]map[string]eth.LogFilter),
+		storage:       make(map[logFilterKey][]eth.MatchedLog),
+		indexes:       make(map[logFilterKey]map[logKey]int),
+		resetAfterGet: resetAfterGet,
+		logger:        logger.With("component", "logs_map_storage"),
+	}
+}
+
+func (m *LogsMapStorage) Init() error {
+	return nil
+}
+
+func (m *LogsMapStorage) Shutdown() error {
+	return nil
+}
+
+func (m *LogsMapStorage) StoreFilter(tenant string, filter eth.LogFilter) error {
+	if m == nil {
+		return ErrUninitialized
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.filters[tenant]; !ok {
+		m.filters[tenant] = make(map[string]eth.LogFilter)
+	}
+	m.filters[tenant][filter.ID] = filter
+
+	m.logger.Debug("stored log filter", "tenant", tenant, "filter_id", filter.ID,
+		"address", filter.Address, "filters", len(m.filters[tenant]))
+	return nil
+}
+
+func (m *LogsMapStorage) DeleteFilter(tenant, filterID string) error {
+	if m == nil {
+		return ErrUninitialized
+	}
+
+	key := logFilterKey{tenant: tenant, filterID: filterID}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.filters[tenant], filterID)
+	if len(m.filters[tenant]) == 0 {
+		delete(m.filters, tenant)
+	}
+	delete(m.storage, key)
+	delete(m.indexes, key)
+
+	m.logger.Debug("deleted log filter", "tenant", tenant, "filter_id", filterID)
+	return nil
+}
+
+func (m *LogsMapStorage) Filters(tenant string) ([]eth.LogFilter, error) {
+	if m == nil {
+		return nil, ErrUninitialized
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	filters := make([]eth.LogFilter, 0, len(m.filters[tenant]))
+	for _, filter := range m.filters[tenant] {
+		filters = append(filters, filter)
+	}
+	return filters, nil
+}
+
+func (m *LogsMapStorage) AllFilters() (map[string][]eth.LogFilter, error) {
+	if m == nil {
+		return nil, ErrUninitialized
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	result := make(map[string][]eth.LogFilter, len(m.filters))
+	for tenant, filters := range m.filters {
+		for _, filter := range filters {
+			result[tenant] = append(result[tenant], filter)
+		}
+	}
+	return result, nil
+}
+
+func (m *LogsMapStorage) Store(tenant, filterID string, log eth.MatchedLog) error {
+	if m == nil {
+		return ErrUninitialized
+	}
+
+	key := logFilterKey{tenant: tenant, filterID: filterID}
+	index := logKey{transactionHash: log.TransactionHash, logIndex: log.LogIndex}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.filters[tenant][filterID]; !ok {
+		// filter was deleted while its logs were being matched
+		return nil
+	}
+
+	if _, ok := m.storage[key]; !ok {
+		m.indexes[key] = make(map[logKey]int)
+	}
+
+	if i, ok := m.indexes[key][index]; ok {
+		m.storage[key][i] = log
+		return nil
+	}
+
+	m.indexes[key][index] = len(m.storage[key])
+	m.storage[key] = append(m.storage[key], log)
+
+	m.logger.Debug("stored log", "tenant", tenant, "filter_id", filterID,
+		"tx_hash", log.TransactionHash, "log_index", log.LogIndex)
+	return nil
+}
+
+func (m *LogsMapStorage) Get(tenant, filterID string) ([]eth.MatchedLog, error) {
+	if m == nil {
+		return nil, ErrUninitialized
+	}
+
+	key := logFilterKey{tenant: tenant, filterID: filterID}
+
+	// stored logs are replaced in place, so that they are copied to be read
+	// without lock
+	m.mu.RLock()
+	result := append([]eth.MatchedLog(nil), m.storage[key]...)
+	m.mu.RUnlock()
+
+	if m.resetAfterGet {
+		m.mu.Lock()
+		delete(m.storage, key)
+		delete(m.indexes, key)
+		m.mu.Unlock()
+
+		m.logger.Debug("reset logs after get",
+			"tenant", tenant, "filter_id", filterID, "logs", len(result))
+	}
+
+	return result, nil
+}
@@ -0,0 +1,200 @@
+package storages
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"eth-parser/auth"
+)
+
+// postgresMigrationsLockID is the key of advisory lock held while migrations
+// are applied, so that replicas started at once do not apply them twice
+const postgresMigrationsLockID = 0x6574685f706172
+
+type PostgresConfig struct {
+	// DSN is connection string of the database, either URL or key-value
+	DSN auth.Secret `yaml:"dsn"`
+
+	MaxConns int `yaml:"max_conns"`
+
+	// Timeout limits every query
+	Timeout time.Duration `yaml:"timeout"`
+}
+
+// postgresMigrations are applied in order, the number of applied ones is kept
+// in schema_migrations table. Applied migrations must never be changed, the
+// schema is changed by appending new ones.
+var postgresMigrations = []string{
+	`CREATE TABLE subscriptions (
+		chain_id   BIGINT      NOT NULL,
+		tenant     TEXT        NOT NULL,
+		address    TEXT        NOT NULL,
+		created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+		PRIMARY KEY (chain_id, tenant, address)
+	);
+	CREATE INDEX subscriptions_address_idx ON subscriptions (chain_id, address);
+
+	CREATE TABLE transactions (
+		id           BIGSERIAL   PRIMARY KEY,
+		chain_id     BIGINT      NOT NULL,
+		tenant       TEXT        NOT NULL,
+		address      TEXT        NOT NULL,
+		hash         TEXT        NOT NULL,
+		block_number BIGINT,
+		block_time   TIMESTAMPTZ,
+		status       TEXT        NOT NULL,
+		data         JSONB       NOT NULL,
+		updated_at   TIMESTAMPTZ NOT NULL DEFAULT now(),
+		UNIQUE (chain_id, tenant, address, hash)
+	);
+	CREATE INDEX transactions_address_idx ON transactions (chain_id, address);
+	CREATE INDEX transactions_block_idx ON transactions (chain_id, block_number);
+	CREATE INDEX transactions_time_idx ON transactions (chain_id, block_time);
+
+	CREATE TABLE checkpoints (
+		chain_id     BIGINT      PRIMARY KEY,
+		block_number BIGINT      NOT NULL,
+		updated_at   TIMESTAMPTZ NOT NULL DEFAULT now()
+	);`,
+}
+
+// PostgresDB is connection pool to PostgreSQL shared by storages of all
+// chains, tables are keyed by chain ID
+type PostgresDB struct {
+	config *PostgresConfig
+	pool   *pgxpool.Pool
+
+	logger *slog.Logger
+}
+
+func NewPostgresDB(config *PostgresConfig, logger *slog.Logger) *PostgresDB {
+	return &PostgresDB{
+		config: config,
+		logger: logger.With("component", "postgres_db"),
+	}
+}
+
+// Init connects to the database and applies migrations which are not applied
+// yet
+func (d *PostgresDB) Init() error {
+	poolConfig, err := pgxpool.ParseConfig(string(d.config.DSN))
+	if err != nil {
+		return fmt.Errorf("could not parse DSN: %w", err)
+	}
+	if d.config.MaxConns > 0 {
+		poolConfig.MaxConns = int32(min(d.config.MaxConns, math.MaxInt32))
+	}
+
+	ctx, cancel := d.context()
+	defer cancel()
+
+	pool, err := pgxpool.NewWithConfig(ctx, poolConfig)
+	if err != nil {
+		return fmt.Errorf("could not create pool: %w", err)
+	}
+	if err := pool.Ping(ctx); err != nil {
+		pool.Close()
+		return fmt.Errorf("could not connect: %w", err)
+	}
+	d.pool = pool
+
+	if err := d.migrate(); err != nil {
+		pool.Close()
+		d.pool = nil
+		return err
+	}
+
+	d.logger.Info("initialized postgres db", "host", poolConfig.ConnConfig.Host,
+		"database", poolConfig.ConnConfig.Database)
+	return nil
+}
+
+func (d *PostgresDB) Shutdown() error {
+	if d.pool != nil {
+		d.pool.Close()
+	}
+
+	return nil
+}
+
+// Checkpoint returns the last block stored for the chain, false is returned
+// if there is none
+func (d *PostgresDB) Checkpoint(chainID uint64) (int64, bool, error) {
+	if d == nil || d.pool == nil {
+		return 0, false, ErrUninitialized
+	}
+
+	ctx, cancel := d.context()
+	defer cancel()
+
+	var blockNumber int64
+	err := d.pool.QueryRow(ctx,
+		`SELECT block_number FROM checkpoints WHERE chain_id = $1`, int64(chainID),
+	).Scan(&blockNumber)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, fmt.Errorf("could not get checkpoint: %w", err)
+	}
+
+	return blockNumber, true, nil
+}
+
+// migrate applies migrations in a single transaction under advisory lock
+func (d *PostgresDB) migrate() error {
+	ctx, cancel := d.context()
+	defer cancel()
+
+	return pgx.BeginFunc(ctx, d.pool, func(tx pgx.Tx) error {
+		if _, err := tx.Exec(ctx,
+			`SELECT pg_advisory_xact_lock($1)`, int64(postgresMigrationsLockID)); err != nil {
+			return fmt.Errorf("could not lock migrations: %w", err)
+		}
+
+		if _, err := tx.Exec(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (
+			version    INTEGER     PRIMARY KEY,
+			applied_at TIMESTAMPTZ NOT NULL DEFAULT now()
+		)`); err != nil {
+			return fmt.Errorf("could not create migrations table: %w", err)
+		}
+
+		var applied int
+		if err := tx.QueryRow(ctx,
+			`SELECT COALESCE(MAX(version), 0) FROM schema_migrations`).Scan(&applied); err != nil {
+			return fmt.Errorf("could not get schema version: %w", err)
+		}
+		if applied > len(postgresMigrations) {
+			return fmt.Errorf("schema version %d is newer than supported %d",
+				applied, len(postgresMigrations))
+		}
+
+		for version := applied + 1; version <= len(postgresMigrations); version++ {
+			// statements without arguments are sent with simple protocol, which
+			// allows several of them in one migration
+			if _, err := tx.Exec(ctx, postgresMigrations[version-1]); err != nil {
+				return fmt.Errorf("could not apply migration %d: %w", version, err)
+			}
+			if _, err := tx.Exec(ctx,
+				`INSERT INTO schema_migrations (version) VALUES ($1)`, version); err != nil {
+				return fmt.Errorf("could not record migration %d: %w", version, err)
+			}
+
+			d.logger.Info("applied migration", "version", version)
+		}
+
+		return nil
+	})
+}
+
+// context returns context of a single query
+func (d *PostgresDB) context() (context.Context, context.CancelFunc) {
+	return context.WithTimeout(context.Background(), d.config.Timeout)
+}
@@ -0,0 +1,109 @@
+package storages
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"eth-parser/auth"
+)
+
+type RedisConfig struct {
+	Addr     string      `yaml:"addr"`
+	Username string      `yaml:"username"`
+	Password auth.Secret `yaml:"password"`
+	DB       int         `yaml:"db"`
+
+	// Prefix is prepended to all keys and channels, so that instances which
+	// must not share state may use the same database
+	Prefix string `yaml:"prefix"`
+
+	// Timeout limits every command
+	Timeout time.Duration `yaml:"timeout"`
+}
+
+// RedisDB is Redis client shared by storages of all chains, keys of storages
+// are prefixed with chain ID
+type RedisDB struct {
+	config *RedisConfig
+	client *redis.Client
+
+	logger *slog.Logger
+}
+
+func NewRedisDB(config *RedisConfig, logger *slog.Logger) *RedisDB {
+	return &RedisDB{
+		config: config,
+		logger: logger.With("component", "redis_db"),
+	}
+}
+
+func (d *RedisDB) Init() error {
+	client := redis.NewClient(&redis.Options{
+		Addr:         d.config.Addr,
+		Username:     d.config.Username,
+		Password:     string(d.config.Password),
+		DB:           d.config.DB,
+		DialTimeout:  d.config.Timeout,
+		ReadTimeout:  d.config.Timeout,
+		WriteTimeout: d.config.Timeout,
+	})
+
+	ctx, cancel := d.context()
+	defer cancel()
+
+	if err := client.Ping(ctx).Err(); err != nil {
+		client.Close()
+		return fmt.Errorf("could not ping: %w", err)
+	}
+	d.client = client
+
+	d.logger.Info("initialized redis db", "addr", d.config.Addr, "prefix", d.config.Prefix)
+	return nil
+}
+
+func (d *RedisDB) Shutdown() error {
+	if d.client == nil {
+		return nil
+	}
+
+	return d.client.Close()
+}
+
+// Checkpoint returns the last block stored for the chain, false is returned
+// if there is none
+func (d *RedisDB) Checkpoint(chainID uint64) (int64, bool, error) {
+	if d == nil || d.client == nil {
+		return 0, false, ErrUninitialized
+	}
+
+	ctx, cancel := d.context()
+	defer cancel()
+
+	blockNumber, err := d.client.Get(ctx, d.key(chainID, "checkpoint")).Int64()
+	if errors.Is(err, redis.Nil) {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, fmt.Errorf("could not get checkpoint: %w", err)
+	}
+
+	return blockNumber, true, nil
+}
+
+// key returns key of the chain, e.g. <prefix>:1:tenant:<tenant>
+func (d *RedisDB) key(chainID uint64, parts ...string) string {
+	return d.config.Prefix + ":" + strconv.FormatUint(chainID, 10) + ":" +
+		strings.Join(parts, ":")
+}
+
+// context returns context of a single command or pipeline
+func (d *RedisDB) context() (context.Context, context.CancelFunc) {
+	return context.WithTimeout(context.Background(), d.config.Timeout)
+}
@@ -0,0 +1,423 @@
+package storages
+
+import (
+	"encoding/json"
+	"io"
+	"log/slog"
+	"os"
+	"reflect"
+	"slices"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+
+	"eth-parser/auth"
+	"eth-parser/eth"
+)
+
+// postgresDSNEnv names the variable with DSN of PostgreSQL database the
+// storages are tested against, the tests are skipped if it is not set
+const postgresDSNEnv = "ETH_PARSER_TEST_POSTGRES_DSN"
+
+const testTimeout = time.Second
+
+var testLogger = slog.New(slog.NewTextHandler(io.Discard, nil))
+
+type transactionsStorage interface {
+	Store(tenant, address string, transaction eth.Transaction) error
+	Get(tenant, address string) ([]eth.Transaction, error)
+	GetPage(tenant, address string, offset, limit int) ([]eth.Transaction, error)
+}
+
+type blockTransactionsStorage interface {
+	transactionsStorage
+	StoreBlock(blockNumber int64, transactions []eth.SubscribedTransaction) error
+}
+
+type addressesStorage interface {
+	Store(tenant, address string) error
+	StoreBatch(tenant string, addresses []string) error
+	Delete(tenant, address string) error
+	Tenants(address string) []string
+	Count(tenant string) (int, error)
+	TenantAddresses(tenant string) ([]string, error)
+}
+
+func newTestRedisDB(t *testing.T, server *miniredis.Miniredis) *RedisDB {
+	db := NewRedisDB(&RedisConfig{Addr: server.Addr(), Prefix: "test", Timeout: testTimeout},
+		testLogger)
+	if err := db.Init(); err != nil {
+		t.Fatalf("could not init redis db: %v", err)
+	}
+	t.Cleanup(func() { db.Shutdown() })
+
+	return db
+}
+
+// newTestPostgresDB connects to the database from postgresDSNEnv, the test
+// is skipped if it is not set. Returned chain ID is unique, so that data of
+// previous runs is not seen.
+func newTestPostgresDB(t *testing.T) (*PostgresDB, uint64) {
+	dsn := os.Getenv(postgresDSNEnv)
+	if len(dsn) == 0 {
+		t.Skipf("%s is not set", postgresDSNEnv)
+	}
+
+	db := NewPostgresDB(&PostgresConfig{DSN: auth.Secret(dsn), Timeout: 5 * testTimeout},
+		testLogger)
+	if err := db.Init(); err != nil {
+		t.Fatalf("could not init postgres db: %v", err)
+	}
+	t.Cleanup(func() { db.Shutdown() })
+
+	return db, uint64(time.Now().UnixNano())
+}
+
+func TestTransactionsMapStorage(t *testing.T) {
+	testTransactionsStorage(t, NewTransactionsMapStorage(false, testLogger))
+}
+
+// TestTransactionsMapStorageConcurrentGet is meant to be run with -race, the
+// returned transactions are read while they are replaced in the storage
+func TestTransactionsMapStorageConcurrentGet(t *testing.T) {
+	storage := NewTransactionsMapStorage(false, testLogger)
+	transaction := eth.Transaction{Hash: "0x01", Status: eth.StatusPending}
+	if err := storage.Store("tenant1", "addr1", transaction); err != nil {
+		t.Fatalf("could not store transaction: %v", err)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+
+		for i := range 1000 {
+			transaction.Nonce = strconv.Itoa(i)
+			storage.Store("tenant1", "addr1", transaction)
+		}
+	}()
+
+	for {
+		select {
+		case <-done:
+			return
+		default:
+		}
+
+		transactions, err := storage.Get("tenant1", "addr1")
+		if err != nil {
+			t.Fatalf("could not get transactions: %v", err)
+		}
+		if _, err := json.Marshal(transactions); err != nil {
+			t.Fatalf("could not marshal transactions: %v", err)
+		}
+	}
+}
+
+func TestTransactionsRedisStorage(t *testing.T) {
+	db := newTestRedisDB(t, miniredis.RunT(t))
+
+	storage := NewTransactionsRedisStorage(db, 1, testLogger)
+	testTransactionsStorage(t, storage)
+	testStoreBlock(t, storage, db, 1)
+}
+
+func TestTransactionsPostgresStorage(t *testing.T) {
+	db, chainID := newTestPostgresDB(t)
+
+	storage := NewTransactionsPostgresStorage(db, chainID, testLogger)
+	testTransactionsStorage(t, storage)
+	testStoreBlock(t, storage, db, chainID)
+}
+
+// testTransactionsStorage checks that transaction stored again for the same
+// address, e.g. after its block is processed again, replaces the stored one
+// in place
+func testTransactionsStorage(t *testing.T, storage transactionsStorage) {
+	t.Helper()
+
+	tx1 := eth.Transaction{Hash: "0x01", From: "addr1", To: "addr2", Status: eth.StatusPending}
+	tx2 := eth.Transaction{Hash: "0x02", From: "addr2", To: "addr1", Status: eth.StatusPending}
+	minedTx1 := tx1
+	minedTx1.BlockNumber = "0x10"
+	minedTx1.Status = eth.StatusMined
+
+	for _, stored := range []struct {
+		address     string
+		transaction eth.Transaction
+	}{
+		{"addr1", tx1},
+		{"addr2", tx1},
+		{"addr1", tx2},
+		{"addr1", minedTx1},
+		{"addr1", minedTx1},
+	} {
+		if err := storage.Store("tenant1", stored.address, stored.transaction); err != nil {
+			t.Fatalf("could not store transaction: %v", err)
+		}
+	}
+
+	transactions, err := storage.Get("tenant1", "addr1")
+	if err != nil {
+		t.Fatalf("could not get transactions: %v", err)
+	}
+	expected := []eth.Transaction{minedTx1, tx2}
+	if !reflect.DeepEqual(transactions, expected) {
+		t.Errorf("transactions are not equal:\nhave: %+v\nwant: %+v", transactions, expected)
+	}
+
+	// the same transaction is stored separately for other addresses
+	transactions, err = storage.Get("tenant1", "addr2")
+	if err != nil {
+		t.Fatalf("could not get transactions: %v", err)
+	}
+	if expected := []eth.Transaction{tx1}; !reflect.DeepEqual(transactions, expected) {
+		t.Errorf("transactions are not equal:\nhave: %+v\nwant: %+v", transactions, expected)
+	}
+
+	transactions, err = storage.GetPage("tenant1", "addr1", 1, 10)
+	if err != nil {
+		t.Fatalf("could not get page: %v", err)
+	}
+	if expected := []eth.Transaction{tx2}; !reflect.DeepEqual(transactions, expected) {
+		t.Errorf("page is not equal:\nhave: %+v\nwant: %+v", transactions, expected)
+	}
+}
+
+// testStoreBlock checks that block stored again, e.g. after restart from the
+// checkpoint, does not duplicate its transactions
+func testStoreBlock(
+	t *testing.T,
+	storage blockTransactionsStorage,
+	checkpoints interface {
+		Checkpoint(chainID uint64) (int64, bool, error)
+	},
+	chainID uint64,
+) {
+	t.Helper()
+
+	tx := eth.Transaction{
+		Hash: "0x03", From: "addr3", To: "addr3", BlockNumber: "0x11", Status: eth.StatusMined,
+	}
+	block := []eth.SubscribedTransaction{
+		{Tenant: "tenant1", Address: "addr3", Transaction: tx},
+		{Tenant: "tenant1", Address: "addr3", Transaction: tx},
+		{Tenant: "tenant2", Address: "addr3", Transaction: tx},
+	}
+
+	for range 2 {
+		if err := storage.StoreBlock(0x11, block); err != nil {
+			t.Fatalf("could not store block: %v", err)
+		}
+	}
+
+	for _, tenant := range []string{"tenant1", "tenant2"} {
+		transactions, err := storage.Get(tenant, "addr3")
+		if err != nil {
+			t.Fatalf("could not get transactions: %v", err)
+		}
+		if expected := []eth.Transaction{tx}; !reflect.DeepEqual(transactions, expected) {
+			t.Errorf("transactions of %s are not equal:\nhave: %+v\nwant: %+v",
+				tenant, transactions, expected)
+		}
+	}
+
+	blockNumber, ok, err := checkpoints.Checkpoint(chainID)
+	if err != nil {
+		t.Fatalf("could not get checkpoint: %v", err)
+	}
+	if !ok || blockNumber != 0x11 {
+		t.Errorf("unexpected checkpoint: %d, %v", blockNumber, ok)
+	}
+}
+
+func TestAddressesMapStorage(t *testing.T) {
+	storage := NewAddressesMapStorage(testLogger)
+	testAddressesStorage(t, storage, storage)
+}
+
+func TestAddressesRedisStorage(t *testing.T) {
+	server := miniredis.RunT(t)
+
+	// changes of one replica are applied to memory of every replica
+	replicas := make([]*AddressesRedisStorage, 2)
+	for i := range replicas {
+		replicas[i] = NewAddressesRedisStorage(newTestRedisDB(t, server), 1, testLogger)
+		if err := replicas[i].Init(); err != nil {
+			t.Fatalf("could not init storage: %v", err)
+		}
+		t.Cleanup(func() { replicas[i].Shutdown() })
+	}
+
+	testAddressesStorage(t, replicas[0], replicas[1])
+}
+
+// TestAddressesRedisStorageOwnChanges checks that changes of the replica are
+// applied without waiting for their messages
+func TestAddressesRedisStorageOwnChanges(t *testing.T) {
+	storage := NewAddressesRedisStorage(newTestRedisDB(t, miniredis.RunT(t)), 1, testLogger)
+	if err := storage.Init(); err != nil {
+		t.Fatalf("could not init storage: %v", err)
+	}
+	t.Cleanup(func() { storage.Shutdown() })
+
+	// messages are not received anymore
+	storage.pubsub.Close()
+	<-storage.done
+
+	if err := storage.StoreBatch("tenant1", []string{"addr1", "addr2"}); err != nil {
+		t.Fatalf("could not store addresses: %v", err)
+	}
+	if err := storage.Delete("tenant1", "addr2"); err != nil {
+		t.Fatalf("could not delete address: %v", err)
+	}
+
+	checkTenants(t, storage, "addr1", []string{"tenant1"})
+	checkTenants(t, storage, "addr2", nil)
+}
+
+// TestBloomAddressesRedisStorage checks that Bloom filter of the replica
+// learns addresses stored before it is initialized and stored by others
+func TestBloomAddressesRedisStorage(t *testing.T) {
+	server := miniredis.RunT(t)
+	config := &BloomFilterConfig{ExpectedAddresses: 1000, FalsePositiveRate: 0.01}
+
+	storage := NewAddressesRedisStorage(newTestRedisDB(t, server), 1, testLogger)
+	if err := storage.Init(); err != nil {
+		t.Fatalf("could not init storage: %v", err)
+	}
+	t.Cleanup(func() { storage.Shutdown() })
+	if err := storage.Store("tenant1", "addr1"); err != nil {
+		t.Fatalf("could not store address: %v", err)
+	}
+
+	replica := NewBloomAddressesStorage(
+		NewAddressesRedisStorage(newTestRedisDB(t, server), 1, testLogger), config, testLogger)
+	if err := replica.Init(); err != nil {
+		t.Fatalf("could not init storage: %v", err)
+	}
+	t.Cleanup(func() { replica.Shutdown() })
+	checkTenants(t, replica, "addr1", []string{"tenant1"})
+
+	if err := storage.StoreBatch("tenant2", []string{"addr1", "addr2"}); err != nil {
+		t.Fatalf("could not store addresses: %v", err)
+	}
+	waitForTenants(t, replica, "addr2", []string{"tenant2"})
+	waitForTenants(t, replica, "addr1", []string{"tenant1", "tenant2"})
+}
+
+func TestAddressesPostgresStorage(t *testing.T) {
+	db, chainID := newTestPostgresDB(t)
+
+	// changes of one replica are applied to memory of every replica
+	replicas := make([]*AddressesPostgresStorage, 2)
+	for i := range replicas {
+		replicas[i] = NewAddressesPostgresStorage(db, chainID, testLogger)
+		if err := replicas[i].Init(); err != nil {
+			t.Fatalf("could not init storage: %v", err)
+		}
+		t.Cleanup(func() { replicas[i].Shutdown() })
+	}
+
+	testAddressesStorage(t, replicas[0], replicas[1])
+}
+
+// testAddressesStorage checks that address subscribed again is stored once,
+// changes are made with storage and looked up with replica
+func testAddressesStorage(t *testing.T, storage addressesStorage, replica addressesStorage) {
+	t.Helper()
+
+	for _, address := range []string{"addr1", "addr2", "addr1"} {
+		if err := storage.Store("tenant1", address); err != nil {
+			t.Fatalf("could not store address: %v", err)
+		}
+	}
+	if err := storage.StoreBatch("tenant1", []string{"addr2", "addr3", "addr3"}); err != nil {
+		t.Fatalf("could not store addresses: %v", err)
+	}
+	if err := storage.Store("tenant2", "addr1"); err != nil {
+		t.Fatalf("could not store address: %v", err)
+	}
+
+	count, err := storage.Count("tenant1")
+	if err != nil {
+		t.Fatalf("could not count addresses: %v", err)
+	}
+	if count != 3 {
+		t.Errorf("unexpected count: %d", count)
+	}
+
+	addresses, err := storage.TenantAddresses("tenant1")
+	if err != nil {
+		t.Fatalf("could not get addresses: %v", err)
+	}
+	slices.Sort(addresses)
+	if expected := []string{"addr1", "addr2", "addr3"}; !reflect.DeepEqual(addresses, expected) {
+		t.Errorf("addresses are not equal:\nhave: %v\nwant: %v", addresses, expected)
+	}
+
+	// changes are seen by the following lookups of the storage at once
+	checkTenants(t, storage, "addr1", []string{"tenant1", "tenant2"})
+	waitForTenants(t, replica, "addr1", []string{"tenant1", "tenant2"})
+
+	if err := storage.Delete("tenant1", "addr1"); err != nil {
+		t.Fatalf("could not delete address: %v", err)
+	}
+	checkTenants(t, storage, "addr1", []string{"tenant2"})
+	waitForTenants(t, replica, "addr1", []string{"tenant2"})
+}
+
+func checkTenants(t *testing.T, storage addressesStorage, address string, expected []string) {
+	t.Helper()
+
+	tenants := storage.Tenants(address)
+	slices.Sort(tenants)
+	if !reflect.DeepEqual(tenants, expected) {
+		t.Errorf("tenants of %s are not equal:\nhave: %v\nwant: %v", address, tenants, expected)
+	}
+}
+
+// waitForTenants waits until changes are applied by replica
+func waitForTenants(t *testing.T, replica addressesStorage, address string, expected []string) {
+	t.Helper()
+
+	var tenants []string
+	for deadline := time.Now().Add(testTimeout); time.Now().Before(deadline); {
+		tenants = replica.Tenants(address)
+		slices.Sort(tenants)
+		if reflect.DeepEqual(tenants, expected) {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	t.Errorf("tenants of %s are not equal:\nhave: %v\nwant: %v", address, tenants, expected)
+}
+
+func TestLogsMapStorage(t *testing.T) {
+	storage := NewLogsMapStorage(false, testLogger)
+	if err := storage.StoreFilter("tenant1", eth.LogFilter{ID: "filter1"}); err != nil {
+		t.Fatalf("could not store filter: %v", err)
+	}
+
+	log1 := eth.MatchedLog{Log: eth.Log{TransactionHash: "0x01", LogIndex: "0x0"}}
+	log2 := eth.MatchedLog{Log: eth.Log{TransactionHash: "0x01", LogIndex: "0x1"}}
+	removedLog1 := log1
+	removedLog1.Removed = true
+
+	// logs are identified by transaction and index within the block
+	for _, log := range []eth.MatchedLog{log1, log2, log1, removedLog1} {
+		if err := storage.Store("tenant1", "filter1", log); err != nil {
+			t.Fatalf("could not store log: %v", err)
+		}
+	}
+
+	logs, err := storage.Get("tenant1", "filter1")
+	if err != nil {
+		t.Fatalf("could not get logs: %v", err)
+	}
+	if expected := []eth.MatchedLog{removedLog1, log2}; !reflect.DeepEqual(logs, expected) {
+		t.Errorf("logs are not equal:\nhave: %+v\nwant: %+v", logs, expected)
+	}
+}
@@ -2,11 +2,18 @@ package storages
 
 import (
 	"fmt"
+	"log/slog"
 	"sync"
 
 	"eth-parser/eth"
 )
 
+const (
+	BackendMemory   = "memory"
+	BackendPostgres = "postgres"
+	BackendRedis    = "redis"
+)
+
 const (
 	initialStorageCap                = 1024
 	initialTransactionsPerAddressCap = 1024
@@ -17,17 +24,32 @@ var (
 	ErrInternal      = fmt.Errorf("internal error")
 )
 
+// subscriptionKey identifies address subscribed by tenant
+type subscriptionKey struct {
+	tenant  string
+	address string
+}
+
+// TransactionsMapStorage keeps transactions of subscriptions in memory, they
+// are identified by hashes, so that transaction stored again replaces the
+// stored one in place
 type TransactionsMapStorage struct {
-	storage       map[string][]eth.Transaction
+	storage map[subscriptionKey][]eth.Transaction
+	// indexes maps hashes of stored transactions to their positions
+	indexes       map[subscriptionKey]map[string]int
 	storageMu     sync.RWMutex
 	resetAfterGet bool
+
+	logger *slog.Logger
 }
 
-func NewTransactionsMapStorage(resetAfterGet bool) *TransactionsMapStorage {
+func NewTransactionsMapStorage(resetAfterGet bool, logger *slog.Logger) *TransactionsMapStorage {
 	return &TransactionsMapStorage{
-		storage:       make(map[string][]eth.Transaction, initialStorageCap),
+		storage:       make(map[subscriptionKey][]eth.Transaction, initialStorageCap),
+		indexes:       make(map[subscriptionKey]map[string]int, initialStorageCap),
 		storageMu:     sync.RWMutex{},
 		resetAfterGet: resetAfterGet,
+		logger:        logger.With("component", "transactions_map_storage"),
 	}
 }
 
@@ -39,29 +61,51 @@ func (m *TransactionsMapStorage) Shutdown() error {
 	return nil
 }
 
-func (m *TransactionsMapStorage) Store(address string, transaction eth.Transaction) error {
+func (m *TransactionsMapStorage) Store(
+	tenant, address string,
+	transaction eth.Transaction,
+) error {
 	if m == nil {
 		return ErrUninitialized
 	}
 
+	key := subscriptionKey{tenant: tenant, address: address}
+
 	m.storageMu.Lock()
 	defer m.storageMu.Unlock()
 
-	if _, ok := m.storage[address]; !ok {
-		m.storage[address] = make([]eth.Transaction, 0, initialTransactionsPerAddressCap)
+	if _, ok := m.storage[key]; !ok {
+		m.storage[key] = make([]eth.Transaction, 0, initialTransactionsPerAddressCap)
+		m.indexes[key] = make(map[string]int, initialTransactionsPerAddressCap)
+	}
+
+	if i, ok := m.indexes[key][transaction.Hash]; ok {
+		m.storage[key][i] = transaction
+
+		m.logger.Debug("replaced transaction", "tenant", tenant, "address", address,
+			"tx_hash", transaction.Hash, "status", transaction.Status)
+		return nil
 	}
 
-	m.storage[address] = append(m.storage[address], transaction)
+	m.indexes[key][transaction.Hash] = len(m.storage[key])
+	m.storage[key] = append(m.storage[key], transaction)
+
+	m.logger.Debug("stored transaction",
+		"tenant", tenant, "address", address, "tx_hash", transaction.Hash)
 	return nil
 }
 
-func (m *TransactionsMapStorage) Get(address string) ([]eth.Transaction, error) {
+func (m *TransactionsMapStorage) Get(tenant, address string) ([]eth.Transaction, error) {
 	if m == nil {
 		return nil, ErrUninitialized
 	}
 
+	key := subscriptionKey{tenant: tenant, address: address}
+
+	// stored transactions are replaced in place, so that they are copied to be
+	// read without lock
 	m.storageMu.RLock()
-	result := m.storage[address]
+	result := append([]eth.Transaction(nil), m.storage[key]...)
 	m.storageMu.RUnlock()
 
 	if m.resetAfterGet {
@@ -69,9 +113,37 @@ func (m *TransactionsMapStorage) Get(address string) ([]eth.Transaction, error)
 		// delete operation here, than to prevent any kind of race with single
 		// lock for the whole function.
 		m.storageMu.Lock()
-		delete(m.storage, address)
+		delete(m.storage, key)
+		delete(m.indexes, key)
 		m.storageMu.Unlock()
+
+		m.logger.Debug("reset transactions after get",
+			"tenant", tenant, "address", address, "transactions", len(result))
 	}
 
 	return result, nil
 }
+
+// GetPage returns copy of the page of transactions, so that they could be
+// read without lock, transactions are not reset after it
+func (m *TransactionsMapStorage) GetPage(
+	tenant, address string,
+	offset, limit int,
+) ([]eth.Transaction, error) {
+	if m == nil {
+		return nil, ErrUninitialized
+	}
+
+	key := subscriptionKey{tenant: tenant, address: address}
+
+	m.storageMu.RLock()
+	defer m.storageMu.RUnlock()
+
+	transactions := m.storage[key]
+	if offset >= len(transactions) {
+		return nil, nil
+	}
+	page := transactions[offset:min(offset+limit, len(transactions))]
+
+	return append([]eth.Transaction(nil), page...), nil
+}
@@ -0,0 +1,209 @@
+package storages
+
+import (
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+
+	"eth-parser/eth"
+)
+
+// upsertTransactionQuery stores transaction keeping its position, so that
+// replacing transaction does not change the order of stored ones
+const upsertTransactionQuery = `INSERT INTO transactions
+	(chain_id, tenant, address, hash, block_number, block_time, status, data)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+	ON CONFLICT (chain_id, tenant, address, hash) DO UPDATE SET
+		block_number = EXCLUDED.block_number,
+		block_time = EXCLUDED.block_time,
+		status = EXCLUDED.status,
+		data = EXCLUDED.data,
+		updated_at = now()`
+
+const upsertCheckpointQuery = `INSERT INTO checkpoints (chain_id, block_number)
+	VALUES ($1, $2)
+	ON CONFLICT (chain_id) DO UPDATE SET
+		block_number = EXCLUDED.block_number,
+		updated_at = now()`
+
+// TransactionsPostgresStorage keeps transactions of the chain in PostgreSQL.
+// Transactions are upserted by tenant, address and hash, so that blocks
+// processed again do not duplicate them, and transactions of a block are
+// stored in one database transaction with the checkpoint of the chain.
+type TransactionsPostgresStorage struct {
+	db      *PostgresDB
+	chainID uint64
+
+	logger *slog.Logger
+}
+
+func NewTransactionsPostgresStorage(
+	db *PostgresDB,
+	chainID uint64,
+	logger *slog.Logger,
+) *TransactionsPostgresStorage {
+	return &TransactionsPostgresStorage{
+		db:      db,
+		chainID: chainID,
+		logger:  logger.With("component", "transactions_postgres_storage"),
+	}
+}
+
+// Init checks that the database is initialized, it is initialized by caller
+// as it is shared by storages of all chains
+func (s *TransactionsPostgresStorage) Init() error {
+	if s == nil || s.db == nil || s.db.pool == nil {
+		return ErrUninitialized
+	}
+
+	return nil
+}
+
+func (s *TransactionsPostgresStorage) Shutdown() error {
+	return nil
+}
+
+func (s *TransactionsPostgresStorage) Store(
+	tenant, address string,
+	transaction eth.Transaction,
+) error {
+	if s == nil || s.db == nil || s.db.pool == nil {
+		return ErrUninitialized
+	}
+
+	args, err := s.transactionArgs(tenant, address, &transaction)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := s.db.context()
+	defer cancel()
+
+	if _, err := s.db.pool.Exec(ctx, upsertTransactionQuery, args...); err != nil {
+		return fmt.Errorf("could not store transaction: %w", err)
+	}
+
+	s.logger.Debug("stored transaction",
+		"tenant", tenant, "address", address, "tx_hash", transaction.Hash)
+	return nil
+}
+
+// StoreBlock stores transactions and the checkpoint of the chain in one
+// database transaction, queries are sent in one batch
+func (s *TransactionsPostgresStorage) StoreBlock(
+	blockNumber int64,
+	transactions []eth.SubscribedTransaction,
+) error {
+	if s == nil || s.db == nil || s.db.pool == nil {
+		return ErrUninitialized
+	}
+
+	batch := &pgx.Batch{}
+	for i := range transactions {
+		stored := &transactions[i]
+		args, err := s.transactionArgs(stored.Tenant, stored.Address, &stored.Transaction)
+		if err != nil {
+			return err
+		}
+		batch.Queue(upsertTransactionQuery, args...)
+	}
+	batch.Queue(upsertCheckpointQuery, int64(s.chainID), blockNumber)
+
+	ctx, cancel := s.db.context()
+	defer cancel()
+
+	err := pgx.BeginFunc(ctx, s.db.pool, func(tx pgx.Tx) error {
+		return tx.SendBatch(ctx, batch).Close()
+	})
+	if err != nil {
+		return fmt.Errorf("could not store block: %w", err)
+	}
+
+	s.logger.Debug("stored block", "block", blockNumber, "transactions", len(transactions))
+	return nil
+}
+
+func (s *TransactionsPostgresStorage) Get(tenant, address string) ([]eth.Transaction, error) {
+	if s == nil || s.db == nil || s.db.pool == nil {
+		return nil, ErrUninitialized
+	}
+
+	ctx, cancel := s.db.context()
+	defer cancel()
+
+	rows, err := s.db.pool.Query(ctx, `SELECT data FROM transactions
+		WHERE chain_id = $1 AND tenant = $2 AND address = $3
+		ORDER BY id`,
+		int64(s.chainID), tenant, address)
+	if err != nil {
+		return nil, fmt.Errorf("could not get transactions: %w", err)
+	}
+
+	transactions, err := pgx.CollectRows(rows, pgx.RowTo[eth.Transaction])
+	if err != nil {
+		return nil, fmt.Errorf("could not get transactions: %w", err)
+	}
+
+	return transactions, nil
+}
+
+func (s *TransactionsPostgresStorage) GetPage(
+	tenant, address string,
+	offset, limit int,
+) ([]eth.Transaction, error) {
+	if s == nil || s.db == nil || s.db.pool == nil {
+		return nil, ErrUninitialized
+	}
+
+	ctx, cancel := s.db.context()
+	defer cancel()
+
+	rows, err := s.db.pool.Query(ctx, `SELECT data FROM transactions
+		WHERE chain_id = $1 AND tenant = $2 AND address = $3
+		ORDER BY id OFFSET $4 LIMIT $5`,
+		int64(s.chainID), tenant, address, offset, limit)
+	if err != nil {
+		return nil, fmt.Errorf("could not get transactions: %w", err)
+	}
+
+	transactions, err := pgx.CollectRows(rows, pgx.RowTo[eth.Transaction])
+	if err != nil {
+		return nil, fmt.Errorf("could not get transactions: %w", err)
+	}
+
+	return transactions, nil
+}
+
+// transactionArgs returns arguments of upsertTransactionQuery, block number
+// and time are NULL for pending transactions
+func (s *TransactionsPostgresStorage) transactionArgs(
+	tenant, address string,
+	transaction *eth.Transaction,
+) ([]any, error) {
+	var blockNumber *int64
+	if len(transaction.BlockNumber) != 0 {
+		number, err := eth.ParseBigQuantity(transaction.BlockNumber)
+		if err != nil {
+			return nil, fmt.Errorf("invalid block number of %s: %w", transaction.Hash, err)
+		}
+		blockNumber = new(int64)
+		*blockNumber = number.Int64()
+	}
+
+	var blockTime *time.Time
+	if len(transaction.Timestamp) != 0 {
+		timestamp, err := eth.ParseBigQuantity(transaction.Timestamp)
+		if err != nil {
+			return nil, fmt.Errorf("invalid timestamp of %s: %w", transaction.Hash, err)
+		}
+		blockTime = new(time.Time)
+		*blockTime = time.Unix(timestamp.Int64(), 0).UTC()
+	}
+
+	return []any{
+		int64(s.chainID), tenant, address, transaction.Hash,
+		blockNumber, blockTime, transaction.Status, transaction,
+	}, nil
+}
@@ -0,0 +1,194 @@
+package storages
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+
+	"github.com/redis/go-redis/v9"
+
+	"eth-parser/eth"
+)
+
+// storeTransactionScript stores transaction by its hash in the hash of
+// KEYS[1], and appends the hash to the sorted set of KEYS[2] ranked in the
+// order transactions were first stored, so that replaced transaction keeps
+// its position. ARGV are the hash and the transaction.
+var storeTransactionScript = redis.NewScript(`
+if redis.call('HSET', KEYS[1], ARGV[1], ARGV[2]) == 1 then
+	redis.call('ZADD', KEYS[2], redis.call('ZCARD', KEYS[2]), ARGV[1])
+end
+return 0
+`)
+
+// TransactionsRedisStorage keeps transactions of the chain in Redis, so that
+// they are shared by replicas. Transactions of a subscription are kept in a
+// hash by their hashes, which makes storing idempotent, with a sorted set of
+// the hashes keeping their order. Transactions of a block are stored in one
+// MULTI/EXEC transaction with the checkpoint of the chain.
+type TransactionsRedisStorage struct {
+	db      *RedisDB
+	chainID uint64
+
+	logger *slog.Logger
+}
+
+func NewTransactionsRedisStorage(
+	db *RedisDB,
+	chainID uint64,
+	logger *slog.Logger,
+) *TransactionsRedisStorage {
+	return &TransactionsRedisStorage{
+		db:      db,
+		chainID: chainID,
+		logger:  logger.With("component", "transactions_redis_storage"),
+	}
+}
+
+// Init checks that the database is initialized, it is initialized by caller
+// as it is shared by storages of all chains
+func (s *TransactionsRedisStorage) Init() error {
+	if s == nil || s.db == nil || s.db.client == nil {
+		return ErrUninitialized
+	}
+
+	return nil
+}
+
+func (s *TransactionsRedisStorage) Shutdown() error {
+	return nil
+}
+
+func (s *TransactionsRedisStorage) Store(
+	tenant, address string,
+	transaction eth.Transaction,
+) error {
+	if s == nil || s.db == nil || s.db.client == nil {
+		return ErrUninitialized
+	}
+
+	ctx, cancel := s.db.context()
+	defer cancel()
+
+	if err := s.store(ctx, s.db.client, tenant, address, &transaction); err != nil {
+		return fmt.Errorf("could not store transaction: %w", err)
+	}
+
+	s.logger.Debug("stored transaction",
+		"tenant", tenant, "address", address, "tx_hash", transaction.Hash)
+	return nil
+}
+
+func (s *TransactionsRedisStorage) StoreBlock(
+	blockNumber int64,
+	transactions []eth.SubscribedTransaction,
+) error {
+	if s == nil || s.db == nil || s.db.client == nil {
+		return ErrUninitialized
+	}
+
+	ctx, cancel := s.db.context()
+	defer cancel()
+
+	_, err := s.db.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		for i := range transactions {
+			stored := &transactions[i]
+			err := s.store(ctx, pipe, stored.Tenant, stored.Address, &stored.Transaction)
+			if err != nil {
+				return err
+			}
+		}
+
+		pipe.Set(ctx, s.db.key(s.chainID, "checkpoint"), blockNumber, 0)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("could not store block: %w", err)
+	}
+
+	s.logger.Debug("stored block", "block", blockNumber, "transactions", len(transactions))
+	return nil
+}
+
+func (s *TransactionsRedisStorage) Get(tenant, address string) ([]eth.Transaction, error) {
+	return s.GetPage(tenant, address, 0, -1)
+}
+
+// GetPage returns transactions in their order, all transactions starting from
+// offset are returned if limit is negative
+func (s *TransactionsRedisStorage) GetPage(
+	tenant, address string,
+	offset, limit int,
+) ([]eth.Transaction, error) {
+	if s == nil || s.db == nil || s.db.client == nil {
+		return nil, ErrUninitialized
+	}
+
+	ctx, cancel := s.db.context()
+	defer cancel()
+
+	stop := int64(-1)
+	if limit >= 0 {
+		stop = int64(offset + limit - 1)
+	}
+
+	hashes, err := s.db.client.ZRange(ctx,
+		s.db.key(s.chainID, "order", tenant, address), int64(offset), stop).Result()
+	if err != nil {
+		return nil, fmt.Errorf("could not get transactions: %w", err)
+	}
+	if len(hashes) == 0 {
+		return nil, nil
+	}
+
+	values, err := s.db.client.HMGet(ctx,
+		s.db.key(s.chainID, "transactions", tenant, address), hashes...).Result()
+	if err != nil {
+		return nil, fmt.Errorf("could not get transactions: %w", err)
+	}
+
+	transactions := make([]eth.Transaction, 0, len(values))
+	for i, value := range values {
+		data, ok := value.(string)
+		if !ok {
+			// transactions are stored with their order by the script, so
+			// that they are never missing
+			return nil, fmt.Errorf("%w: transaction %s is missing", ErrInternal, hashes[i])
+		}
+
+		var transaction eth.Transaction
+		if err := json.Unmarshal([]byte(data), &transaction); err != nil {
+			return nil, fmt.Errorf("could not unmarshal transaction: %w", err)
+		}
+		transactions = append(transactions, transaction)
+	}
+
+	return transactions, nil
+}
+
+// store runs storeTransactionScript with client or pipeline
+func (s *TransactionsRedisStorage) store(
+	ctx context.Context,
+	scripter redis.Scripter,
+	tenant, address string,
+	transaction *eth.Transaction,
+) error {
+	data, err := json.Marshal(transaction)
+	if err != nil {
+		return fmt.Errorf("could not marshal transaction: %w", err)
+	}
+
+	keys := []string{
+		s.db.key(s.chainID, "transactions", tenant, address),
+		s.db.key(s.chainID, "order", tenant, address),
+	}
+
+	// pipelined script is sent with its body, as pipelines can not fall back
+	// to it if the script is not loaded
+	if _, ok := scripter.(redis.Pipeliner); ok {
+		return storeTransactionScript.Eval(ctx, scripter, keys, transaction.Hash, data).Err()
+	}
+
+	return storeTransactionScript.Run(ctx, scripter, keys, transaction.Hash, data).Err()
+}
@@ -0,0 +1,559 @@
+// Package testutil provides an in-process Ethereum JSON-RPC node serving a
+// scripted chain, it is used to test the poller and the whole pipeline
+// without network. Kafka broker and NATS server stand-ins test sinks the
+// same way.
+package testutil
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"eth-parser/eth"
+	"eth-parser/jsonrpc"
+)
+
+const (
+	// GenesisTime is unix time of the genesis block, the next blocks are
+	// mined every BlockInterval seconds after it
+	GenesisTime   = 1700000000
+	BlockInterval = 12
+)
+
+// FaultKind is the kind of failure injected into responses of the node
+type FaultKind int
+
+const (
+	// FaultStatus responds with HTTP status Status
+	FaultStatus FaultKind = iota
+	// FaultDelay responds after Delay, it times out requests of clients with
+	// shorter timeout
+	FaultDelay
+	// FaultReset resets the connection without response
+	FaultReset
+	// FaultRateLimit responds with 429 Too Many Requests
+	FaultRateLimit
+	// FaultRPCError responds with JSON-RPC error Code
+	FaultRPCError
+)
+
+// Fault is a failure injected into the next requests of the method
+type Fault struct {
+	// Method is the method of requests to fail, requests of any method fail
+	// if empty. Batch requests fail if any of their packets matches.
+	Method string
+	Kind   FaultKind
+
+	Status int
+	Delay  time.Duration
+	Code   int
+	// RetryAfter is Retry-After header of FaultStatus and FaultRateLimit
+	// responses, it is not set if empty
+	RetryAfter string
+
+	// Times is the number of requests to fail, a single one if zero
+	Times int
+}
+
+// EthNode serves a single chain which is extended with Mine and rewritten
+// with Reorg. Block numbers and hashes are generated by the node, hashes of
+// blocks mined after a reorg differ from the hashes of the replaced ones.
+type EthNode struct {
+	chainID uint64
+	server  *httptest.Server
+
+	// blocks is the canonical chain, the block number is its index
+	blocks []*eth.Block
+	// fork is incremented on reorg to generate new hashes
+	fork int
+	// missing are numbers of mined blocks which are reported as not found
+	missing  map[int64]struct{}
+	receipts map[string]*eth.Receipt
+	// logs maps block hash to logs of the block
+	logs     map[string][]eth.Log
+	balances map[eth.Holding]*big.Int
+	// reverting are contracts whose calls revert
+	reverting map[string]struct{}
+
+	pending []eth.Transaction
+	// pendingFilters map IDs of pending transactions filters to the number
+	// of pending transactions already returned by the filter
+	pendingFilters map[string]int
+
+	faults []*Fault
+	calls  map[string]int
+	mu     sync.Mutex
+}
+
+// NewEthNode starts the node with genesis block, it must be closed with Close
+func NewEthNode(chainID uint64) *EthNode {
+	n := &EthNode{
+		chainID:        chainID,
+		missing:        make(map[int64]struct{}),
+		receipts:       make(map[string]*eth.Receipt),
+		logs:           make(map[string][]eth.Log),
+		balances:       make(map[eth.Holding]*big.Int),
+		reverting:      make(map[string]struct{}),
+		pendingFilters: make(map[string]int),
+		calls:          make(map[string]int),
+	}
+	n.Mine()
+
+	n.server = httptest.NewServer(http.HandlerFunc(n.serveHTTP))
+	return n
+}
+
+// URL returns the endpoint of the node
+func (n *EthNode) URL() string {
+	return n.server.URL
+}
+
+func (n *EthNode) Close() {
+	n.server.CloseClientConnections()
+	n.server.Close()
+}
+
+// Mine appends a block with the transactions to the chain and returns it,
+// transactions without hashes get generated ones
+func (n *EthNode) Mine(transactions ...eth.Transaction) *eth.Block {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	number := int64(len(n.blocks))
+	var parentHash string
+	if number > 0 {
+		parentHash = n.blocks[number-1].Hash
+	}
+	block := &eth.Block{
+		Number:       quantity(number),
+		Hash:         fmt.Sprintf("0x%056x%08x", n.fork, number),
+		ParentHash:   parentHash,
+		Timestamp:    quantity(GenesisTime + number*BlockInterval),
+		Transactions: make([]eth.Transaction, len(transactions)),
+	}
+	for i, transaction := range transactions {
+		if len(transaction.Hash) == 0 {
+			transaction.Hash = fmt.Sprintf("0x%048x%08x%08x", n.fork, number, i)
+		}
+		block.Transactions[i] = transaction
+	}
+
+	n.blocks = append(n.blocks, block)
+	return block
+}
+
+// Reorg removes depth latest blocks from the chain, blocks mined after it
+// replace them with new hashes
+func (n *EthNode) Reorg(depth int) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	depth = min(depth, len(n.blocks)-1)
+	for _, block := range n.blocks[len(n.blocks)-depth:] {
+		delete(n.logs, block.Hash)
+	}
+	n.blocks = n.blocks[:len(n.blocks)-depth]
+	n.fork++
+}
+
+// SetMissing makes mined block with the number not found until it is unset,
+// as nodes behind a load balancer may lag behind each other
+func (n *EthNode) SetMissing(number int64, missing bool) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if missing {
+		n.missing[number] = struct{}{}
+	} else {
+		delete(n.missing, number)
+	}
+}
+
+func (n *EthNode) SetReceipt(receipt *eth.Receipt) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	n.receipts[receipt.TransactionHash] = receipt
+}
+
+// AddLog adds log to the block with its BlockHash
+func (n *EthNode) AddLog(log eth.Log) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	n.logs[log.BlockHash] = append(n.logs[log.BlockHash], log)
+}
+
+// SetBalance sets balance of the holding at all blocks
+func (n *EthNode) SetBalance(holding eth.Holding, balance *big.Int) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	n.balances[holding] = balance
+}
+
+// SetReverting makes calls to the contract revert, e.g. balanceOf calls to
+// contract which is not ERC-20 token
+func (n *EthNode) SetReverting(contract string) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	n.reverting[contract] = struct{}{}
+}
+
+// AddPending adds transaction to the pool of pending transactions, it is
+// returned by all pending transactions filters
+func (n *EthNode) AddPending(transaction eth.Transaction) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	n.pending = append(n.pending, transaction)
+}
+
+// Inject injects fault into the next requests
+func (n *EthNode) Inject(fault Fault) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if fault.Times == 0 {
+		fault.Times = 1
+	}
+	n.faults = append(n.faults, &fault)
+}
+
+// Calls returns the number of received requests of the method including the
+// failed ones, every packet of batch requests is counted
+func (n *EthNode) Calls(method string) int {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	return n.calls[method]
+}
+
+func (n *EthNode) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	var raw json.RawMessage
+	if err := json.NewDecoder(r.Body).Decode(&raw); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	batch := strings.HasPrefix(strings.TrimSpace(string(raw)), "[")
+	var packets []*jsonrpc.Packet
+	if batch {
+		if err := json.Unmarshal(raw, &packets); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	} else {
+		packet := &jsonrpc.Packet{}
+		if err := json.Unmarshal(raw, packet); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		packets = append(packets, packet)
+	}
+
+	if fault := n.takeFault(packets); fault != nil {
+		if n.fail(w, fault, packets) {
+			return
+		}
+	}
+
+	responses := make([]*jsonrpc.Packet, len(packets))
+	for i, packet := range packets {
+		responses[i] = n.handle(packet)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if batch {
+		json.NewEncoder(w).Encode(responses)
+		return
+	}
+	json.NewEncoder(w).Encode(responses[0])
+}
+
+// takeFault counts calls of the packets and returns the first fault matching
+// any of them
+func (n *EthNode) takeFault(packets []*jsonrpc.Packet) *Fault {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	for _, packet := range packets {
+		n.calls[packet.Method]++
+	}
+
+	for i, fault := range n.faults {
+		for _, packet := range packets {
+			if len(fault.Method) != 0 && fault.Method != packet.Method {
+				continue
+			}
+
+			fault.Times--
+			if fault.Times == 0 {
+				n.faults = append(n.faults[:i], n.faults[i+1:]...)
+			}
+			return fault
+		}
+	}
+
+	return nil
+}
+
+// fail writes the failure of the fault, it returns false if the request
+// should be served after it
+func (n *EthNode) fail(w http.ResponseWriter, fault *Fault, packets []*jsonrpc.Packet) bool {
+	if len(fault.RetryAfter) != 0 {
+		w.Header().Set("Retry-After", fault.RetryAfter)
+	}
+
+	switch fault.Kind {
+	case FaultStatus:
+		http.Error(w, http.StatusText(fault.Status), fault.Status)
+	case FaultDelay:
+		time.Sleep(fault.Delay)
+		return false
+	case FaultReset:
+		conn, _, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			panic(err)
+		}
+		// closing with zero linger sends RST instead of FIN
+		if tcpConn, ok := conn.(*net.TCPConn); ok {
+			tcpConn.SetLinger(0)
+		}
+		conn.Close()
+	case FaultRateLimit:
+		http.Error(w, http.StatusText(http.StatusTooManyRequests), http.StatusTooManyRequests)
+	case FaultRPCError:
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(&jsonrpc.Packet{
+			JSONRPC: jsonrpc.Version,
+			ID:      packets[0].ID,
+			Error:   &jsonrpc.Error{Code: fault.Code, Message: "injected error"},
+		})
+	}
+
+	return true
+}
+
+func (n *EthNode) handle(packet *jsonrpc.Packet) *jsonrpc.Packet {
+	response := &jsonrpc.Packet{JSONRPC: jsonrpc.Version, ID: packet.ID}
+
+	var params []json.RawMessage
+	if packet.Params != nil {
+		data, _ := json.Marshal(packet.Params)
+		if err := json.Unmarshal(data, &params); err != nil {
+			response.Error = &jsonrpc.Error{Code: jsonrpc.CodeInvalidParams, Message: err.Error()}
+			return response
+		}
+	}
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	result, err := n.call(packet.Method, params)
+	if err != nil {
+		response.Error = err
+		return response
+	}
+
+	// nil result is encoded as null unlike omitted nil interface
+	response.Result = json.RawMessage("null")
+	if result != nil {
+		response.Result = result
+	}
+
+	return response
+}
+
+func (n *EthNode) call(method string, params []json.RawMessage) (interface{}, *jsonrpc.Error) {
+	var args []interface{}
+	for _, param := range params {
+		var arg interface{}
+		json.Unmarshal(param, &arg)
+		args = append(args, arg)
+	}
+	stringArg := func(i int) string {
+		if i >= len(args) {
+			return ""
+		}
+		s, _ := args[i].(string)
+		return s
+	}
+
+	switch method {
+	case "eth_chainId":
+		return quantity(int64(n.chainID)), nil
+	case "eth_blockNumber":
+		return quantity(int64(len(n.blocks) - 1)), nil
+	case "eth_getBlockByNumber":
+		return n.blockByNumber(stringArg(0)), nil
+	case "eth_getTransactionReceipt":
+		if receipt, ok := n.receipts[stringArg(0)]; ok {
+			return receipt, nil
+		}
+		return nil, nil
+	case "eth_getLogs":
+		var filter logsFilter
+		if len(params) != 0 {
+			json.Unmarshal(params[0], &filter)
+		}
+		return n.filterLogs(&filter), nil
+	case "eth_getBalance":
+		return n.balance(eth.Holding{Address: strings.ToLower(stringArg(0))}), nil
+	case "eth_call":
+		call, _ := args[0].(map[string]interface{})
+		to, _ := call["to"].(string)
+		data, _ := call["data"].(string)
+		_, reverting := n.reverting[to]
+		if reverting || !strings.HasPrefix(data, "0x70a08231") || len(data) < 10+64 {
+			return nil, &jsonrpc.Error{
+				Code: jsonrpc.CodeExecutionReverted, Message: "execution reverted",
+			}
+		}
+		holder := "0x" + data[len(data)-40:]
+		return fmt.Sprintf("0x%064x", n.balance(eth.Holding{Address: holder, Token: to}).ToInt()), nil
+	case "eth_newPendingTransactionFilter":
+		id := quantity(int64(len(n.pendingFilters) + 1))
+		n.pendingFilters[id] = len(n.pending)
+		return id, nil
+	case "eth_getFilterChanges":
+		returned, ok := n.pendingFilters[stringArg(0)]
+		if !ok {
+			return nil, &jsonrpc.Error{Code: jsonrpc.CodeInvalidParams, Message: "filter not found"}
+		}
+		hashes := []string{}
+		for _, transaction := range n.pending[returned:] {
+			hashes = append(hashes, transaction.Hash)
+		}
+		n.pendingFilters[stringArg(0)] = len(n.pending)
+		return hashes, nil
+	case "eth_getTransactionByHash":
+		return n.transactionByHash(stringArg(0)), nil
+	}
+
+	return nil, &jsonrpc.Error{
+		Code:    jsonrpc.CodeMethodNotFound,
+		Message: fmt.Sprintf("method %s not found", method),
+	}
+}
+
+func (n *EthNode) blockByNumber(rawNumber string) *eth.Block {
+	number := int64(len(n.blocks) - 1)
+	if rawNumber != "latest" {
+		parsed, err := strconv.ParseInt(strings.TrimPrefix(rawNumber, "0x"), 16, 64)
+		if err != nil {
+			return nil
+		}
+		number = parsed
+	}
+
+	if number < 0 || number >= int64(len(n.blocks)) {
+		return nil
+	}
+	if _, ok := n.missing[number]; ok {
+		return nil
+	}
+
+	return n.blocks[number]
+}
+
+// logsFilter is the filter of eth_getLogs, only filters by block hash are
+// supported
+type logsFilter struct {
+	BlockHash string     `json:"blockHash"`
+	Address   []string   `json:"address"`
+	Topics    [][]string `json:"topics"`
+}
+
+func (n *EthNode) filterLogs(filter *logsFilter) []eth.Log {
+	logs := []eth.Log{}
+	for _, log := range n.logs[filter.BlockHash] {
+		if len(filter.Address) != 0 && !contains(filter.Address, log.Address) {
+			continue
+		}
+
+		matched := true
+		for i, alternatives := range filter.Topics {
+			if len(alternatives) == 0 {
+				continue
+			}
+			if i >= len(log.Topics) || !contains(alternatives, log.Topics[i]) {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			logs = append(logs, log)
+		}
+	}
+
+	return logs
+}
+
+func (n *EthNode) balance(holding eth.Holding) *hexBig {
+	balance, ok := n.balances[holding]
+	if !ok {
+		return (*hexBig)(new(big.Int))
+	}
+
+	return (*hexBig)(balance)
+}
+
+// transactionByHash returns pending or mined transaction, mined one has
+// block number
+func (n *EthNode) transactionByHash(hash string) interface{} {
+	for _, block := range n.blocks {
+		for _, transaction := range block.Transactions {
+			if transaction.Hash == hash {
+				return struct {
+					eth.Transaction
+					BlockNumber string `json:"blockNumber"`
+				}{transaction, block.Number}
+			}
+		}
+	}
+
+	for _, transaction := range n.pending {
+		if transaction.Hash == hash {
+			return struct {
+				eth.Transaction
+				BlockNumber *string `json:"blockNumber"`
+			}{transaction, nil}
+		}
+	}
+
+	return nil
+}
+
+// hexBig is encoded as hex quantity
+type hexBig big.Int
+
+func (b *hexBig) ToInt() *big.Int {
+	return (*big.Int)(b)
+}
+
+func (b *hexBig) MarshalJSON() ([]byte, error) {
+	return json.Marshal("0x" + b.ToInt().Text(16))
+}
+
+func quantity(number int64) string {
+	return "0x" + strconv.FormatInt(number, 16)
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if strings.EqualFold(v, value) {
+			return true
+		}
+	}
+
+	return false
+}